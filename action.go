@@ -13,19 +13,32 @@
 package delta
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/iancoleman/strcase"
 	"golang.org/x/exp/constraints"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	ErrorActionJSONFormat error = errors.New("invalid format for action JSON")
+	ErrorInvalidSchema    error = errors.New("invalid table schema")
 )
 
 // Delta log action that describes a parquet data file that is part of the table.
 type Action interface {
-	// Add | CommitInfo
+	// Add | Remove | MetaData | Protocol | Txn | CommitInfo | Cdc | DomainMetadata | Sidecar | UnknownAction
 }
 
 type CommitInfo map[string]interface{}
@@ -67,24 +80,17 @@ type Add struct {
 	DataChange bool `json:"dataChange"`
 	// Contains statistics (e.g., count, min/max values for columns) about the data in this file
 	Stats string `json:"stats"`
-	// Contains statistics (e.g., count, min/max values for columns) about the data in this file in
-	// raw parquet format. This field needs to be written when statistics are available and the
-	// table property: delta.checkpoint.writeStatsAsStruct is set to true.
-	//
-	// // This field is only available in add action records read from checkpoints
-	// #[cfg(feature = "parquet")]
-	// #[serde(skip_serializing, skip_deserializing)]
-	// pub stats_parsed: Option<parquet::record::Row>,
-	// // Contains statistics (e.g., count, min/max values for columns) about the data in this file in
-	// // raw parquet format. This field needs to be written when statistics are available and the
-	// // table property: delta.checkpoint.writeStatsAsStruct is set to true.
-	// //
-	// // This field is only available in add action records read from checkpoints
-	// #[cfg(feature = "parquet2")]
-	// #[serde(skip_serializing, skip_deserializing)]
-	// pub stats_parsed: Option<String>,
+	// Statistics read from the stats_parsed column of a checkpoint, in raw parquet format.
+	// When present, ParseStats returns them instead of parsing Stats.
+	statsParsed *Stats `json:"-"`
 	// Map containing metadata about this file
 	Tags map[string]string `json:"tags,omitempty"`
+	// Row id of the first row in the file, only set when the table has the rowTracking feature
+	BaseRowId *int64 `json:"baseRowId,omitempty"`
+	// First commit version in which a row of the file was added or moved, only set when the table has the rowTracking feature
+	DefaultRowCommitVersion *int64 `json:"defaultRowCommitVersion,omitempty"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // / Represents a tombstone (deleted file) in the Delta log.
@@ -108,6 +114,34 @@ type Remove struct {
 	Size DeltaDataTypeLong `json:"size"`
 	/// Map containing metadata about this file
 	Tags map[string]string `json:"tags"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
+}
+
+// / Represents a change data file (CDC) written for a commit when change data feed is enabled.
+// / This is a top-level action in Delta log entries.
+type Cdc struct {
+	/// A relative path, from the root of the table, to the change data file
+	Path string `json:"path"`
+	/// A map from partition column to value for this file
+	PartitionValues map[string]string `json:"partitionValues"`
+	/// The size of this file in bytes
+	Size DeltaDataTypeLong `json:"size"`
+	/// Should always be false for cdc actions because they never change the data of the table
+	DataChange bool `json:"dataChange"`
+	/// Map containing metadata about this file
+	Tags map[string]string `json:"tags,omitempty"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
+}
+
+// UnknownAction holds a log entry whose action type is not modeled by delta-go.
+// The raw JSON is kept so that the action is written back unchanged.
+type UnknownAction struct {
+	/// The key of the log entry, e.g. "checkpointMetadata"
+	Name string
+	/// The unparsed action
+	Data json.RawMessage
 }
 
 // Describes the data format of files in the table.
@@ -149,6 +183,8 @@ type MetaData struct {
 	CreatedTime int64 `json:"createdTime"`
 	/// A map containing configuration options for the table
 	Configuration map[string]string `json:"configuration"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // MetaData.ToDeltaTableMetaData() converts a MetaData to DeltaTableMetaData
@@ -170,17 +206,14 @@ func (md *MetaData) ToDeltaTableMetaData() (DeltaTableMetaData, error) {
 
 func logEntryFromAction(action Action) ([]byte, error) {
 	var log []byte
-	m := make(map[string]any)
 
 	var err error
 	switch action.(type) {
 	//TODO: Add errors for missing or null values that are not allowed by the delta protocol
 	//https://github.com/delta-io/delta/blob/master/PROTOCOL.md#actions
-	case Add, Remove, CommitInfo, MetaData, Protocol:
+	case Add, Remove, CommitInfo, MetaData, Protocol, Txn, Cdc, DomainMetadata, Sidecar, UnknownAction:
 		// wrap the action data in a camelCase of the action type
-		key := strcase.ToLowerCamel(reflect.TypeOf(action).Name())
-		m[key] = action
-		log, err = json.Marshal(m)
+		log, err = json.Marshal(LogEntry{Action: action})
 	default:
 		log, err = json.Marshal(action)
 	}
@@ -205,6 +238,315 @@ func LogEntryFromActions(actions []Action) ([]byte, error) {
 	return bytes.Join(jsons, []byte("\n")), nil
 }
 
+// LogEntry is the JSON envelope of an action in the log: an object with a single key naming
+// the action type, e.g. {"add": {...}}
+type LogEntry struct {
+	Action Action
+}
+
+func (entry LogEntry) MarshalJSON() ([]byte, error) {
+	switch action := entry.Action.(type) {
+	case UnknownAction:
+		return json.Marshal(map[string]json.RawMessage{action.Name: action.Data})
+	case Add, Remove, CommitInfo, MetaData, Protocol, Txn, Cdc, DomainMetadata, Sidecar:
+		key := strcase.ToLowerCamel(reflect.TypeOf(action).Name())
+		return json.Marshal(map[string]any{key: action})
+	default:
+		return nil, errors.Join(ErrorActionJSONFormat, fmt.Errorf("unsupported action type %T", action))
+	}
+}
+
+func (entry *LogEntry) UnmarshalJSON(data []byte) error {
+	var unstructuredResult map[string]json.RawMessage
+	if err := json.Unmarshal(data, &unstructuredResult); err != nil {
+		return errors.Join(ErrorActionJSONFormat, err)
+	}
+	action, err := actionFromLogEntry(unstructuredResult)
+	if err != nil {
+		return err
+	}
+	entry.Action = action
+	return nil
+}
+
+// jsonFieldNames caches the JSON keys declared by each action struct type
+var jsonFieldNames sync.Map
+
+// declaredJSONFields returns the JSON keys that are declared by the fields of struct type t
+func declaredJSONFields(t reflect.Type) map[string]bool {
+	if names, ok := jsonFieldNames.Load(t); ok {
+		return names.(map[string]bool)
+	}
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	jsonFieldNames.Store(t, names)
+	return names
+}
+
+// unmarshalWithExtras decodes data into v, a pointer to an action struct without custom JSON methods,
+// and returns the fields of data that are not declared by v.
+// Actions keep these fields in their Extras, and marshalWithExtras re-emits them unchanged, so that the fields
+// written by newer Delta writers survive when delta-go writes an action it has read to a new commit.
+func unmarshalWithExtras(data []byte, v any) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	declared := declaredJSONFields(reflect.TypeOf(v).Elem())
+	for name := range fields {
+		if declared[name] {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// marshalWithExtras encodes v, an action struct without custom JSON methods, and appends the extra fields
+func marshalWithExtras(v any, extras map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extras) == 0 {
+		return data, err
+	}
+	extraData, err := json.Marshal(extras)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= 2 {
+		return extraData, nil
+	}
+	// Splice the extra fields into the object after the declared fields to keep their order stable
+	result := make([]byte, 0, len(data)+len(extraData))
+	result = append(result, data[:len(data)-1]...)
+	result = append(result, ',')
+	result = append(result, extraData[1:]...)
+	return result, nil
+}
+
+func (add Add) MarshalJSON() ([]byte, error) {
+	type action Add
+	return marshalWithExtras(action(add), add.Extras)
+}
+
+func (add *Add) UnmarshalJSON(data []byte) error {
+	type action Add
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*add = Add(a)
+	add.Extras = extras
+	return err
+}
+
+func (remove Remove) MarshalJSON() ([]byte, error) {
+	type action Remove
+	return marshalWithExtras(action(remove), remove.Extras)
+}
+
+func (remove *Remove) UnmarshalJSON(data []byte) error {
+	type action Remove
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*remove = Remove(a)
+	remove.Extras = extras
+	return err
+}
+
+func (cdc Cdc) MarshalJSON() ([]byte, error) {
+	type action Cdc
+	return marshalWithExtras(action(cdc), cdc.Extras)
+}
+
+func (cdc *Cdc) UnmarshalJSON(data []byte) error {
+	type action Cdc
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*cdc = Cdc(a)
+	cdc.Extras = extras
+	return err
+}
+
+func (metaData MetaData) MarshalJSON() ([]byte, error) {
+	type action MetaData
+	return marshalWithExtras(action(metaData), metaData.Extras)
+}
+
+func (metaData *MetaData) UnmarshalJSON(data []byte) error {
+	type action MetaData
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*metaData = MetaData(a)
+	metaData.Extras = extras
+	return err
+}
+
+func (txn Txn) MarshalJSON() ([]byte, error) {
+	type action Txn
+	return marshalWithExtras(action(txn), txn.Extras)
+}
+
+func (txn *Txn) UnmarshalJSON(data []byte) error {
+	type action Txn
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*txn = Txn(a)
+	txn.Extras = extras
+	return err
+}
+
+func (protocol Protocol) MarshalJSON() ([]byte, error) {
+	type action Protocol
+	return marshalWithExtras(action(protocol), protocol.Extras)
+}
+
+func (protocol *Protocol) UnmarshalJSON(data []byte) error {
+	type action Protocol
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*protocol = Protocol(a)
+	protocol.Extras = extras
+	return err
+}
+
+func (domainMetadata DomainMetadata) MarshalJSON() ([]byte, error) {
+	type action DomainMetadata
+	return marshalWithExtras(action(domainMetadata), domainMetadata.Extras)
+}
+
+func (domainMetadata *DomainMetadata) UnmarshalJSON(data []byte) error {
+	type action DomainMetadata
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*domainMetadata = DomainMetadata(a)
+	domainMetadata.Extras = extras
+	return err
+}
+
+func (sidecar Sidecar) MarshalJSON() ([]byte, error) {
+	type action Sidecar
+	return marshalWithExtras(action(sidecar), sidecar.Extras)
+}
+
+func (sidecar *Sidecar) UnmarshalJSON(data []byte) error {
+	type action Sidecar
+	var a action
+	extras, err := unmarshalWithExtras(data, &a)
+	*sidecar = Sidecar(a)
+	sidecar.Extras = extras
+	return err
+}
+
+// actionFromLogEntry unwraps a single log entry such as {"add": {...}} into its action type.
+// Entries with an action key that delta-go does not model are returned as an UnknownAction.
+func actionFromLogEntry(unstructuredResult map[string]json.RawMessage) (Action, error) {
+	if len(unstructuredResult) != 1 {
+		return nil, errors.Join(ErrorActionJSONFormat, errors.New("log entry must contain exactly one action"))
+	}
+
+	var action Action
+	var err error
+	for key, data := range unstructuredResult {
+		switch key {
+		case "add":
+			add := Add{}
+			err = json.Unmarshal(data, &add)
+			action = add
+		case "remove":
+			remove := Remove{}
+			err = json.Unmarshal(data, &remove)
+			action = remove
+		case "metaData":
+			metaData := MetaData{}
+			err = json.Unmarshal(data, &metaData)
+			action = metaData
+		case "protocol":
+			protocol := Protocol{}
+			err = json.Unmarshal(data, &protocol)
+			action = protocol
+		case "txn":
+			txn := Txn{}
+			err = json.Unmarshal(data, &txn)
+			action = txn
+		case "commitInfo":
+			commitInfo := make(CommitInfo)
+			err = json.Unmarshal(data, &commitInfo)
+			action = commitInfo
+		case "cdc":
+			cdc := Cdc{}
+			err = json.Unmarshal(data, &cdc)
+			action = cdc
+		case "domainMetadata":
+			domainMetadata := DomainMetadata{}
+			err = json.Unmarshal(data, &domainMetadata)
+			action = domainMetadata
+		case "sidecar":
+			sidecar := Sidecar{}
+			err = json.Unmarshal(data, &sidecar)
+			action = sidecar
+		default:
+			action = UnknownAction{Name: key, Data: append(json.RawMessage(nil), data...)}
+		}
+	}
+	if err != nil {
+		return nil, errors.Join(ErrorActionJSONFormat, err)
+	}
+	return action, nil
+}
+
+// ActionsFromLogEntries parses newline delimited log entries, such as the contents of a commit file, into actions.
+func ActionsFromLogEntries(logData []byte) ([]Action, error) {
+	var actions []Action
+	err := foldLogEntries(bytes.NewReader(logData), func(action Action) error {
+		actions = append(actions, action)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// foldLogEntries parses newline delimited log entries from reader and calls fn with each action as soon as it
+// is parsed, stopping at the first error
+func foldLogEntries(reader io.Reader, fn func(action Action) error) error {
+	lines := bufio.NewReader(reader)
+	for {
+		line, readErr := lines.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry LogEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				if errors.Is(err, ErrorActionJSONFormat) {
+					return err
+				}
+				return errors.Join(ErrorActionJSONFormat, err)
+			}
+			if err := fn(entry.Action); err != nil {
+				return err
+			}
+		}
+		if readErr != nil {
+			return nil
+		}
+	}
+}
+
 // Returns the table schema from the embedded schema string contained within the metadata
 // action.
 func (m *MetaData) GetSchema() (Schema, error) {
@@ -213,15 +555,59 @@ func (m *MetaData) GetSchema() (Schema, error) {
 	return schema, err
 }
 
+// Validate checks that the schema string holds a valid schema and that every partition column is in the schema
+func (m *MetaData) Validate() error {
+	schema, err := m.GetSchema()
+	if err != nil {
+		return errors.Join(ErrorInvalidSchema, err)
+	}
+	for _, column := range m.PartitionColumns {
+		if _, ok := schema.GetField(column); !ok {
+			return errors.Join(ErrorInvalidSchema, fmt.Errorf("partition column %s is not in the schema", column))
+		}
+	}
+	return nil
+}
+
 // / Action used by streaming systems to track progress using application-specific versions to
 // / enable idempotency.
 type Txn struct {
 	/// A unique identifier for the application performing the transaction.
-	AppId string
+	AppId string `json:"appId"`
 	/// An application-specific numeric identifier for this transaction.
-	Version DeltaDataTypeVersion
+	Version DeltaDataTypeVersion `json:"version"`
 	/// The time when this transaction action was created in milliseconds since the Unix epoch.
-	LastUpdated DeltaDataTypeTimestamp
+	LastUpdated DeltaDataTypeTimestamp `json:"lastUpdated,omitempty"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
+}
+
+// / Action holding the configuration of a named metadata domain, such as the settings of a table feature.
+// / https://github.com/delta-io/delta/blob/master/PROTOCOL.md#domain-metadata
+type DomainMetadata struct {
+	/// Identifier of the domain; domains prefixed with "delta." are reserved for table features
+	Domain string `json:"domain"`
+	/// Configuration of the domain, a string the domain is free to interpret (usually JSON)
+	Configuration string `json:"configuration"`
+	/// True if the domain has been removed from the table
+	Removed bool `json:"removed"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
+}
+
+// / Action of a V2 checkpoint referencing a sidecar file, which holds some of the file actions of the checkpoint.
+// / https://github.com/delta-io/delta/blob/master/PROTOCOL.md#sidecar-file-information
+type Sidecar struct {
+	/// The name of the sidecar file in the _delta_log/_sidecars directory
+	Path string `json:"path"`
+	/// The size of the sidecar file in bytes
+	SizeInBytes DeltaDataTypeLong `json:"sizeInBytes"`
+	/// The time the sidecar file was created, in milliseconds since the Unix epoch
+	ModificationTime DeltaDataTypeTimestamp `json:"modificationTime"`
+	/// Map containing metadata about the sidecar file
+	Tags map[string]string `json:"tags,omitempty"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // / Action used to increase the version of the Delta protocol required to read or write to the
@@ -233,6 +619,12 @@ type Protocol struct {
 	/// Minimum version of the Delta write protocol a client must implement to correctly read the
 	/// table.
 	MinWriterVersion DeltaDataTypeInt `json:"minWriterVersion"`
+	/// Table features a client must implement to read the table, only used with reader version 3
+	ReaderFeatures []string `json:"readerFeatures,omitempty"`
+	/// Table features a client must implement to write to the table, only used with writer version 7
+	WriterFeatures []string `json:"writerFeatures,omitempty"`
+	// Fields not modeled by delta-go, see unmarshalWithExtras
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // /// Operation performed when creating a new log entry with one or more actions.
@@ -261,7 +653,7 @@ type Create struct {
 func (op Create) GetCommitInfo() CommitInfo {
 	commitInfo := make(CommitInfo)
 
-	operation := "delta-go.Create"
+	operation := "CREATE TABLE"
 	commitInfo["operation"] = operation
 	commitInfo["operationParameters"] = op
 
@@ -282,13 +674,99 @@ type Write struct {
 func (op Write) GetCommitInfo() CommitInfo {
 	commitInfo := make(CommitInfo)
 
-	operation := "delta-go.Write"
+	operation := "WRITE"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = op
+
+	return commitInfo
+}
+
+// / Represents a Delta `Clone` operation.
+// / A shallow clone references the data files of the source table instead of copying them.
+type Clone struct {
+	/// The URI of the source table
+	Source string `json:"source"`
+	/// The version of the source table that was cloned
+	SourceVersion DeltaDataTypeVersion `json:"sourceVersion"`
+	/// Whether the clone references the source data files rather than copying them
+	IsShallow bool `json:"isShallow"`
+}
+
+func (op Clone) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "CLONE"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = op
+
+	return commitInfo
+}
+
+// / Represents a Delta `UpgradeProtocol` operation, enabling table features.
+type UpgradeProtocol struct {
+	/// The protocol of the table after the upgrade
+	NewProtocol Protocol `json:"newProtocol"`
+}
+
+func (op UpgradeProtocol) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "UPGRADE PROTOCOL"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = op
+
+	return commitInfo
+}
+
+// / Represents a Delta `SetTableProperties` operation, changing table properties.
+type SetTableProperties struct {
+	/// The table properties that were set
+	Properties map[string]string `json:"properties"`
+}
+
+func (op SetTableProperties) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "SET TBLPROPERTIES"
 	commitInfo["operation"] = operation
 	commitInfo["operationParameters"] = op
 
 	return commitInfo
 }
 
+// / Represents a Delta `UnsetTableProperties` operation, removing table properties.
+type UnsetTableProperties struct {
+	/// The keys of the table properties that were removed
+	PropertyKeys []string `json:"propertyKeys"`
+}
+
+func (op UnsetTableProperties) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "UNSET TBLPROPERTIES"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = op
+
+	return commitInfo
+}
+
+// / Represents a Delta `ComputeStats` operation, re-adding data files with their statistics without data change.
+type ComputeStats struct {
+	/// The number of files whose statistics were computed
+	NumFiles int64 `json:"-"`
+}
+
+func (op ComputeStats) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "COMPUTE STATS"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = op
+	commitInfo["operationMetrics"] = map[string]string{"numFiles": strconv.FormatInt(op.NumFiles, 10)}
+
+	return commitInfo
+}
+
 // / Represents a Delta `StreamingUpdate` operation.
 type StreamingUpdate struct {
 	/// The output mode the streaming writer is using.
@@ -299,16 +777,19 @@ type StreamingUpdate struct {
 	EpochId int64
 }
 
-//     #[serde(rename_all = "camelCase")]
-//     /// Represents a `Optimize` operation
-//     Optimize {
-//         // TODO: Create a string representation of the filter passed to optimize
-//         /// The filter used to determine which partitions to filter
-//         predicate: Option<String>,
-//         /// Target optimize size
-//         target_size: DeltaDataTypeLong,
-//     }, // TODO: Add more operations
-// }
+func (op StreamingUpdate) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "STREAMING UPDATE"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = map[string]any{
+		"outputMode": op.OutputMode,
+		"queryId":    op.QueryId,
+		"epochId":    op.EpochId,
+	}
+
+	return commitInfo
+}
 
 // / The SaveMode used when performing a DeltaOperation
 type SaveMode string
@@ -344,12 +825,20 @@ const (
 //		"maxValues\":{\"letter\":\"c\",\"number\":3,\"a_float\":3.3},
 //		"nullCount\":{\"letter\":0,\"number\":0,\"a_float\":0}
 //		}"
+//
+// Statistics of struct columns mirror the shape of the struct, e.g. "minValues":{"event":{"timestamp":...}}.
 type Stats struct {
-	NumRecords  int64            `json:"numRecords"`
+	NumRecords int64 `json:"numRecords"`
+	// Whether the min and max values are exact. Wide bounds, such as those of a file with a deletion vector, may be
+	// below the actual min or above the actual max and cannot be used to skip the file. Statistics without
+	// tightBounds, as written by writers that do not support deletion vectors, have tight bounds.
 	TightBounds bool             `json:"tightBounds"`
 	MinValues   map[string]any   `json:"minValues"`
 	MaxValues   map[string]any   `json:"maxValues"`
 	NullCount   map[string]int64 `json:"nullCount"`
+	// Null counts of the leaves of struct columns, keyed by the dot separated path of the leaf.
+	// They are serialized nested within nullCount.
+	NestedNullCount map[string]int64 `json:"-"`
 }
 
 func (s *Stats) Json() []byte {
@@ -357,6 +846,142 @@ func (s *Stats) Json() []byte {
 	return b
 }
 
+func (s Stats) MarshalJSON() ([]byte, error) {
+	type stats Stats
+	if len(s.NestedNullCount) == 0 {
+		return json.Marshal(stats(s))
+	}
+	nullCount := make(map[string]any, len(s.NullCount)+len(s.NestedNullCount))
+	for column, count := range s.NullCount {
+		nullCount[column] = count
+	}
+	for path, count := range s.NestedNullCount {
+		parent := nullCount
+		names := strings.Split(path, ".")
+		for _, name := range names[:len(names)-1] {
+			child, ok := parent[name].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				parent[name] = child
+			}
+			parent = child
+		}
+		parent[names[len(names)-1]] = count
+	}
+	return json.Marshal(struct {
+		stats
+		NullCount map[string]any `json:"nullCount"`
+	}{stats(s), nullCount})
+}
+
+func (s *Stats) UnmarshalJSON(data []byte) error {
+	type stats Stats
+	var raw struct {
+		stats
+		NullCount map[string]any `json:"nullCount"`
+	}
+	raw.TightBounds = true
+	// Numbers are decoded as json.Number so that decimal and long statistics keep their exact value
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	*s = Stats(raw.stats)
+	s.NullCount = nil
+	s.NestedNullCount = nil
+	var flatten func(counts map[string]any, prefix string)
+	flatten = func(counts map[string]any, prefix string) {
+		for name, value := range counts {
+			switch value := value.(type) {
+			case json.Number:
+				count, err := value.Int64()
+				if err != nil {
+					continue
+				}
+				if prefix == "" {
+					if s.NullCount == nil {
+						s.NullCount = make(map[string]int64)
+					}
+					s.NullCount[name] = count
+				} else {
+					if s.NestedNullCount == nil {
+						s.NestedNullCount = make(map[string]int64)
+					}
+					s.NestedNullCount[prefix+name] = count
+				}
+			case map[string]any:
+				flatten(value, prefix+name+".")
+			}
+		}
+	}
+	if raw.NullCount != nil {
+		s.NullCount = make(map[string]int64)
+		flatten(raw.NullCount, "")
+	}
+	return nil
+}
+
+// LeafMinValues returns the min values of all leaf columns, keyed by the dot separated path of the column
+func (s *Stats) LeafMinValues() map[string]any {
+	return leafValues(s.MinValues)
+}
+
+// LeafMaxValues returns the max values of all leaf columns, keyed by the dot separated path of the column
+func (s *Stats) LeafMaxValues() map[string]any {
+	return leafValues(s.MaxValues)
+}
+
+// LeafNullCount returns the null counts of all leaf columns, keyed by the dot separated path of the column
+func (s *Stats) LeafNullCount() map[string]int64 {
+	counts := make(map[string]int64, len(s.NullCount)+len(s.NestedNullCount))
+	maps.Copy(counts, s.NullCount)
+	maps.Copy(counts, s.NestedNullCount)
+	return counts
+}
+
+// leafValues flattens nested stats values into a map keyed by the dot separated path of the leaves
+func leafValues(values map[string]any) map[string]any {
+	leaves := make(map[string]any)
+	var flatten func(values map[string]any, prefix string)
+	flatten = func(values map[string]any, prefix string) {
+		for name, value := range values {
+			if nested, ok := value.(map[string]any); ok {
+				flatten(nested, prefix+name+".")
+				continue
+			}
+			leaves[prefix+name] = value
+		}
+	}
+	flatten(values, "")
+	return leaves
+}
+
+// ParseStats parses the statistics of the file, returning nil if the file has no statistics.
+// Statistics read from the stats_parsed column of a checkpoint are returned without parsing Stats.
+func (add *Add) ParseStats() (*Stats, error) {
+	if add.statsParsed != nil {
+		stats := *add.statsParsed
+		return &stats, nil
+	}
+	if add.Stats == "" {
+		return nil, nil
+	}
+	stats := new(Stats)
+	err := json.Unmarshal([]byte(add.Stats), stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Tag returns the value of a tag of the file, such as the location of an index sidecar written for it,
+// and false if the file has no such tag
+func (add *Add) Tag(name string) (string, bool) {
+	value, ok := add.Tags[name]
+	return value, ok
+}
+
 // UpdateStats computes Stats.NullCount, Stats.MinValues, Stats.MaxValues for a given k,v struct property
 // the struct property is passed in as a pointer to ensure that it can be evaluated as nil[NULL]
 // TODO Handel struct types
@@ -14,6 +14,7 @@ package delta
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -50,15 +51,15 @@ func TestLogEntryFromActions(t *testing.T) {
 	}
 	println(string(logs))
 
-	expectedStr := `{"commitInfo":{"operation":"delta-go.Write","operationParameters":{"mode":"ErrorIfExists","partitionBy":null,"Predicate":""},"timestamp":1675020556534}}
+	expectedStr := `{"commitInfo":{"operation":"WRITE","operationParameters":{"mode":"ErrorIfExists","partitionBy":null,"Predicate":""},"timestamp":1675020556534}}
 	{"add":{"path":"part-1.snappy.parquet","size":1,"partitionValues":null,"modificationTime":{},"dataChange":false,"stats":"","Tags":null}}
 	{"path":"part-2.snappy.parquet","size":2,"partitionValues":null,"modificationTime":{},"dataChange":false,"stats":"","Tags":null}`
 
-	if !strings.Contains(string(logs), `{"commitInfo":{"operation":"delta-go.Write"`) {
+	if !strings.Contains(string(logs), `{"commitInfo":{"operation":"WRITE"`) {
 		t.Errorf("want:\n%s\nhas:\n%s\n", expectedStr, string(logs))
 	}
 
-	if !strings.Contains(string(logs), `{"commitInfo":{"operation":"delta-go.Write","operationParameters":{"mode":"ErrorIfExists","partitionBy":null,"Predicate":""},"timestamp":1675020556534}}`) {
+	if !strings.Contains(string(logs), `{"commitInfo":{"operation":"WRITE","operationParameters":{"mode":"ErrorIfExists","partitionBy":null,"Predicate":""},"timestamp":1675020556534}}`) {
 		t.Errorf("want:\n%s\nhas:\n%s\n", expectedStr, string(logs))
 	}
 
@@ -183,3 +184,83 @@ func TestFormatDefault(t *testing.T) {
 	}
 
 }
+
+func TestLogEntryRoundTrip(t *testing.T) {
+	entries := []string{
+		`{"add":{"path":"part-1.snappy.parquet","size":1,"partitionValues":{"date":"2023-01-01"},"modificationTime":1675020556534,"dataChange":true,"stats":"{\"numRecords\":1}"}}`,
+		`{"remove":{"path":"part-0.snappy.parquet","deletionTimestamp":1675020556534,"dataChange":true,"extendedFileMetadata":false,"partitionValues":null,"size":0,"tags":null}}`,
+		`{"protocol":{"minReaderVersion":1,"minWriterVersion":2}}`,
+		`{"txn":{"appId":"stream","version":3,"lastUpdated":1675020556534}}`,
+		`{"cdc":{"path":"_change_data/cdc-1.snappy.parquet","partitionValues":{},"size":10,"dataChange":false}}`,
+		`{"commitInfo":{"operation":"WRITE","timestamp":1675020556534}}`,
+		`{"domainMetadata":{"domain":"delta.clustering","configuration":"{\"clusteringColumns\":[]}","removed":false}}`,
+		`{"sidecar":{"path":"00000000000000000010.checkpoint.0000000001.0000000001.uuid.parquet","sizeInBytes":1024,"modificationTime":1675020556534}}`,
+		`{"someFutureAction":{"b":[1,2,3],"a":"x"}}`,
+	}
+	types := []Action{Add{}, Remove{}, Protocol{}, Txn{}, Cdc{}, CommitInfo{}, DomainMetadata{}, Sidecar{}, UnknownAction{}}
+
+	actions, err := ActionsFromLogEntries([]byte(strings.Join(entries, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != len(entries) {
+		t.Fatalf("want %d actions, has %d", len(entries), len(actions))
+	}
+	for i, action := range actions {
+		if fmt.Sprintf("%T", action) != fmt.Sprintf("%T", types[i]) {
+			t.Errorf("entry %d: want %T, has %T", i, types[i], action)
+		}
+	}
+
+	logs, err := LogEntryFromActions(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(string(logs), "\n") {
+		if line != entries[i] {
+			t.Errorf("want:\n%s\nhas:\n%s\n", entries[i], line)
+		}
+	}
+}
+
+func TestLogEntryUnmarshalErrors(t *testing.T) {
+	for _, entry := range []string{`{"add":{"path":1}}`, `{"add":{},"remove":{}}`, `not json`} {
+		_, err := ActionsFromLogEntries([]byte(entry))
+		if !errors.Is(err, ErrorActionJSONFormat) {
+			t.Errorf("%s: want ErrorActionJSONFormat, has %v", entry, err)
+		}
+	}
+}
+
+func TestLogEntryPreservesUnknownFields(t *testing.T) {
+	entries := []string{
+		`{"add":{"path":"part-1.snappy.parquet","size":1,"partitionValues":{},"modificationTime":1675020556534,"dataChange":true,"stats":"","baseRowId":5,"deletionVector":{"cardinality":2,"storageType":"u"}}}`,
+		`{"remove":{"path":"part-0.snappy.parquet","deletionTimestamp":1675020556534,"dataChange":true,"extendedFileMetadata":false,"partitionValues":null,"size":0,"tags":null,"baseRowId":4}}`,
+		`{"protocol":{"minReaderVersion":3,"minWriterVersion":7,"readerFeatures":["deletionVectors"],"writerFeatures":["deletionVectors"]}}`,
+	}
+
+	actions, err := ActionsFromLogEntries([]byte(strings.Join(entries, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := actions[0].(Add)
+	if string(add.Extras["deletionVector"]) != `{"cardinality":2,"storageType":"u"}` {
+		t.Errorf("want deletionVector extra, has %s", add.Extras["deletionVector"])
+	}
+	if add.BaseRowId == nil || *add.BaseRowId != 5 {
+		t.Error("declared field baseRowId should be parsed")
+	}
+	if _, ok := add.Extras["path"]; ok {
+		t.Error("declared field path should not be kept as an extra")
+	}
+
+	logs, err := LogEntryFromActions(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(string(logs), "\n") {
+		if line != entries[i] {
+			t.Errorf("want:\n%s\nhas:\n%s\n", entries[i], line)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	ErrorBatchCommit            error = errors.New("error committing the batch")
+	ErrorConcurrentModification error = errors.New("the table was modified by a conflicting commit")
+)
+
+// BatchTransaction accumulates the changes of several logical operations, such as an append, a change of the table
+// properties and a protocol upgrade, and commits them atomically in a single version.
+// Data files and other actions are added with the methods of the embedded transaction, while the metadata and the
+// protocol are changed with the methods of the batch, so that the commit holds at most one metaData and one protocol
+// action with all the changes.
+// Unlike other transactions, the commit of a batch fails with ErrorConcurrentModification if a commit made since the
+// batch was created conflicts with its actions, see Commit.
+type BatchTransaction struct {
+	*DeltaTransaction
+	// The pending metadata and protocol, nil if the batch does not change them
+	metadata *DeltaTableMetaData
+	protocol *Protocol
+}
+
+// NewBatchTransaction creates a batch of changes to the loaded table state, committed with the transaction options
+func (table *DeltaTable) NewBatchTransaction(options *DeltaTransactionOptions) *BatchTransaction {
+	return &BatchTransaction{DeltaTransaction: table.createCheckedTransaction(options)}
+}
+
+// createCheckedTransaction creates a transaction whose commit fails with ErrorConcurrentModification if a commit
+// made since the loaded table state conflicts with its actions, see checkConcurrentCommits
+func (table *DeltaTable) createCheckedTransaction(options *DeltaTransactionOptions) *DeltaTransaction {
+	transaction := table.CreateTransaction(options)
+	transaction.readVersion = table.State.Version
+	transaction.checkConflicts = true
+	return transaction
+}
+
+// currentMetadata returns the metadata of the table with the changes of the batch
+func (batch *BatchTransaction) currentMetadata() DeltaTableMetaData {
+	if batch.metadata != nil {
+		return *batch.metadata
+	}
+	return batch.DeltaTable.State.CurrentMetadata
+}
+
+// currentProtocol returns the protocol of the table with the changes of the batch
+func (batch *BatchTransaction) currentProtocol() Protocol {
+	if batch.protocol != nil {
+		return *batch.protocol
+	}
+	return batch.DeltaTable.State.protocol()
+}
+
+// SetMetadata replaces the metadata of the table, including the changes of the properties made so far by the batch
+func (batch *BatchTransaction) SetMetadata(metadata DeltaTableMetaData) {
+	batch.metadata = &metadata
+}
+
+// SetProperties sets the table properties, validated and upgrading the protocol as by DeltaTable.SetProperties
+func (batch *BatchTransaction) SetProperties(properties map[string]string) error {
+	metadata := batch.currentMetadata()
+	configuration := maps.Clone(metadata.Configuration)
+	if configuration == nil {
+		configuration = make(map[string]string)
+	}
+	maps.Copy(configuration, properties)
+	return batch.setConfiguration(metadata, configuration)
+}
+
+// UnsetProperties removes the table properties as by DeltaTable.UnsetProperties
+func (batch *BatchTransaction) UnsetProperties(keys []string) error {
+	metadata := batch.currentMetadata()
+	configuration := maps.Clone(metadata.Configuration)
+	for _, key := range keys {
+		delete(configuration, key)
+	}
+	return batch.setConfiguration(metadata, configuration)
+}
+
+// setConfiguration changes the configuration of the pending metadata, adding the table features it requires to the
+// pending protocol
+func (batch *BatchTransaction) setConfiguration(metadata DeltaTableMetaData, configuration map[string]string) error {
+	if maps.Equal(configuration, metadata.Configuration) {
+		return nil
+	}
+	if err := batch.DeltaTable.State.validateProperties(configuration); err != nil {
+		return err
+	}
+	protocol, upgrade, err := upgradeProtocol(batch.currentProtocol(), changedPropertyFeatures(metadata.Configuration, configuration)...)
+	if err != nil {
+		return err
+	}
+	metadata.Configuration = configuration
+	metaData := metadata.ToMetaData()
+	if err := metaData.Validate(); err != nil {
+		return err
+	}
+	batch.metadata = &metadata
+	if upgrade {
+		batch.protocol = &protocol
+	}
+	return nil
+}
+
+// EnableFeature adds the table feature, and the features it depends on, to the protocol as by
+// DeltaTable.EnableFeature
+func (batch *BatchTransaction) EnableFeature(name string) error {
+	upgraded, changed, err := upgradeProtocol(batch.currentProtocol(), name)
+	if err != nil || !changed {
+		return err
+	}
+	batch.protocol = &upgraded
+	return nil
+}
+
+// Commit commits the changes of the batch in a single version and returns the committed version, as
+// DeltaTransaction.Commit does. Nothing is committed if the batch has no changes.
+// The commits made since the batch was created are checked before each commit attempt, and the commit fails with
+// ErrorConcurrentModification, removing the data files tracked by the batch, if one of them changed the metadata or
+// the protocol, removed a file the batch removes or adds, or has a txn or domain metadata action of the same application or
+// domain as the batch. Commits only adding files do not conflict.
+func (batch *BatchTransaction) Commit(operation DeltaOperation, appMetadata map[string]any) (state.DeltaDataTypeVersion, error) {
+	return batch.CommitWithContext(context.Background(), operation, appMetadata)
+}
+
+// CommitWithContext is Commit giving up when the context is cancelled, see DeltaTransaction.CommitWithContext
+func (batch *BatchTransaction) CommitWithContext(ctx context.Context, operation DeltaOperation, appMetadata map[string]any) (state.DeltaDataTypeVersion, error) {
+	if batch.DeltaTable.State.Version < 0 {
+		return batch.DeltaTable.State.Version, ErrorNotATable
+	}
+	var actions []Action
+	if batch.protocol != nil {
+		actions = append(actions, *batch.protocol)
+	}
+	if batch.metadata != nil {
+		actions = append(actions, batch.metadata.ToMetaData())
+	}
+	for _, action := range batch.Actions {
+		switch action.(type) {
+		case MetaData, Protocol:
+			batch.AbortWrite()
+			return batch.DeltaTable.State.Version, errors.Join(ErrorBatchCommit, fmt.Errorf("%T actions are added with the methods of the batch", action))
+		}
+	}
+	if len(actions) == 0 && len(batch.Actions) == 0 {
+		return batch.DeltaTable.State.Version, nil
+	}
+	batch.Actions = append(actions, batch.Actions...)
+	return batch.DeltaTransaction.CommitWithContext(ctx, operation, appMetadata)
+}
+
+// checkConcurrentCommits returns ErrorConcurrentModification if one of the commits after the version the transaction
+// was created at and before the given version conflicts with its actions. A file removed by such a commit conflicts
+// with both a removal of the file and an add action adding it again, for instance with new statistics.
+// Versions missing from the log are skipped.
+func (transaction *DeltaTransaction) checkConcurrentCommits(version state.DeltaDataTypeVersion) error {
+	if !transaction.checkConflicts {
+		return nil
+	}
+	paths := make(map[string]bool)
+	appIds := make(map[string]bool)
+	domains := make(map[string]bool)
+	for _, action := range transaction.Actions {
+		switch a := action.(type) {
+		case Add:
+			paths[a.Path] = true
+		case Remove:
+			paths[a.Path] = true
+		case Txn:
+			appIds[a.AppId] = true
+		case DomainMetadata:
+			domains[a.Domain] = true
+		}
+	}
+
+	checked := true
+	for v := transaction.readVersion + 1; v < version; v++ {
+		actions, err := transaction.DeltaTable.ReadActions(v)
+		if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+			checked = false
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, action := range actions {
+			var conflict string
+			switch a := action.(type) {
+			case MetaData:
+				conflict = "changed the metadata"
+			case Protocol:
+				conflict = "changed the protocol"
+			case Remove:
+				if paths[a.Path] {
+					conflict = fmt.Sprintf("removed %s", a.Path)
+				}
+			case Txn:
+				if appIds[a.AppId] {
+					conflict = fmt.Sprintf("committed a transaction of application %s", a.AppId)
+				}
+			case DomainMetadata:
+				if domains[a.Domain] {
+					conflict = fmt.Sprintf("changed the domain %s", a.Domain)
+				}
+			}
+			if conflict != "" {
+				return errors.Join(ErrorConcurrentModification, fmt.Errorf("version %d %s", v, conflict))
+			}
+		}
+		// The versions checked are not read again by the next attempt
+		if checked {
+			transaction.readVersion = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+// Helper function to set up a table with one file, and a second writer of the same table
+func setupBatchTransactionTable(t *testing.T) (*DeltaTable, *DeltaTable) {
+	t.Helper()
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	if err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{{Path: "part-0.parquet", Size: 100, DataChange: true}}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := OpenTable(table.Store, table.LockClient, table.StateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table, other
+}
+
+func TestBatchTransactionCommit(t *testing.T) {
+	table, _ := setupBatchTransactionTable(t)
+
+	batch := table.NewBatchTransaction(NewDeltaTransactionOptions())
+	batch.AddAction(Add{Path: "part-1.parquet", Size: 100, DataChange: true})
+	if err := batch.SetProperties(map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "20"}); err != nil {
+		t.Fatal(err)
+	}
+	// Later changes build on the pending metadata and protocol
+	if err := batch.SetProperties(map[string]string{APPEND_ONLY_PROPERTY: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.EnableFeature(DOMAIN_METADATA_FEATURE); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.SetProperties(map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "0"}); !errors.Is(err, ErrorInvalidTableProperty) {
+		t.Errorf("want ErrorInvalidTableProperty, has %v", err)
+	}
+	version, err := batch.Commit(Write{Mode: Append}, nil)
+	if err != nil || version != 1 {
+		t.Fatalf("want version 1, has %d %v", version, err)
+	}
+
+	actions, err := table.ReadActions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, action := range actions {
+		switch action.(type) {
+		case MetaData:
+			counts["metaData"]++
+		case Protocol:
+			counts["protocol"]++
+		case Add:
+			counts["add"]++
+		}
+	}
+	if counts["metaData"] != 1 || counts["protocol"] != 1 || counts["add"] != 1 {
+		t.Errorf("want one metaData, protocol and add action, has %v", counts)
+	}
+	properties := table.State.Properties()
+	if properties[CHECKPOINT_INTERVAL_PROPERTY] != "20" || properties[APPEND_ONLY_PROPERTY] != "true" || len(table.State.Files) != 2 {
+		t.Errorf("unexpected table state %v with %d files", properties, len(table.State.Files))
+	}
+	protocol := table.State.protocol()
+	if protocol.MinWriterVersion != 7 || !protocol.HasWriterFeature(DOMAIN_METADATA_FEATURE) {
+		t.Errorf("want the domain metadata feature, has %+v", protocol)
+	}
+
+	// Metadata and protocol actions are not added to the batch directly, and empty batches are not committed
+	batch = table.NewBatchTransaction(NewDeltaTransactionOptions())
+	batch.AddAction(table.State.CurrentMetadata.ToMetaData())
+	if _, err := batch.Commit(Write{Mode: Append}, nil); !errors.Is(err, ErrorBatchCommit) {
+		t.Errorf("want ErrorBatchCommit, has %v", err)
+	}
+	if version, err := table.NewBatchTransaction(NewDeltaTransactionOptions()).Commit(Write{Mode: Append}, nil); err != nil || version != 1 {
+		t.Errorf("want nothing committed, has version %d %v", version, err)
+	}
+}
+
+func TestBatchTransactionConcurrentCommits(t *testing.T) {
+	table, other := setupBatchTransactionTable(t)
+
+	// A concurrent append does not conflict
+	batch := table.NewBatchTransaction(NewDeltaTransactionOptions())
+	batch.AddAction(Remove{Path: "part-0.parquet", DataChange: true})
+	if err := batch.PutDataFile(storage.NewPath("part-1.parquet"), make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	batch.AddAction(Add{Path: "part-1.parquet", Size: 100, DataChange: true})
+	transaction := other.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-2.parquet", Size: 100, DataChange: true})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := batch.Commit(Write{Mode: Overwrite}, nil); err != nil || version != 2 {
+		t.Fatalf("want version 2, has %d %v", version, err)
+	}
+
+	// A concurrent change of the metadata or removal of the same file conflicts
+	for _, concurrent := range []func() error{
+		func() error {
+			_, err := other.SetProperties(map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "20"})
+			return err
+		},
+		func() error {
+			transaction := other.CreateTransaction(NewDeltaTransactionOptions())
+			transaction.AddAction(Remove{Path: "part-2.parquet", DataChange: true})
+			_, err := transaction.Commit(Write{Mode: Overwrite}, nil)
+			return err
+		},
+	} {
+		if err := table.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Update(); err != nil {
+			t.Fatal(err)
+		}
+		batch := table.NewBatchTransaction(NewDeltaTransactionOptions())
+		batch.AddAction(Remove{Path: "part-2.parquet", DataChange: true})
+		if err := batch.PutDataFile(storage.NewPath("part-3.parquet"), make([]byte, 100)); err != nil {
+			t.Fatal(err)
+		}
+		batch.AddAction(Add{Path: "part-3.parquet", Size: 100, DataChange: true})
+		if err := concurrent(); err != nil {
+			t.Fatal(err)
+		}
+		version := other.State.Version
+		if _, err := batch.Commit(Write{Mode: Overwrite}, nil); !errors.Is(err, ErrorConcurrentModification) {
+			t.Errorf("want ErrorConcurrentModification, has %v", err)
+		}
+		if _, err := table.Store.Head(storage.NewPath("part-3.parquet")); !errors.Is(err, storage.ErrorObjectDoesNotExist) {
+			t.Errorf("want the data file of the batch removed, has %v", err)
+		}
+		if err := table.Update(); err != nil || table.State.Version != version {
+			t.Errorf("want nothing committed after version %d, has version %d %v", version, table.State.Version, err)
+		}
+	}
+}
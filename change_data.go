@@ -0,0 +1,271 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+)
+
+var (
+	ErrorChangeDataUnavailable error = errors.New("the change data is not available")
+)
+
+// The columns added to the rows of a ChangeStream
+// https://github.com/delta-io/delta/blob/master/PROTOCOL.md#change-data-files
+const (
+	CHANGE_TYPE_COLUMN      = "_change_type"
+	COMMIT_VERSION_COLUMN   = "_commit_version"
+	COMMIT_TIMESTAMP_COLUMN = "_commit_timestamp"
+)
+
+// The values of the _change_type column
+const (
+	ChangeInsert          = "insert"
+	ChangeDelete          = "delete"
+	ChangeUpdatePreimage  = "update_preimage"
+	ChangeUpdatePostimage = "update_postimage"
+)
+
+// ChangeFile is a file holding the changed rows of a commit
+type ChangeFile struct {
+	Version state.DeltaDataTypeVersion
+	// The in-commit timestamp of the commit, or the last modified time of the commit file if it has none
+	Timestamp       time.Time
+	Path            string
+	PartitionValues map[string]string
+	// The change type of every row of the file, empty for the change data files written by commits with the change
+	// data feed enabled, whose rows have a _change_type column
+	ChangeType string
+}
+
+// ChangeFiles lists the files holding the rows changed by the commits from startVersion to endVersion inclusive.
+// The change data files of a commit are used when it has some; the changes of other commits are derived from their
+// data files, the rows of the added files being inserted and the rows of the removed files deleted.
+// Returns ErrorInvalidVersion if a commit of the range is not in the log, and ErrorChangeDataUnavailable for the data
+// files with a deletion vector, whose changed rows cannot be derived.
+func (table *DeltaTable) ChangeFiles(startVersion state.DeltaDataTypeVersion, endVersion state.DeltaDataTypeVersion) ([]ChangeFile, error) {
+	if startVersion < 0 || startVersion > endVersion {
+		return nil, errors.Join(ErrorInvalidVersion, fmt.Errorf("invalid range from version %d to %d", startVersion, endVersion))
+	}
+	versions, err := table.ListVersions()
+	if err != nil {
+		return nil, err
+	}
+	timestamps := make(map[state.DeltaDataTypeVersion]time.Time, len(versions))
+	for _, info := range versions {
+		timestamps[info.Version] = info.Timestamp
+	}
+
+	var files []ChangeFile
+	for version := startVersion; version <= endVersion; version++ {
+		timestamp, ok := timestamps[version]
+		if !ok {
+			return nil, errors.Join(ErrorInvalidVersion, fmt.Errorf("version %d is missing", version))
+		}
+		actions, err := table.readLogEntry(table.CommitUriFromVersion(version))
+		if err != nil {
+			return nil, err
+		}
+
+		var changeDataFiles, dataFiles []ChangeFile
+		for _, action := range actions {
+			file := ChangeFile{Version: version}
+			var deletionVector *DeletionVectorDescriptor
+			switch action := action.(type) {
+			case CommitInfo:
+				if inCommitTimestamp, ok := action.InCommitTimestamp(); ok {
+					timestamp = inCommitTimestamp
+				}
+				continue
+			case Cdc:
+				file.Path, file.PartitionValues = action.Path, action.PartitionValues
+				changeDataFiles = append(changeDataFiles, file)
+				continue
+			case Add:
+				if !action.DataChange {
+					continue
+				}
+				file.Path, file.PartitionValues, file.ChangeType = action.Path, action.PartitionValues, ChangeInsert
+				deletionVector, err = action.DeletionVector()
+			case Remove:
+				if !action.DataChange {
+					continue
+				}
+				file.Path, file.PartitionValues, file.ChangeType = action.Path, action.PartitionValues, ChangeDelete
+				deletionVector, err = action.DeletionVector()
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if deletionVector != nil {
+				return nil, errors.Join(ErrorChangeDataUnavailable, fmt.Errorf("version %d: %s has a deletion vector", version, file.Path))
+			}
+			dataFiles = append(dataFiles, file)
+		}
+
+		if len(changeDataFiles) > 0 {
+			dataFiles = changeDataFiles
+		}
+		for _, file := range dataFiles {
+			file.Timestamp = timestamp
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// ChangeRow is a row changed by a commit, keyed by the column names of the data files, with the values of the
+// partition columns parsed as by TypedPartitionValues, and the _change_type, _commit_version and _commit_timestamp
+// columns. The commit version is a DeltaDataTypeVersion and the commit timestamp a time.Time.
+type ChangeRow map[string]any
+
+// ChangeType returns the _change_type of the row
+func (row ChangeRow) ChangeType() string {
+	changeType, _ := row[CHANGE_TYPE_COLUMN].(string)
+	return changeType
+}
+
+// ChangeStream reads the rows of the change files of a range of commits, one file at a time
+type ChangeStream struct {
+	table  *DeltaTable
+	schema Schema
+	files  []ChangeFile
+	// The index of the next file to open
+	nextFile int
+
+	file            *ChangeFile
+	partitionValues map[string]any
+	reader          *parquet.GenericReader[any]
+	buffer          []any
+	rows            []any
+}
+
+// ChangeStream returns a stream of the rows changed by the commits from startVersion to endVersion inclusive, in the
+// order of the commits, reading the files listed by ChangeFiles. The partition values are parsed with the schema of
+// the loaded table state.
+func (table *DeltaTable) ChangeStream(startVersion state.DeltaDataTypeVersion, endVersion state.DeltaDataTypeVersion) (*ChangeStream, error) {
+	files, err := table.ChangeFiles(startVersion, endVersion)
+	if err != nil {
+		return nil, err
+	}
+	stream := new(ChangeStream)
+	stream.table = table
+	stream.schema = table.State.CurrentMetadata.Schema
+	stream.files = files
+	stream.buffer = make([]any, 1024)
+	return stream, nil
+}
+
+// Next returns the next changed row, or io.EOF once all the rows have been read.
+// Returns ErrorChangeDataUnavailable if a file has been deleted, e.g. by a vacuum.
+func (stream *ChangeStream) Next() (ChangeRow, error) {
+	for {
+		if len(stream.rows) > 0 {
+			fields, _ := stream.rows[0].(map[string]any)
+			stream.rows = stream.rows[1:]
+			return stream.changeRow(fields), nil
+		}
+		if stream.reader != nil {
+			if err := stream.read(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if stream.nextFile == len(stream.files) {
+			return nil, io.EOF
+		}
+		if err := stream.open(&stream.files[stream.nextFile]); err != nil {
+			return nil, err
+		}
+		stream.nextFile++
+	}
+}
+
+// Close releases the file being read
+func (stream *ChangeStream) Close() error {
+	if stream.reader == nil {
+		return nil
+	}
+	err := stream.reader.Close()
+	stream.reader = nil
+	stream.rows = nil
+	return err
+}
+
+// open starts reading the rows of the change file
+func (stream *ChangeStream) open(file *ChangeFile) error {
+	partitionValues, err := typedPartitionValues(stream.schema, file.PartitionValues)
+	if err != nil {
+		return err
+	}
+	data, err := stream.table.dataStore().Get(storage.NewPath(unescapedDataPath(file.Path)))
+	if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		return errors.Join(ErrorChangeDataUnavailable, fmt.Errorf("version %d: %s", file.Version, file.Path), err)
+	}
+	if err != nil {
+		return err
+	}
+	parquetFile, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Path, err)
+	}
+	stream.file = file
+	stream.partitionValues = partitionValues
+	stream.reader = parquet.NewGenericReader[any](parquetFile, parquetFile.Schema())
+	return nil
+}
+
+// read reads the next rows of the current file, closing it once all its rows have been read
+func (stream *ChangeStream) read() error {
+	for i := range stream.buffer {
+		stream.buffer[i] = nil
+	}
+	n, err := stream.reader.Read(stream.buffer)
+	stream.rows = stream.buffer[:n]
+	if errors.Is(err, io.EOF) {
+		err = stream.reader.Close()
+		stream.reader = nil
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", stream.file.Path, err)
+	}
+	return nil
+}
+
+// changeRow adds the partition values and the change columns to the fields of a row of the current file
+func (stream *ChangeStream) changeRow(fields map[string]any) ChangeRow {
+	row := make(ChangeRow, len(fields)+len(stream.partitionValues)+3)
+	for column, value := range fields {
+		row[column] = value
+	}
+	for column, value := range stream.partitionValues {
+		row[column] = value
+	}
+	if stream.file.ChangeType != "" {
+		row[CHANGE_TYPE_COLUMN] = stream.file.ChangeType
+	}
+	row[COMMIT_VERSION_COLUMN] = stream.file.Version
+	row[COMMIT_TIMESTAMP_COLUMN] = stream.file.Timestamp
+	return row
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+)
+
+type changeTestRow struct {
+	Id    int64  `parquet:"id"`
+	Value string `parquet:"value"`
+}
+
+type changeTestCdcRow struct {
+	Id         int64  `parquet:"id"`
+	Value      string `parquet:"value"`
+	ChangeType string `parquet:"_change_type"`
+}
+
+// Helper function to write the rows to a parquet file of the table
+func writeChangeTestFile[T any](t *testing.T, table *DeltaTable, path string, rows []T) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Store.Put(storage.NewPath(path), buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Helper function to set up a table partitioned by part with 4 commits:
+// 0 inserts a.parquet, 1 replaces it with b.parquet, 2 updates a row with change data files, and 3 only rearranges
+// the data files
+func setupChangeTable(t *testing.T) *DeltaTable {
+	t.Helper()
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "value", Type: String}, {Name: "part", Type: String}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{"part"}, map[string]string{CHANGE_DATA_FEED_PROPERTY: "true"})
+	part := map[string]string{"part": "x"}
+
+	writeChangeTestFile(t, table, "part=x/a.parquet", []changeTestRow{{1, "a"}, {2, "b"}})
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 4}, CommitInfo{}, []Add{{Path: "part=x/a.parquet", PartitionValues: part, DataChange: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(actions ...Action) {
+		t.Helper()
+		transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+		transaction.AddActions(actions)
+		if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeChangeTestFile(t, table, "part=x/b.parquet", []changeTestRow{{2, "b"}})
+	commit(Remove{Path: "part=x/a.parquet", PartitionValues: part, DataChange: true}, Add{Path: "part=x/b.parquet", PartitionValues: part, DataChange: true})
+
+	writeChangeTestFile(t, table, "part=x/c.parquet", []changeTestRow{{2, "c"}})
+	writeChangeTestFile(t, table, "_change_data/part=x/cdc-c.parquet", []changeTestCdcRow{{2, "b", ChangeUpdatePreimage}, {2, "c", ChangeUpdatePostimage}})
+	commit(Remove{Path: "part=x/b.parquet", PartitionValues: part, DataChange: true}, Add{Path: "part=x/c.parquet", PartitionValues: part, DataChange: true},
+		Cdc{Path: "_change_data/part=x/cdc-c.parquet", PartitionValues: part})
+
+	writeChangeTestFile(t, table, "part=x/d.parquet", []changeTestRow{{2, "c"}})
+	commit(Remove{Path: "part=x/c.parquet", PartitionValues: part}, Add{Path: "part=x/d.parquet", PartitionValues: part})
+
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestChangeFiles(t *testing.T) {
+	table := setupChangeTable(t)
+
+	files, err := table.ChangeFiles(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []string
+	for _, file := range files {
+		if file.Timestamp.IsZero() {
+			t.Errorf("%s should have the timestamp of version %d", file.Path, file.Version)
+		}
+		changes = append(changes, fmt.Sprintf("%d %s %s", file.Version, file.ChangeType, file.Path))
+	}
+	expected := []string{
+		"0 insert part=x/a.parquet",
+		"1 delete part=x/a.parquet",
+		"1 insert part=x/b.parquet",
+		"2  _change_data/part=x/cdc-c.parquet",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("want %v, has %v", expected, changes)
+	}
+
+	if _, err := table.ChangeFiles(2, 4); !errors.Is(err, ErrorInvalidVersion) {
+		t.Errorf("want ErrorInvalidVersion, has %v", err)
+	}
+}
+
+func TestChangeStream(t *testing.T) {
+	table := setupChangeTable(t)
+
+	stream, err := table.ChangeStream(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var changes []string
+	for {
+		row, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["part"] != "x" {
+			t.Errorf("want the partition value x, has %v", row["part"])
+		}
+		if _, ok := row[COMMIT_TIMESTAMP_COLUMN]; !ok {
+			t.Errorf("the row should have a commit timestamp, has %v", row)
+		}
+		changes = append(changes, fmt.Sprintf("%d %s %v %v", row[COMMIT_VERSION_COLUMN], row.ChangeType(), row["id"], row["value"]))
+	}
+	expected := []string{
+		"0 insert 1 a",
+		"0 insert 2 b",
+		"1 delete 1 a",
+		"1 delete 2 b",
+		"1 insert 2 b",
+		"2 update_preimage 2 b",
+		"2 update_postimage 2 c",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("want %v, has %v", expected, changes)
+	}
+
+	// The rows of deleted files are no longer available
+	if err := table.Store.Delete(storage.NewPath("part=x/a.parquet")); err != nil {
+		t.Fatal(err)
+	}
+	stream, err = table.ChangeStream(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Next(); !errors.Is(err, ErrorChangeDataUnavailable) {
+		t.Errorf("want ErrorChangeDataUnavailable, has %v", err)
+	}
+}
@@ -0,0 +1,773 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/lock"
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrorReadingCheckpoint    error = errors.New("error reading checkpoint")
+	ErrorCheckpointIncomplete error = errors.New("the checkpoint is missing parts")
+	ErrorCheckpointSidecar    error = errors.New("sidecar files only hold add and remove actions")
+)
+
+// checkpointRow is a row of a checkpoint Parquet file, holding exactly one action
+// https://github.com/delta-io/delta/blob/master/PROTOCOL.md#checkpoint-schema
+type checkpointRow struct {
+	Txn      *checkpointTxn      `parquet:"txn,optional"`
+	Add      *checkpointAdd      `parquet:"add,optional"`
+	Remove   *checkpointRemove   `parquet:"remove,optional"`
+	MetaData *checkpointMetaData `parquet:"metaData,optional"`
+	Protocol *checkpointProtocol `parquet:"protocol,optional"`
+	// Domains with a configuration delta-go does not interpret are kept as is
+	DomainMetadata *checkpointDomainMetadata `parquet:"domainMetadata,optional"`
+	// The sidecar files holding file actions of a V2 checkpoint
+	Sidecar *checkpointSidecar `parquet:"sidecar,optional"`
+}
+
+type checkpointTxn struct {
+	AppId       string `parquet:"appId"`
+	Version     int64  `parquet:"version"`
+	LastUpdated int64  `parquet:"lastUpdated,optional"`
+}
+
+type checkpointAdd struct {
+	Path             string            `parquet:"path"`
+	PartitionValues  map[string]string `parquet:"partitionValues"`
+	Size             int64             `parquet:"size"`
+	ModificationTime int64             `parquet:"modificationTime"`
+	DataChange       bool              `parquet:"dataChange"`
+	Stats            string            `parquet:"stats,optional"`
+	Tags             map[string]string `parquet:"tags,optional"`
+	// Row tracking fields
+	BaseRowId               *int64 `parquet:"baseRowId,optional"`
+	DefaultRowCommitVersion *int64 `parquet:"defaultRowCommitVersion,optional"`
+}
+
+type checkpointRemove struct {
+	Path                 string            `parquet:"path"`
+	DeletionTimestamp    int64             `parquet:"deletionTimestamp,optional"`
+	DataChange           bool              `parquet:"dataChange"`
+	ExtendedFileMetadata bool              `parquet:"extendedFileMetadata,optional"`
+	PartitionValues      map[string]string `parquet:"partitionValues,optional"`
+	Size                 int64             `parquet:"size,optional"`
+	Tags                 map[string]string `parquet:"tags,optional"`
+}
+
+type checkpointFormat struct {
+	Provider string            `parquet:"provider"`
+	Options  map[string]string `parquet:"options,optional"`
+}
+
+type checkpointMetaData struct {
+	Id               string            `parquet:"id"`
+	Name             string            `parquet:"name,optional"`
+	Description      string            `parquet:"description,optional"`
+	Format           checkpointFormat  `parquet:"format"`
+	SchemaString     string            `parquet:"schemaString"`
+	PartitionColumns []string          `parquet:"partitionColumns,list"`
+	Configuration    map[string]string `parquet:"configuration,optional"`
+	CreatedTime      int64             `parquet:"createdTime,optional"`
+}
+
+type checkpointProtocol struct {
+	MinReaderVersion int32    `parquet:"minReaderVersion"`
+	MinWriterVersion int32    `parquet:"minWriterVersion"`
+	ReaderFeatures   []string `parquet:"readerFeatures,list"`
+	WriterFeatures   []string `parquet:"writerFeatures,list"`
+}
+
+type checkpointDomainMetadata struct {
+	Domain        string `parquet:"domain"`
+	Configuration string `parquet:"configuration"`
+	Removed       bool   `parquet:"removed"`
+}
+
+type checkpointSidecar struct {
+	Path             string            `parquet:"path"`
+	SizeInBytes      int64             `parquet:"sizeInBytes"`
+	ModificationTime int64             `parquet:"modificationTime"`
+	Tags             map[string]string `parquet:"tags,optional"`
+}
+
+// action converts the checkpoint row to the action it holds, or nil if the row is empty
+func (row *checkpointRow) action() (Action, error) {
+	switch {
+	case row.Add != nil:
+		return Add{
+			Path:             row.Add.Path,
+			Size:             DeltaDataTypeLong(row.Add.Size),
+			PartitionValues:  row.Add.PartitionValues,
+			ModificationTime: DeltaDataTypeTimestamp(row.Add.ModificationTime),
+			DataChange:       row.Add.DataChange,
+			Stats:            row.Add.Stats,
+			Tags:             row.Add.Tags,
+
+			BaseRowId:               row.Add.BaseRowId,
+			DefaultRowCommitVersion: row.Add.DefaultRowCommitVersion,
+		}, nil
+	case row.Remove != nil:
+		return Remove{
+			Path:                 row.Remove.Path,
+			DeletionTimestamp:    DeltaDataTypeTimestamp(row.Remove.DeletionTimestamp),
+			DataChange:           row.Remove.DataChange,
+			ExtendedFileMetadata: row.Remove.ExtendedFileMetadata,
+			PartitionValues:      row.Remove.PartitionValues,
+			Size:                 DeltaDataTypeLong(row.Remove.Size),
+			Tags:                 row.Remove.Tags,
+		}, nil
+	case row.MetaData != nil:
+		id, err := uuid.Parse(row.MetaData.Id)
+		if err != nil {
+			return nil, err
+		}
+		return MetaData{
+			Id:               id,
+			Name:             row.MetaData.Name,
+			Description:      row.MetaData.Description,
+			Format:           Format{Provider: row.MetaData.Format.Provider, Options: row.MetaData.Format.Options},
+			SchemaString:     row.MetaData.SchemaString,
+			PartitionColumns: row.MetaData.PartitionColumns,
+			CreatedTime:      row.MetaData.CreatedTime,
+			Configuration:    row.MetaData.Configuration,
+		}, nil
+	case row.Protocol != nil:
+		return Protocol{
+			MinReaderVersion: DeltaDataTypeInt(row.Protocol.MinReaderVersion),
+			MinWriterVersion: DeltaDataTypeInt(row.Protocol.MinWriterVersion),
+			ReaderFeatures:   row.Protocol.ReaderFeatures,
+			WriterFeatures:   row.Protocol.WriterFeatures,
+		}, nil
+	case row.Txn != nil:
+		return Txn{
+			AppId:       row.Txn.AppId,
+			Version:     DeltaDataTypeVersion(row.Txn.Version),
+			LastUpdated: DeltaDataTypeTimestamp(row.Txn.LastUpdated),
+		}, nil
+	case row.DomainMetadata != nil:
+		return DomainMetadata{
+			Domain:        row.DomainMetadata.Domain,
+			Configuration: row.DomainMetadata.Configuration,
+			Removed:       row.DomainMetadata.Removed,
+		}, nil
+	case row.Sidecar != nil:
+		return Sidecar{
+			Path:             row.Sidecar.Path,
+			SizeInBytes:      DeltaDataTypeLong(row.Sidecar.SizeInBytes),
+			ModificationTime: DeltaDataTypeTimestamp(row.Sidecar.ModificationTime),
+			Tags:             row.Sidecar.Tags,
+		}, nil
+	}
+	return nil, nil
+}
+
+// newCheckpointRow converts an action to a checkpoint row, returning false for actions that are not kept in checkpoints
+func newCheckpointRow(action Action) (checkpointRow, bool) {
+	switch action := action.(type) {
+	case Add:
+		return checkpointRow{Add: &checkpointAdd{
+			Path:             action.Path,
+			PartitionValues:  action.PartitionValues,
+			Size:             int64(action.Size),
+			ModificationTime: int64(action.ModificationTime),
+			DataChange:       action.DataChange,
+			Stats:            action.Stats,
+			Tags:             action.Tags,
+
+			BaseRowId:               action.BaseRowId,
+			DefaultRowCommitVersion: action.DefaultRowCommitVersion,
+		}}, true
+	case Remove:
+		return checkpointRow{Remove: &checkpointRemove{
+			Path:                 action.Path,
+			DeletionTimestamp:    int64(action.DeletionTimestamp),
+			DataChange:           action.DataChange,
+			ExtendedFileMetadata: action.ExtendedFileMetadata,
+			PartitionValues:      action.PartitionValues,
+			Size:                 int64(action.Size),
+			Tags:                 action.Tags,
+		}}, true
+	case MetaData:
+		return checkpointRow{MetaData: &checkpointMetaData{
+			Id:               action.Id.String(),
+			Name:             action.Name,
+			Description:      action.Description,
+			Format:           checkpointFormat{Provider: action.Format.Provider, Options: action.Format.Options},
+			SchemaString:     action.SchemaString,
+			PartitionColumns: action.PartitionColumns,
+			Configuration:    action.Configuration,
+			CreatedTime:      action.CreatedTime,
+		}}, true
+	case Protocol:
+		return checkpointRow{Protocol: &checkpointProtocol{
+			MinReaderVersion: int32(action.MinReaderVersion),
+			MinWriterVersion: int32(action.MinWriterVersion),
+			ReaderFeatures:   action.ReaderFeatures,
+			WriterFeatures:   action.WriterFeatures,
+		}}, true
+	case Txn:
+		return checkpointRow{Txn: &checkpointTxn{
+			AppId:       action.AppId,
+			Version:     int64(action.Version),
+			LastUpdated: int64(action.LastUpdated),
+		}}, true
+	case DomainMetadata:
+		return checkpointRow{DomainMetadata: &checkpointDomainMetadata{
+			Domain:        action.Domain,
+			Configuration: action.Configuration,
+			Removed:       action.Removed,
+		}}, true
+	case Sidecar:
+		return checkpointRow{Sidecar: &checkpointSidecar{
+			Path:             action.Path,
+			SizeInBytes:      int64(action.SizeInBytes),
+			ModificationTime: int64(action.ModificationTime),
+			Tags:             action.Tags,
+		}}, true
+	}
+	return checkpointRow{}, false
+}
+
+// CheckpointUriFromVersion returns the uri of the single-part checkpoint of the given version
+func (table *DeltaTable) CheckpointUriFromVersion(version state.DeltaDataTypeVersion) *storage.Path {
+	str := version.String() + ".checkpoint.parquet"
+	path := storage.PathFromIter([]string{"_delta_log", str})
+	return &path
+}
+
+// CheckpointPartUriFromVersion returns the uri of one part of a multi-part checkpoint of the given version,
+// parts are numbered from 1
+func (table *DeltaTable) CheckpointPartUriFromVersion(version state.DeltaDataTypeVersion, part uint32, parts uint32) *storage.Path {
+	str := fmt.Sprintf("%s.checkpoint.%010d.%010d.parquet", version, part, parts)
+	path := storage.PathFromIter([]string{"_delta_log", str})
+	return &path
+}
+
+// SidecarUriFromPath returns the uri of a sidecar file of a V2 checkpoint from the path of its sidecar action,
+// which is relative to the _delta_log/_sidecars directory
+func (table *DeltaTable) SidecarUriFromPath(path string) *storage.Path {
+	location := storage.PathFromIter([]string{"_delta_log", SIDECAR_DIRECTORY, path})
+	return &location
+}
+
+// WriteCheckpointSidecar writes the file actions of one part of the V2 checkpoint of the given version to a sidecar
+// file, and returns the sidecar action referencing it, to be written to the checkpoint.
+// Sidecar files are named <version>.checkpoint.<part>.<parts>.<uuid>.parquet, parts being numbered from 1.
+// Returns ErrorCheckpointSidecar if an action is not an Add or a Remove.
+func (table *DeltaTable) WriteCheckpointSidecar(version state.DeltaDataTypeVersion, part uint32, parts uint32, actions []Action) (Sidecar, error) {
+	rows := make([]checkpointRow, 0, len(actions))
+	for _, action := range actions {
+		switch action.(type) {
+		case Add, Remove:
+		default:
+			return Sidecar{}, errors.Join(ErrorCheckpointSidecar, fmt.Errorf("unexpected %T action", action))
+		}
+		row, _ := newCheckpointRow(action)
+		rows = append(rows, row)
+	}
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows); err != nil {
+		return Sidecar{}, err
+	}
+
+	name := fmt.Sprintf("%s.checkpoint.%010d.%010d.%s.parquet", version, part, parts, uuid.New())
+	if err := table.Store.Put(table.SidecarUriFromPath(name), buf.Bytes()); err != nil {
+		return Sidecar{}, err
+	}
+	return Sidecar{Path: name, SizeInBytes: DeltaDataTypeLong(buf.Len()), ModificationTime: DeltaDataTypeTimestamp(time.Now().UnixMilli())}, nil
+}
+
+// checkpointParts lists the files of the checkpoint of the given version.
+// A complete multi-part checkpoint is preferred over a single-part checkpoint of the same version.
+func (table *DeltaTable) checkpointParts(version state.DeltaDataTypeVersion) ([]storage.Path, error) {
+	results, err := table.Store.List(storage.NewPath("_delta_log/" + version.String() + ".checkpoint"))
+	if err != nil {
+		return nil, errors.Join(ErrorReadingCheckpoint, err)
+	}
+	return table.checkpointPartsFrom(version, results)
+}
+
+// checkpointPartsFrom returns the paths of the checkpoint of the given version among the listed log files
+func (table *DeltaTable) checkpointPartsFrom(version state.DeltaDataTypeVersion, results []storage.ObjectMeta) ([]storage.Path, error) {
+	singlePart := false
+	partsByCount := make(map[uint32]map[uint32]bool)
+	for _, result := range results {
+		if kind, _, _ := classifyLogFile(result.Location.Raw); kind != LogFileCheckpoint {
+			continue
+		}
+		match := checkpointFileRegex.FindStringSubmatch(result.Location.Base())
+		if match[1] != version.String() {
+			continue
+		}
+		if match[2] == "" {
+			singlePart = true
+			continue
+		}
+		part, err := strconv.ParseUint(match[2][1:11], 10, 32)
+		if err != nil {
+			return nil, errors.Join(ErrorReadingCheckpoint, err)
+		}
+		parts, err := strconv.ParseUint(match[2][12:], 10, 32)
+		if err != nil {
+			return nil, errors.Join(ErrorReadingCheckpoint, err)
+		}
+		if _, ok := partsByCount[uint32(parts)]; !ok {
+			partsByCount[uint32(parts)] = make(map[uint32]bool)
+		}
+		partsByCount[uint32(parts)][uint32(part)] = true
+	}
+
+	// Checkpoints of the same version may have been written more than once with different part counts
+	var counts []uint32
+	for count := range partsByCount {
+		counts = append(counts, count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] < counts[j] })
+	for _, count := range counts {
+		if uint32(len(partsByCount[count])) != count {
+			continue
+		}
+		paths := make([]storage.Path, 0, count)
+		for part := uint32(1); part <= count; part++ {
+			paths = append(paths, *table.CheckpointPartUriFromVersion(version, part, count))
+		}
+		return paths, nil
+	}
+	if singlePart {
+		return []storage.Path{*table.CheckpointUriFromVersion(version)}, nil
+	}
+	if len(counts) > 0 {
+		return nil, errors.Join(ErrorCheckpointIncomplete, fmt.Errorf("version %d", version))
+	}
+	return nil, errors.Join(ErrorReadingCheckpoint, fmt.Errorf("no checkpoint for version %d", version))
+}
+
+// readCheckpoint reads the actions stored in the checkpoint of the given version
+func (table *DeltaTable) readCheckpoint(version state.DeltaDataTypeVersion) ([]Action, CheckPoint, error) {
+	paths, err := table.checkpointParts(version)
+	if err != nil {
+		return nil, CheckPoint{}, err
+	}
+	return table.readCheckpointFiles(version, paths)
+}
+
+// readCheckpointFiles reads the actions stored in the parts of the checkpoint of the given version, and in the
+// sidecar files the parts reference.
+// The version of the checkpointMetadata of a V2 checkpoint must be the version of the checkpoint, and
+// ErrorCheckpointIncomplete is returned if a sidecar file is missing or does not have the size of its sidecar action.
+func (table *DeltaTable) readCheckpointFiles(version state.DeltaDataTypeVersion, paths []storage.Path) ([]Action, CheckPoint, error) {
+	var actions []Action
+	for i := range paths {
+		data, err := table.Store.Get(&paths[i])
+		if err != nil {
+			return nil, CheckPoint{}, errors.Join(ErrorReadingCheckpoint, err)
+		}
+		partActions, err := parseCheckpointFile(&paths[i], data)
+		if err != nil {
+			return nil, CheckPoint{}, err
+		}
+		metadataVersion, ok, err := readCheckpointMetadataVersion(data)
+		if err != nil {
+			return nil, CheckPoint{}, errors.Join(ErrorReadingCheckpoint, fmt.Errorf("%s: %w", paths[i].Raw, err))
+		}
+		if ok && metadataVersion != version {
+			return nil, CheckPoint{}, errors.Join(ErrorReadingCheckpoint, fmt.Errorf("%s: checkpointMetadata of version %d", paths[i].Raw, metadataVersion))
+		}
+		for _, action := range partActions {
+			sidecar, ok := action.(Sidecar)
+			if !ok {
+				actions = append(actions, action)
+				continue
+			}
+			if strings.Contains(sidecar.Path, "/") {
+				return nil, CheckPoint{}, errors.Join(ErrorReadingCheckpoint, fmt.Errorf("%s: unsupported sidecar path %s", paths[i].Raw, sidecar.Path))
+			}
+			sidecarActions, err := table.readSidecar(sidecar)
+			if err != nil {
+				return nil, CheckPoint{}, err
+			}
+			actions = append(actions, sidecarActions...)
+		}
+	}
+
+	checkpoint := CheckPoint{Version: version, Size: DeltaDataTypeLong(len(actions))}
+	if len(paths) > 1 {
+		checkpoint.Parts = uint32(len(paths))
+	}
+	return actions, checkpoint, nil
+}
+
+// readSidecar reads the actions stored in the sidecar file of a V2 checkpoint.
+// Returns ErrorCheckpointIncomplete if the file is missing or does not have the size of the sidecar action.
+func (table *DeltaTable) readSidecar(sidecar Sidecar) ([]Action, error) {
+	path := table.SidecarUriFromPath(sidecar.Path)
+	data, err := table.Store.Get(path)
+	if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		return nil, errors.Join(ErrorCheckpointIncomplete, fmt.Errorf("missing sidecar %s", sidecar.Path))
+	}
+	if err != nil {
+		return nil, errors.Join(ErrorReadingCheckpoint, err)
+	}
+	if sidecar.SizeInBytes > 0 && int64(len(data)) != int64(sidecar.SizeInBytes) {
+		return nil, errors.Join(ErrorCheckpointIncomplete, fmt.Errorf("sidecar %s has %d bytes, want %d", sidecar.Path, len(data), sidecar.SizeInBytes))
+	}
+	return parseCheckpointFile(path, data)
+}
+
+// parseCheckpointFile returns the actions stored in the data of a checkpoint part or sidecar file
+func parseCheckpointFile(path *storage.Path, data []byte) ([]Action, error) {
+	rows, err := parquet.Read[checkpointRow](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Join(ErrorReadingCheckpoint, fmt.Errorf("%s: %w", path.Raw, err))
+	}
+	parsedStats, err := readParsedStats(data)
+	if err != nil {
+		return nil, errors.Join(ErrorReadingCheckpoint, fmt.Errorf("%s: %w", path.Raw, err))
+	}
+	var actions []Action
+	for j := range rows {
+		action, err := rows[j].action()
+		if err != nil {
+			return nil, errors.Join(ErrorReadingCheckpoint, fmt.Errorf("%s: %w", path.Raw, err))
+		}
+		if add, ok := action.(Add); ok && j < len(parsedStats) && parsedStats[j] != nil {
+			add.statsParsed = parsedStats[j]
+			// Keep the statistics when the add action is written back to the log
+			if add.Stats == "" {
+				add.Stats = string(parsedStats[j].Json())
+			}
+			action = add
+		}
+		if action != nil {
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// readCheckpointMetadataVersion returns the version of the checkpointMetadata action of a V2 checkpoint file, or false
+// if the file does not have one
+func readCheckpointMetadataVersion(data []byte) (state.DeltaDataTypeVersion, bool, error) {
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return 0, false, err
+	}
+	var metadataNode parquet.Node
+	for _, field := range file.Schema().Fields() {
+		if field.Name() == "checkpointMetadata" {
+			metadataNode = field
+		}
+	}
+	if metadataNode == nil {
+		return 0, false, nil
+	}
+
+	schema := parquet.NewSchema("checkpoint", parquet.Group{"checkpointMetadata": metadataNode})
+	reader := parquet.NewGenericReader[any](file, schema)
+	defer reader.Close()
+	rows := make([]any, 1024)
+	for {
+		n, err := reader.Read(rows)
+		for _, row := range rows[:n] {
+			fields, _ := row.(map[string]any)
+			metadata, _ := fields["checkpointMetadata"].(map[string]any)
+			if version, ok := metadata["version"].(int64); ok {
+				return state.DeltaDataTypeVersion(version), true, nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	}
+}
+
+// readParsedStats reads the add.stats_parsed column of a checkpoint file, returning the statistics of each row,
+// nil for the rows without statistics, or no statistics at all if the checkpoint does not have the column
+func readParsedStats(data []byte) ([]*Stats, error) {
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var statsNode parquet.Node
+	for _, field := range file.Schema().Fields() {
+		if field.Name() != "add" {
+			continue
+		}
+		for _, addField := range field.Fields() {
+			if addField.Name() == "stats_parsed" {
+				statsNode = addField
+			}
+		}
+	}
+	if statsNode == nil {
+		return nil, nil
+	}
+
+	// Only the stats_parsed column is read, its schema depends on the columns of the table
+	schema := parquet.NewSchema("checkpoint", parquet.Group{"add": parquet.Optional(parquet.Group{"stats_parsed": statsNode})})
+	reader := parquet.NewGenericReader[any](file, schema)
+	defer reader.Close()
+
+	parsedStats := make([]*Stats, 0, file.NumRows())
+	rows := make([]any, 1024)
+	for {
+		n, err := reader.Read(rows)
+		for _, row := range rows[:n] {
+			fields, _ := row.(map[string]any)
+			add, _ := fields["add"].(map[string]any)
+			statsParsed, ok := add["stats_parsed"].(map[string]any)
+			if !ok {
+				parsedStats = append(parsedStats, nil)
+				continue
+			}
+			parsedStats = append(parsedStats, statsFromParsed(statsNode, statsParsed))
+		}
+		if errors.Is(err, io.EOF) {
+			return parsedStats, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// statsFromParsed converts a stats_parsed struct with the given schema to Stats, with the values converted to
+// the types they have when the JSON stats are parsed
+func statsFromParsed(node parquet.Node, statsParsed map[string]any) *Stats {
+	stats := &Stats{TightBounds: true}
+	if numRecords, ok := parsedStatsValue(fieldNode(node, "numRecords"), statsParsed["numRecords"]).(json.Number); ok {
+		stats.NumRecords, _ = numRecords.Int64()
+	}
+	if tightBounds, ok := statsParsed["tightBounds"].(bool); ok {
+		stats.TightBounds = tightBounds
+	}
+	if minValues, ok := statsParsed["minValues"].(map[string]any); ok {
+		stats.MinValues = parsedStatsValues(fieldNode(node, "minValues"), minValues)
+	}
+	if maxValues, ok := statsParsed["maxValues"].(map[string]any); ok {
+		stats.MaxValues = parsedStatsValues(fieldNode(node, "maxValues"), maxValues)
+	}
+	if nullCount, ok := statsParsed["nullCount"].(map[string]any); ok {
+		stats.NullCount = make(map[string]int64)
+		var flatten func(counts map[string]any, prefix string)
+		flatten = func(counts map[string]any, prefix string) {
+			for name, value := range counts {
+				if nested, ok := value.(map[string]any); ok {
+					flatten(nested, prefix+name+".")
+					continue
+				}
+				number, ok := parsedStatsValue(nil, value).(json.Number)
+				if !ok {
+					continue
+				}
+				count, err := number.Int64()
+				if err != nil {
+					continue
+				}
+				if prefix == "" {
+					stats.NullCount[name] = count
+				} else {
+					if stats.NestedNullCount == nil {
+						stats.NestedNullCount = make(map[string]int64)
+					}
+					stats.NestedNullCount[prefix+name] = count
+				}
+			}
+		}
+		flatten(nullCount, "")
+	}
+	return stats
+}
+
+// fieldNode returns the field of a group node with the given name, or nil if there is no such field
+func fieldNode(node parquet.Node, name string) parquet.Node {
+	if node == nil || node.Leaf() {
+		return nil
+	}
+	for _, field := range node.Fields() {
+		if field.Name() == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// parsedStatsValues converts the min or max values of a stats_parsed struct, dropping null values
+func parsedStatsValues(node parquet.Node, values map[string]any) map[string]any {
+	converted := make(map[string]any, len(values))
+	for name, value := range values {
+		if value = parsedStatsValue(fieldNode(node, name), value); value != nil {
+			converted[name] = value
+		}
+	}
+	return converted
+}
+
+// parsedStatsValue converts a parquet value to the type it has in parsed JSON stats:
+// integers become json.Number, floating point numbers float64, binary values strings, and dates and timestamps strings
+func parsedStatsValue(node parquet.Node, value any) any {
+	if values, ok := value.(map[string]any); ok {
+		return parsedStatsValues(node, values)
+	}
+	if node != nil {
+		if logicalType := node.Type().LogicalType(); logicalType != nil {
+			switch {
+			case logicalType.Date != nil:
+				if days, ok := value.(int32); ok {
+					return time.Unix(int64(days)*24*60*60, 0).UTC().Format("2006-01-02")
+				}
+			case logicalType.Timestamp != nil:
+				if timestamp, ok := value.(int64); ok {
+					var t time.Time
+					switch {
+					case logicalType.Timestamp.Unit.Micros != nil:
+						t = time.UnixMicro(timestamp)
+					case logicalType.Timestamp.Unit.Nanos != nil:
+						t = time.Unix(0, timestamp)
+					default:
+						t = time.UnixMilli(timestamp)
+					}
+					return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+				}
+			}
+		}
+	}
+	switch value := value.(type) {
+	case int32:
+		return json.Number(strconv.FormatInt(int64(value), 10))
+	case int64:
+		return json.Number(strconv.FormatInt(value, 10))
+	case uint32:
+		return json.Number(strconv.FormatUint(uint64(value), 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(value, 10))
+	case float32:
+		return float64(value)
+	case []byte:
+		return string(value)
+	case time.Time:
+		return value.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	}
+	return value
+}
+
+// loadLatestCheckpoint loads the state of the latest checkpoint at or below targetVersion that can be read,
+// trying the checkpoint referenced by _last_checkpoint before listing the log.
+// Returns false if there is no such checkpoint.
+func (table *DeltaTable) loadLatestCheckpoint(targetVersion state.DeltaDataTypeVersion) (*DeltaTableState, CheckPoint, bool, error) {
+	tried := make(map[state.DeltaDataTypeVersion]bool)
+	load := func(version state.DeltaDataTypeVersion) (*DeltaTableState, CheckPoint, bool) {
+		tried[version] = true
+		actions, checkpoint, err := table.readCheckpoint(version)
+		if err == nil {
+			tableState := NewDeltaTableState(version)
+			if err = tableState.applyActions(actions); err == nil {
+				return tableState, checkpoint, true
+			}
+		}
+		log.Debugf("delta-go: unable to load checkpoint version %d, falling back to an older version: %v", version, err)
+		return nil, CheckPoint{}, false
+	}
+
+	lastCheckpoint, ok, err := table.readLastCheckpoint()
+	if err != nil {
+		log.Debugf("delta-go: unable to read %s, listing the checkpoints: %v", LAST_CHECKPOINT_FILE, err)
+	}
+	if ok && lastCheckpoint.Version <= targetVersion {
+		if tableState, checkpoint, ok := load(lastCheckpoint.Version); ok {
+			return tableState, checkpoint, true, nil
+		}
+	}
+
+	logFiles, err := table.Store.List(table.BaseCommitUri())
+	if err != nil {
+		return nil, CheckPoint{}, false, err
+	}
+	var versions []state.DeltaDataTypeVersion
+	for _, meta := range logFiles {
+		kind, version, err := classifyLogFile(meta.Location.Raw)
+		if err != nil {
+			return nil, CheckPoint{}, false, err
+		}
+		if kind == LogFileCheckpoint && version <= targetVersion && !tried[version] {
+			tried[version] = true
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	for _, version := range versions {
+		if tableState, checkpoint, ok := load(version); ok {
+			return tableState, checkpoint, true, nil
+		}
+	}
+	return nil, CheckPoint{}, false, nil
+}
+
+// OpenFromCheckpoint loads the table from the checkpoint of the given version, ignoring the _last_checkpoint
+// pointer, and then replays the commits following the checkpoint up to the latest version.
+func OpenFromCheckpoint(store storage.ObjectStore, lock lock.Locker, stateStore state.StateStore, checkpointVersion state.DeltaDataTypeVersion) (*DeltaTable, error) {
+	table := NewDeltaTable(store, lock, stateStore)
+	err := table.LoadFromCheckpoint(checkpointVersion)
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// LoadFromCheckpoint loads the table state from the checkpoint of the given version, followed by the
+// commits after it up to the latest version.
+func (table *DeltaTable) LoadFromCheckpoint(checkpointVersion state.DeltaDataTypeVersion) error {
+	actions, checkpoint, err := table.readCheckpoint(checkpointVersion)
+	if err != nil {
+		return err
+	}
+	commits, compactions, err := table.listLogFiles()
+	if err != nil {
+		return err
+	}
+
+	tableState := NewDeltaTableState(checkpointVersion)
+	if err := tableState.applyActions(actions); err != nil {
+		return fmt.Errorf("checkpoint version %d: %w", checkpointVersion, err)
+	}
+	targetVersion := checkpointVersion
+	for v := range commits {
+		targetVersion = max(targetVersion, v)
+	}
+	err = table.replayLog(tableState, checkpointVersion.Next(), targetVersion, compactions)
+	if err != nil {
+		return err
+	}
+	table.State = *tableState
+	table.LastCheckPoint = checkpoint
+	return nil
+}
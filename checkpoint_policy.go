@@ -0,0 +1,123 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/rivian/delta-go/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// The number of commits between checkpoints when delta.checkpointInterval is not set
+const DEFAULT_CHECKPOINT_INTERVAL = 10
+
+// CheckpointReason is the threshold of a CheckpointPolicy that makes a checkpoint due
+type CheckpointReason string
+
+const (
+	// The number of commits since the last checkpoint reached the interval
+	CheckpointReasonInterval CheckpointReason = "interval"
+	// The size of the commit files since the last checkpoint reached the byte interval
+	CheckpointReasonSize CheckpointReason = "size"
+)
+
+// CheckpointPolicy sets when a checkpoint is due: after a number of commits, or after a cumulative size of commit
+// files, whichever is reached first
+type CheckpointPolicy struct {
+	// The number of commits between checkpoints, or 0 for the delta.checkpointInterval of the table
+	Interval int64
+	// The size in bytes of the commit files between checkpoints, or 0 to only checkpoint every Interval commits
+	IntervalBytes int64
+}
+
+// NewCheckpointPolicy sets the default values of the checkpoint policy: the delta.checkpointInterval of the table,
+// without a size threshold
+func NewCheckpointPolicy() *CheckpointPolicy {
+	return &CheckpointPolicy{}
+}
+
+// CheckpointInterval returns the number of commits between checkpoints, DEFAULT_CHECKPOINT_INTERVAL by default
+func (dtmd *DeltaTableMetaData) CheckpointInterval() (int64, error) {
+	value, ok := dtmd.Configuration[CHECKPOINT_INTERVAL_PROPERTY]
+	if !ok {
+		return DEFAULT_CHECKPOINT_INTERVAL, nil
+	}
+	interval, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || interval < 1 {
+		return 0, errors.Join(ErrorInvalidTableProperty, fmt.Errorf("%s=%s", CHECKPOINT_INTERVAL_PROPERTY, value))
+	}
+	return interval, nil
+}
+
+// CheckpointDue returns whether the log needs a new checkpoint under the policy, and the threshold that was reached.
+// The commits listed after the latest checkpoint are counted, or all the commits if the table has none.
+// When both thresholds are reached, the reason is CheckpointReasonInterval.
+// The table metadata must be loaded unless the policy sets Interval. A nil policy uses NewCheckpointPolicy.
+func (table *DeltaTable) CheckpointDue(policy *CheckpointPolicy) (CheckpointReason, bool, error) {
+	if policy == nil {
+		policy = NewCheckpointPolicy()
+	}
+	interval := policy.Interval
+	if interval <= 0 {
+		var err error
+		interval, err = table.State.CurrentMetadata.CheckpointInterval()
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	logFiles, err := table.Store.List(table.BaseCommitUri())
+	if err != nil {
+		return "", false, err
+	}
+	checkpointVersion, ok, err := table.latestCheckpointVersion(logFiles)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok {
+		checkpointVersion = -1
+	}
+
+	var commits, size int64
+	var latestVersion state.DeltaDataTypeVersion
+	for _, meta := range logFiles {
+		kind, version, err := classifyLogFile(meta.Location.Raw)
+		if err != nil {
+			return "", false, err
+		}
+		if kind != LogFileCommit {
+			continue
+		}
+		if version.After(checkpointVersion) {
+			commits++
+			size += meta.Size
+			latestVersion = max(latestVersion, version)
+		}
+	}
+
+	var reason CheckpointReason
+	switch {
+	case commits >= interval:
+		reason = CheckpointReasonInterval
+	case policy.IntervalBytes > 0 && size >= policy.IntervalBytes:
+		reason = CheckpointReasonSize
+	default:
+		return "", false, nil
+	}
+	log.Infof("delta-go: checkpoint due at version %d by %s: %d commits of %d bytes since the last checkpoint (interval %d commits, %d bytes)",
+		latestVersion, reason, commits, size, interval, policy.IntervalBytes)
+	return reason, true, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckpointInterval(t *testing.T) {
+	metadata := DeltaTableMetaData{Configuration: map[string]string{}}
+	if interval, err := metadata.CheckpointInterval(); err != nil || interval != DEFAULT_CHECKPOINT_INTERVAL {
+		t.Errorf("want the default interval, has %d %v", interval, err)
+	}
+	metadata.Configuration[CHECKPOINT_INTERVAL_PROPERTY] = "3"
+	if interval, err := metadata.CheckpointInterval(); err != nil || interval != 3 {
+		t.Errorf("want an interval of 3, has %d %v", interval, err)
+	}
+	metadata.Configuration[CHECKPOINT_INTERVAL_PROPERTY] = "0"
+	if _, err := metadata.CheckpointInterval(); !errors.Is(err, ErrorInvalidTableProperty) {
+		t.Errorf("want ErrorInvalidTableProperty, has %v", err)
+	}
+}
+
+func TestCheckpointDue(t *testing.T) {
+	// Commit 4 follows the latest checkpoint, of version 3
+	table, _ := setupLogCleanupTable(t, map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "2"})
+	meta, err := table.Store.Head(table.CommitUriFromVersion(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		policy *CheckpointPolicy
+		due    bool
+		reason CheckpointReason
+	}{
+		{nil, false, ""},
+		{&CheckpointPolicy{IntervalBytes: meta.Size + 1}, false, ""},
+		{&CheckpointPolicy{IntervalBytes: meta.Size}, true, CheckpointReasonSize},
+		{&CheckpointPolicy{Interval: 1}, true, CheckpointReasonInterval},
+		{&CheckpointPolicy{Interval: 1, IntervalBytes: meta.Size}, true, CheckpointReasonInterval},
+	} {
+		reason, due, err := table.CheckpointDue(test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if due != test.due || reason != test.reason {
+			t.Errorf("%+v: want %t %q, has %t %q", test.policy, test.due, test.reason, due, reason)
+		}
+	}
+
+	// Without a checkpoint, all the commits count
+	table, _, _ = setupTest(t)
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), SchemaTypeStruct{}, []string{}, map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "2"})
+	if err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, due, err := table.CheckpointDue(nil); err != nil || due {
+		t.Errorf("want no checkpoint due after one commit, has %t %v", due, err)
+	}
+	transaction, operation, appMetaData := setupTransaction(t, table, NewDeltaTransactionOptions())
+	if _, err := transaction.Commit(operation, appMetaData); err != nil {
+		t.Fatal(err)
+	}
+	if reason, due, err := table.CheckpointDue(nil); err != nil || !due || reason != CheckpointReasonInterval {
+		t.Errorf("want a checkpoint due by interval after two commits, has %t %q %v", due, reason, err)
+	}
+}
@@ -0,0 +1,607 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rivian/delta-go/state"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+)
+
+// Helper function to write checkpoint rows to the given path of the table
+func writeTestCheckpoint(t *testing.T, table *DeltaTable, path *storage.Path, rows []checkpointRow) {
+	t.Helper()
+	var buf bytes.Buffer
+	err := parquet.Write(&buf, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Store.Put(path, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Helper function to set up a table with 3 commits, where the checkpoint of version 1 has the state of commits 0 and 1
+func setupCheckpointTable(t *testing.T) (*DeltaTable, []checkpointRow) {
+	t.Helper()
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: String}}}
+	metadata := NewDeltaTableMetaData("Test Table", "", new(Format).Default(), schema, []string{"date"}, map[string]string{"delta.appendOnly": "true"})
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{{Path: "date=2023-01-01/part-0.snappy.parquet", Size: 1, PartitionValues: map[string]string{"date": "2023-01-01"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"} {
+		transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+		transaction.AddAction(Add{Path: path, Size: 2, PartitionValues: map[string]string{"date": path[5:15]}, DataChange: true})
+		_, err = transaction.Commit(Write{Mode: Append}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemaString := schema.Json()
+	rows := []checkpointRow{
+		{Protocol: &checkpointProtocol{MinReaderVersion: 1, MinWriterVersion: 2}},
+		{MetaData: &checkpointMetaData{
+			Id:               metadata.Id.String(),
+			Name:             metadata.Name,
+			Format:           checkpointFormat{Provider: "parquet"},
+			SchemaString:     string(schemaString),
+			PartitionColumns: []string{"date"},
+			Configuration:    map[string]string{"delta.appendOnly": "true"},
+		}},
+		{Txn: &checkpointTxn{AppId: "stream", Version: 4}},
+		{Add: &checkpointAdd{Path: "date=2023-01-01/part-0.snappy.parquet", Size: 1, PartitionValues: map[string]string{"date": "2023-01-01"}}},
+		{Add: &checkpointAdd{Path: "date=2023-01-02/part-1.snappy.parquet", Size: 2, PartitionValues: map[string]string{"date": "2023-01-02"}}},
+		{Remove: &checkpointRemove{Path: "date=2022-12-31/part-old.snappy.parquet", DeletionTimestamp: 1675020556534}},
+	}
+	return table, rows
+}
+
+// coordinationCounter is a lock and a state store counting their calls
+type coordinationCounter struct {
+	calls int
+}
+
+func (c *coordinationCounter) TryLock() (bool, error) {
+	c.calls++
+	return true, nil
+}
+
+func (c *coordinationCounter) Unlock() error {
+	c.calls++
+	return nil
+}
+
+func (c *coordinationCounter) AutoRenew(ctx context.Context) <-chan error {
+	c.calls++
+	return nil
+}
+
+func (c *coordinationCounter) Get() (state.CommitState, error) {
+	c.calls++
+	return state.CommitState{Version: 7}, nil
+}
+
+func (c *coordinationCounter) Put(commitState state.CommitState) error {
+	c.calls++
+	return nil
+}
+
+func TestReadsDoNotCoordinate(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(1), rows)
+	table.Store.Put(storage.NewPath("_delta_log/_last_checkpoint"), []byte(`{"version":1,"size":6}`))
+
+	counter := new(coordinationCounter)
+	readTable, err := OpenTable(table.Store, counter, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readTable.State.Version != 2 || readTable.LastCheckPoint.Version != 1 {
+		t.Errorf("want version 2 from checkpoint 1, has %d from %+v", readTable.State.Version, readTable.LastCheckPoint)
+	}
+	version := state.DeltaDataTypeVersion(1)
+	if err := readTable.LoadVersion(&version); err != nil {
+		t.Fatal(err)
+	}
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "date=2023-01-04/part-3.snappy.parquet", Size: 2, PartitionValues: map[string]string{"date": "2023-01-04"}, DataChange: true})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := readTable.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if readTable.State.Version != 3 {
+		t.Errorf("want version 3, has %d", readTable.State.Version)
+	}
+	if counter.calls != 0 {
+		t.Errorf("reads should not use the lock or the state store, has %d calls", counter.calls)
+	}
+}
+
+func TestOpenFromCheckpoint(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(1), rows)
+	// A wrong _last_checkpoint pointer is ignored
+	table.Store.Put(storage.NewPath("_delta_log/_last_checkpoint"), []byte(`{"version":7,"size":1}`))
+
+	checkpointTable, err := OpenFromCheckpoint(table.Store, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpointTable.State.Version != 2 {
+		t.Errorf("want version 2, has %d", checkpointTable.State.Version)
+	}
+	if checkpointTable.LastCheckPoint.Version != 1 || checkpointTable.LastCheckPoint.Size != DeltaDataTypeLong(len(rows)) {
+		t.Errorf("want checkpoint of version 1 with %d actions, has %+v", len(rows), checkpointTable.LastCheckPoint)
+	}
+	assertActiveFiles(t, checkpointTable, []string{"date=2023-01-01/part-0.snappy.parquet", "date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"})
+	if _, ok := checkpointTable.State.Tombstones["date=2022-12-31/part-old.snappy.parquet"]; !ok {
+		t.Error("tombstone of the checkpoint should be loaded")
+	}
+	if checkpointTable.State.AppTransactionVersion["stream"] != 4 {
+		t.Errorf("want app transaction version 4, has %d", checkpointTable.State.AppTransactionVersion["stream"])
+	}
+	metadata := checkpointTable.State.CurrentMetadata
+	if metadata.Name != "Test Table" || len(metadata.Schema.Fields) != 2 || metadata.PartitionColumns[0] != "date" || metadata.Configuration["delta.appendOnly"] != "true" {
+		t.Errorf("metadata of the checkpoint is not loaded, has %+v", metadata)
+	}
+	if checkpointTable.State.MinWriterVersion != 2 {
+		t.Errorf("want min writer version 2, has %d", checkpointTable.State.MinWriterVersion)
+	}
+}
+
+func TestLoadFromLatestCheckpoint(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(1), rows)
+	// An unreadable checkpoint and a wrong _last_checkpoint pointer fall back to the latest readable checkpoint
+	table.Store.Put(table.CheckpointUriFromVersion(2), []byte("checkpoint"))
+	table.Store.Put(storage.NewPath("_delta_log/_last_checkpoint"), []byte(`{"version":7,"size":1}`))
+
+	loaded, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.State.Version != 2 || loaded.LastCheckPoint.Version != 1 {
+		t.Errorf("want version 2 from checkpoint 1, has %d from %d", loaded.State.Version, loaded.LastCheckPoint.Version)
+	}
+	// The app transaction is only in the checkpoint
+	if loaded.State.AppTransactionVersion["stream"] != 4 {
+		t.Errorf("want app transaction version 4, has %d", loaded.State.AppTransactionVersion["stream"])
+	}
+	assertActiveFiles(t, loaded, []string{"date=2023-01-01/part-0.snappy.parquet", "date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"})
+
+	// Versions before the checkpoint are replayed from the first commit
+	version := state.DeltaDataTypeVersion(0)
+	err = loaded.LoadVersion(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.State.AppTransactionVersion["stream"]; ok || loaded.State.Version != 0 {
+		t.Errorf("version 0 should not be loaded from the checkpoint, has %+v", loaded.State)
+	}
+	assertActiveFiles(t, loaded, []string{"date=2023-01-01/part-0.snappy.parquet"})
+}
+
+func TestOpenFromMultiPartCheckpoint(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+	writeTestCheckpoint(t, table, table.CheckpointPartUriFromVersion(1, 1, 2), rows[:3])
+	writeTestCheckpoint(t, table, table.CheckpointPartUriFromVersion(1, 2, 2), rows[3:])
+	// An incomplete multi-part checkpoint with a different part count is skipped
+	writeTestCheckpoint(t, table, table.CheckpointPartUriFromVersion(1, 1, 3), rows[:1])
+
+	checkpointTable, err := OpenFromCheckpoint(table.Store, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpointTable.LastCheckPoint.Parts != 2 {
+		t.Errorf("want 2 parts, has %d", checkpointTable.LastCheckPoint.Parts)
+	}
+	assertActiveFiles(t, checkpointTable, []string{"date=2023-01-01/part-0.snappy.parquet", "date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"})
+}
+
+func TestOpenFromCheckpointWithSidecars(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+	checkpointRows := append([]checkpointRow{}, rows[:3]...)
+	for i, fileRows := range [][]checkpointRow{rows[3:5], rows[5:]} {
+		var actions []Action
+		for _, row := range fileRows {
+			action, err := row.action()
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions = append(actions, action)
+		}
+		sidecar, err := table.WriteCheckpointSidecar(1, uint32(i+1), 2, actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row, _ := newCheckpointRow(sidecar)
+		checkpointRows = append(checkpointRows, row)
+	}
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(1), checkpointRows)
+	table.Store.Put(storage.NewPath("_delta_log/_last_checkpoint"), []byte(`{"version":1,"size":5}`))
+
+	// The sidecar files are neither commits nor checkpoints
+	logFiles, err := table.LogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecars := 0
+	for _, logFile := range logFiles {
+		if logFile.Kind == LogFileSidecar {
+			sidecars++
+			if !strings.HasPrefix(logFile.Meta.Location.Raw, "_delta_log/_sidecars/00000000000000000001.checkpoint.") {
+				t.Errorf("unexpected sidecar location %s", logFile.Meta.Location.Raw)
+			}
+		}
+	}
+	if sidecars != 2 {
+		t.Errorf("want 2 sidecar files, has %d in %+v", sidecars, logFiles)
+	}
+
+	loaded, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.State.Version != 2 || loaded.LastCheckPoint.Version != 1 || loaded.LastCheckPoint.Size != DeltaDataTypeLong(len(rows)) {
+		t.Errorf("want version 2 from checkpoint 1 with %d actions, has %d from %+v", len(rows), loaded.State.Version, loaded.LastCheckPoint)
+	}
+	assertActiveFiles(t, loaded, []string{"date=2023-01-01/part-0.snappy.parquet", "date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"})
+	if _, ok := loaded.State.Tombstones["date=2022-12-31/part-old.snappy.parquet"]; !ok {
+		t.Error("tombstone of the sidecar should be loaded")
+	}
+
+	if _, err := table.WriteCheckpointSidecar(1, 1, 1, []Action{Txn{AppId: "stream"}}); !errors.Is(err, ErrorCheckpointSidecar) {
+		t.Errorf("want ErrorCheckpointSidecar, has %v", err)
+	}
+	// Sidecar paths are relative to the sidecar directory
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(1), append(checkpointRows[:3], checkpointRow{Sidecar: &checkpointSidecar{Path: "s3://bucket/sidecar.parquet"}}))
+	if _, err := OpenFromCheckpoint(table.Store, nil, nil, 1); !errors.Is(err, ErrorReadingCheckpoint) {
+		t.Errorf("want ErrorReadingCheckpoint, has %v", err)
+	}
+}
+
+// v2CheckpointRow is a row of a V2 checkpoint with its checkpointMetadata action
+type v2CheckpointRow struct {
+	Protocol           *checkpointProtocol `parquet:"protocol,optional"`
+	MetaData           *checkpointMetaData `parquet:"metaData,optional"`
+	Sidecar            *checkpointSidecar  `parquet:"sidecar,optional"`
+	CheckpointMetadata *struct {
+		Version int64             `parquet:"version"`
+		Tags    map[string]string `parquet:"tags,optional"`
+	} `parquet:"checkpointMetadata,optional"`
+}
+
+func TestOpenFromV2CheckpointIncomplete(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+	var sidecars []Sidecar
+	for i, fileRows := range [][]checkpointRow{rows[3:5], rows[5:]} {
+		var actions []Action
+		for _, row := range fileRows {
+			action, err := row.action()
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions = append(actions, action)
+		}
+		sidecar, err := table.WriteCheckpointSidecar(1, uint32(i+1), 2, actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sidecars = append(sidecars, sidecar)
+	}
+	writeCheckpoint := func(version int64, sidecars []Sidecar) {
+		t.Helper()
+		checkpointRows := []v2CheckpointRow{{Protocol: rows[0].Protocol}, {MetaData: rows[1].MetaData}}
+		checkpointRows = append(checkpointRows, v2CheckpointRow{CheckpointMetadata: &struct {
+			Version int64             `parquet:"version"`
+			Tags    map[string]string `parquet:"tags,optional"`
+		}{Version: version}})
+		for _, sidecar := range sidecars {
+			row, _ := newCheckpointRow(sidecar)
+			checkpointRows = append(checkpointRows, v2CheckpointRow{Sidecar: row.Sidecar})
+		}
+		var buf bytes.Buffer
+		if err := parquet.Write(&buf, checkpointRows); err != nil {
+			t.Fatal(err)
+		}
+		if err := table.Store.Put(table.CheckpointUriFromVersion(1), buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeCheckpoint(1, sidecars)
+	checkpointTable, err := OpenFromCheckpoint(table.Store, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpointTable.LastCheckPoint.Size != 5 {
+		t.Errorf("want a checkpoint of 5 actions, has %+v", checkpointTable.LastCheckPoint)
+	}
+	assertActiveFiles(t, checkpointTable, []string{"date=2023-01-01/part-0.snappy.parquet", "date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"})
+
+	// The checkpointMetadata must have the version of the checkpoint
+	writeCheckpoint(2, sidecars)
+	if _, err := OpenFromCheckpoint(table.Store, nil, nil, 1); !errors.Is(err, ErrorReadingCheckpoint) {
+		t.Errorf("want ErrorReadingCheckpoint, has %v", err)
+	}
+
+	// Sidecar files must exist and have the size of their sidecar action
+	truncated := append([]Sidecar{}, sidecars...)
+	truncated[1].SizeInBytes++
+	writeCheckpoint(1, truncated)
+	if _, err := OpenFromCheckpoint(table.Store, nil, nil, 1); !errors.Is(err, ErrorCheckpointIncomplete) {
+		t.Errorf("want ErrorCheckpointIncomplete, has %v", err)
+	}
+	writeCheckpoint(1, sidecars)
+	if err := table.Store.Delete(table.SidecarUriFromPath(sidecars[0].Path)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFromCheckpoint(table.Store, nil, nil, 1); !errors.Is(err, ErrorCheckpointIncomplete) {
+		t.Errorf("want ErrorCheckpointIncomplete, has %v", err)
+	}
+
+	// The table is loaded from the commits instead of the incomplete checkpoint
+	loaded, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveFiles(t, loaded, []string{"date=2023-01-01/part-0.snappy.parquet", "date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"})
+}
+
+func TestOpenFromCheckpointErrors(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+
+	_, err := OpenFromCheckpoint(table.Store, nil, nil, 1)
+	if !errors.Is(err, ErrorReadingCheckpoint) {
+		t.Errorf("want ErrorReadingCheckpoint, has %v", err)
+	}
+
+	writeTestCheckpoint(t, table, table.CheckpointPartUriFromVersion(1, 1, 2), rows)
+	_, err = OpenFromCheckpoint(table.Store, nil, nil, 1)
+	if !errors.Is(err, ErrorCheckpointIncomplete) {
+		t.Errorf("want ErrorCheckpointIncomplete, has %v", err)
+	}
+
+	table.Store.Put(table.CheckpointUriFromVersion(2), []byte("not parquet"))
+	_, err = OpenFromCheckpoint(table.Store, nil, nil, 2)
+	if !errors.Is(err, ErrorReadingCheckpoint) {
+		t.Errorf("want ErrorReadingCheckpoint, has %v", err)
+	}
+
+	rows[1].MetaData.Id = "not a uuid"
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(1), rows)
+	_, err = OpenFromCheckpoint(table.Store, nil, nil, 1)
+	if !errors.Is(err, ErrorReadingCheckpoint) {
+		t.Errorf("want ErrorReadingCheckpoint, has %v", err)
+	}
+}
+
+func TestDomainMetadata(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(DomainMetadata{Domain: "delta.clustering", Configuration: `{"clusteringColumns":["id"]}`})
+	transaction.AddAction(DomainMetadata{Domain: "com.example.custom", Configuration: "opaque"})
+	transaction.AddAction(DomainMetadata{Domain: "com.example.dropped", Configuration: "{}"})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction = table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(DomainMetadata{Domain: "com.example.dropped", Configuration: "{}", Removed: true})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configuration, ok := table.State.DomainMetadata("delta.clustering")
+	if !ok || configuration != `{"clusteringColumns":["id"]}` {
+		t.Errorf("unexpected clustering domain %s", configuration)
+	}
+	if _, ok := table.State.DomainMetadata("com.example.dropped"); ok {
+		t.Error("removed domain should not be in the state")
+	}
+
+	// Domains survive a round trip through a checkpoint, including the ones delta-go does not interpret
+	var rows []checkpointRow
+	actions := []Action{Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, table.State.CurrentMetadata.ToMetaData()}
+	for _, domain := range table.State.Domains {
+		actions = append(actions, domain)
+	}
+	for _, action := range actions {
+		row, ok := newCheckpointRow(action)
+		if !ok {
+			t.Fatalf("%T should be kept in checkpoints", action)
+		}
+		rows = append(rows, row)
+	}
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(2), rows)
+	checkpointTable, err := OpenFromCheckpoint(table.Store, nil, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpointTable.State.Domains) != 2 {
+		t.Errorf("want 2 domains, has %v", checkpointTable.State.Domains)
+	}
+	configuration, ok = checkpointTable.State.DomainMetadata("com.example.custom")
+	if !ok || configuration != "opaque" {
+		t.Errorf("unexpected custom domain %s", configuration)
+	}
+}
+
+func TestAddTags(t *testing.T) {
+	entry := `{"add":{"path":"part-0.snappy.parquet","size":1,"partitionValues":{},"modificationTime":1675020556534,"dataChange":true,"stats":"","tags":{"INSERTION_TIME":"1675020556534000","BLOOM_FILTER_INDEX":"_delta_index/part-0.snappy.parquet"}}}`
+	actions, err := ActionsFromLogEntries([]byte(entry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := actions[0].(Add)
+	index, ok := add.Tag("BLOOM_FILTER_INDEX")
+	if !ok || index != "_delta_index/part-0.snappy.parquet" {
+		t.Errorf("unexpected index tag %s", index)
+	}
+	if _, ok := add.Tag("ZORDER_BY"); ok {
+		t.Error("the file has no ZORDER_BY tag")
+	}
+
+	// Tags survive a round trip through a checkpoint
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	var rows []checkpointRow
+	for _, action := range []Action{Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, metadata.ToMetaData(), add} {
+		row, _ := newCheckpointRow(action)
+		rows = append(rows, row)
+	}
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(0), rows)
+	err = table.LoadFromCheckpoint(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := table.State.Files[add.Path]
+	if len(loaded.Tags) != 2 || loaded.Tags["BLOOM_FILTER_INDEX"] != index {
+		t.Errorf("unexpected tags %v", loaded.Tags)
+	}
+}
+
+type testParsedValues struct {
+	Id   int64     `parquet:"id"`
+	Name *string   `parquet:"name,optional"`
+	Ts   time.Time `parquet:"ts,timestamp(millisecond)"`
+}
+
+type testStatsParsed struct {
+	NumRecords int64            `parquet:"numRecords"`
+	MinValues  testParsedValues `parquet:"minValues"`
+	MaxValues  testParsedValues `parquet:"maxValues"`
+	NullCount  struct {
+		Id    int64 `parquet:"id"`
+		Event struct {
+			Code int64 `parquet:"code"`
+		} `parquet:"event"`
+	} `parquet:"nullCount"`
+}
+
+type testStatsParsedAdd struct {
+	Path             string           `parquet:"path"`
+	Size             int64            `parquet:"size"`
+	ModificationTime int64            `parquet:"modificationTime"`
+	DataChange       bool             `parquet:"dataChange"`
+	Stats            string           `parquet:"stats,optional"`
+	StatsParsed      *testStatsParsed `parquet:"stats_parsed,optional"`
+}
+
+type testStatsParsedRow struct {
+	Add      *testStatsParsedAdd `parquet:"add,optional"`
+	MetaData *checkpointMetaData `parquet:"metaData,optional"`
+	Protocol *checkpointProtocol `parquet:"protocol,optional"`
+}
+
+func TestCheckpointStatsParsed(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "name", Type: String}, {Name: "ts", Type: Timestamp}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	protocolRow, _ := newCheckpointRow(Protocol{MinReaderVersion: 1, MinWriterVersion: 2})
+	metadataRow, _ := newCheckpointRow(metadata.ToMetaData())
+
+	name := "a"
+	statsParsed := &testStatsParsed{NumRecords: 3}
+	statsParsed.MinValues = testParsedValues{Id: 1, Name: &name, Ts: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	statsParsed.MaxValues = testParsedValues{Id: 3, Ts: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)}
+	statsParsed.NullCount.Id = 0
+	statsParsed.NullCount.Event.Code = 2
+	rows := []testStatsParsedRow{
+		{Protocol: protocolRow.Protocol},
+		{MetaData: metadataRow.MetaData},
+		// The parsed stats are preferred over the stats string
+		{Add: &testStatsParsedAdd{Path: "part-0.parquet", Size: 1, Stats: `{"numRecords":100}`, StatsParsed: statsParsed}},
+		{Add: &testStatsParsedAdd{Path: "part-1.parquet", Size: 1, StatsParsed: statsParsed}},
+		{Add: &testStatsParsedAdd{Path: "part-2.parquet", Size: 1, Stats: `{"numRecords":5}`}},
+	}
+	var buf bytes.Buffer
+	err := parquet.Write(&buf, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Store.Put(table.CheckpointUriFromVersion(0), buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.LoadFromCheckpoint(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"part-0.parquet", "part-1.parquet"} {
+		add := table.State.Files[path]
+		stats, err := add.ParseStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.NumRecords != 3 {
+			t.Errorf("%s: want 3 records, has %d", path, stats.NumRecords)
+		}
+		expectedMin := map[string]any{"id": json.Number("1"), "name": "a", "ts": "2023-01-01T00:00:00.000Z"}
+		if !reflect.DeepEqual(stats.MinValues, expectedMin) {
+			t.Errorf("%s: want min values %v, has %v", path, expectedMin, stats.MinValues)
+		}
+		expectedMax := map[string]any{"id": json.Number("3"), "ts": "2023-01-02T00:00:00.000Z"}
+		if !reflect.DeepEqual(stats.MaxValues, expectedMax) {
+			t.Errorf("%s: want max values %v, has %v", path, expectedMax, stats.MaxValues)
+		}
+		if stats.NullCount["id"] != 0 || stats.NestedNullCount["event.code"] != 2 {
+			t.Errorf("%s: unexpected null counts %v %v", path, stats.NullCount, stats.NestedNullCount)
+		}
+	}
+
+	// The stats string is kept when present, and written from the parsed stats otherwise
+	if table.State.Files["part-0.parquet"].Stats != `{"numRecords":100}` {
+		t.Errorf("unexpected stats %s", table.State.Files["part-0.parquet"].Stats)
+	}
+	add := table.State.Files["part-1.parquet"]
+	reparsed := Add{Stats: add.Stats}
+	stats, err := reparsed.ParseStats()
+	if err != nil || stats.NumRecords != 3 || stats.NestedNullCount["event.code"] != 2 {
+		t.Errorf("unexpected stats %s", add.Stats)
+	}
+
+	add = table.State.Files["part-2.parquet"]
+	stats, err = add.ParseStats()
+	if err != nil || stats.NumRecords != 5 {
+		t.Errorf("the stats string should be parsed without stats_parsed, has %v", stats)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+var (
+	ErrorComputeStats error = errors.New("error computing the statistics of the table")
+)
+
+// ComputeStatsOptions configures ComputeStats
+type ComputeStatsOptions struct {
+	// Recompute the statistics of every data file, not only of the files whose add action has none
+	Recompute bool
+	// The options of the transaction committing the statistics
+	TransactionOptions *DeltaTransactionOptions
+}
+
+// NewComputeStatsOptions returns the default compute stats options, which compute the missing statistics only
+func NewComputeStatsOptions() *ComputeStatsOptions {
+	return &ComputeStatsOptions{TransactionOptions: NewDeltaTransactionOptions()}
+}
+
+// ComputeStats reads the statistics of the data files whose add action has none with DataFileStats, and commits a
+// COMPUTE STATS operation adding the files again with their statistics, without data change, so that they can be
+// skipped by scans. This reads the footer of every such file, and the whole file when the footer has no statistics.
+// The statistics of files with a deletion vector do not have tight bounds, since they include the deleted rows.
+// The table state must be loaded; it is updated to the latest version first. The commit fails with
+// ErrorConcurrentModification if a concurrent commit removed one of the files, which would otherwise be added back.
+// Returns the number of files whose statistics were committed. Nothing is committed if every file has statistics.
+func (table *DeltaTable) ComputeStats(options *ComputeStatsOptions) (int, error) {
+	return table.ComputeStatsWithContext(context.Background(), options)
+}
+
+// ComputeStatsWithContext is ComputeStats stopping promptly when the context is cancelled. No more files are read
+// once the context is done, nothing is committed and the error of the context is returned.
+func (table *DeltaTable) ComputeStatsWithContext(ctx context.Context, options *ComputeStatsOptions) (int, error) {
+	if options == nil {
+		options = NewComputeStatsOptions()
+	}
+	if table.State.Version < 0 {
+		return 0, ErrorNotATable
+	}
+	if err := table.Update(); err != nil {
+		return 0, errors.Join(ErrorComputeStats, err)
+	}
+
+	paths := make([]string, 0, len(table.State.Files))
+	for path, add := range table.State.Files {
+		if add.Stats == "" || options.Recompute {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return 0, nil
+	}
+	sort.Strings(paths)
+
+	actions := make([]Action, 0, len(paths))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		add := table.State.Files[path]
+		stats, err := table.DataFileStats(&add)
+		if err != nil {
+			return 0, errors.Join(ErrorComputeStats, err)
+		}
+		deletionVector, err := add.DeletionVector()
+		if err != nil {
+			return 0, errors.Join(ErrorComputeStats, err)
+		}
+		stats.TightBounds = deletionVector == nil
+		add.Stats = string(stats.Json())
+		add.DataChange = false
+		actions = append(actions, add)
+	}
+
+	transaction := table.createCheckedTransaction(options.TransactionOptions)
+	transaction.AddActions(actions)
+	if _, err := transaction.CommitWithContext(ctx, ComputeStats{NumFiles: int64(len(actions))}, nil); err != nil {
+		return 0, err
+	}
+	return len(actions), nil
+}
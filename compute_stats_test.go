@@ -0,0 +1,142 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	table := setupWriteBatchTable(t)
+	ts := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	options := NewWriteOptions()
+	options.MaxRowsPerFile = 2
+	rows := []Row{
+		{"id": int64(1), "name": "a", "ts": ts, "date": ts},
+		{"id": int64(2), "name": "b", "ts": ts, "date": ts},
+		{"id": int64(3), "ts": ts, "date": ts},
+		{"id": int64(4), "name": "d", "ts": ts, "date": ts},
+	}
+	if _, err := table.WriteBatch(sendRows(rows), options); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the statistics of the files, as written by writers that do not collect them
+	expected := make(map[string]string)
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	for path, add := range table.State.Files {
+		expected[path] = add.Stats
+		add.Stats = ""
+		add.DataChange = false
+		transaction.AddAction(add)
+	}
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := table.Scan(nil, Comparison{Column: "id", Operator: GreaterThan, Value: int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.StatsPrunedFiles != 0 {
+		t.Errorf("files without statistics should not be pruned, has %d", plan.StatsPrunedFiles)
+	}
+
+	computed, err := table.ComputeStats(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if computed != 2 {
+		t.Errorf("want the statistics of 2 files, has %d", computed)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(table.State.Files) != 2 {
+		t.Errorf("want 2 files, has %d", len(table.State.Files))
+	}
+	for path, add := range table.State.Files {
+		var want, has Stats
+		if err := json.Unmarshal([]byte(expected[path]), &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(add.Stats), &has); err != nil {
+			t.Fatal(err)
+		}
+		if string(has.Json()) != string(want.Json()) {
+			t.Errorf("%s: want %s, has %s", path, want.Json(), has.Json())
+		}
+	}
+	history, err := table.History(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history[0].CommitInfo["operation"] != "COMPUTE STATS" {
+		t.Errorf("unexpected commit info %v", history[0].CommitInfo)
+	}
+	plan, err = table.Scan(nil, Comparison{Column: "id", Operator: GreaterThan, Value: int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.StatsPrunedFiles != 1 {
+		t.Errorf("want 1 file pruned with the computed statistics, has %d", plan.StatsPrunedFiles)
+	}
+
+	// Nothing is committed once every file has statistics
+	version := table.State.Version
+	computed, err = table.ComputeStats(nil)
+	if err != nil || computed != 0 || table.State.Version != version {
+		t.Errorf("want nothing committed, has %d files at version %d %v", computed, table.State.Version, err)
+	}
+}
+
+func TestComputeStatsConcurrentRemove(t *testing.T) {
+	table := setupWriteBatchTable(t)
+	ts := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := table.WriteBatch(sendRows([]Row{{"id": int64(1), "name": "a", "ts": ts, "date": ts}}), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	other, err := OpenTable(table.Store, table.LockClient, table.StateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Store = &concurrentCommitStore{ObjectStore: table.Store, prefix: "date=", commit: func() error {
+		transaction := other.CreateTransaction(NewDeltaTransactionOptions())
+		for _, add := range other.State.Files {
+			transaction.AddAction(Remove{Path: add.Path, DataChange: true})
+		}
+		_, err := transaction.Commit(Delete{}, nil)
+		return err
+	}}
+
+	// Adding the removed file again would restore its rows
+	options := NewComputeStatsOptions()
+	options.Recompute = true
+	if _, err := table.ComputeStats(options); !errors.Is(err, ErrorConcurrentModification) {
+		t.Fatalf("want ErrorConcurrentModification, has %v", err)
+	}
+	if err := table.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 2 || len(table.State.Files) != 0 {
+		t.Errorf("want the concurrent removal only, has %d files at version %d", len(table.State.Files), table.State.Version)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+var (
+	ErrorInvalidDataPath error = errors.New("invalid data file path")
+)
+
+// DataFilePath returns the path, relative to the table root, of a data file written under the directory with the
+// given partition values: directory/col1=value1/col2=value2/fileName.
+// This is the key of the file in the object store, with the partition values Hive escaped; the Path of its Add
+// action is the URI encoding of the key, see DataFileUri.
+// The path must stay inside the table root and outside of the _delta_log.
+func DataFilePath(directory string, partitionColumns []string, partitionValues map[string]string, fileName string) (string, error) {
+	if fileName == "" || strings.Contains(fileName, "/") {
+		return "", errors.Join(ErrorInvalidDataPath, fmt.Errorf("file name %q", fileName))
+	}
+	partitionPath, err := PartitionPath(partitionColumns, partitionValues)
+	if err != nil {
+		return "", err
+	}
+	dataPath := path.Join(directory, partitionPath, fileName)
+	if path.IsAbs(directory) || dataPath == ".." || strings.HasPrefix(dataPath, "../") {
+		return "", errors.Join(ErrorInvalidDataPath, fmt.Errorf("%s is outside of the table root", dataPath))
+	}
+	if dataPath == "_delta_log" || strings.HasPrefix(dataPath, "_delta_log/") {
+		return "", errors.Join(ErrorInvalidDataPath, fmt.Errorf("%s is in the log directory", dataPath))
+	}
+	return dataPath, nil
+}
+
+// DataFileUri returns the relative URI stored in the Path of the Add action of the data file with the given key,
+// which is the key with each segment URI encoded
+func DataFileUri(dataPath string) string {
+	segments := strings.Split(dataPath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// AppendDataFile writes a data file under the DataDirectory of the transaction options, in the partition
+// directory of its partition values, and adds the Add action of the file, with its URI encoded path, to the
+// transaction.
+// The file is tracked so that it is removed by AbortWrite. Stats may be empty.
+func (transaction *DeltaTransaction) AppendDataFile(fileName string, partitionValues map[string]string, data []byte, stats string) (Add, error) {
+	directory := ""
+	if transaction.Options != nil {
+		directory = transaction.Options.DataDirectory
+	}
+	dataPath, err := DataFilePath(directory, transaction.DeltaTable.State.CurrentMetadata.PartitionColumns, partitionValues, fileName)
+	if err != nil {
+		return Add{}, err
+	}
+
+	err = transaction.PutDataFile(storage.NewPath(dataPath), data)
+	if err != nil {
+		return Add{}, err
+	}
+	add := Add{
+		Path:             DataFileUri(dataPath),
+		Size:             DeltaDataTypeLong(len(data)),
+		PartitionValues:  partitionValues,
+		ModificationTime: DeltaDataTypeTimestamp(time.Now().UnixMilli()),
+		DataChange:       true,
+		Stats:            stats,
+	}
+	transaction.AddAction(add)
+	return add, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataFilePath(t *testing.T) {
+	for _, directory := range []string{"..", "data/../..", "/tmp", "_delta_log"} {
+		_, err := DataFilePath(directory, []string{}, map[string]string{}, "part-0.parquet")
+		if !errors.Is(err, ErrorInvalidDataPath) {
+			t.Errorf("%s: want ErrorInvalidDataPath, has %v", directory, err)
+		}
+	}
+	_, err := DataFilePath("data", []string{}, map[string]string{}, "../part-0.parquet")
+	if !errors.Is(err, ErrorInvalidDataPath) {
+		t.Errorf("want ErrorInvalidDataPath, has %v", err)
+	}
+
+	dataPath, err := DataFilePath("data/", []string{"date"}, map[string]string{"date": "2023-01-01"}, "part-0.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataPath != "data/date=2023-01-01/part-0.parquet" {
+		t.Errorf("unexpected path %s", dataPath)
+	}
+}
+
+func TestAppendDataFile(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: String}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{"date"}, map[string]string{})
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := NewDeltaTransactionOptions()
+	options.DataDirectory = "data"
+	transaction := table.CreateTransaction(options)
+	add, err := transaction.AppendDataFile("part-0.parquet", map[string]string{"date": "2023-01-01"}, []byte("data"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if add.Path != "data/date=2023-01-01/part-0.parquet" || add.Size != 4 {
+		t.Errorf("unexpected add %v", add)
+	}
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists(filepath.Join(tmpDir, "data", "date=2023-01-01", "part-0.parquet")) {
+		t.Error("data file should be written under the data directory")
+	}
+
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.State.Files[add.Path]; !ok {
+		t.Error("data file should be in the table")
+	}
+
+	// The add path is URI encoded, the file is written under the Hive escaped partition directory
+	transaction = table.CreateTransaction(options)
+	add, err = transaction.AppendDataFile("part-1.parquet", map[string]string{"date": "2023-01-02 10:00"}, []byte("data"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if add.Path != "data/date=2023-01-02%2010%253A00/part-1.parquet" {
+		t.Errorf("unexpected add path %s", add.Path)
+	}
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists(filepath.Join(tmpDir, "data", "date=2023-01-02 10%3A00", "part-1.parquet")) {
+		t.Error("data file should be written under the Hive escaped partition directory")
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.State.Files[add.Path]; !ok {
+		t.Error("data file should be in the table")
+	}
+	if values := PartitionValuesFromPath(unescapedDataPath(add.Path)); values["date"] != "2023-01-02 10:00" {
+		t.Errorf("unexpected partition values %v", values)
+	}
+
+	transaction = table.CreateTransaction(options)
+	_, err = transaction.AppendDataFile("part-2.parquet", map[string]string{}, []byte("data"), "")
+	if !errors.Is(err, ErrorInvalidPartitionValue) {
+		t.Errorf("want ErrorInvalidPartitionValue, has %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrorInvalidDecimal error = errors.New("invalid decimal value")
+)
+
+var decimalTypeRegex = regexp.MustCompile(`^decimal\((\d+),\s*(\d+)\)$`)
+
+// DecimalType returns the schema type of decimals with the given precision and scale, e.g. decimal(10,2)
+func DecimalType(precision int, scale int) SchemaDataType {
+	return SchemaDataType(fmt.Sprintf("decimal(%d,%d)", precision, scale))
+}
+
+// DecimalPrecisionScale returns the precision and scale of a decimal type, and false if the type is not a decimal
+func (t SchemaDataType) DecimalPrecisionScale() (precision int, scale int, ok bool) {
+	match := decimalTypeRegex.FindStringSubmatch(string(t))
+	if match == nil {
+		return 0, 0, false
+	}
+	precision, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	scale, err = strconv.Atoi(match[2])
+	if err != nil || scale > precision {
+		return 0, 0, false
+	}
+	return precision, scale, true
+}
+
+// Decimal is a fixed-point value of a decimal column: Unscaled * 10^-Scale
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+// ParseDecimal parses the string representation of a decimal, e.g. "12.3", into a decimal with the scale of
+// the column type. Values with more fractional digits than the scale, or more digits than the precision, are
+// rejected rather than rounded.
+func ParseDecimal(value string, precision int, scale int) (Decimal, error) {
+	digits := strings.TrimSpace(value)
+	negative := strings.HasPrefix(digits, "-")
+	if negative || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+	integer, fraction, _ := strings.Cut(digits, ".")
+	if integer == "" && fraction == "" {
+		return Decimal{}, errors.Join(ErrorInvalidDecimal, fmt.Errorf("%q", value))
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > scale {
+		return Decimal{}, errors.Join(ErrorInvalidDecimal, fmt.Errorf("%q has more than %d fractional digits", value, scale))
+	}
+	fraction += strings.Repeat("0", scale-len(fraction))
+
+	unscaled, ok := new(big.Int).SetString(integer+fraction, 10)
+	if !ok || strings.ContainsAny(integer+fraction, "+-") {
+		return Decimal{}, errors.Join(ErrorInvalidDecimal, fmt.Errorf("%q", value))
+	}
+	if len(strings.TrimLeft(unscaled.String(), "0")) > precision {
+		return Decimal{}, errors.Join(ErrorInvalidDecimal, fmt.Errorf("%q has more than %d digits", value, precision))
+	}
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+// String returns the representation of the decimal with Scale fractional digits
+func (d Decimal) String() string {
+	if d.Unscaled == nil {
+		return ""
+	}
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	sign := ""
+	if d.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.Scale <= 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+}
+
+// Cmp compares two decimals by value, regardless of their scales, returning -1, 0 or +1
+func (d Decimal) Cmp(other Decimal) int {
+	return d.rat().Cmp(other.rat())
+}
+
+// rat returns the value of the decimal as a rational number
+func (d Decimal) rat() *big.Rat {
+	unscaled := d.Unscaled
+	if unscaled == nil {
+		unscaled = new(big.Int)
+	}
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return new(big.Rat).SetFrac(unscaled, denominator)
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecimalPrecisionScale(t *testing.T) {
+	precision, scale, ok := DecimalType(10, 2).DecimalPrecisionScale()
+	if !ok || precision != 10 || scale != 2 {
+		t.Errorf("unexpected precision and scale %d %d %v", precision, scale, ok)
+	}
+	if _, _, ok := SchemaDataType("decimal(10, 2)").DecimalPrecisionScale(); !ok {
+		t.Error("decimal types may have a space after the comma")
+	}
+	for _, dataType := range []SchemaDataType{Long, "decimal", "decimal(2,10)"} {
+		if _, _, ok := dataType.DecimalPrecisionScale(); ok {
+			t.Errorf("%s is not a valid decimal type", dataType)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value    string
+		unscaled int64
+		str      string
+	}{
+		{"12.34", 1234, "12.34"},
+		{"12.3", 1230, "12.30"},
+		{"12", 1200, "12.00"},
+		{"-0.5", -50, "-0.50"},
+		{".05", 5, "0.05"},
+		{"12.300", 1230, "12.30"},
+		{"99999999.99", 9999999999, "99999999.99"},
+	}
+	for _, test := range tests {
+		d, err := ParseDecimal(test.value, 10, 2)
+		if err != nil {
+			t.Errorf("%s: %v", test.value, err)
+			continue
+		}
+		if d.Unscaled.Int64() != test.unscaled || d.Scale != 2 {
+			t.Errorf("%s: want %d, has %v", test.value, test.unscaled, d.Unscaled)
+		}
+		if d.String() != test.str {
+			t.Errorf("%s: want %s, has %s", test.value, test.str, d.String())
+		}
+	}
+
+	for _, value := range []string{"12.345", "100000000.00", "abc", "", "1e5", "--1", "1.2.3"} {
+		if _, err := ParseDecimal(value, 10, 2); !errors.Is(err, ErrorInvalidDecimal) {
+			t.Errorf("%q: want ErrorInvalidDecimal, has %v", value, err)
+		}
+	}
+}
+
+func TestDecimalCmp(t *testing.T) {
+	a, _ := ParseDecimal("12.3", 10, 2)
+	b, _ := ParseDecimal("12.30", 10, 3)
+	c, _ := ParseDecimal("9.99", 10, 2)
+	if a.Cmp(b) != 0 {
+		t.Errorf("%s and %s should be equal", a, b)
+	}
+	if a.Cmp(c) != 1 || c.Cmp(a) != -1 {
+		t.Errorf("%s should be greater than %s", a, c)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/storage"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	ErrorDelete error = errors.New("error deleting rows")
+)
+
+// DeleteOptions configures DeleteRows
+type DeleteOptions struct {
+	// The predicate that selected the deleted rows, recorded in the commitInfo
+	Predicate []string
+	// The options of the transaction committing the deletion vectors
+	TransactionOptions *DeltaTransactionOptions
+}
+
+// NewDeleteOptions returns the default delete options
+func NewDeleteOptions() *DeleteOptions {
+	return &DeleteOptions{TransactionOptions: NewDeltaTransactionOptions()}
+}
+
+// DeleteRows deletes rows of the active files by writing deletion vectors marking them as deleted instead of
+// rewriting the files, and commits a DELETE operation adding the files back with their new deletion vectors.
+// The deleted rows are given by file path as the indexes of the rows in the data file, starting at 0, as selected by
+// the engine evaluating the delete predicate. Rows already deleted by the current deletion vector of a file stay
+// deleted, and files whose rows are all deleted are removed. The deletion vectors are written to a single deletion
+// vector file at the root of the table.
+// The table must have the deletionVectors writer feature, ErrorUnsupportedProtocol is returned otherwise.
+// The table state must be loaded; it is updated to the latest version first. The commit fails with
+// ErrorConcurrentModification if a concurrent commit removed one of the files, for instance to delete its rows.
+// Returns the metrics of the operation, also recorded in the commitInfo. Nothing is committed if no row is deleted.
+func (table *DeltaTable) DeleteRows(rows map[string][]uint64, options *DeleteOptions) (DeleteMetrics, error) {
+	return table.DeleteRowsWithContext(context.Background(), rows, options)
+}
+
+// DeleteRowsWithContext is DeleteRows stopping promptly when the context is cancelled. The deletion vector file is
+// removed if the deletion is not committed.
+func (table *DeltaTable) DeleteRowsWithContext(ctx context.Context, rows map[string][]uint64, options *DeleteOptions) (DeleteMetrics, error) {
+	if options == nil {
+		options = NewDeleteOptions()
+	}
+	if table.State.Version < 0 {
+		return DeleteMetrics{}, ErrorNotATable
+	}
+	if err := table.Update(); err != nil {
+		return DeleteMetrics{}, errors.Join(ErrorDelete, err)
+	}
+	protocol := table.State.protocol()
+	if !protocol.HasWriterFeature(DELETION_VECTORS_FEATURE) {
+		return DeleteMetrics{}, errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("deleting rows requires the %s table feature", DELETION_VECTORS_FEATURE))
+	}
+
+	paths := maps.Keys(rows)
+	sort.Strings(paths)
+	var removed, updated []Add
+	var deletionVectors [][]byte
+	var cardinalities []int64
+	var numDeletedRows int64
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return DeleteMetrics{}, err
+		}
+		add, ok := table.State.Files[path]
+		if !ok {
+			return DeleteMetrics{}, errors.Join(ErrorDelete, fmt.Errorf("%s is not an active file of the table", path))
+		}
+		deleted, numNewRows, allDeleted, err := table.mergeDeletedRows(&add, rows[path])
+		if err != nil {
+			return DeleteMetrics{}, errors.Join(ErrorDelete, err)
+		}
+		if numNewRows == 0 {
+			continue
+		}
+		numDeletedRows += numNewRows
+		if allDeleted {
+			removed = append(removed, add)
+			continue
+		}
+		updated = append(updated, add)
+		deletionVectors = append(deletionVectors, encodeDeletionVector(deleted))
+		cardinalities = append(cardinalities, int64(len(deleted)))
+	}
+	if len(removed) == 0 && len(updated) == 0 {
+		return DeleteMetrics{}, nil
+	}
+
+	transaction := table.createCheckedTransaction(options.TransactionOptions)
+	now := DeltaDataTypeTimestamp(time.Now().UnixMilli())
+	for _, add := range removed {
+		transaction.AddAction(add.deletedFileRemove(now))
+	}
+	if len(updated) > 0 {
+		data, offsets := encodeDeletionVectorFile(deletionVectors)
+		id := uuid.New()
+		if err := transaction.PutDataFile(storage.NewPath(fmt.Sprintf("deletion_vector_%s.bin", id)), data); err != nil {
+			return DeleteMetrics{}, errors.Join(ErrorDelete, err)
+		}
+		for i, add := range updated {
+			descriptor := DeletionVectorDescriptor{
+				StorageType:    DELETION_VECTOR_RELATIVE_PATH,
+				PathOrInlineDv: z85Encode(id[:]),
+				Offset:         &offsets[i],
+				SizeInBytes:    int32(len(deletionVectors[i])),
+				Cardinality:    cardinalities[i],
+			}
+			updatedAdd, err := add.withDeletionVector(descriptor)
+			if err != nil {
+				transaction.AbortWrite()
+				return DeleteMetrics{}, errors.Join(ErrorDelete, err)
+			}
+			transaction.AddAction(add.deletedFileRemove(now))
+			transaction.AddAction(updatedAdd)
+		}
+	}
+
+	metrics := NewDeleteMetrics(transaction.Actions)
+	metrics.NumDeletedRows = numDeletedRows
+	_, err := transaction.CommitWithContext(ctx, Delete{Predicate: options.Predicate, NumDeletedRows: numDeletedRows, Metrics: &metrics}, nil)
+	if err != nil {
+		return DeleteMetrics{}, err
+	}
+	return metrics, nil
+}
+
+// mergeDeletedRows returns the rows of the file deleted by its current deletion vector and the given rows, in
+// ascending order, the number of rows that were not deleted yet, and whether all the rows of the file are deleted.
+// The rows must be below the number of records of the file when its statistics have one.
+func (table *DeltaTable) mergeDeletedRows(add *Add, rows []uint64) ([]uint64, int64, bool, error) {
+	var current []uint64
+	deletionVector, err := add.DeletionVector()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if deletionVector != nil {
+		current, err = table.ReadDeletionVector(deletionVector)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("%s: %w", add.Path, err)
+		}
+	}
+
+	numRecords := int64(-1)
+	stats, err := add.ParseStats()
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("%s: %w", add.Path, err)
+	}
+	if stats != nil {
+		numRecords = stats.NumRecords
+	}
+
+	deleted := make([]uint64, 0, len(current)+len(rows))
+	deleted = append(append(deleted, current...), rows...)
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+	unique := deleted[:0]
+	for _, row := range deleted {
+		if numRecords >= 0 && row >= uint64(numRecords) {
+			return nil, 0, false, fmt.Errorf("%s: row %d is out of the %d records of the file", add.Path, row, numRecords)
+		}
+		if len(unique) == 0 || row != unique[len(unique)-1] {
+			unique = append(unique, row)
+		}
+	}
+	return unique, int64(len(unique) - len(current)), int64(len(unique)) == numRecords, nil
+}
+
+// deletedFileRemove returns the remove action of the file, with its deletion vector
+func (add *Add) deletedFileRemove(timestamp DeltaDataTypeTimestamp) Remove {
+	remove := Remove{
+		Path:                 add.Path,
+		DeletionTimestamp:    timestamp,
+		DataChange:           true,
+		ExtendedFileMetadata: true,
+		PartitionValues:      add.PartitionValues,
+		Size:                 add.Size,
+		Tags:                 add.Tags,
+	}
+	if deletionVector, ok := add.Extras["deletionVector"]; ok {
+		remove.Extras = map[string]json.RawMessage{"deletionVector": deletionVector}
+	}
+	return remove
+}
+
+// withDeletionVector returns the add action of the file with the deletion vector. The bounds of the statistics are
+// no longer tight, since the deleted rows may hold the min or max values.
+func (add *Add) withDeletionVector(deletionVector DeletionVectorDescriptor) (Add, error) {
+	data, err := json.Marshal(deletionVector)
+	if err != nil {
+		return Add{}, err
+	}
+	updated := *add
+	updated.DataChange = true
+	updated.Extras = maps.Clone(add.Extras)
+	if updated.Extras == nil {
+		updated.Extras = make(map[string]json.RawMessage)
+	}
+	updated.Extras["deletionVector"] = data
+
+	stats, err := add.ParseStats()
+	if err != nil {
+		return Add{}, fmt.Errorf("%s: %w", add.Path, err)
+	}
+	if stats != nil {
+		stats.TightBounds = false
+		updated.Stats = string(stats.Json())
+		updated.statsParsed = nil
+	}
+	return updated, nil
+}
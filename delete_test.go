@@ -0,0 +1,185 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+// Helper function to set up a table with the deletionVectors feature and two files of 10 rows
+func setupDeleteTable(t *testing.T, protocol Protocol) *DeltaTable {
+	t.Helper()
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	files := []Add{
+		{Path: "part-0.parquet", Size: 100, DataChange: true, Stats: `{"numRecords":10,"minValues":{"id":0},"maxValues":{"id":9},"nullCount":{"id":0}}`},
+		{Path: "part-1.parquet", Size: 100, DataChange: true, Stats: `{"numRecords":10}`},
+	}
+	for _, add := range files {
+		if err := table.Store.Put(storage.NewPath(add.Path), make([]byte, add.Size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Create(*metadata, protocol, CommitInfo{}, files); err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestDeleteRows(t *testing.T) {
+	table := setupDeleteTable(t, Protocol{MinReaderVersion: 3, MinWriterVersion: 7,
+		ReaderFeatures: []string{DELETION_VECTORS_FEATURE}, WriterFeatures: []string{DELETION_VECTORS_FEATURE}})
+
+	options := NewDeleteOptions()
+	options.Predicate = []string{"id IN (0, 9)"}
+	metrics, err := table.DeleteRows(map[string][]uint64{"part-0.parquet": {9, 0, 9}}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.NumDeletedRows != 2 || metrics.NumDeletionVectorsAdded != 1 || metrics.NumAddedFiles != 0 || metrics.NumRemovedFiles != 0 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+
+	// The file is added back with a deletion vector stored in the table directory
+	loaded, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := loaded.State.Files["part-0.parquet"]
+	deletionVector, err := add.DeletionVector()
+	if err != nil || deletionVector == nil {
+		t.Fatalf("want a deletion vector, has %v %v", deletionVector, err)
+	}
+	if rows, err := loaded.ReadDeletionVector(deletionVector); err != nil || !reflect.DeepEqual(rows, []uint64{0, 9}) {
+		t.Errorf("want rows 0 and 9 deleted, has %v %v", rows, err)
+	}
+	dvPath, _, err := deletionVector.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Store.Head(storage.NewPath(dvPath)); err != nil {
+		t.Errorf("want the deletion vector file %s, has %v", dvPath, err)
+	}
+	if stats, err := add.ParseStats(); err != nil || stats.TightBounds || stats.NumRecords != 10 {
+		t.Errorf("want the stats of the file with wide bounds, has %+v %v", stats, err)
+	}
+	actions, err := loaded.ReadActions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var operation any
+	for _, action := range actions {
+		if commitInfo, ok := action.(CommitInfo); ok {
+			operation = commitInfo["operation"]
+		}
+	}
+	if operation != "DELETE" {
+		t.Errorf("want a DELETE commit, has %v", operation)
+	}
+
+	// Deleted rows are merged with the current deletion vector, files without rows are removed
+	metrics, err = table.DeleteRows(map[string][]uint64{"part-0.parquet": {0, 3}, "part-1.parquet": {0, 1, 2, 3, 4, 5, 6, 7, 8, 9}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.NumDeletedRows != 11 || metrics.NumDeletionVectorsUpdated != 1 || metrics.NumRemovedFiles != 1 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+	if _, ok := table.State.Files["part-1.parquet"]; ok {
+		t.Error("part-1.parquet should be removed")
+	}
+	add = table.State.Files["part-0.parquet"]
+	if deletionVector, err = add.DeletionVector(); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err := table.ReadDeletionVector(deletionVector); err != nil || !reflect.DeepEqual(rows, []uint64{0, 3, 9}) {
+		t.Errorf("want rows 0, 3 and 9 deleted, has %v %v", rows, err)
+	}
+	if report, err := table.Verify(nil); err != nil || len(report.Problems) > 0 {
+		t.Errorf("want no problems, has %v %v", report, err)
+	}
+
+	// Nothing is committed without new deleted rows
+	if _, err := table.DeleteRows(map[string][]uint64{"part-0.parquet": {3}}, nil); err != nil || table.State.Version != 2 {
+		t.Errorf("want nothing committed, has version %d %v", table.State.Version, err)
+	}
+	for _, rows := range []map[string][]uint64{{"part-0.parquet": {10}}, {"part-2.parquet": {0}}} {
+		if _, err := table.DeleteRows(rows, nil); !errors.Is(err, ErrorDelete) {
+			t.Errorf("%v: want ErrorDelete, has %v", rows, err)
+		}
+	}
+}
+
+func TestDeleteRowsRequiresDeletionVectors(t *testing.T) {
+	table := setupDeleteTable(t, Protocol{MinReaderVersion: 1, MinWriterVersion: 2})
+	if _, err := table.DeleteRows(map[string][]uint64{"part-0.parquet": {0}}, nil); !errors.Is(err, ErrorUnsupportedProtocol) {
+		t.Errorf("want ErrorUnsupportedProtocol, has %v", err)
+	}
+	if table.State.Version != 0 {
+		t.Errorf("want nothing committed, has version %d", table.State.Version)
+	}
+
+	// The feature is enabled as a reader and writer feature
+	if _, err := table.EnableFeature(DELETION_VECTORS_FEATURE); err != nil {
+		t.Fatal(err)
+	}
+	if table.State.MinReaderVersion != 3 || !reflect.DeepEqual(table.State.ReaderFeatures, []string{DELETION_VECTORS_FEATURE}) {
+		t.Errorf("unexpected protocol %d %v", table.State.MinReaderVersion, table.State.ReaderFeatures)
+	}
+	if metrics, err := table.DeleteRows(map[string][]uint64{"part-0.parquet": {0}}, nil); err != nil || metrics.NumDeletedRows != 1 {
+		t.Errorf("want 1 deleted row, has %+v %v", metrics, err)
+	}
+}
+
+func TestDeleteRowsConcurrentDelete(t *testing.T) {
+	table := setupDeleteTable(t, Protocol{MinReaderVersion: 3, MinWriterVersion: 7,
+		ReaderFeatures: []string{DELETION_VECTORS_FEATURE}, WriterFeatures: []string{DELETION_VECTORS_FEATURE}})
+	other, err := OpenTable(table.Store, table.LockClient, table.StateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Store = &concurrentCommitStore{ObjectStore: table.Store, prefix: "deletion_vector_", commit: func() error {
+		_, err := other.DeleteRows(map[string][]uint64{"part-0.parquet": {1}}, nil)
+		return err
+	}}
+
+	// The concurrent deletion removed the file, adding it back would restore row 1
+	_, err = table.DeleteRows(map[string][]uint64{"part-0.parquet": {2}}, nil)
+	if !errors.Is(err, ErrorConcurrentModification) {
+		t.Fatalf("want ErrorConcurrentModification, has %v", err)
+	}
+	if err := table.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 1 {
+		t.Errorf("want the concurrent version 1 only, has version %d", table.State.Version)
+	}
+	add := table.State.Files["part-0.parquet"]
+	deletionVector, err := add.DeletionVector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, err := table.ReadDeletionVector(deletionVector); err != nil || !reflect.DeepEqual(rows, []uint64{1}) {
+		t.Errorf("want row 1 deleted, has %v %v", rows, err)
+	}
+
+	// A retry merges the row with the deletion vector of the latest version
+	if metrics, err := table.DeleteRows(map[string][]uint64{"part-0.parquet": {2}}, nil); err != nil || metrics.NumDeletedRows != 1 {
+		t.Errorf("want 1 deleted row, has %+v %v", metrics, err)
+	}
+}
@@ -0,0 +1,498 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/storage"
+	"golang.org/x/exp/maps"
+)
+
+// Storage types of a deletion vector
+const (
+	DELETION_VECTOR_RELATIVE_PATH = "u"
+	DELETION_VECTOR_INLINE        = "i"
+	DELETION_VECTOR_ABSOLUTE_PATH = "p"
+)
+
+// The alphabet of the Z85 encoding of the UUIDs of deletion vector files
+const z85Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ.-:+=^!/*?&<>()[]{}@%$#"
+
+const (
+	// The version of the format of deletion vector files, written as their first byte
+	deletionVectorFileVersion byte = 1
+	// The magic numbers starting a RoaringBitmapArray serialized in the portable and in the older native format
+	deletionVectorMagic       uint32 = 1681511377
+	deletionVectorNativeMagic uint32 = 1681511376
+	// The cookies of 32 bit roaring bitmaps serialized in the portable format, without and with run containers
+	roaringCookieNoRuns uint32 = 12346
+	roaringCookie       uint32 = 12347
+	// Containers of more values are serialized as bitmaps instead of arrays
+	roaringMaxArrayCardinality = 4096
+)
+
+// z85Encode encodes data whose length must be a multiple of 4 with Z85
+func z85Encode(data []byte) string {
+	encoded := make([]byte, 0, len(data)/4*5)
+	for i := 0; i+4 <= len(data); i += 4 {
+		value := binary.BigEndian.Uint32(data[i:])
+		var block [5]byte
+		for j := 4; j >= 0; j-- {
+			block[j] = z85Alphabet[value%85]
+			value /= 85
+		}
+		encoded = append(encoded, block[:]...)
+	}
+	return string(encoded)
+}
+
+// z85Decode decodes Z85 encoded data, whose length must be a multiple of 5
+func z85Decode(encoded string) ([]byte, error) {
+	if len(encoded)%5 != 0 {
+		return nil, fmt.Errorf("z85 data of length %d is not a multiple of 5", len(encoded))
+	}
+	decoded := make([]byte, 0, len(encoded)/5*4)
+	for i := 0; i < len(encoded); i += 5 {
+		var value uint64
+		for j := i; j < i+5; j++ {
+			digit := strings.IndexByte(z85Alphabet, encoded[j])
+			if digit < 0 {
+				return nil, fmt.Errorf("invalid z85 character %q", encoded[j])
+			}
+			value = value*85 + uint64(digit)
+		}
+		if value > 0xffffffff {
+			return nil, fmt.Errorf("invalid z85 block %q", encoded[i:i+5])
+		}
+		decoded = append(decoded, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+	}
+	return decoded, nil
+}
+
+// Path returns the path of the file holding the deletion vector: relative to the table root for storage type u,
+// and the absolute path for storage type p. Returns false for inline deletion vectors.
+// https://github.com/delta-io/delta/blob/master/PROTOCOL.md#deletion-vector-descriptor-schema
+func (dv *DeletionVectorDescriptor) Path() (string, bool, error) {
+	switch dv.StorageType {
+	case DELETION_VECTOR_INLINE:
+		return "", false, nil
+	case DELETION_VECTOR_ABSOLUTE_PATH:
+		return dv.PathOrInlineDv, true, nil
+	case DELETION_VECTOR_RELATIVE_PATH:
+		// An optional random prefix followed by the Z85 encoded UUID of the file
+		if len(dv.PathOrInlineDv) < 20 {
+			return "", false, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("path %q is too short", dv.PathOrInlineDv))
+		}
+		prefix := dv.PathOrInlineDv[:len(dv.PathOrInlineDv)-20]
+		decoded, err := z85Decode(dv.PathOrInlineDv[len(prefix):])
+		if err != nil {
+			return "", false, errors.Join(ErrorInvalidDeletionVector, err)
+		}
+		id, err := uuid.FromBytes(decoded)
+		if err != nil {
+			return "", false, errors.Join(ErrorInvalidDeletionVector, err)
+		}
+		return path.Join(prefix, fmt.Sprintf("deletion_vector_%s.bin", id)), true, nil
+	default:
+		return "", false, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("storage type %q", dv.StorageType))
+	}
+}
+
+// DeletionVectorUri returns the absolute URI of the file holding the deletion vector, resolving the paths of storage
+// type u against the root of the table data. Returns false for inline deletion vectors.
+func (table *DeltaTable) DeletionVectorUri(deletionVector *DeletionVectorDescriptor) (string, bool, error) {
+	dvPath, ok, err := deletionVector.Path()
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return absolutePath(table.dataStore().RootURI(), dvPath), true, nil
+}
+
+// absoluteDeletionVector replaces a deletion vector of storage type u of the file by one of storage type p with the
+// absolute path of its file, so that it still resolves from another table, such as a shallow clone
+func (add *Add) absoluteDeletionVector(rootURI string) error {
+	deletionVector, err := add.DeletionVector()
+	if err != nil || deletionVector == nil || deletionVector.StorageType != DELETION_VECTOR_RELATIVE_PATH {
+		return err
+	}
+	dvPath, _, err := deletionVector.Path()
+	if err != nil {
+		return fmt.Errorf("%s: %w", add.Path, err)
+	}
+	deletionVector.StorageType = DELETION_VECTOR_ABSOLUTE_PATH
+	deletionVector.PathOrInlineDv = absolutePath(rootURI, dvPath)
+	data, err := json.Marshal(deletionVector)
+	if err != nil {
+		return err
+	}
+	add.Extras = maps.Clone(add.Extras)
+	add.Extras["deletionVector"] = data
+	return nil
+}
+
+// uniqueID identifies the deletion vector among the deletion vectors of a file, "" for a file without deletion vector
+func (dv *DeletionVectorDescriptor) uniqueID() string {
+	if dv == nil {
+		return ""
+	}
+	if dv.Offset == nil {
+		return dv.StorageType + dv.PathOrInlineDv
+	}
+	return fmt.Sprintf("%s%s@%d", dv.StorageType, dv.PathOrInlineDv, *dv.Offset)
+}
+
+// DeletionVector returns the deletion vector of the removed file, or nil if the file had none
+func (remove *Remove) DeletionVector() (*DeletionVectorDescriptor, error) {
+	data, ok := remove.Extras["deletionVector"]
+	if !ok || string(data) == "null" {
+		return nil, nil
+	}
+	deletionVector := new(DeletionVectorDescriptor)
+	if err := json.Unmarshal(data, deletionVector); err != nil {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%s: %w", remove.Path, err))
+	}
+	return deletionVector, nil
+}
+
+// deletionVectorPath returns the path of the file holding the deletion vector, or false if there is no deletion
+// vector or it is stored inline
+func deletionVectorPath(deletionVector *DeletionVectorDescriptor, err error) (string, bool, error) {
+	if err != nil || deletionVector == nil {
+		return "", false, err
+	}
+	return deletionVector.Path()
+}
+
+// encodeDeletionVector serializes row indexes, sorted and without duplicates, as a RoaringBitmapArray in the portable
+// format: the magic number, the number of 32 bit bitmaps and, for each of them, the high 32 bits of its row indexes
+// followed by the portable roaring bitmap of their low 32 bits
+// https://github.com/RoaringBitmap/RoaringFormatSpec
+func encodeDeletionVector(rows []uint64) []byte {
+	var keys []uint32
+	var bitmaps [][]uint32
+	for _, row := range rows {
+		key := uint32(row >> 32)
+		if len(keys) == 0 || keys[len(keys)-1] != key {
+			keys = append(keys, key)
+			bitmaps = append(bitmaps, nil)
+		}
+		bitmaps[len(bitmaps)-1] = append(bitmaps[len(bitmaps)-1], uint32(row))
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, deletionVectorMagic)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(keys)))
+	for i, key := range keys {
+		data = binary.LittleEndian.AppendUint32(data, key)
+		data = appendRoaringBitmap(data, bitmaps[i])
+	}
+	return data
+}
+
+// appendRoaringBitmap appends the sorted values in the portable format of 32 bit roaring bitmaps, without run
+// containers
+func appendRoaringBitmap(data []byte, values []uint32) []byte {
+	var keys []uint16
+	var containers [][]uint16
+	for _, value := range values {
+		key := uint16(value >> 16)
+		if len(keys) == 0 || keys[len(keys)-1] != key {
+			keys = append(keys, key)
+			containers = append(containers, nil)
+		}
+		containers[len(containers)-1] = append(containers[len(containers)-1], uint16(value))
+	}
+
+	data = binary.LittleEndian.AppendUint32(data, roaringCookieNoRuns)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(keys)))
+	for i, key := range keys {
+		data = binary.LittleEndian.AppendUint16(data, key)
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(containers[i])-1))
+	}
+	// The offsets of the containers from the start of the bitmap
+	offset := 8 + 8*len(keys)
+	for _, container := range containers {
+		data = binary.LittleEndian.AppendUint32(data, uint32(offset))
+		if len(container) > roaringMaxArrayCardinality {
+			offset += 8192
+		} else {
+			offset += 2 * len(container)
+		}
+	}
+	for _, container := range containers {
+		if len(container) > roaringMaxArrayCardinality {
+			var words [1024]uint64
+			for _, value := range container {
+				words[value/64] |= 1 << (value % 64)
+			}
+			for _, word := range words {
+				data = binary.LittleEndian.AppendUint64(data, word)
+			}
+			continue
+		}
+		for _, value := range container {
+			data = binary.LittleEndian.AppendUint16(data, value)
+		}
+	}
+	return data
+}
+
+// deletionVectorReader reads the values of a serialized deletion vector, recording the first error.
+// Values are little endian, except for the headers of the native format, which are read with order.
+type deletionVectorReader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *deletionVectorReader) next(n int) []byte {
+	if r.err == nil && len(r.data) < n {
+		r.err = io.ErrUnexpectedEOF
+	}
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *deletionVectorReader) uint16() uint16 {
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *deletionVectorReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *deletionVectorReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+// decodeDeletionVector parses the row indexes of a serialized RoaringBitmapArray. In the portable format, each 32 bit
+// bitmap is preceded by the high 32 bits of its row indexes. In the native format, the 32 bit bitmaps of all the high
+// bits are serialized in order, each preceded by its size.
+func decodeDeletionVector(data []byte) ([]uint64, error) {
+	if len(data) < 4 {
+		return nil, errors.Join(ErrorInvalidDeletionVector, io.ErrUnexpectedEOF)
+	}
+	r := &deletionVectorReader{data: data[4:], order: binary.LittleEndian}
+	var numBitmaps uint64
+	native := false
+	switch {
+	case binary.LittleEndian.Uint32(data) == deletionVectorMagic:
+		numBitmaps = r.uint64()
+	case binary.LittleEndian.Uint32(data) == deletionVectorNativeMagic:
+		native = true
+	case binary.BigEndian.Uint32(data) == deletionVectorNativeMagic:
+		native, r.order = true, binary.BigEndian
+	default:
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("unexpected magic number %d", binary.LittleEndian.Uint32(data)))
+	}
+	if native {
+		numBitmaps = uint64(r.order.Uint32(r.next(4)))
+	}
+	if numBitmaps > uint64(len(data)) {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("invalid number of bitmaps %d", numBitmaps))
+	}
+
+	var rows []uint64
+	for i := uint64(0); i < numBitmaps && r.err == nil; i++ {
+		key := i << 32
+		if native {
+			r.next(4)
+		} else {
+			key = uint64(r.uint32()) << 32
+		}
+		values, err := decodeRoaringBitmap(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			rows = append(rows, key|uint64(value))
+		}
+	}
+	if r.err != nil {
+		return nil, errors.Join(ErrorInvalidDeletionVector, r.err)
+	}
+	return rows, nil
+}
+
+// decodeRoaringBitmap reads a 32 bit roaring bitmap serialized in the portable format
+func decodeRoaringBitmap(r *deletionVectorReader) ([]uint32, error) {
+	cookie := r.uint32()
+	var size int
+	var runFlags []byte
+	hasOffsets := true
+	switch {
+	case cookie == roaringCookieNoRuns:
+		size = int(r.uint32())
+	case cookie&0xffff == roaringCookie:
+		size = int(cookie>>16) + 1
+		runFlags = r.next((size + 7) / 8)
+		hasOffsets = size >= 4
+	default:
+		if r.err != nil {
+			return nil, errors.Join(ErrorInvalidDeletionVector, r.err)
+		}
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("unexpected roaring cookie %d", cookie))
+	}
+	if size > 65536 || size > len(r.data) {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("invalid number of containers %d", size))
+	}
+
+	keys := make([]uint32, size)
+	cardinalities := make([]int, size)
+	for i := range keys {
+		keys[i] = uint32(r.uint16()) << 16
+		cardinalities[i] = int(r.uint16()) + 1
+	}
+	if hasOffsets {
+		r.next(4 * size)
+	}
+	var values []uint32
+	for i, key := range keys {
+		switch {
+		case runFlags != nil && runFlags[i/8]&(1<<(i%8)) != 0:
+			numRuns := int(r.uint16())
+			for j := 0; j < numRuns && r.err == nil; j++ {
+				start := int(r.uint16())
+				end := start + int(r.uint16())
+				if end > 0xffff {
+					return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("invalid run %d to %d", start, end))
+				}
+				for value := start; value <= end; value++ {
+					values = append(values, key|uint32(value))
+				}
+			}
+		case cardinalities[i] > roaringMaxArrayCardinality:
+			for j := 0; j < 1024; j++ {
+				word := r.uint64()
+				for bit := 0; bit < 64; bit++ {
+					if word&(1<<bit) != 0 {
+						values = append(values, key|uint32(j*64+bit))
+					}
+				}
+			}
+		default:
+			for j := 0; j < cardinalities[i]; j++ {
+				values = append(values, key|uint32(r.uint16()))
+			}
+		}
+		if r.err != nil {
+			return nil, errors.Join(ErrorInvalidDeletionVector, r.err)
+		}
+	}
+	return values, nil
+}
+
+// encodeDeletionVectorFile returns a deletion vector file holding the serialized deletion vectors, and the offset of
+// each of them in the file. Each deletion vector is stored as its big endian size, its data and the big endian
+// CRC-32 checksum of its data.
+func encodeDeletionVectorFile(deletionVectors [][]byte) ([]byte, []int32) {
+	data := []byte{deletionVectorFileVersion}
+	offsets := make([]int32, 0, len(deletionVectors))
+	for _, deletionVector := range deletionVectors {
+		offsets = append(offsets, int32(len(data)))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(deletionVector)))
+		data = append(data, deletionVector...)
+		data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(deletionVector))
+	}
+	return data, offsets
+}
+
+// ReadDeletionVector returns the indexes of the rows of the data file marked as deleted by the deletion vector,
+// in ascending order. Deletion vector files are read from the data store, so that files with an absolute path
+// outside of the table cannot be read.
+func (table *DeltaTable) ReadDeletionVector(deletionVector *DeletionVectorDescriptor) ([]uint64, error) {
+	var data []byte
+	if deletionVector.StorageType == DELETION_VECTOR_INLINE {
+		decoded, err := z85Decode(deletionVector.PathOrInlineDv)
+		if err != nil {
+			return nil, errors.Join(ErrorInvalidDeletionVector, err)
+		}
+		if len(decoded) < int(deletionVector.SizeInBytes) {
+			return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("inline deletion vector of %d bytes, want %d", len(decoded), deletionVector.SizeInBytes))
+		}
+		data = decoded[:deletionVector.SizeInBytes]
+	} else {
+		var err error
+		data, err = table.readDeletionVectorFile(deletionVector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := decodeDeletionVector(data)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(rows)) != deletionVector.Cardinality {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%d deleted rows, want a cardinality of %d", len(rows), deletionVector.Cardinality))
+	}
+	return rows, nil
+}
+
+// readDeletionVectorFile returns the data of the deletion vector stored at its offset in a deletion vector file,
+// after checking its size and checksum
+func (table *DeltaTable) readDeletionVectorFile(deletionVector *DeletionVectorDescriptor) ([]byte, error) {
+	dvPath, _, err := deletionVector.Path()
+	if err != nil {
+		return nil, err
+	}
+	if deletionVector.StorageType == DELETION_VECTOR_ABSOLUTE_PATH {
+		relative, ok := relativePath(table.dataStore().RootURI(), dvPath)
+		if !ok {
+			return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%s is outside of the table", dvPath))
+		}
+		dvPath = relative
+	}
+	if deletionVector.Offset == nil || *deletionVector.Offset < 0 || deletionVector.SizeInBytes < 0 {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%s: invalid offset or size", dvPath))
+	}
+
+	location := storage.NewPath(dvPath)
+	start := int64(*deletionVector.Offset)
+	end := start + 4 + int64(deletionVector.SizeInBytes) + 4
+	var stored []byte
+	if rangeGetter, ok := table.dataStore().(storage.RangeGetter); ok {
+		stored, err = rangeGetter.GetRange(location, storage.Range{Start: start, End: end})
+	} else {
+		stored, err = table.dataStore().Get(location)
+		if err == nil && int64(len(stored)) >= end {
+			stored = stored[start:end]
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(stored)) != end-start {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%s is too short for a deletion vector at offset %d", dvPath, start))
+	}
+	if size := binary.BigEndian.Uint32(stored); size != uint32(deletionVector.SizeInBytes) {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%s: deletion vector of %d bytes, want %d", dvPath, size, deletionVector.SizeInBytes))
+	}
+	data := stored[4 : len(stored)-4]
+	if checksum := binary.BigEndian.Uint32(stored[len(stored)-4:]); checksum != crc32.ChecksumIEEE(data) {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%s: deletion vector checksum mismatch", dvPath))
+	}
+	return data, nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rivian/delta-go/lock/filelock"
+	"github.com/rivian/delta-go/state/filestate"
+	"github.com/rivian/delta-go/storage"
+	"github.com/rivian/delta-go/storage/filestore"
+)
+
+func TestDeletionVectorPath(t *testing.T) {
+	tests := []struct {
+		dv       DeletionVectorDescriptor
+		expected string
+		ok       bool
+		err      error
+	}{
+		// The example of the protocol
+		{DeletionVectorDescriptor{StorageType: "u", PathOrInlineDv: "ab^-aqEH.-t@S}K{vb[*k^"}, "ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin", true, nil},
+		{DeletionVectorDescriptor{StorageType: "u", PathOrInlineDv: "^-aqEH.-t@S}K{vb[*k^"}, "deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin", true, nil},
+		{DeletionVectorDescriptor{StorageType: "p", PathOrInlineDv: "s3://bucket/table/dv.bin"}, "s3://bucket/table/dv.bin", true, nil},
+		{DeletionVectorDescriptor{StorageType: "i", PathOrInlineDv: "wi5b=000010000siXQKl0rr91000f55c8Xg0@@D72lkbi5=-{L"}, "", false, nil},
+		{DeletionVectorDescriptor{StorageType: "u", PathOrInlineDv: "short"}, "", false, ErrorInvalidDeletionVector},
+		{DeletionVectorDescriptor{StorageType: "u", PathOrInlineDv: "ab^-aqEH.-t@S}K{vb[*k~"}, "", false, ErrorInvalidDeletionVector},
+		{DeletionVectorDescriptor{StorageType: "x"}, "", false, ErrorInvalidDeletionVector},
+	}
+	for _, test := range tests {
+		path, ok, err := test.dv.Path()
+		if !errors.Is(err, test.err) || path != test.expected || ok != test.ok {
+			t.Errorf("%+v: want %s %t %v, has %s %t %v", test.dv, test.expected, test.ok, test.err, path, ok, err)
+		}
+	}
+}
+
+func TestRemoveDeletionVector(t *testing.T) {
+	remove := Remove{Path: "part-1.parquet", Extras: map[string]json.RawMessage{
+		"deletionVector": json.RawMessage(`{"storageType":"u","pathOrInlineDv":"ab^-aqEH.-t@S}K{vb[*k^","offset":4,"sizeInBytes":40,"cardinality":6}`),
+	}}
+	path, ok, err := deletionVectorPath(remove.DeletionVector())
+	if err != nil || !ok || path != "ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin" {
+		t.Errorf("unexpected deletion vector path %s %t %v", path, ok, err)
+	}
+	if dv, err := (&Remove{Path: "part-2.parquet"}).DeletionVector(); dv != nil || err != nil {
+		t.Errorf("want no deletion vector, has %+v %v", dv, err)
+	}
+}
+
+func TestDeletionVectorEncoding(t *testing.T) {
+	// The inline example of the protocol, in the native format
+	table := NewDeltaTable(nil, nil, nil)
+	inline := &DeletionVectorDescriptor{StorageType: "i", PathOrInlineDv: "wi5b=000010000siXQKl0rr91000f55c8Xg0@@D72lkbi5=-{L", SizeInBytes: 40, Cardinality: 6}
+	rows, err := table.ReadDeletionVector(inline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, []uint64{3, 4, 7, 11, 18, 29}) {
+		t.Errorf("unexpected deleted rows %v", rows)
+	}
+	// Deletion vectors are written in the portable format
+	encoded := encodeDeletionVector(rows)
+	if magic := binary.LittleEndian.Uint32(encoded); magic != deletionVectorMagic || len(encoded) != 44 {
+		t.Errorf("want a portable deletion vector of 44 bytes, has magic %d and %d bytes", magic, len(encoded))
+	}
+	if decoded, err := decodeDeletionVector(encoded); err != nil || !reflect.DeepEqual(decoded, rows) {
+		t.Errorf("unexpected round trip %v %v", decoded, err)
+	}
+
+	// Array and bitmap containers, in bitmaps of different high bits
+	rows = []uint64{0, 9, 70000}
+	for row := uint64(1 << 17); row < 1<<17+5000; row++ {
+		rows = append(rows, row)
+	}
+	rows = append(rows, 1<<32+5, 3<<32)
+	decoded, err := decodeDeletionVector(encodeDeletionVector(rows))
+	if err != nil || !reflect.DeepEqual(decoded, rows) {
+		t.Errorf("unexpected round trip %d rows %v", len(decoded), err)
+	}
+
+	// A run container of the rows 5 to 14
+	data := binary.LittleEndian.AppendUint32(nil, deletionVectorMagic)
+	data = binary.LittleEndian.AppendUint64(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = append(data, 0x3b, 0x30, 0, 0, 1, 0, 0, 9, 0, 1, 0, 5, 0, 9, 0)
+	decoded, err = decodeDeletionVector(data)
+	if err != nil || !reflect.DeepEqual(decoded, []uint64{5, 6, 7, 8, 9, 10, 11, 12, 13, 14}) {
+		t.Errorf("unexpected run container rows %v %v", decoded, err)
+	}
+
+	if _, err := decodeDeletionVector(data[:len(data)-1]); !errors.Is(err, ErrorInvalidDeletionVector) {
+		t.Errorf("want ErrorInvalidDeletionVector, has %v", err)
+	}
+	if _, err := table.ReadDeletionVector(&DeletionVectorDescriptor{StorageType: "i", PathOrInlineDv: inline.PathOrInlineDv, SizeInBytes: 40, Cardinality: 5}); !errors.Is(err, ErrorInvalidDeletionVector) {
+		t.Errorf("want ErrorInvalidDeletionVector, has %v", err)
+	}
+}
+
+func TestReadDeletionVectorFile(t *testing.T) {
+	table, _, _ := setupTest(t)
+	first, second := encodeDeletionVector([]uint64{0, 9}), encodeDeletionVector([]uint64{2})
+	data, offsets := encodeDeletionVectorFile([][]byte{first, second})
+	if len(first) != 36 || offsets[0] != 1 {
+		t.Errorf("want a first deletion vector of 36 bytes at offset 1, has %d bytes at %d", len(first), offsets[0])
+	}
+	if err := table.Store.Put(storage.NewPath("ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin"), data); err != nil {
+		t.Fatal(err)
+	}
+
+	relative := &DeletionVectorDescriptor{StorageType: "u", PathOrInlineDv: "ab^-aqEH.-t@S}K{vb[*k^", Offset: &offsets[1], SizeInBytes: int32(len(second)), Cardinality: 1}
+	if rows, err := table.ReadDeletionVector(relative); err != nil || !reflect.DeepEqual(rows, []uint64{2}) {
+		t.Errorf("want row 2 deleted, has %v %v", rows, err)
+	}
+	absolute := &DeletionVectorDescriptor{StorageType: "p", PathOrInlineDv: table.Store.RootURI() + "/ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin", Offset: &offsets[0], SizeInBytes: int32(len(first)), Cardinality: 2}
+	if rows, err := table.ReadDeletionVector(absolute); err != nil || !reflect.DeepEqual(rows, []uint64{0, 9}) {
+		t.Errorf("want rows 0 and 9 deleted, has %v %v", rows, err)
+	}
+
+	// The size and checksum are checked
+	for _, dv := range []*DeletionVectorDescriptor{
+		{StorageType: "u", PathOrInlineDv: relative.PathOrInlineDv, Offset: &offsets[0], SizeInBytes: int32(len(second)), Cardinality: 1},
+		{StorageType: "p", PathOrInlineDv: "s3://other-bucket/dv.bin", Offset: &offsets[0], SizeInBytes: 36, Cardinality: 2},
+	} {
+		if _, err := table.ReadDeletionVector(dv); !errors.Is(err, ErrorInvalidDeletionVector) {
+			t.Errorf("%+v: want ErrorInvalidDeletionVector, has %v", dv, err)
+		}
+	}
+	data[offsets[1]+5] ^= 0xff
+	if err := table.Store.Put(storage.NewPath("ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin"), data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.ReadDeletionVector(relative); !errors.Is(err, ErrorInvalidDeletionVector) {
+		t.Errorf("want a checksum mismatch, has %v", err)
+	}
+}
+
+func TestDeletionVectorStorageTypes(t *testing.T) {
+	table, err := OpenTable(filestore.New(storage.NewPath("testdata/deletion_vectors")), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := table.Store.RootURI()
+	tests := []struct {
+		path string
+		uri  string
+		ok   bool
+		rows []uint64
+		err  error
+	}{
+		{"part-00000-u.snappy.parquet", root + "/ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin", true, []uint64{0, 9}, nil},
+		{"part-00001-i.snappy.parquet", "", false, []uint64{3, 4, 7, 11, 18, 29}, nil},
+		// Files outside of the table resolve but cannot be read from its store
+		{"part-00002-p.snappy.parquet", "s3://other-bucket/shared/deletion_vector_8a3c4c1e-2d5f-4b6a-9e7d-0f1a2b3c4d5e.bin", true, nil, ErrorInvalidDeletionVector},
+	}
+	for _, test := range tests {
+		add := table.State.Files[test.path]
+		deletionVector, err := add.DeletionVector()
+		if err != nil || deletionVector == nil {
+			t.Fatalf("%s: want a deletion vector, has %v %v", test.path, deletionVector, err)
+		}
+		if uri, ok, err := table.DeletionVectorUri(deletionVector); err != nil || uri != test.uri || ok != test.ok {
+			t.Errorf("%s: want %s %t, has %s %t %v", test.path, test.uri, test.ok, uri, ok, err)
+		}
+		if rows, err := table.ReadDeletionVector(deletionVector); !errors.Is(err, test.err) || !reflect.DeepEqual(rows, test.rows) {
+			t.Errorf("%s: want %v %v, has %v %v", test.path, test.rows, test.err, rows, err)
+		}
+	}
+	add := table.State.Files["part-00003.snappy.parquet"]
+	if deletionVector, err := add.DeletionVector(); deletionVector != nil || err != nil {
+		t.Errorf("want no deletion vector, has %+v %v", deletionVector, err)
+	}
+
+	// Relative deletion vectors of a shallow clone keep resolving to the files of the source table
+	clonePath := storage.NewPath(t.TempDir())
+	clone, err := table.ShallowClone(filestore.New(clonePath), filelock.New(clonePath, "_delta_log/_commit.lock", filelock.LockOptions{}), filestate.New(clonePath, "_delta_log/_commit.state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		add := table.State.Files[test.path]
+		source, _ := add.DeletionVector()
+		clonedAdd := clone.State.Files[absolutePath(root, test.path)]
+		cloned, err := clonedAdd.DeletionVector()
+		if err != nil || cloned == nil {
+			t.Fatalf("%s: want a deletion vector, has %v %v", test.path, cloned, err)
+		}
+		if uri, ok, err := clone.DeletionVectorUri(cloned); err != nil || uri != test.uri || ok != test.ok {
+			t.Errorf("%s: want %s %t in the clone, has %s %t %v", test.path, test.uri, test.ok, uri, ok, err)
+		}
+		if source.StorageType == DELETION_VECTOR_RELATIVE_PATH && cloned.StorageType != DELETION_VECTOR_ABSOLUTE_PATH {
+			t.Errorf("%s: want an absolute deletion vector in the clone, has %+v", test.path, cloned)
+		}
+	}
+	add = table.State.Files["part-00000-u.snappy.parquet"]
+	if deletionVector, _ := add.DeletionVector(); deletionVector.StorageType != DELETION_VECTOR_RELATIVE_PATH {
+		t.Errorf("the source table should be unchanged, has %+v", deletionVector)
+	}
+}
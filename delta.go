@@ -13,10 +13,19 @@
 package delta
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,13 +37,22 @@ import (
 	"golang.org/x/exp/maps"
 )
 
+// The version of delta-go
 const DELTA_CLIENT_VERSION = "alpha-0.0.0"
 
+// The engineInfo of the commitInfo of the commits written by delta-go, unless DeltaTransactionOptions.EngineInfo is set
+const DELTA_ENGINE_INFO = "delta-go/" + DELTA_CLIENT_VERSION
+
 var (
 	ErrorDeltaTable                  error = errors.New("failed to apply transaction log")
 	ErrorRetrieveLockBytes           error = errors.New("failed to retrieve bytes from lock")
 	ErrorLockDataEmpty               error = errors.New("lock data is empty")
 	ErrorExceededCommitRetryAttempts error = errors.New("exceeded commit retry attempts")
+	ErrorNotATable                   error = errors.New("not a Delta table")
+	ErrorInvalidVersion              error = errors.New("invalid version")
+	ErrorReadingLogEntry             error = errors.New("error reading log entry")
+	ErrorCloneTargetNotEmpty         error = errors.New("the clone target is not empty")
+	ErrorTableAlreadyExists          error = errors.New("the table already exists")
 )
 
 type DeltaTable struct {
@@ -44,8 +62,10 @@ type DeltaTable struct {
 	StateStore state.StateStore
 	// the load options used during load
 	Config DeltaTableConfig
-	// object store to access log and data files
+	// object store to access log files, and data files unless DataStore is set
 	Store storage.ObjectStore
+	// object store to access data files, if they live apart from the log
+	DataStore storage.ObjectStore
 	// Locking client to ensure optimistic locked commits from distributed workers
 	LockClient lock.Locker
 	// // file metadata for latest checkpoint
@@ -64,10 +84,112 @@ func NewDeltaTable(store storage.ObjectStore, lock lock.Locker, stateStore state
 	table.StateStore = stateStore
 	table.LockClient = lock
 	table.LastCheckPoint = CheckPoint{}
-	table.State = DeltaTableState{Version: -1}
+	table.State = *NewDeltaTableState(-1)
 	return table
 }
 
+// OpenTable loads the latest version of the table.
+// Loading a table never uses the lock or the state store, which may be nil for a reader.
+func OpenTable(store storage.ObjectStore, lock lock.Locker, stateStore state.StateStore) (*DeltaTable, error) {
+	table := NewDeltaTable(store, lock, stateStore)
+	err := table.Load()
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// CreateTable creates a new table in store by writing version 0 with the Metadata of the schema, partition
+// columns and table properties, and the lowest Protocol supporting the table features the properties require,
+// and returns the loaded table.
+// Version 0 is written with RenameIfNotExists, so the lock is not used to create the table; the lock and the state
+// store (which may be nil) are used by the commits that follow.
+// ErrorTableAlreadyExists is returned if the log already has a version.
+func CreateTable(store storage.ObjectStore, lock lock.Locker, stateStore state.StateStore, schema Schema, partitionColumns []string, properties map[string]string) (*DeltaTable, error) {
+	protocol, err := ProtocolForFeatures(tablePropertyFeatures(properties)...)
+	if err != nil {
+		return nil, err
+	}
+	return CreateTableWithProtocol(store, lock, stateStore, schema, partitionColumns, properties, protocol)
+}
+
+// CreateTableWithProtocol creates a new table like CreateTable, with the given protocol.
+// The protocol must be supported by delta-go, be consistent, and have the table features the properties require.
+func CreateTableWithProtocol(store storage.ObjectStore, lock lock.Locker, stateStore state.StateStore, schema Schema, partitionColumns []string, properties map[string]string, protocol Protocol) (*DeltaTable, error) {
+	if err := protocol.Validate(); err != nil {
+		return nil, err
+	}
+	for _, feature := range tablePropertyFeatures(properties) {
+		if !protocol.HasWriterFeature(feature) {
+			return nil, errors.Join(ErrorInvalidProtocol, fmt.Errorf("the table properties require table feature %s", feature))
+		}
+	}
+
+	table := NewDeltaTable(store, lock, stateStore)
+	exists, err := table.Exists()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrorTableAlreadyExists
+	}
+
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, partitionColumns, properties)
+	metaData := metadata.ToMetaData()
+	if err := metaData.Validate(); err != nil {
+		return nil, err
+	}
+
+	transaction := table.CreateTransaction(&DeltaTransactionOptions{NoLock: true})
+	transaction.AddActions([]Action{protocol, metaData})
+	operation := Create{Mode: ErrorIfExists, Location: store.RootURI(), Protocol: protocol, MetaData: *metadata}
+	commit, err := transaction.PrepareCommit(operation, nil)
+	if err != nil {
+		transaction.abortFailedCommit(&commit)
+		return nil, err
+	}
+	err = transaction.TryCommit(&commit)
+	if err != nil {
+		transaction.abortFailedCommit(&commit)
+		if errors.Is(err, storage.ErrorVersionAlreadyExists) {
+			return nil, errors.Join(ErrorTableAlreadyExists, err)
+		}
+		return nil, err
+	}
+
+	if stateStore != nil {
+		if err := stateStore.Put(state.CommitState{Version: 0}); err != nil {
+			return nil, err
+		}
+	}
+	err = table.Load()
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// OpenTableWithStores loads the latest version of a table whose log is in logStore and whose data files are in dataStore
+func OpenTableWithStores(logStore storage.ObjectStore, dataStore storage.ObjectStore, lock lock.Locker, stateStore state.StateStore) (*DeltaTable, error) {
+	table := NewDeltaTable(logStore, lock, stateStore)
+	table.DataStore = dataStore
+	err := table.Load()
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// OpenTableWithVersion loads the table at the given version
+func OpenTableWithVersion(store storage.ObjectStore, lock lock.Locker, stateStore state.StateStore, version state.DeltaDataTypeVersion) (*DeltaTable, error) {
+	table := NewDeltaTable(store, lock, stateStore)
+	err := table.LoadVersion(&version)
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
 // Creates a new DeltaTransaction for the DeltaTable.
 // The transaction holds a mutable reference to the DeltaTable, preventing other references
 // until the transaction is dropped.
@@ -77,7 +199,7 @@ func (table *DeltaTable) CreateTransaction(options *DeltaTransactionOptions) *De
 
 // / Return the uri of commit version.
 func (table *DeltaTable) CommitUriFromVersion(version state.DeltaDataTypeVersion) *storage.Path {
-	str := fmt.Sprintf("%020d.json", version)
+	str := version.String() + ".json"
 	path := storage.PathFromIter([]string{"_delta_log", str})
 	return &path
 }
@@ -87,19 +209,282 @@ func (table *DeltaTable) BaseCommitUri() *storage.Path {
 	return storage.NewPath("_delta_log")
 }
 
+// IsValidCommitUri returns true if the path is a commit file of the log, and not a checksum, checkpoint, log
+// compaction or temporary file of the same version
 func (table *DeltaTable) IsValidCommitUri(path *storage.Path) (bool, error) {
-	match, err := regexp.MatchString(`\d{20}\.json`, path.Base())
-	return match, err
+	kind, _, err := classifyLogFile(path.Raw)
+	return kind == LogFileCommit, err
+}
+
+// CompactedUriFromVersions returns the uri of the log compaction file summarizing the commits from startVersion to endVersion inclusive
+func (table *DeltaTable) CompactedUriFromVersions(startVersion state.DeltaDataTypeVersion, endVersion state.DeltaDataTypeVersion) *storage.Path {
+	str := startVersion.String() + "." + endVersion.String() + ".compacted.json"
+	path := storage.PathFromIter([]string{"_delta_log", str})
+	return &path
+}
+
+// A log compaction file covering the commits from Start to End inclusive
+type logCompaction struct {
+	Start state.DeltaDataTypeVersion
+	End   state.DeltaDataTypeVersion
+	Path  storage.Path
+}
+
+// Load loads the table state using the latest version in the log
+func (table *DeltaTable) Load() error {
+	return table.LoadVersion(nil)
+}
+
+// LoadVersion loads the table state at the given version by replaying the log.
+// If version is nil the latest version in the log is loaded.
+// The latest complete checkpoint at or below the version is loaded first, preferring the one referenced by
+// _last_checkpoint, and only the commits after it are replayed; if no checkpoint can be read, the log is
+// replayed from the first commit.
+// Log compaction files are used to skip over the individual commits in their range; if a compaction
+// file cannot be read or parsed, the individual commits are replayed instead.
+// Only the log is read: the lock and the state store of the writers are never used.
+func (table *DeltaTable) LoadVersion(version *state.DeltaDataTypeVersion) error {
+	commits, compactions, err := table.listLogFiles()
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return ErrorNotATable
+	}
+
+	var latestVersion state.DeltaDataTypeVersion = -1
+	for v := range commits {
+		latestVersion = max(latestVersion, v)
+	}
+	targetVersion := latestVersion
+	if version != nil {
+		if _, ok := commits[*version]; !ok {
+			return errors.Join(ErrorInvalidVersion, fmt.Errorf("version %d does not exist", *version))
+		}
+		targetVersion = *version
+	}
+
+	tableState, checkpoint, ok, err := table.loadLatestCheckpoint(targetVersion)
+	if err != nil {
+		return err
+	}
+	startVersion := checkpoint.Version.Next()
+	if !ok {
+		tableState = NewDeltaTableState(-1)
+		startVersion = 0
+	}
+	err = table.replayLog(tableState, startVersion, targetVersion, compactions)
+	if err != nil {
+		return err
+	}
+	table.State = *tableState
+	table.LastCheckPoint = checkpoint
+	return nil
+}
+
+// replayLog applies the commits from startVersion to targetVersion inclusive to the table state,
+// using log compaction files to skip over individual commits where possible.
+// With Config.StrictPartitionValidation, the partition paths of all the active files are then validated.
+func (table *DeltaTable) replayLog(tableState *DeltaTableState, startVersion state.DeltaDataTypeVersion, targetVersion state.DeltaDataTypeVersion, compactions []logCompaction) error {
+	currentVersion := startVersion
+	for currentVersion <= targetVersion {
+		if compaction, ok := bestCompaction(compactions, currentVersion, targetVersion); ok {
+			actions, err := table.readLogEntry(&compaction.Path)
+			if err == nil {
+				if err := tableState.applyActions(actions); err != nil {
+					return fmt.Errorf("versions %d to %d: %w", compaction.Start, compaction.End, err)
+				}
+				currentVersion = compaction.End + 1
+				continue
+			}
+			log.Debugf("delta-go: unable to read log compaction file %s, falling back to commits: %v", compaction.Path.Raw, err)
+		}
+
+		if err := table.applyLogEntry(tableState, table.CommitUriFromVersion(currentVersion)); err != nil {
+			if errors.Is(err, ErrorReadingLogEntry) {
+				return err
+			}
+			return fmt.Errorf("version %d: %w", currentVersion, err)
+		}
+		currentVersion++
+	}
+	tableState.Version = targetVersion
+	if table.Config.StrictPartitionValidation {
+		for _, add := range tableState.Files {
+			if err := add.ValidatePartitionPath(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listLogFiles returns the metadata of the commit files by version and the log compaction files found in the log directory
+func (table *DeltaTable) listLogFiles() (map[state.DeltaDataTypeVersion]storage.ObjectMeta, []logCompaction, error) {
+	results, err := table.Store.List(table.BaseCommitUri())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commits := make(map[state.DeltaDataTypeVersion]storage.ObjectMeta)
+	var compactions []logCompaction
+	for _, result := range results {
+		kind, version, err := classifyLogFile(result.Location.Raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch kind {
+		case LogFileCommit:
+			commits[version] = result
+		case LogFileCompaction:
+			match := compactedFileRegex.FindStringSubmatch(result.Location.Base())
+			start, err := strconv.ParseInt(match[1], 10, 64)
+			if err != nil {
+				return nil, nil, err
+			}
+			compactions = append(compactions, logCompaction{
+				Start: state.DeltaDataTypeVersion(start),
+				End:   version,
+				Path:  *table.CompactedUriFromVersions(state.DeltaDataTypeVersion(start), version),
+			})
+		}
+	}
+	return commits, compactions, nil
+}
+
+// bestCompaction returns the compaction file starting at startVersion that covers the most commits without going past targetVersion
+func bestCompaction(compactions []logCompaction, startVersion state.DeltaDataTypeVersion, targetVersion state.DeltaDataTypeVersion) (logCompaction, bool) {
+	var best logCompaction
+	found := false
+	for _, compaction := range compactions {
+		if compaction.Start != startVersion || compaction.End > targetVersion || compaction.End < compaction.Start {
+			continue
+		}
+		if !found || compaction.End > best.End {
+			best = compaction
+			found = true
+		}
+	}
+	return best, found
+}
+
+// readLogEntry reads and parses the actions stored in a commit or log compaction file
+func (table *DeltaTable) readLogEntry(path *storage.Path) ([]Action, error) {
+	data, err := table.readLogEntryRaw(path)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := ActionsFromLogEntries(data)
+	if err != nil {
+		return nil, errors.Join(ErrorReadingLogEntry, err)
+	}
+	return actions, nil
+}
+
+// applyLogEntry folds the actions of a commit file into the table state one entry at a time, so that the actions
+// of the commit are never held in memory together.
+// The table state is left partially updated if an entry cannot be parsed or applied.
+func (table *DeltaTable) applyLogEntry(tableState *DeltaTableState, path *storage.Path) error {
+	reader, err := table.openLogEntry(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	var applyErr error
+	err = foldLogEntries(reader, func(action Action) error {
+		applyErr = tableState.processAction(action)
+		return applyErr
+	})
+	if err != nil && applyErr == nil {
+		return errors.Join(ErrorReadingLogEntry, err)
+	}
+	return err
+}
+
+// readLogEntryRaw reads the unparsed content of a commit or log compaction file, decompressing it if it is gzipped
+func (table *DeltaTable) readLogEntryRaw(path *storage.Path) ([]byte, error) {
+	reader, err := table.openLogEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Join(ErrorReadingLogEntry, err)
+	}
+	return data, nil
+}
+
+// openLogEntry returns a reader of the content of a commit or log compaction file, decompressing it if it is gzipped
+func (table *DeltaTable) openLogEntry(path *storage.Path) (io.ReadCloser, error) {
+	data, err := table.Store.Get(path)
+	if err != nil {
+		return nil, errors.Join(ErrorReadingLogEntry, err)
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Join(ErrorReadingLogEntry, err)
+	}
+	return reader, nil
+}
+
+// The leading bytes of gzip compressed content
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ReadCommitRaw returns the unparsed JSON content of the commit file for the given version
+func (table *DeltaTable) ReadCommitRaw(version state.DeltaDataTypeVersion) ([]byte, error) {
+	return table.readLogEntryRaw(table.CommitUriFromVersion(version))
+}
+
+// ReadActions returns the actions of the commit file for the given version, without loading the table state.
+// Returns an error wrapping ErrorReadingLogEntry and storage.ErrorObjectDoesNotExist if the version is not in the log.
+func (table *DeltaTable) ReadActions(version state.DeltaDataTypeVersion) ([]Action, error) {
+	return table.readLogEntry(table.CommitUriFromVersion(version))
+}
+
+// WriteCommitRaw commits pre-serialized content as the given version.
+// The content is written to a temporary file and renamed into place with RenameIfNotExists, so an existing
+// version is never overwritten. The lock and the state store are not used, and the content is not validated.
+func (table *DeltaTable) WriteCommitRaw(version state.DeltaDataTypeVersion, data []byte) error {
+	token := uuid.New().String()
+	tmpPath := storage.PathFromIter([]string{"_delta_log", fmt.Sprintf("_commit_%s.json.tmp", token)})
+	err := table.Store.Put(&tmpPath, data)
+	if err != nil {
+		return err
+	}
+	err = table.Store.RenameIfNotExists(&tmpPath, table.CommitUriFromVersion(version))
+	if err != nil {
+		if deleteErr := table.Store.Delete(&tmpPath); deleteErr != nil {
+			log.Warnf("delta-go: unable to remove temporary commit file %s: %v", tmpPath.Raw, deleteErr)
+		}
+		return err
+	}
+	return nil
 }
 
 // / Create a DeltaTable with version 0 given the provided MetaData, Protocol, and CommitInfo
 func (table *DeltaTable) Create(metadata DeltaTableMetaData, protocol Protocol, commitInfo CommitInfo, addActions []Add) error {
+	actions := make([]Action, 0, len(addActions))
+	for _, add := range addActions {
+		actions = append(actions, add)
+	}
+	return table.create(metadata, protocol, commitInfo, actions)
+}
+
+// create commits version 0 of the table with the given actions following the protocol and metadata
+func (table *DeltaTable) create(metadata DeltaTableMetaData, protocol Protocol, commitInfo CommitInfo, tableActions []Action) error {
 	meta := metadata.ToMetaData()
 
 	// delta-rs commit info will include the delta-rs version and timestamp as of now
 	enrichedCommitInfo := maps.Clone(commitInfo)
 	enrichedCommitInfo["clientVersion"] = fmt.Sprintf("delta-go.%s", DELTA_CLIENT_VERSION)
 	enrichedCommitInfo["timestamp"] = time.Now().UnixMilli()
+	if _, ok := enrichedCommitInfo["engineInfo"]; !ok {
+		enrichedCommitInfo["engineInfo"] = DELTA_ENGINE_INFO
+	}
 
 	actions := []Action{
 		enrichedCommitInfo,
@@ -107,9 +492,7 @@ func (table *DeltaTable) Create(metadata DeltaTableMetaData, protocol Protocol,
 		meta,
 	}
 
-	for _, add := range addActions {
-		actions = append(actions, add)
-	}
+	actions = append(actions, tableActions...)
 
 	transaction := table.CreateTransaction(nil)
 	transaction.AddActions(actions)
@@ -144,6 +527,23 @@ func (table *DeltaTable) Create(metadata DeltaTableMetaData, protocol Protocol,
 	return nil
 }
 
+// dataStore returns the object store holding the data files of the table
+func (table *DeltaTable) dataStore() storage.ObjectStore {
+	if table.DataStore != nil {
+		return table.DataStore
+	}
+	return table.Store
+}
+
+// Close releases the resources held by the table's object stores. The table must not be used after Close.
+func (table *DeltaTable) Close() error {
+	err := table.Store.Close()
+	if table.DataStore != nil && table.DataStore != table.Store {
+		err = errors.Join(err, table.DataStore.Close())
+	}
+	return err
+}
+
 // / Exists checks if a DeltaTable with version 0 exists in the object store.
 func (table *DeltaTable) Exists() (bool, error) {
 	path := table.CommitUriFromVersion(0)
@@ -184,45 +584,125 @@ func (table *DeltaTable) Exists() (bool, error) {
 	return true, nil
 }
 
+// NextVersion returns the version the next commit to the table should try: one past the latest committed version in
+// the log, or past the latest version recorded in the state store if it is ahead, since the state store records the
+// versions writers have tried. The next version of a table without commits is 0.
+// A state store that cannot be read is ignored, as in TryCommit.
+func (table *DeltaTable) NextVersion() (state.DeltaDataTypeVersion, error) {
+	commits, _, err := table.listLogFiles()
+	if err != nil {
+		return 0, err
+	}
+	var latestVersion state.DeltaDataTypeVersion = -1
+	for version := range commits {
+		latestVersion = max(latestVersion, version)
+	}
+	if table.StateStore != nil {
+		commitState, err := table.StateStore.Get()
+		if err != nil {
+			log.Debugf("delta-go: unable to read the state store, using the log for the next version: %v", err)
+		} else {
+			latestVersion = max(latestVersion, commitState.Version)
+		}
+	}
+	return latestVersion.Next(), nil
+}
+
+// ShallowClone creates a new table in targetStore whose version 0 references the data files of the
+// loaded table state without copying them.
+// The Add actions of the clone use absolute paths into the source table, including the paths of their
+// deletion vectors, the Metadata (with a new table id), the Protocol with its table features, and the domain
+// metadata are copied from the source, and the clone source is recorded in the commitInfo.
+// The target store must not contain any objects.
+func (table *DeltaTable) ShallowClone(targetStore storage.ObjectStore, targetLock lock.Locker, targetStateStore state.StateStore) (*DeltaTable, error) {
+	if table.State.Version < 0 {
+		return nil, ErrorNotATable
+	}
+
+	existing, err := targetStore.List(storage.NewPath(""))
+	if err != nil {
+		return nil, err
+	}
+	for _, meta := range existing {
+		// Empty directories are allowed in the target
+		location := meta.Location.Raw
+		if len(location) > 0 && !os.IsPathSeparator(location[len(location)-1]) {
+			return nil, errors.Join(ErrorCloneTargetNotEmpty, fmt.Errorf("found object %s", location))
+		}
+	}
+
+	sourceURI := table.TableUri()
+	dataURI := table.dataStore().RootURI()
+	addActions := make([]Add, 0, len(table.State.Files))
+	for _, add := range table.State.Files {
+		add.Path = absolutePath(dataURI, add.Path)
+		if err := add.absoluteDeletionVector(dataURI); err != nil {
+			return nil, err
+		}
+		addActions = append(addActions, add)
+	}
+	sort.Slice(addActions, func(i, j int) bool { return addActions[i].Path < addActions[j].Path })
+	actions := make([]Action, 0, len(addActions)+len(table.State.Domains))
+	for _, add := range addActions {
+		actions = append(actions, add)
+	}
+	domains := make([]string, 0, len(table.State.Domains))
+	for domain := range table.State.Domains {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		actions = append(actions, table.State.Domains[domain])
+	}
+
+	metadata := table.State.CurrentMetadata
+	metadata.Id = uuid.New()
+	metadata.CreatedTime = time.Now()
+	operation := Clone{Source: sourceURI, SourceVersion: DeltaDataTypeVersion(table.State.Version), IsShallow: true}
+
+	target := NewDeltaTable(targetStore, targetLock, targetStateStore)
+	err = target.create(metadata, table.State.protocol(), operation.GetCommitInfo(), actions)
+	if err != nil {
+		return nil, err
+	}
+	err = target.Load()
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// absolutePath resolves a (possibly relative) Add path against the table root uri
+func absolutePath(rootURI string, path string) string {
+	if strings.Contains(path, "://") {
+		return path
+	}
+	return strings.TrimSuffix(rootURI, "/") + "/" + path
+}
+
+// relativePath returns the path relative to the root uri of an absolute path under it, or false if the path is not
+// absolute or is outside of the root
+func relativePath(rootURI string, path string) (string, bool) {
+	prefix := strings.TrimSuffix(rootURI, "/") + "/"
+	if !strings.Contains(path, "://") || !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(path, prefix), true
+}
+
 // The URI of the underlying data
-// func (table *DeltaTable) TableUri() string {
-// 	return table.Store.RootURI()
-// }
+func (table *DeltaTable) TableUri() string {
+	return table.Store.RootURI()
+}
 
 // / Metadata for a checkpoint file
 type CheckPoint struct {
 	/// Delta table version
-	Version state.DeltaDataTypeVersion
+	Version state.DeltaDataTypeVersion `json:"version"`
 	// 20 digits decimals
-	Size DeltaDataTypeLong
+	Size DeltaDataTypeLong `json:"size"`
 	// 10 digits decimals
-	Parts uint32
-}
-
-type DeltaTableState struct {
-	// current table version represented by this table state
-	Version state.DeltaDataTypeVersion
-	// A remove action should remain in the state of the table as a tombstone until it has expired.
-	// A tombstone expires when the creation timestamp of the delta file exceeds the expiration
-	Tombstones map[*Remove]bool
-	// active files for table state
-	Files []Add
-	// Information added to individual commits
-	CommitInfos           map[string]CommitInfo
-	AppTransactionVersion map[string]state.DeltaDataTypeVersion
-	MinReaderVersion      int32
-	MinWriterVersion      int32
-	// table metadata corresponding to current version
-	CurrentMetadata DeltaTableMetaData
-	// retention period for tombstones in milli-seconds
-	TombstoneRetention time.Duration
-	// retention period for log entries in milli-seconds
-	LogRetention            time.Duration
-	EnableExpiredLogCleanup bool
-}
-
-func (state *DeltaTableState) WithVersion(version state.DeltaDataTypeVersion) {
-	state.Version = version
+	Parts uint32 `json:"parts,omitempty"`
 }
 
 // Delta table metadata
@@ -286,6 +766,10 @@ type DeltaTableConfig struct {
 	/// may want to skip them.
 	/// defaults to true as a safe default.
 	RequireTombstones bool
+	// StrictPartitionValidation fails loading the table with ErrorPartitionPathMismatch if the partition
+	// directories in the path of an active file disagree with its partitionValues. Mismatches are only logged
+	// by default, since checking every active file slows down loading large tables.
+	StrictPartitionValidation bool
 }
 
 // / Object representing a delta transaction.
@@ -297,12 +781,19 @@ type DeltaTableConfig struct {
 // / the `prepare_commit` and `try_commit_transaction` methods and manage the Delta version
 // / themselves so that they can resolve data conflicts that may occur between Delta versions.
 // /
-// / Please not that in case of non-retryable error the temporary commit file such as
-// / `_delta_log/_commit_<uuid>.json` will orphaned in storage.
+// / Please not that in case of non-retryable error that may occur after the commit was renamed into place, the
+// / temporary commit file such as `_delta_log/_commit_<uuid>.json` will orphaned in storage.
 type DeltaTransaction struct {
 	DeltaTable *DeltaTable
 	Actions    []Action
 	Options    *DeltaTransactionOptions
+	// data files written for this transaction, removed if the transaction is aborted
+	dataFiles []storage.Path
+	// the version of the latest commit attempt, so that retries try the following versions
+	version state.DeltaDataTypeVersion
+	// whether the commits after readVersion are checked for conflicts before each commit attempt, see createCheckedTransaction
+	checkConflicts bool
+	readVersion    state.DeltaDataTypeVersion
 }
 
 // / Creates a new delta transaction.
@@ -312,6 +803,7 @@ func NewDeltaTransaction(deltaTable *DeltaTable, options *DeltaTransactionOption
 	transaction := new(DeltaTransaction)
 	transaction.DeltaTable = deltaTable
 	transaction.Options = options
+	transaction.version = -1
 	return transaction
 }
 
@@ -325,9 +817,64 @@ func (transaction *DeltaTransaction) AddActions(actions []Action) {
 	transaction.Actions = append(transaction.Actions, actions...)
 }
 
-// Commits the given actions to the delta log.
+// PutDataFile writes a data file to the table data store and tracks it as part of this transaction,
+// so that it is removed by AbortWrite if the transaction cannot be committed.
+func (transaction *DeltaTransaction) PutDataFile(location *storage.Path, data []byte) error {
+	err := transaction.DeltaTable.dataStore().Put(location, data)
+	if err != nil {
+		return err
+	}
+	transaction.TrackDataFile(location)
+	return nil
+}
+
+// TrackDataFile tracks a data file that was written for this transaction outside of PutDataFile
+func (transaction *DeltaTransaction) TrackDataFile(location *storage.Path) {
+	transaction.dataFiles = append(transaction.dataFiles, *location)
+}
+
+// AbortWrite deletes the data files tracked by this transaction.
+// Cleanup is best-effort: every file is attempted, failures are logged and returned joined together.
+// AbortWrite must not be called after a successful commit since the files are then part of the table.
+func (transaction *DeltaTransaction) AbortWrite() error {
+	var errs []error
+	for i := range transaction.dataFiles {
+		location := transaction.dataFiles[i]
+		if err := transaction.DeltaTable.dataStore().Delete(&location); err != nil {
+			log.Warnf("delta-go: unable to remove data file %s of aborted transaction: %v", location.Raw, err)
+			errs = append(errs, err)
+		}
+	}
+	transaction.dataFiles = nil
+	return errors.Join(errs...)
+}
+
+// abortFailedCommit cleans up after a commit that definitely did not happen.
+// Failures are only logged so they do not mask the original commit error.
+func (transaction *DeltaTransaction) abortFailedCommit(commit *PreparedCommit) {
+	if commit != nil && commit.URI.Raw != "" {
+		if err := transaction.DeltaTable.Store.Delete(&commit.URI); err != nil {
+			log.Warnf("delta-go: unable to remove temporary commit file %s: %v", commit.URI.Raw, err)
+		}
+	}
+	transaction.AbortWrite()
+}
+
+// Commits the given actions to the delta log and returns the committed version.
 // This method will retry the transaction commit based on the value of `max_retry_commit_attempts` set in `DeltaTransactionOptions`.
+// Once committed, the table state is brought to the committed version, see TryCommit.
+// If the commit fails before it is renamed into place, for instance when the retries are exhausted or the rename
+// is rejected by the store, the temporary commit file and the data files tracked by the transaction are removed.
 func (transaction *DeltaTransaction) Commit(operation DeltaOperation, appMetadata map[string]any) (state.DeltaDataTypeVersion, error) {
+	return transaction.CommitWithContext(context.Background(), operation, appMetadata)
+}
+
+// CommitWithContext is Commit giving up when the context is cancelled before the commit happens, for instance
+// to cancel a long running Optimize or other maintenance operation. The error of the context is returned and,
+// as when the retries are exhausted, the temporary commit file and the data files tracked by the transaction are
+// removed, so that the table is left unchanged. Once the commit file is being renamed into place the commit is
+// no longer interrupted.
+func (transaction *DeltaTransaction) CommitWithContext(ctx context.Context, operation DeltaOperation, appMetadata map[string]any) (state.DeltaDataTypeVersion, error) {
 	// TODO: stubbing `operation` parameter (which will be necessary for writing the CommitInfo action),
 	// but leaving it unused for now. `CommitInfo` is a fairly dynamic data structure so we should work
 	// out the data structure approach separately.
@@ -347,13 +894,46 @@ func (transaction *DeltaTransaction) Commit(operation DeltaOperation, appMetadat
 	//     IsolationLevel::Serializable
 	// };
 
+	if err := ctx.Err(); err != nil {
+		transaction.AbortWrite()
+		return transaction.DeltaTable.State.Version, err
+	}
 	PreparedCommit, err := transaction.PrepareCommit(operation, appMetadata)
+	if err != nil {
+		transaction.abortFailedCommit(&PreparedCommit)
+		return transaction.DeltaTable.State.Version, err
+	}
+
+	err = transaction.tryCommitLoop(ctx, &PreparedCommit)
+	// Only clean up when the commit is known not to have happened; other errors may occur after
+	// the commit file was renamed into place, in which case the caller must decide whether to call AbortWrite.
+	if commitNotHappened(ctx, err) {
+		transaction.abortFailedCommit(&PreparedCommit)
+	}
 	if err != nil {
 		return transaction.DeltaTable.State.Version, err
 	}
+	return transaction.version, nil
+}
+
+// commitNotHappened returns true if the commit error shows that the prepared commit was not renamed into place
+func commitNotHappened(ctx context.Context, err error) bool {
+	var notRenamed commitNotRenamedError
+	return errors.Is(err, ErrorExceededCommitRetryAttempts) || errors.Is(err, lock.ErrorLockLost) || errors.Is(err, ErrorConcurrentModification) ||
+		(ctx.Err() != nil && errors.Is(err, ctx.Err())) || errors.As(err, &notRenamed)
+}
+
+// commitNotRenamedError wraps a commit error that occurred before the prepared commit was renamed into place
+type commitNotRenamedError struct {
+	err error
+}
+
+func (e commitNotRenamedError) Error() string {
+	return e.err.Error()
+}
 
-	err = transaction.TryCommitLoop(&PreparedCommit)
-	return transaction.DeltaTable.State.Version, err
+func (e commitNotRenamedError) Unwrap() error {
+	return e.err
 }
 
 // / Low-level transaction API. Creates a temporary commit file. Once created,
@@ -370,18 +950,37 @@ func (transaction *DeltaTransaction) PrepareCommit(operation DeltaOperation, app
 	}
 	//if not any commit, add new commit info
 	if !anyCommitInfo {
+		if op, ok := operation.(metricsOperation); ok {
+			operation = op.withMetrics(transaction.Actions)
+		}
 		commitInfo := make(CommitInfo)
-		commitInfo["timestamp"] = time.Now().UnixMilli()
+		// The timestamp is moved after the one of the previous version once the version is known, see TryCommit
+		commitInfo["timestamp"] = timeNow().UnixMilli()
 		commitInfo["clientVersion"] = fmt.Sprintf("delta-go.%s", DELTA_CLIENT_VERSION)
+		commitInfo["engineInfo"] = DELTA_ENGINE_INFO
+		if transaction.Options != nil && transaction.Options.EngineInfo != "" {
+			commitInfo["engineInfo"] = transaction.Options.EngineInfo
+		}
 		maps.Copy(commitInfo, operation.GetCommitInfo())
 		maps.Copy(commitInfo, appMetadata)
 		transaction.AddAction(commitInfo)
 	}
 
+	// Only collect stats for the columns indexed by the table
+	err := transaction.limitAddStats()
+	if err != nil {
+		return PreparedCommit{}, err
+	}
+
+	err = transaction.checkRowTracking()
+	if err != nil {
+		return PreparedCommit{}, err
+	}
+
 	// Serialize all actions that are part of this log entry.
 	logEntry, err := LogEntryFromActions(transaction.Actions)
 	if err != nil {
-		return PreparedCommit{}, nil
+		return PreparedCommit{}, err
 	}
 
 	// Write delta log entry as temporary file to storage. For the actual commit,
@@ -390,7 +989,7 @@ func (transaction *DeltaTransaction) PrepareCommit(operation DeltaOperation, app
 	fileName := fmt.Sprintf("_commit_%s.json.tmp", token)
 	// TODO: Open question, should storagePath use the basePath for the transaction or just hard code the _delta_log path?
 	path := storage.Path{Raw: filepath.Join("_delta_log", fileName)}
-	commit := PreparedCommit{URI: path}
+	commit := PreparedCommit{URI: path, logEntry: logEntry}
 
 	err = transaction.DeltaTable.Store.Put(&path, logEntry)
 	if err != nil {
@@ -400,16 +999,90 @@ func (transaction *DeltaTransaction) PrepareCommit(operation DeltaOperation, app
 	return commit, nil
 }
 
+// orderCommitTimestamp moves the timestamp of the prepared commit to 1ms after the timestamp of the previous version
+// if the clock went backward, so that commit timestamps increase with the version, rewriting the prepared commit file.
+// The timestamp of the previous version is cached by the table, so it is only read from the log for the first commit.
+// Returns the timestamp of the commit, or the zero time if the commit has no timestamp.
+func (transaction *DeltaTransaction) orderCommitTimestamp(commit *PreparedCommit, version state.DeltaDataTypeVersion) (time.Time, error) {
+	var commitInfo CommitInfo
+	for _, action := range transaction.Actions {
+		if info, ok := action.(CommitInfo); ok {
+			commitInfo = info
+			break
+		}
+	}
+	timestamp, ok := commitInfo.Timestamp()
+	if !ok || version == 0 {
+		return timestamp, nil
+	}
+
+	table := transaction.DeltaTable
+	previous, ok := table.VersionTimestamp[DeltaDataTypeVersion(version.Prev())]
+	if !ok {
+		commitPath := table.CommitUriFromVersion(version.Prev())
+		meta, err := table.Store.Head(commitPath)
+		if err == nil {
+			previous, err = table.commitTimestamp(version.Prev(), meta)
+		}
+		if err != nil {
+			log.Debugf("delta-go: unable to read the timestamp of commit %s: %v", commitPath.Raw, err)
+			return timestamp, nil
+		}
+	}
+	if timestamp.After(previous) {
+		return timestamp, nil
+	}
+
+	// A prepared commit that is gone, for instance because it was already committed, must not be written again
+	if _, err := table.Store.Head(&commit.URI); err != nil {
+		return timestamp, err
+	}
+	timestamp = previous.Add(time.Millisecond)
+	commitInfo["timestamp"] = timestamp.UnixMilli()
+	logEntry, err := LogEntryFromActions(transaction.Actions)
+	if err != nil {
+		return timestamp, commitNotRenamedError{err}
+	}
+	if err := table.Store.Put(&commit.URI, logEntry); err != nil {
+		return timestamp, commitNotRenamedError{err}
+	}
+	commit.logEntry = logEntry
+	return timestamp, nil
+}
+
+// cacheCommitTimestamp records the timestamp of a version committed by the transaction
+func (table *DeltaTable) cacheCommitTimestamp(version state.DeltaDataTypeVersion, timestamp time.Time) {
+	if timestamp.IsZero() {
+		return
+	}
+	if table.VersionTimestamp == nil {
+		table.VersionTimestamp = make(map[DeltaDataTypeVersion]time.Time)
+	}
+	table.VersionTimestamp[DeltaDataTypeVersion(version)] = timestamp
+}
+
 // TryCommitLoop: Loads metadata from lock containing the latest locked version and tries to obtain the lock and commit for the version + 1 in a loop
 func (transaction *DeltaTransaction) TryCommitLoop(commit *PreparedCommit) error {
+	return transaction.tryCommitLoop(context.Background(), commit)
+}
+
+// tryCommitLoop is TryCommitLoop returning the error of the context if it is done before an attempt
+func (transaction *DeltaTransaction) tryCommitLoop(ctx context.Context, commit *PreparedCommit) error {
 	attemptNumber := 0
+	start := time.Now()
 	for {
 		if attemptNumber > 0 {
-			time.Sleep(transaction.Options.RetryWaitDuration)
+			select {
+			case <-time.After(transaction.Options.retryBackoff(attemptNumber)):
+			case <-ctx.Done():
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 		if attemptNumber > int(transaction.Options.MaxRetryCommitAttempts)+1 {
 			log.Debugf("Transaction attempt failed. Attempts exhausted beyond max_retry_commit_attempts of %d so failing.", transaction.Options.MaxRetryCommitAttempts)
-			return ErrorExceededCommitRetryAttempts
+			return fmt.Errorf("%w: %d attempts in %s", ErrorExceededCommitRetryAttempts, attemptNumber, time.Since(start))
 		}
 
 		err := transaction.TryCommit(commit)
@@ -432,8 +1105,13 @@ func (transaction *DeltaTransaction) TryCommitLoop(commit *PreparedCommit) error
 
 }
 
-// TryCommitLoop: Loads metadata from lock containing the latest locked version and tries to obtain the lock and commit for the version + 1 in a loop
+// TryCommit: Loads metadata from lock containing the latest locked version and tries to obtain the lock and commit for the version + 1.
+// The version tried is kept by the transaction so that the next attempt tries the following version, while the table
+// state is only changed once the commit happened, by applying the committed actions or updating it from the log.
 func (transaction *DeltaTransaction) TryCommit(commit *PreparedCommit) error {
+	if transaction.Options != nil && transaction.Options.NoLock {
+		return transaction.tryCommitWithoutLock(commit)
+	}
 
 	var err error
 	// Step 1) Acquire Lock
@@ -451,6 +1129,9 @@ func (transaction *DeltaTransaction) TryCommit(commit *PreparedCommit) error {
 	}
 
 	if locked {
+		// Keep the lease alive for the duration of the commit; renewal stops when the lock is released
+		lockLost := transaction.DeltaTable.LockClient.AutoRenew(context.Background())
+
 		// 2) Lookup the latest prior state
 		priorState, err := transaction.DeltaTable.StateStore.Get()
 		// if err != nil {
@@ -461,8 +1142,20 @@ func (transaction *DeltaTransaction) TryCommit(commit *PreparedCommit) error {
 		// 4) Update the state with the latest tried, even in the case that the
 		// RenameNotExists was unsuccessful, this ensures that the next try increments the version
 		// Take the max of the local state and remote state version in the case that the remote state is not accessible.
-		version := max(priorState.Version, transaction.DeltaTable.State.Version) + 1
-		transaction.DeltaTable.State.WithVersion(version)
+		version := max(max(priorState.Version, transaction.DeltaTable.State.Version), transaction.version) + 1
+
+		// Another writer may hold the lock if the lease lapsed, in which case neither the state
+		// nor the log may be written based on this writer's view of the table
+		select {
+		case lostErr := <-lockLost:
+			return lostErr
+		default:
+		}
+		if err := transaction.checkConcurrentCommits(version); err != nil {
+			return err
+		}
+
+		transaction.version = version
 		newState := state.CommitState{
 			Version: version,
 		}
@@ -472,13 +1165,20 @@ func (transaction *DeltaTransaction) TryCommit(commit *PreparedCommit) error {
 			}
 		}()
 
+		timestamp, err := transaction.orderCommitTimestamp(commit, version)
+		if err != nil {
+			return err
+		}
+
 		// 3) Try to Rename the file
 		from := storage.NewPath(commit.URI.Raw)
 		to := transaction.DeltaTable.CommitUriFromVersion(version)
-		err = transaction.DeltaTable.Store.RenameIfNotExists(from, to)
+		err = transaction.renameCommit(from, to, lockLost)
 		if err != nil {
 			return err
 		}
+		transaction.DeltaTable.cacheCommitTimestamp(version, timestamp)
+		transaction.applyCommit(commit, version)
 
 	} else {
 		return errors.Join(lock.ErrorLockNotObtained, err)
@@ -486,6 +1186,130 @@ func (transaction *DeltaTransaction) TryCommit(commit *PreparedCommit) error {
 	return nil
 }
 
+// tryCommitWithoutLock commits the next version after the local table state for single-writer tables.
+// The tried version is kept even if the rename fails so that the next attempt tries the following version.
+func (transaction *DeltaTransaction) tryCommitWithoutLock(commit *PreparedCommit) error {
+	version := max(transaction.DeltaTable.State.Version, transaction.version) + 1
+	if err := transaction.checkConcurrentCommits(version); err != nil {
+		return err
+	}
+	transaction.version = version
+	timestamp, err := transaction.orderCommitTimestamp(commit, version)
+	if err != nil {
+		return err
+	}
+	from := storage.NewPath(commit.URI.Raw)
+	to := transaction.DeltaTable.CommitUriFromVersion(version)
+	if err := transaction.renameCommit(from, to, nil); err != nil {
+		return err
+	}
+	transaction.DeltaTable.cacheCommitTimestamp(version, timestamp)
+	transaction.applyCommit(commit, version)
+	return nil
+}
+
+// applyCommit brings the table state to the committed version. The committed actions are applied if the state is
+// at the version before, otherwise the commits of other writers are read from the log with Update.
+// The commit has happened, so a failure is only logged; the state is then left at its previous version.
+func (transaction *DeltaTransaction) applyCommit(commit *PreparedCommit, version state.DeltaDataTypeVersion) {
+	table := transaction.DeltaTable
+	if table.State.Version.Equal(version.Prev()) && commit.logEntry != nil {
+		actions, err := ActionsFromLogEntries(commit.logEntry)
+		if err == nil {
+			tableState := table.State.clone()
+			if err = tableState.applyActions(actions); err == nil {
+				tableState.Version = version
+				table.State = *tableState
+				return
+			}
+		}
+		log.Warnf("delta-go: unable to apply commit version %d to the table state: %v", version, err)
+		return
+	}
+	if err := table.Update(); err != nil {
+		log.Warnf("delta-go: unable to update the table state to commit version %d: %v", version, err)
+	}
+}
+
+// renameCommit moves the prepared commit into place with RenameIfNotExists, retrying up to MaxRenameAttempts
+// times when the store reports a transient failure (storage.ErrorTransient).
+// ErrorVersionAlreadyExists is a conflict with another writer and is returned immediately, for the commit loop to
+// retry with the next version. After a transient failure the rename may have succeeded nonetheless, so an existing
+// destination is only reported as a conflict if the prepared commit was not moved there.
+// The lease of the lock is checked before every attempt, since retries may outlast it; lockLost is nil for commits
+// without a lock.
+func (transaction *DeltaTransaction) renameCommit(from *storage.Path, to *storage.Path, lockLost <-chan error) error {
+	attempts := uint32(1)
+	var wait time.Duration
+	if transaction.Options != nil {
+		attempts = max(attempts, transaction.Options.MaxRenameAttempts)
+		wait = transaction.Options.RenameRetryWaitDuration
+	}
+	failedTransiently := false
+	for attempt := uint32(1); ; attempt++ {
+		select {
+		case lostErr := <-lockLost:
+			if failedTransiently && transaction.commitRenamed(from, to) {
+				return nil
+			}
+			return lostErr
+		default:
+		}
+		err := transaction.DeltaTable.Store.RenameIfNotExists(from, to)
+		if errors.Is(err, storage.ErrorVersionAlreadyExists) {
+			if failedTransiently && transaction.commitRenamed(from, to) {
+				return nil
+			}
+			return err
+		}
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, storage.ErrorTransient) || attempt >= attempts {
+			if transaction.commitNotRenamed(from, to) {
+				return commitNotRenamedError{err}
+			}
+			return err
+		}
+		log.Debugf("delta-go: transient failure renaming %s to %s on attempt %d, retrying: %v", from.Raw, to.Raw, attempt, err)
+		failedTransiently = true
+		time.Sleep(wait)
+	}
+}
+
+// commitRenamed returns whether the prepared commit at from was moved to to by an earlier rename attempt, which is the
+// case if from is gone, since the source is only removed once the destination is in place, or if to has its content
+func (transaction *DeltaTransaction) commitRenamed(from *storage.Path, to *storage.Path) bool {
+	store := transaction.DeltaTable.Store
+	fromData, err := store.Get(from)
+	if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	toData, err := store.Get(to)
+	return err == nil && bytes.Equal(fromData, toData)
+}
+
+// commitNotRenamed returns whether the prepared commit at from is known not to have been moved to to, which is the
+// case if from is still there and to is missing or has another content
+func (transaction *DeltaTransaction) commitNotRenamed(from *storage.Path, to *storage.Path) bool {
+	store := transaction.DeltaTable.Store
+	fromData, err := store.Get(from)
+	if err != nil {
+		return false
+	}
+	toData, err := store.Get(to)
+	if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		return true
+	}
+	return err == nil && !bytes.Equal(fromData, toData)
+}
+
+// timeNow returns the current time, replaced by tests simulating clock skew
+var timeNow = time.Now
+
 func max[T constraints.Ordered](a, b T) T {
 	if a > b {
 		return a
@@ -493,25 +1317,94 @@ func max[T constraints.Ordered](a, b T) T {
 	return b
 }
 
+func min[T constraints.Ordered](a, b T) T {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // Holds the uri to prepared commit temporary file created with `DeltaTransaction.prepare_commit`.
 // Once created, the actual commit could be executed with `DeltaTransaction.try_commit`.
 type PreparedCommit struct {
 	URI storage.Path
+	// the content of the commit file, applied to the table state once committed
+	logEntry []byte
 }
 
 const DEFAULT_DELTA_MAX_RETRY_COMMIT_ATTEMPTS uint32 = 10000000
 
+const (
+	// The default number of attempts to rename a commit into place on transient failures
+	DEFAULT_DELTA_MAX_RENAME_ATTEMPTS uint32 = 3
+	// The default time to wait between rename attempts
+	DEFAULT_DELTA_RENAME_RETRY_WAIT_DURATION = 100 * time.Millisecond
+	// The default cap of the exponential backoff between commit retries
+	DEFAULT_DELTA_MAX_BACKOFF = 30 * time.Second
+)
+
 // Options for customizing behavior of a `DeltaTransaction`
 type DeltaTransactionOptions struct {
 	// number of retry attempts allowed when committing a transaction
 	MaxRetryCommitAttempts uint32
 	// RetryWaitDuration sets the amount of times between retry's on the transaction
+	// It is only used when BaseBackoff is not set
 	RetryWaitDuration time.Duration
+	// BaseBackoff enables exponential backoff with jitter between retry's, starting from BaseBackoff
+	// and doubling on every attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps the exponential backoff between retry's, DEFAULT_DELTA_MAX_BACKOFF is used if it is 0
+	MaxBackoff time.Duration
+	// NoLock commits without the lock client and the state store, relying only on RenameIfNotExists
+	// to never overwrite an existing commit.
+	//
+	// WARNING: this is ONLY safe when there is exactly one writer to the table. RenameIfNotExists is not
+	// atomic on every object store (e.g. S3), so concurrent writers in NoLock mode can overwrite each other's
+	// commits and corrupt the table. NoLock writers also do not update the state store, so they must not be
+	// mixed with writers that use the lock.
+	NoLock bool
+	// DataDirectory is the directory, relative to the table root, under which AppendDataFile writes data files,
+	// e.g. "data". Files are written to the table root when it is empty.
+	DataDirectory string
+	// MaxRenameAttempts is the number of times the rename of a commit into place is attempted when the object store
+	// reports a transient failure, such as a server error during the S3 copy. The rename is attempted once when 0.
+	// A commit version that already exists is never retried by the rename, but by the commit loop.
+	MaxRenameAttempts uint32
+	// RenameRetryWaitDuration is the time to wait between rename attempts
+	RenameRetryWaitDuration time.Duration
+	// EngineInfo is written as the engineInfo of the commitInfo, e.g. "my-ingest/1.2.0 delta-go/alpha-0.0.0".
+	// DELTA_ENGINE_INFO is used when it is empty.
+	EngineInfo string
 }
 
 // NewDeltaTransactionOptions Sets the default MaxRetryCommitAttempts to DEFAULT_DELTA_MAX_RETRY_COMMIT_ATTEMPTS = 10000000
+// and the default rename retries on transient failures
 func NewDeltaTransactionOptions() *DeltaTransactionOptions {
-	return &DeltaTransactionOptions{MaxRetryCommitAttempts: DEFAULT_DELTA_MAX_RETRY_COMMIT_ATTEMPTS}
+	return &DeltaTransactionOptions{
+		MaxRetryCommitAttempts:  DEFAULT_DELTA_MAX_RETRY_COMMIT_ATTEMPTS,
+		MaxRenameAttempts:       DEFAULT_DELTA_MAX_RENAME_ATTEMPTS,
+		RenameRetryWaitDuration: DEFAULT_DELTA_RENAME_RETRY_WAIT_DURATION,
+	}
+}
+
+// retryBackoff returns the time to wait before the given retry attempt
+// With BaseBackoff set, the backoff grows exponentially up to MaxBackoff and half of it is randomized
+// so that concurrent writers that conflicted do not all retry at the same moment.
+func (options *DeltaTransactionOptions) retryBackoff(attemptNumber int) time.Duration {
+	if options.BaseBackoff <= 0 {
+		return options.RetryWaitDuration
+	}
+	maxBackoff := options.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DEFAULT_DELTA_MAX_BACKOFF
+	}
+	// The exponent is clamped so that the backoff of a long series of retries does not overflow
+	exponent := min(max(attemptNumber-1, 0), 62)
+	backoff := float64(options.BaseBackoff) * math.Pow(2, float64(exponent))
+	if backoff > float64(maxBackoff) {
+		backoff = float64(maxBackoff)
+	}
+	return time.Duration(backoff/2 + rand.Float64()*backoff/2)
 }
 
 // // / [RFC 1738]: https://www.ietf.org/rfc/rfc1738.txt
@@ -0,0 +1,186 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/state"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+)
+
+type DeltaTableState struct {
+	// current table version represented by this table state
+	Version state.DeltaDataTypeVersion
+	// A remove action should remain in the state of the table as a tombstone until it has expired.
+	// A tombstone expires when the creation timestamp of the delta file exceeds the expiration
+	Tombstones map[string]Remove
+	// active files for table state, keyed by path
+	Files map[string]Add
+	// The commit info of the latest commit applied to the state, if it has one. The commit infos of earlier commits
+	// are not kept, so that the state does not grow with the history of the table; see History to read them.
+	CommitInfos           []CommitInfo
+	AppTransactionVersion map[string]state.DeltaDataTypeVersion
+	MinReaderVersion      int32
+	MinWriterVersion      int32
+	// table features required by the protocol, with reader version 3 and writer version 7
+	ReaderFeatures []string
+	WriterFeatures []string
+	// table metadata corresponding to current version
+	CurrentMetadata DeltaTableMetaData
+	// retention period for tombstones in milli-seconds
+	TombstoneRetention time.Duration
+	// retention period for log entries in milli-seconds
+	LogRetention            time.Duration
+	EnableExpiredLogCleanup bool
+	// active metadata domains, keyed by domain
+	Domains map[string]DomainMetadata
+}
+
+// NewDeltaTableState creates an empty table state for the given version
+func NewDeltaTableState(version state.DeltaDataTypeVersion) *DeltaTableState {
+	tableState := new(DeltaTableState)
+	tableState.Version = version
+	tableState.Files = make(map[string]Add)
+	tableState.Tombstones = make(map[string]Remove)
+	tableState.AppTransactionVersion = make(map[string]state.DeltaDataTypeVersion)
+	tableState.Domains = make(map[string]DomainMetadata)
+	tableState.TombstoneRetention = DEFAULT_DELETED_FILE_RETENTION_DURATION
+	tableState.LogRetention = DEFAULT_LOG_RETENTION_DURATION
+	tableState.EnableExpiredLogCleanup = true
+	return tableState
+}
+
+func (state *DeltaTableState) WithVersion(version state.DeltaDataTypeVersion) {
+	state.Version = version
+}
+
+// applyActions folds the actions of a commit (or a range of commits) into the table state
+func (tableState *DeltaTableState) applyActions(actions []Action) error {
+	for _, action := range actions {
+		if err := tableState.processAction(action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processAction applies a single action to the table state
+func (tableState *DeltaTableState) processAction(action Action) error {
+	switch action := action.(type) {
+	case Add:
+		if len(action.PartitionValues) > 0 {
+			if err := action.ValidatePartitionPath(); err != nil {
+				log.Warnf("delta-go: %v", err)
+			}
+		}
+		tableState.Files[action.Path] = action
+		delete(tableState.Tombstones, action.Path)
+	case Remove:
+		delete(tableState.Files, action.Path)
+		tableState.Tombstones[action.Path] = action
+	case MetaData:
+		if err := action.Validate(); err != nil {
+			return err
+		}
+		metadata, err := action.ToDeltaTableMetaData()
+		if err != nil {
+			return err
+		}
+		tableState.CurrentMetadata = metadata
+		// Invalid retention properties are reported by the operations that rely on them
+		if retention, err := metadata.DeletedFileRetentionDuration(); err == nil {
+			tableState.TombstoneRetention = retention
+		}
+		if retention, err := metadata.LogRetentionDuration(); err == nil {
+			tableState.LogRetention = retention
+		}
+		if enabled, err := metadata.ExpiredLogCleanupEnabled(); err == nil {
+			tableState.EnableExpiredLogCleanup = enabled
+		}
+	case Protocol:
+		tableState.MinReaderVersion = int32(action.MinReaderVersion)
+		tableState.MinWriterVersion = int32(action.MinWriterVersion)
+		tableState.ReaderFeatures = action.ReaderFeatures
+		tableState.WriterFeatures = action.WriterFeatures
+	case Txn:
+		tableState.AppTransactionVersion[action.AppId] = state.DeltaDataTypeVersion(action.Version)
+	case CommitInfo:
+		tableState.CommitInfos = []CommitInfo{action}
+	case DomainMetadata:
+		if action.Removed {
+			delete(tableState.Domains, action.Domain)
+		} else {
+			tableState.Domains[action.Domain] = action
+		}
+	}
+	return nil
+}
+
+// DomainMetadata returns the configuration of the given metadata domain, or false if the domain is not in the table
+func (tableState *DeltaTableState) DomainMetadata(domain string) (string, bool) {
+	domainMetadata, ok := tableState.Domains[domain]
+	return domainMetadata.Configuration, ok
+}
+
+// Property returns the value of the table property from the configuration of the current metadata, or false if
+// the property is not set
+func (tableState *DeltaTableState) Property(key string) (string, bool) {
+	value, ok := tableState.CurrentMetadata.Configuration[key]
+	return value, ok
+}
+
+// Properties returns a copy of the table properties from the configuration of the current metadata
+func (tableState *DeltaTableState) Properties() map[string]string {
+	return maps.Clone(tableState.CurrentMetadata.Configuration)
+}
+
+// TableID returns the unique id of the table from the current metadata, which stays the same when the table is
+// moved or renamed, or "" if the state has no metadata
+func (tableState *DeltaTableState) TableID() string {
+	if tableState.CurrentMetadata.Id == uuid.Nil {
+		return ""
+	}
+	return tableState.CurrentMetadata.Id.String()
+}
+
+// CreatedTime returns the creation time of the current metadata, or the zero time if the metadata has no
+// createdTime
+func (tableState *DeltaTableState) CreatedTime() time.Time {
+	if tableState.CurrentMetadata.CreatedTime.UnixMilli() == 0 {
+		return time.Time{}
+	}
+	return tableState.CurrentMetadata.CreatedTime
+}
+
+// FilesMatchingPartitions returns the active files whose partition values satisfy all of the filters
+// An empty slice is returned if no files match, including for a table without files
+func (tableState *DeltaTableState) FilesMatchingPartitions(filters []PartitionFilter) []Add {
+	files := []Add{}
+	for path := range tableState.Files {
+		add := tableState.Files[path]
+		matches := true
+		for _, filter := range filters {
+			if !filter.Matches(&add) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			files = append(files, add)
+		}
+	}
+	return files
+}
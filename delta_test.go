@@ -13,12 +13,18 @@
 package delta
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -26,12 +32,15 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/rivian/delta-go/lock"
 	"github.com/rivian/delta-go/lock/filelock"
+	"github.com/rivian/delta-go/state"
 	"github.com/rivian/delta-go/state/filestate"
 	"github.com/segmentio/parquet-go"
 
 	"github.com/rivian/delta-go/storage"
 	"github.com/rivian/delta-go/storage/filestore"
+	"github.com/rivian/delta-go/storage/httpstore"
 )
 
 func TestDeltaTransactionPrepareCommit(t *testing.T) {
@@ -95,8 +104,11 @@ func TestDeltaTableTryCommitTransaction(t *testing.T) {
 	if !errors.Is(err, storage.ErrorObjectDoesNotExist) {
 		t.Error(err)
 	}
-	if transaction.DeltaTable.State.Version != 2 {
-		t.Errorf("want version = 1,has version = %d", transaction.DeltaTable.State.Version)
+	if transaction.version != 2 {
+		t.Errorf("want tried version = 2,has version = %d", transaction.version)
+	}
+	if transaction.DeltaTable.State.Version != 1 {
+		t.Errorf("the failed commit should not change the table state, has version = %d", transaction.DeltaTable.State.Version)
 	}
 
 	//prpare a new commit and try on the next version
@@ -105,8 +117,8 @@ func TestDeltaTableTryCommitTransaction(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if transaction.DeltaTable.State.Version != 3 {
-		t.Errorf("want version = 2,has version = %d", transaction.DeltaTable.State.Version)
+	if transaction.version != 3 {
+		t.Errorf("want version = 3,has version = %d", transaction.version)
 	}
 
 }
@@ -135,6 +147,9 @@ func TestTryCommitWithExistingLock(t *testing.T) {
 	if !errors.Is(err, ErrorExceededCommitRetryAttempts) {
 		t.Error(err)
 	}
+	if !strings.Contains(err.Error(), "attempts in") {
+		t.Errorf("error should report the elapsed time, has %v", err)
+	}
 	if version != -1 {
 		t.Errorf("version stored in table.State.Version was never synced so is -1")
 	}
@@ -161,6 +176,426 @@ func TestTryCommitWithExistingLock(t *testing.T) {
 		t.Errorf("Final Version in lock should be 1")
 	}
 }
+func TestRetryBackoff(t *testing.T) {
+	options := DeltaTransactionOptions{RetryWaitDuration: 5 * time.Millisecond}
+	if backoff := options.retryBackoff(3); backoff != 5*time.Millisecond {
+		t.Errorf("without BaseBackoff the RetryWaitDuration should be used, has %s", backoff)
+	}
+
+	options = DeltaTransactionOptions{BaseBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for attempt, maxWait := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 4: 50 * time.Millisecond, 10: 50 * time.Millisecond} {
+		backoff := options.retryBackoff(attempt)
+		if backoff < maxWait/2 || backoff > maxWait {
+			t.Errorf("attempt %d: want backoff between %s and %s, has %s", attempt, maxWait/2, maxWait, backoff)
+		}
+	}
+
+	// Without MaxBackoff the default cap applies, even after as many attempts as the default retries allow
+	options = DeltaTransactionOptions{BaseBackoff: 10 * time.Millisecond}
+	for _, attempt := range []int{100, 1000, int(DEFAULT_DELTA_MAX_RETRY_COMMIT_ATTEMPTS)} {
+		backoff := options.retryBackoff(attempt)
+		if backoff < DEFAULT_DELTA_MAX_BACKOFF/2 || backoff > DEFAULT_DELTA_MAX_BACKOFF {
+			t.Errorf("attempt %d: want backoff between %s and %s, has %s", attempt, DEFAULT_DELTA_MAX_BACKOFF/2, DEFAULT_DELTA_MAX_BACKOFF, backoff)
+		}
+	}
+}
+
+func TestCommitFailureRemovesDataFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := storage.NewPath(tmpDir)
+	os.MkdirAll(filepath.Join(tmpDir, "_delta_log"), 0700)
+	//Lock is held by another writer so the commit can never succeed
+	w0lockClient := filelock.New(tmpPath, "_delta_log/_commit.lock", filelock.LockOptions{})
+	w0lockClient.TryLock()
+	defer w0lockClient.Unlock()
+
+	table := NewDeltaTable(filestore.New(tmpPath), filelock.New(tmpPath, "_delta_log/_commit.lock", filelock.LockOptions{}), filestate.New(tmpPath, "_delta_log/_commit.state"))
+	transaction, operation, appMetaData := setupTransaction(t, table, &DeltaTransactionOptions{MaxRetryCommitAttempts: 1})
+	dataFile := storage.NewPath("part-00000-80a9bb40-ec43-43b6-bb8a-fc66ef7cd768-c000.snappy.parquet")
+	err := transaction.PutDataFile(dataFile, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists(filepath.Join(tmpDir, dataFile.Raw)) {
+		t.Fatal("data file should exist before the commit")
+	}
+
+	_, err = transaction.Commit(operation, appMetaData)
+	if !errors.Is(err, ErrorExceededCommitRetryAttempts) {
+		t.Error(err)
+	}
+	if fileExists(filepath.Join(tmpDir, dataFile.Raw)) {
+		t.Error("data file should be removed after the commit failed")
+	}
+	tmpCommits, _ := filepath.Glob(filepath.Join(tmpDir, "_delta_log", "_commit_*.json.tmp"))
+	if len(tmpCommits) != 0 {
+		t.Errorf("temporary commit files should be removed, found %v", tmpCommits)
+	}
+}
+
+func TestCommitWithContextCancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := storage.NewPath(tmpDir)
+	os.MkdirAll(filepath.Join(tmpDir, "_delta_log"), 0700)
+	//Lock is held by another writer so the commit keeps retrying until the context is done
+	w0lockClient := filelock.New(tmpPath, "_delta_log/_commit.lock", filelock.LockOptions{})
+	w0lockClient.TryLock()
+	defer w0lockClient.Unlock()
+
+	table := NewDeltaTable(filestore.New(tmpPath), filelock.New(tmpPath, "_delta_log/_commit.lock", filelock.LockOptions{}), filestate.New(tmpPath, "_delta_log/_commit.state"))
+	options := NewDeltaTransactionOptions()
+	options.RetryWaitDuration = time.Millisecond
+	transaction, _, appMetaData := setupTransaction(t, table, options)
+	dataFile := storage.NewPath("part-00000-compacted.snappy.parquet")
+	err := transaction.PutDataFile(dataFile, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = transaction.CommitWithContext(ctx, Optimize{}, appMetaData)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, has %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the commit should stop promptly, took %s", elapsed)
+	}
+	if fileExists(filepath.Join(tmpDir, dataFile.Raw)) {
+		t.Error("data file should be removed after the commit was cancelled")
+	}
+	tmpCommits, _ := filepath.Glob(filepath.Join(tmpDir, "_delta_log", "_commit_*.json.tmp"))
+	if len(tmpCommits) != 0 {
+		t.Errorf("temporary commit files should be removed, found %v", tmpCommits)
+	}
+
+	// A context cancelled before the commit leaves the table unchanged
+	table, _, tmpDir = setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	transaction = table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "compacted.parquet"})
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = transaction.CommitWithContext(cancelled, Optimize{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, has %v", err)
+	}
+	if fileExists(filepath.Join(tmpDir, "_delta_log", "00000000000000000001.json")) {
+		t.Error("version 1 should not be committed")
+	}
+}
+
+func TestAbortWrite(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	paths := []string{"part-1.snappy.parquet", "part-2.snappy.parquet"}
+	for _, path := range paths {
+		if err := transaction.PutDataFile(storage.NewPath(path), []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//A file that has already been removed does not stop the cleanup of the others
+	os.Remove(filepath.Join(tmpDir, paths[0]))
+
+	err := transaction.AbortWrite()
+	if err == nil {
+		t.Error("AbortWrite should report the file that could not be removed")
+	}
+	for _, path := range paths {
+		if fileExists(filepath.Join(tmpDir, path)) {
+			t.Errorf("%s should be removed", path)
+		}
+	}
+}
+
+// lostLock is a file lock whose lease is lost as soon as it is acquired
+type lostLock struct {
+	*filelock.FileLock
+}
+
+func (l lostLock) AutoRenew(ctx context.Context) <-chan error {
+	lost := make(chan error, 1)
+	lost <- errors.Join(lock.ErrorLockLost, errors.New("lease expired"))
+	return lost
+}
+
+func TestCommitFailsWhenLockIsLost(t *testing.T) {
+	table, state, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	table.LockClient = lostLock{filelock.New(storage.NewPath(tmpDir), "_delta_log/_commit.lock", filelock.LockOptions{})}
+
+	transaction, operation, appMetaData := setupTransaction(t, table, NewDeltaTransactionOptions())
+	dataFile := storage.NewPath("part-1.snappy.parquet")
+	err := transaction.PutDataFile(dataFile, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = transaction.Commit(operation, appMetaData)
+	if !errors.Is(err, lock.ErrorLockLost) {
+		t.Errorf("want ErrorLockLost, has %v", err)
+	}
+	if fileExists(filepath.Join(tmpDir, "_delta_log", "00000000000000000001.json")) {
+		t.Error("version 1 should not be committed after the lock was lost")
+	}
+	if fileExists(filepath.Join(tmpDir, dataFile.Raw)) {
+		t.Error("data file should be removed after the lock was lost")
+	}
+	commitState, err := state.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commitState.Version != 0 {
+		t.Errorf("state should not be updated after the lock was lost, has version %d", commitState.Version)
+	}
+}
+
+func TestCommitWithoutLock(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+
+	// No lock client or state store is needed in NoLock mode
+	writer, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := &DeltaTransactionOptions{MaxRetryCommitAttempts: 3, NoLock: true}
+	transaction, operation, appMetaData := setupTransaction(t, writer, options)
+	version, err := transaction.Commit(operation, appMetaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("want version 1, has %d", version)
+	}
+
+	// A writer with a stale view of the table moves past the existing commit instead of overwriting it
+	staleWriter, err := OpenTableWithVersion(table.Store, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction, operation, appMetaData = setupTransaction(t, staleWriter, options)
+	version, err = transaction.Commit(operation, appMetaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("want version 2, has %d", version)
+	}
+	for _, commit := range []string{"00000000000000000001.json", "00000000000000000002.json"} {
+		if !fileExists(filepath.Join(tmpDir, "_delta_log", commit)) {
+			t.Errorf("%s should be committed", commit)
+		}
+	}
+}
+
+func TestCommitEngineInfo(t *testing.T) {
+	table, _, _ := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+
+	options := NewDeltaTransactionOptions()
+	transaction, operation, appMetaData := setupTransaction(t, table, options)
+	if _, err := transaction.Commit(operation, appMetaData); err != nil {
+		t.Fatal(err)
+	}
+	options.EngineInfo = "ingest/1.0 " + DELTA_ENGINE_INFO
+	transaction, operation, appMetaData = setupTransaction(t, table, options)
+	if _, err := transaction.Commit(operation, appMetaData); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := table.History(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		engineInfo string
+		operation  string
+	}{
+		{"ingest/1.0 delta-go/" + DELTA_CLIENT_VERSION, "WRITE"},
+		{"delta-go/" + DELTA_CLIENT_VERSION, "WRITE"},
+		{"delta-go/" + DELTA_CLIENT_VERSION, ""},
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("want %d versions, has %d", len(expected), len(history))
+	}
+	for i, want := range expected {
+		commitInfo := history[i].CommitInfo
+		if commitInfo["engineInfo"] != want.engineInfo {
+			t.Errorf("version %d: want engineInfo %s, has %v", history[i].Version, want.engineInfo, commitInfo["engineInfo"])
+		}
+		if want.operation != "" && commitInfo["operation"] != want.operation {
+			t.Errorf("version %d: want operation %s, has %v", history[i].Version, want.operation, commitInfo["operation"])
+		}
+	}
+}
+
+func TestCommitRaw(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	transaction, operation, appMetaData := setupTransaction(t, table, NewDeltaTransactionOptions())
+	_, err := transaction.Commit(operation, appMetaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replicate the commits to another table without parsing them
+	target, _, targetDir := setupTest(t)
+	for version := state.DeltaDataTypeVersion(0); version <= 1; version++ {
+		data, err := table.ReadCommitRaw(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := os.ReadFile(filepath.Join(tmpDir, table.CommitUriFromVersion(version).Raw))
+		if string(data) != string(expected) {
+			t.Errorf("version %d: want %s, has %s", version, expected, data)
+		}
+		err = target.WriteCommitRaw(version, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = target.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveFiles(t, target, []string{"part-00000-80a9bb40-ec43-43b6-bb8a-fc66ef7cd768-c000.snappy.parquet"})
+
+	err = target.WriteCommitRaw(1, []byte("{}"))
+	if !errors.Is(err, storage.ErrorVersionAlreadyExists) {
+		t.Errorf("want ErrorVersionAlreadyExists, has %v", err)
+	}
+	tmpCommits, _ := filepath.Glob(filepath.Join(targetDir, "_delta_log", "_commit_*.json.tmp"))
+	if len(tmpCommits) != 0 {
+		t.Errorf("temporary commit files should be removed, found %v", tmpCommits)
+	}
+
+	_, err = target.ReadCommitRaw(2)
+	if !errors.Is(err, ErrorReadingLogEntry) {
+		t.Errorf("want ErrorReadingLogEntry, has %v", err)
+	}
+}
+
+func TestReadCommitRawGzip(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	content := `{"commitInfo":{"operation":"WRITE"}}`
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(content))
+	writer.Close()
+	os.MkdirAll(filepath.Join(tmpDir, "_delta_log"), 0700)
+	os.WriteFile(filepath.Join(tmpDir, table.CommitUriFromVersion(0).Raw), compressed.Bytes(), 0700)
+
+	data, err := table.ReadCommitRaw(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("want %s, has %s", content, data)
+	}
+}
+
+func TestReadActions(t *testing.T) {
+	table, _, _ := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	content := `{"commitInfo":{"operation":"WRITE"}}` + "\n" +
+		`{"add":{"path":"part-1.parquet","size":1,"partitionValues":{},"modificationTime":0,"dataChange":true}}` + "\n" +
+		`{"remove":{"path":"part-0.parquet","dataChange":true}}`
+	if err := table.WriteCommitRaw(1, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := table.ReadActions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 3 {
+		t.Fatalf("want 3 actions, has %v", actions)
+	}
+	if add, ok := actions[1].(Add); !ok || add.Path != "part-1.parquet" {
+		t.Errorf("want the add action of part-1.parquet, has %#v", actions[1])
+	}
+	if remove, ok := actions[2].(Remove); !ok || remove.Path != "part-0.parquet" {
+		t.Errorf("want the remove action of part-0.parquet, has %#v", actions[2])
+	}
+	if table.State.Version != 0 {
+		t.Errorf("the table state should not be updated, has version %d", table.State.Version)
+	}
+
+	_, err = table.ReadActions(2)
+	if !errors.Is(err, ErrorReadingLogEntry) || !errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		t.Errorf("want ErrorReadingLogEntry and ErrorObjectDoesNotExist, has %v", err)
+	}
+}
+
+func TestLoadLongHistory(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	for version := 1; version <= 500; version++ {
+		// Every commit replaces the file of the previous one
+		commit := fmt.Sprintf("{\"commitInfo\":{\"timestamp\":%d}}\n{\"add\":{\"path\":\"part-%d.parquet\",\"size\":1,\"dataChange\":true}}\n{\"remove\":{\"path\":\"part-%d.parquet\",\"dataChange\":true}}", version, version, version-1)
+		if version == 250 {
+			// Log entries longer than a typical line buffer
+			commit += fmt.Sprintf("\n{\"txn\":{\"appId\":\"%s\",\"version\":1}}\n", strings.Repeat("a", 1<<17))
+		}
+		data := []byte(commit)
+		if version%100 == 0 {
+			var compressed bytes.Buffer
+			writer := gzip.NewWriter(&compressed)
+			writer.Write(data)
+			writer.Close()
+			data = compressed.Bytes()
+		}
+		if err := table.WriteCommitRaw(state.DeltaDataTypeVersion(version), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	table, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 500 {
+		t.Errorf("want version 500, has %d", table.State.Version)
+	}
+	assertActiveFiles(t, table, []string{"part-500.parquet"})
+	if len(table.State.Tombstones) != 500 || table.State.AppTransactionVersion[strings.Repeat("a", 1<<17)] != 1 {
+		t.Errorf("want 500 tombstones and the long transaction, has %d tombstones", len(table.State.Tombstones))
+	}
+	// Only the commit info of the latest commit is kept
+	if len(table.State.CommitInfos) != 1 {
+		t.Fatalf("want 1 commit info, has %d", len(table.State.CommitInfos))
+	}
+	if timestamp, ok := table.State.CommitInfos[0].Timestamp(); !ok || timestamp.UnixMilli() != 500 {
+		t.Errorf("want the commit info of version 500, has %v", table.State.CommitInfos[0])
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, table.CommitUriFromVersion(501).Raw), []byte("{\"add\":{\"path\":\"part-501.parquet\"}}\n{\"add\":"), 0700)
+	_, err = OpenTable(table.Store, nil, nil)
+	if !errors.Is(err, ErrorReadingLogEntry) || !errors.Is(err, ErrorActionJSONFormat) {
+		t.Errorf("want ErrorReadingLogEntry, has %v", err)
+	}
+}
+
+func TestLoadInvalidSchema(t *testing.T) {
+	for name, metadata := range map[string]string{
+		"malformed schema":         `{"metaData":{"id":"6d3a1b62-9c3e-4b1a-8a0e-0b6d8f0c2d11","format":{"provider":"parquet"},"schemaString":"{\"type\":\"struct\",","partitionColumns":[],"createdTime":0}}`,
+		"missing partition column": `{"metaData":{"id":"6d3a1b62-9c3e-4b1a-8a0e-0b6d8f0c2d11","format":{"provider":"parquet"},"schemaString":"{\"type\":\"struct\",\"fields\":[]}","partitionColumns":["date"],"createdTime":0}}`,
+	} {
+		table, _, tmpDir := setupTest(t)
+		table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+		os.WriteFile(filepath.Join(tmpDir, table.CommitUriFromVersion(1).Raw), []byte(metadata), 0700)
+
+		_, err := OpenTable(table.Store, nil, nil)
+		if !errors.Is(err, ErrorInvalidSchema) {
+			t.Errorf("%s: want ErrorInvalidSchema, has %v", name, err)
+		} else if !strings.Contains(err.Error(), "version 1") {
+			t.Errorf("%s: the error should name the offending version, has %v", name, err)
+		}
+	}
+}
+
 func TestDeltaTableCreate(t *testing.T) {
 	table, state, _ := setupTest(t)
 	//schema
@@ -264,6 +699,50 @@ func TestDeltaTableExists(t *testing.T) {
 	}
 }
 
+func TestNextVersion(t *testing.T) {
+	table, stateStore, tmpDir := setupTest(t)
+
+	version, err := table.NextVersion()
+	if err != nil || version != 0 {
+		t.Errorf("want version 0 for an empty table, has %d %v", version, err)
+	}
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	version, err = table.NextVersion()
+	if err != nil || version != 1 {
+		t.Errorf("want version 1, has %d %v", version, err)
+	}
+
+	// A version tried by another writer is skipped
+	if err := stateStore.Put(state.CommitState{Version: 3}); err != nil {
+		t.Fatal(err)
+	}
+	version, err = table.NextVersion()
+	if err != nil || version != 4 {
+		t.Errorf("want version 4, has %d %v", version, err)
+	}
+
+	// A state store that is behind, unreadable, or not configured leaves the log to decide
+	table.WriteCommitRaw(1, []byte(`{"commitInfo":{}}`))
+	table.WriteCommitRaw(2, []byte(`{"commitInfo":{}}`))
+	table.WriteCommitRaw(3, []byte(`{"commitInfo":{}}`))
+	table.WriteCommitRaw(4, []byte(`{"commitInfo":{}}`))
+	version, err = table.NextVersion()
+	if err != nil || version != 5 {
+		t.Errorf("want version 5, has %d %v", version, err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "_delta_log", "_commit.state"), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	version, err = table.NextVersion()
+	if err != nil || version != 5 {
+		t.Errorf("want version 5 with an unreadable state, has %d %v", version, err)
+	}
+	version, err = NewDeltaTable(table.Store, nil, nil).NextVersion()
+	if err != nil || version != 5 {
+		t.Errorf("want version 5 without a state store, has %d %v", version, err)
+	}
+}
+
 func TestDeltaTableTryCommitLoop(t *testing.T) {
 	table, _, _ := setupTest(t)
 
@@ -323,13 +802,13 @@ func TestDeltaTableTryCommitLoopWithCommitExists(t *testing.T) {
 		t.Error(err)
 	}
 
-	if table.State.Version != 4 {
-		t.Errorf("want table.State.Version=4, has %d", table.State.Version)
+	if transaction.version != 4 {
+		t.Errorf("want committed version 4, has %d", transaction.version)
 	}
 
 	commitState, _ := table.StateStore.Get()
 	if commitState.Version != 4 {
-		t.Errorf("want table.State.Version=4, has %d", table.State.Version)
+		t.Errorf("want commitState.Version=4, has %d", commitState.Version)
 	}
 
 	if !fileExists(filepath.Join(tmpDir, table.CommitUriFromVersion(4).Raw)) {
@@ -542,6 +1021,148 @@ func TestCreateWithParquet(t *testing.T) {
 	//	    `{"type":"struct","fields":[{"name":"letter","type":"string","nullable":true,"metadata":{}},{"name":"number","type":"long","nullable":true,"metadata":{}},{"name":"a_float","type":"double","nullable":true,"metadata":{}}]}"`
 }
 
+func TestLoadWithLogCompaction(t *testing.T) {
+	store := filestore.New(storage.NewPath("testdata/compacted_log"))
+	table, err := OpenTable(store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 3 {
+		t.Errorf("want version = 3, has version = %d", table.State.Version)
+	}
+	assertActiveFiles(t, table, []string{"part-00001.snappy.parquet", "part-00002.snappy.parquet", "part-00003.snappy.parquet"})
+	if _, ok := table.State.Tombstones["part-00000.snappy.parquet"]; !ok {
+		t.Error("part-00000.snappy.parquet should be a tombstone")
+	}
+
+	// The compaction file ends after version 1, so it cannot be used
+	table, err = OpenTableWithVersion(store, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveFiles(t, table, []string{"part-00000.snappy.parquet", "part-00001.snappy.parquet"})
+}
+
+func TestOpenTableOverHTTP(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+	store, err := httpstore.New(storage.NewPath(server.URL + "/compacted_log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := OpenTable(store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 3 {
+		t.Errorf("want version = 3, has version = %d", table.State.Version)
+	}
+	assertActiveFiles(t, table, []string{"part-00001.snappy.parquet", "part-00002.snappy.parquet", "part-00003.snappy.parquet"})
+
+	// The table is read-only
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-00004.snappy.parquet"})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); !errors.Is(err, storage.ErrorUnsupported) {
+		t.Errorf("want ErrorUnsupported, has %v", err)
+	}
+}
+
+func TestLoadUsesLogCompaction(t *testing.T) {
+	tmpDir := copyTestTable(t, "testdata/compacted_log")
+	// With the covered commits gone the table can only be loaded through the compaction file
+	os.Remove(filepath.Join(tmpDir, "_delta_log", "00000000000000000001.json"))
+	os.Remove(filepath.Join(tmpDir, "_delta_log", "00000000000000000002.json"))
+
+	table, err := OpenTable(filestore.New(storage.NewPath(tmpDir)), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveFiles(t, table, []string{"part-00001.snappy.parquet", "part-00002.snappy.parquet", "part-00003.snappy.parquet"})
+}
+
+func TestLoadWithCorruptLogCompaction(t *testing.T) {
+	tmpDir := copyTestTable(t, "testdata/compacted_log")
+	compacted := filepath.Join(tmpDir, "_delta_log", "00000000000000000001.00000000000000000002.compacted.json")
+	os.WriteFile(compacted, []byte("{\"add\":{\"path\":"), 0700)
+
+	table, err := OpenTable(filestore.New(storage.NewPath(tmpDir)), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 3 {
+		t.Errorf("want version = 3, has version = %d", table.State.Version)
+	}
+	assertActiveFiles(t, table, []string{"part-00001.snappy.parquet", "part-00002.snappy.parquet", "part-00003.snappy.parquet"})
+}
+
+func TestLoadNotATable(t *testing.T) {
+	_, err := OpenTable(filestore.New(storage.NewPath(t.TempDir())), nil, nil)
+	if !errors.Is(err, ErrorNotATable) {
+		t.Errorf("want ErrorNotATable, has %v", err)
+	}
+}
+
+func TestShallowClone(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	add := Add{
+		Path:             "part-00000-80a9bb40-ec43-43b6-bb8a-fc66ef7cd768-c000.snappy.parquet",
+		Size:             984,
+		ModificationTime: DeltaDataTypeTimestamp(time.Now().UnixMilli()),
+		PartitionValues:  make(map[string]string),
+	}
+	metadata := NewDeltaTableMetaData("Test Table", "", new(Format).Default(), SchemaTypeStruct{}, []string{}, make(map[string]string))
+	protocol := Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{"appendOnly", "invariants", DOMAIN_METADATA_FEATURE}}
+	err := table.Create(*metadata, protocol, CommitInfo{}, []Add{add})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(DomainMetadata{Domain: "com.example.custom", Configuration: "opaque"})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cloneDir := t.TempDir()
+	clonePath := storage.NewPath(cloneDir)
+	clone, err := table.ShallowClone(filestore.New(clonePath), filelock.New(clonePath, "_delta_log/_commit.lock", filelock.LockOptions{}), filestate.New(clonePath, "_delta_log/_commit.state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clone.State.Version != 0 {
+		t.Errorf("want version = 0, has version = %d", clone.State.Version)
+	}
+	expectedPath := table.TableUri() + "/" + add.Path
+	if !strings.HasPrefix(expectedPath, "file://"+filepath.ToSlash(tmpDir)) {
+		t.Errorf("absolute path %s should reference the source table", expectedPath)
+	}
+	assertActiveFiles(t, clone, []string{expectedPath})
+	if clone.State.CurrentMetadata.Id == metadata.Id {
+		t.Error("clone should have a new table id")
+	}
+	if !reflect.DeepEqual(clone.State.protocol(), protocol) {
+		t.Errorf("want protocol %+v, has %+v", protocol, clone.State.protocol())
+	}
+	if configuration, ok := clone.State.DomainMetadata("com.example.custom"); !ok || configuration != "opaque" {
+		t.Errorf("domain metadata should be cloned, has %q", configuration)
+	}
+	operationParameters := clone.State.CommitInfos[0]["operationParameters"].(map[string]any)
+	if operationParameters["source"] != table.TableUri() {
+		t.Errorf("commitInfo should record the clone source, has %v", operationParameters["source"])
+	}
+
+	// Cloning into a non-empty target fails
+	_, err = table.ShallowClone(filestore.New(clonePath), nil, nil)
+	if !errors.Is(err, ErrorCloneTargetNotEmpty) {
+		t.Errorf("want ErrorCloneTargetNotEmpty, has %v", err)
+	}
+}
+
 type testData struct {
 	Id     int64     `parquet:"id,snappy"`
 	T1     int64     `parquet:"t1,timestamp(microsecond)"`
@@ -657,6 +1278,31 @@ func setupTest(t *testing.T) (table *DeltaTable, state *filestate.FileStateStore
 	return
 }
 
+// Helper function to set up a table with writer version 2, created with the files at version 0, followed by an
+// append of the actions added by each commit function, and loaded
+func setupTestTable(t *testing.T, schema SchemaTypeStruct, partitionColumns []string, configuration map[string]string, files []Add, commits ...func(transaction *DeltaTransaction)) (*DeltaTable, string) {
+	t.Helper()
+	table, _, tmpDir := setupTest(t)
+	if configuration == nil {
+		configuration = map[string]string{}
+	}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, partitionColumns, configuration)
+	if err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, files); err != nil {
+		t.Fatal(err)
+	}
+	for _, commit := range commits {
+		transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+		commit(transaction)
+		if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return table, tmpDir
+}
+
 // / Helper function to set up a basic transaction
 func setupTransaction(t *testing.T, table *DeltaTable, options *DeltaTransactionOptions) (transaction *DeltaTransaction, operation DeltaOperation, appMetaData map[string]any) {
 	t.Helper()
@@ -681,3 +1327,480 @@ func fileExists(filename string) bool {
 	}
 	return !info.IsDir()
 }
+
+// / Helper function to assert the active files of the table state
+func assertActiveFiles(t *testing.T, table *DeltaTable, paths []string) {
+	t.Helper()
+	if len(table.State.Files) != len(paths) {
+		t.Errorf("want %d active files, has %d", len(paths), len(table.State.Files))
+	}
+	for _, path := range paths {
+		if _, ok := table.State.Files[path]; !ok {
+			t.Errorf("%s should be an active file", path)
+		}
+	}
+}
+
+// / Helper function to copy a test table fixture into a temp directory
+func copyTestTable(t *testing.T, src string) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(tmpDir, relPath), 0700)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(tmpDir, relPath), data, 0700)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpDir
+}
+
+// flakyRenameStore fails the first failures renames with a transient error, after renaming if renameOnFailure is set
+type flakyRenameStore struct {
+	storage.ObjectStore
+	failures        int
+	renameOnFailure bool
+	renames         int
+}
+
+func (s *flakyRenameStore) RenameIfNotExists(from *storage.Path, to *storage.Path) error {
+	s.renames++
+	if s.renames > s.failures {
+		return s.ObjectStore.RenameIfNotExists(from, to)
+	}
+	if s.renameOnFailure {
+		if err := s.ObjectStore.RenameIfNotExists(from, to); err != nil {
+			return err
+		}
+	}
+	return storage.NewStorageError("rename", to, storage.ErrorTransient, errors.New("internal server error"))
+}
+
+func TestCommitRetriesTransientRenameFailures(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		failures        int
+		renameOnFailure bool
+		existing        bool
+		wantErr         error
+		wantVersion     state.DeltaDataTypeVersion
+		wantRenames     int
+	}{
+		{name: "transient failures", failures: 2, wantVersion: 1, wantRenames: 3},
+		{name: "attempts exhausted", failures: 3, wantErr: storage.ErrorTransient, wantVersion: 0, wantRenames: 3},
+		{name: "lost response", failures: 1, renameOnFailure: true, wantVersion: 1, wantRenames: 2},
+		// The conflict is not retried by the rename but by the commit loop, with the next version
+		{name: "conflict", existing: true, wantVersion: 2, wantRenames: 2},
+	} {
+		table, _, tmpDir := setupTest(t)
+		table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+		if err := table.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if test.existing {
+			table.WriteCommitRaw(1, []byte(`{"commitInfo":{}}`))
+		}
+		store := &flakyRenameStore{ObjectStore: table.Store, failures: test.failures, renameOnFailure: test.renameOnFailure}
+		table.Store = store
+
+		options := NewDeltaTransactionOptions()
+		options.NoLock = true
+		options.RetryWaitDuration = time.Millisecond
+		options.RenameRetryWaitDuration = time.Millisecond
+		transaction := table.CreateTransaction(options)
+		transaction.AddAction(Add{Path: "part-1.parquet"})
+		version, err := transaction.Commit(Write{Mode: Append}, nil)
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) || errors.Is(err, ErrorExceededCommitRetryAttempts) {
+				t.Errorf("%s: want %v, has %v", test.name, test.wantErr, err)
+			}
+		} else if err != nil || version != test.wantVersion {
+			t.Errorf("%s: want version %d, has %d %v", test.name, test.wantVersion, version, err)
+		}
+		if store.renames != test.wantRenames {
+			t.Errorf("%s: want %d renames, has %d", test.name, test.wantRenames, store.renames)
+		}
+
+		versions, err := table.ListVersions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := versions[len(versions)-1].Version; last != test.wantVersion {
+			t.Errorf("%s: want version %d to be the last, has %d", test.name, test.wantVersion, last)
+		}
+		// The temporary commit file is removed once the commit is known not to have happened
+		if tmpCommits, _ := filepath.Glob(filepath.Join(tmpDir, "_delta_log", "_commit_*.json.tmp")); len(tmpCommits) != 0 {
+			t.Errorf("%s: unexpected temporary commit files %v", test.name, tmpCommits)
+		}
+	}
+}
+
+// leaseLapsingLock is a file lock whose lease is lost once lapse is called
+type leaseLapsingLock struct {
+	*filelock.FileLock
+	lost chan error
+}
+
+func (l leaseLapsingLock) AutoRenew(ctx context.Context) <-chan error {
+	return l.lost
+}
+
+func (l leaseLapsingLock) lapse() {
+	l.lost <- errors.Join(lock.ErrorLockLost, errors.New("lease expired"))
+}
+
+// leaseLapsingRenameStore fails the first rename transiently and lets the lease of the lock lapse meanwhile
+type leaseLapsingRenameStore struct {
+	*flakyRenameStore
+	lock leaseLapsingLock
+}
+
+func (s *leaseLapsingRenameStore) RenameIfNotExists(from *storage.Path, to *storage.Path) error {
+	err := s.flakyRenameStore.RenameIfNotExists(from, to)
+	if s.renames == 1 {
+		s.lock.lapse()
+	}
+	return err
+}
+
+func TestCommitRenameRetryStopsWhenLockIsLost(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	lapsingLock := leaseLapsingLock{filelock.New(storage.NewPath(tmpDir), "_delta_log/_commit.lock", filelock.LockOptions{}), make(chan error, 1)}
+	table.LockClient = lapsingLock
+	store := &leaseLapsingRenameStore{&flakyRenameStore{ObjectStore: table.Store, failures: 1}, lapsingLock}
+	table.Store = store
+
+	options := NewDeltaTransactionOptions()
+	options.RenameRetryWaitDuration = time.Millisecond
+	transaction := table.CreateTransaction(options)
+	transaction.AddAction(Add{Path: "part-1.parquet"})
+	_, err := transaction.Commit(Write{Mode: Append}, nil)
+	if !errors.Is(err, lock.ErrorLockLost) {
+		t.Errorf("want ErrorLockLost, has %v", err)
+	}
+	if store.renames != 1 {
+		t.Errorf("the rename should not be retried after the lock was lost, has %d renames", store.renames)
+	}
+	if fileExists(filepath.Join(tmpDir, "_delta_log", "00000000000000000001.json")) {
+		t.Error("version 1 should not be committed after the lock was lost")
+	}
+}
+
+// rejectingRenameStore fails every rename with a permanent error
+type rejectingRenameStore struct {
+	storage.ObjectStore
+}
+
+func (s *rejectingRenameStore) RenameIfNotExists(from *storage.Path, to *storage.Path) error {
+	return errors.New("access denied")
+}
+
+func TestCommitRejectedRenameRemovesDataFiles(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	table.Store = &rejectingRenameStore{ObjectStore: table.Store}
+
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	dataFile := storage.NewPath("part-00000.parquet")
+	if err := transaction.PutDataFile(dataFile, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	transaction.AddAction(Add{Path: dataFile.Raw, Size: 4})
+	_, err := transaction.Commit(Write{Mode: Append}, nil)
+	if err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("want the rename error, has %v", err)
+	}
+	if fileExists(filepath.Join(tmpDir, dataFile.Raw)) {
+		t.Error("data file should be removed after the commit failed")
+	}
+	if tmpCommits, _ := filepath.Glob(filepath.Join(tmpDir, "_delta_log", "_commit_*.json.tmp")); len(tmpCommits) != 0 {
+		t.Errorf("temporary commit files should be removed, found %v", tmpCommits)
+	}
+}
+
+// concurrentCommitStore runs commit, once, before the first object with the prefix is read or written, to simulate
+// a commit made concurrently by another writer
+type concurrentCommitStore struct {
+	storage.ObjectStore
+	prefix string
+	commit func() error
+}
+
+func (s *concurrentCommitStore) runCommit(location *storage.Path) error {
+	if s.commit == nil || !strings.HasPrefix(location.Raw, s.prefix) {
+		return nil
+	}
+	commit := s.commit
+	s.commit = nil
+	return commit()
+}
+
+func (s *concurrentCommitStore) Put(location *storage.Path, data []byte) error {
+	if err := s.runCommit(location); err != nil {
+		return err
+	}
+	return s.ObjectStore.Put(location, data)
+}
+
+func (s *concurrentCommitStore) Get(location *storage.Path) ([]byte, error) {
+	if err := s.runCommit(location); err != nil {
+		return nil, err
+	}
+	return s.ObjectStore.Get(location)
+}
+
+func (s *concurrentCommitStore) Head(location *storage.Path) (storage.ObjectMeta, error) {
+	if err := s.runCommit(location); err != nil {
+		return storage.ObjectMeta{}, err
+	}
+	return s.ObjectStore.Head(location)
+}
+
+// closingStore records whether the store was closed
+type closingStore struct {
+	storage.ObjectStore
+	closed bool
+}
+
+func (s *closingStore) Close() error {
+	s.closed = true
+	return s.ObjectStore.Close()
+}
+
+func TestCreateTable(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := storage.NewPath(tmpDir)
+	store := filestore.New(tmpPath)
+	stateStore := filestate.New(tmpPath, "_delta_log/_commit.state")
+	lock := filelock.New(tmpPath, "_delta_log/_commit.lock", filelock.LockOptions{})
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: String}}}
+
+	_, err := CreateTable(store, lock, stateStore, schema, []string{"region"}, nil)
+	if !errors.Is(err, ErrorInvalidSchema) {
+		t.Errorf("want ErrorInvalidSchema, has %v", err)
+	}
+
+	table, err := CreateTable(store, lock, stateStore, schema, []string{"date"}, map[string]string{"delta.appendOnly": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 0 {
+		t.Errorf("want version 0, has %d", table.State.Version)
+	}
+	metadata := table.State.CurrentMetadata
+	if metadata.Id == uuid.Nil || metadata.CreatedTime.IsZero() {
+		t.Error("the table should have an id and a creation time")
+	}
+	if !reflect.DeepEqual(metadata.PartitionColumns, []string{"date"}) || metadata.Configuration["delta.appendOnly"] != "true" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+	if _, ok := metadata.Schema.GetField("id"); !ok {
+		t.Error("the schema should have the id column")
+	}
+	if table.State.MinReaderVersion != 1 || table.State.MinWriterVersion != 2 {
+		t.Errorf("unexpected protocol %d %d", table.State.MinReaderVersion, table.State.MinWriterVersion)
+	}
+	commitState, err := stateStore.Get()
+	if err != nil || commitState.Version != 0 {
+		t.Errorf("the state store should have version 0, has %v %v", commitState, err)
+	}
+
+	_, err = CreateTable(store, lock, stateStore, schema, []string{"date"}, nil)
+	if !errors.Is(err, ErrorTableAlreadyExists) {
+		t.Errorf("want ErrorTableAlreadyExists, has %v", err)
+	}
+
+	// The created table can be committed to
+	transaction, operation, appMetaData := setupTransaction(t, table, NewDeltaTransactionOptions())
+	version, err := transaction.Commit(operation, appMetaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("want version 1, has %d", version)
+	}
+}
+
+func TestTableProperties(t *testing.T) {
+	table, stateStore, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	table, err := CreateTable(table.Store, table.LockClient, stateStore, schema, nil, map[string]string{"delta.appendOnly": "true", "com.example.owner": "data-eng"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value, ok := table.State.Property("com.example.owner"); !ok || value != "data-eng" {
+		t.Errorf("want com.example.owner = data-eng, has %s %t", value, ok)
+	}
+	if _, ok := table.State.Property("delta.enableChangeDataFeed"); ok {
+		t.Error("delta.enableChangeDataFeed should not be set")
+	}
+	properties := table.State.Properties()
+	if !reflect.DeepEqual(properties, map[string]string{"delta.appendOnly": "true", "com.example.owner": "data-eng"}) {
+		t.Errorf("unexpected properties %v", properties)
+	}
+	// The properties are a copy
+	properties["delta.appendOnly"] = "false"
+	if value, _ := table.State.Property("delta.appendOnly"); value != "true" {
+		t.Errorf("the table properties should not change, has delta.appendOnly = %s", value)
+	}
+}
+
+func TestTableIDAndCreatedTime(t *testing.T) {
+	tableState := NewDeltaTableState(-1)
+	if tableState.TableID() != "" || !tableState.CreatedTime().IsZero() {
+		t.Errorf("want no id and creation time without metadata, has %q %s", tableState.TableID(), tableState.CreatedTime())
+	}
+
+	table, stateStore, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	before := time.Now().Add(-time.Second)
+	table, err := CreateTable(table.Store, table.LockClient, stateStore, schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uuid.Parse(table.State.TableID()); err != nil {
+		t.Errorf("want a UUID, has %q: %v", table.State.TableID(), err)
+	}
+	if createdTime := table.State.CreatedTime(); createdTime.Before(before) || createdTime.After(time.Now()) {
+		t.Errorf("want the creation time of the table, has %s", createdTime)
+	}
+
+	// The identity is read back from the Metadata action
+	loaded, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.State.TableID() != table.State.TableID() || loaded.State.CreatedTime().UnixMilli() != table.State.CreatedTime().UnixMilli() {
+		t.Errorf("want %s created at %s, has %s created at %s", table.State.TableID(), table.State.CreatedTime(), loaded.State.TableID(), loaded.State.CreatedTime())
+	}
+}
+
+func TestOpenEmptyTable(t *testing.T) {
+	table, stateStore, tmpDir := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: Date}}}
+	if _, err := CreateTable(table.Store, table.LockClient, stateStore, schema, []string{"date"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := OpenTable(table.Store, table.LockClient, stateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 0 || len(table.State.Files) != 0 || len(table.State.Tombstones) != 0 {
+		t.Errorf("want version 0 without files, has version %d with %d files", table.State.Version, len(table.State.Files))
+	}
+	if !reflect.DeepEqual(table.State.CurrentMetadata.Schema, schema) || !reflect.DeepEqual(table.State.CurrentMetadata.PartitionColumns, []string{"date"}) {
+		t.Errorf("unexpected metadata %v", table.State.CurrentMetadata)
+	}
+
+	if files := table.State.FilesMatchingPartitions(nil); files == nil || len(files) != 0 {
+		t.Errorf("want an empty slice of files, has %#v", files)
+	}
+	values, err := table.State.PartitionValues("date")
+	if err != nil || values == nil || len(values) != 0 {
+		t.Errorf("want an empty slice of partition values, has %#v %v", values, err)
+	}
+	plan, err := table.Scan(nil, Comparison{Column: "id", Operator: GreaterThan, Value: int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Version != 0 || plan.Files == nil || len(plan.Files) != 0 || plan.TotalFiles != 0 || len(plan.Columns) != 2 {
+		t.Errorf("unexpected scan plan %#v", plan)
+	}
+	report, err := table.Verify(nil)
+	if err != nil || !report.Ok() {
+		t.Errorf("the empty table should verify, has %v %v", report, err)
+	}
+	deleted, err := table.Vacuum(nil)
+	if err != nil || len(deleted) != 0 {
+		t.Errorf("want nothing to vacuum, has %v %v", deleted, err)
+	}
+	if !fileExists(filepath.Join(tmpDir, "_delta_log", "00000000000000000000.json")) {
+		t.Error("version 0 should remain in the log")
+	}
+}
+
+func TestOpenTableWithStores(t *testing.T) {
+	logDir := t.TempDir()
+	dataDir := t.TempDir()
+	logPath := storage.NewPath(logDir)
+	logStore := filestore.New(logPath)
+	dataStore := filestore.New(storage.NewPath(dataDir))
+	table := NewDeltaTable(logStore, filelock.New(logPath, "_delta_log/_commit.lock", filelock.LockOptions{}), filestate.New(logPath, "_delta_log/_commit.state"))
+	table.DataStore = dataStore
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+
+	transaction, operation, appMetaData := setupTransaction(t, table, NewDeltaTransactionOptions())
+	dataFile := storage.NewPath("part-00000-80a9bb40-ec43-43b6-bb8a-fc66ef7cd768-c000.snappy.parquet")
+	err := transaction.PutDataFile(dataFile, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = transaction.Commit(operation, appMetaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fileExists(filepath.Join(dataDir, dataFile.Raw)) || fileExists(filepath.Join(logDir, dataFile.Raw)) {
+		t.Error("data file should only be written to the data store")
+	}
+	if !fileExists(filepath.Join(logDir, "_delta_log", "00000000000000000001.json")) || fileExists(filepath.Join(dataDir, "_delta_log", "00000000000000000001.json")) {
+		t.Error("commit should only be written to the log store")
+	}
+
+	opened, err := OpenTableWithStores(logStore, dataStore, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.State.Version != 1 {
+		t.Errorf("want version 1, has %d", opened.State.Version)
+	}
+	if _, ok := opened.State.Files[dataFile.Raw]; !ok {
+		t.Errorf("state should contain %s", dataFile.Raw)
+	}
+	if opened.dataStore() != dataStore {
+		t.Error("data files should be resolved in the data store")
+	}
+
+	sameStore, err := OpenTable(logStore, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sameStore.dataStore() != logStore {
+		t.Error("data store should default to the log store")
+	}
+}
+
+func TestTableClose(t *testing.T) {
+	table, _, _ := setupTest(t)
+	store := &closingStore{ObjectStore: table.Store}
+	table.Store = store
+	err := table.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	if !store.closed {
+		t.Error("Close should close the table store")
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+)
+
+// The key of the in-commit timestamp in the commit info, in milliseconds since the epoch
+const IN_COMMIT_TIMESTAMP_KEY = "inCommitTimestamp"
+
+var (
+	// ErrorStopWalk is returned by the callback of WalkLogReverse or WalkScanFiles to stop the walk without an error
+	ErrorStopWalk error = errors.New("stop walking the log")
+)
+
+// VersionInfo describes a committed version of the table
+type VersionInfo struct {
+	Version state.DeltaDataTypeVersion
+	// The last modified time of the commit file, or the in-commit timestamp of the commit if History read one
+	Timestamp time.Time
+	// The commit info of the version, only set by History
+	CommitInfo CommitInfo
+}
+
+// ListVersions returns all committed versions of the table in ascending order.
+// Only the log directory is listed, commit contents are not read.
+func (table *DeltaTable) ListVersions() ([]VersionInfo, error) {
+	commits, _, err := table.listLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]VersionInfo, 0, len(commits))
+	for version, meta := range commits {
+		versions = append(versions, VersionInfo{Version: version, Timestamp: meta.LastModified})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// History returns the latest versions of the table with their commit info, newest first.
+// At most limit commits are read; if limit is 0 all commits are read.
+func (table *DeltaTable) History(limit int) ([]VersionInfo, error) {
+	versions, err := table.ListVersions()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(versions) > limit {
+		versions = versions[len(versions)-limit:]
+	}
+
+	history := make([]VersionInfo, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		info := versions[i]
+		actions, err := table.readLogEntry(table.CommitUriFromVersion(info.Version))
+		if err != nil {
+			return nil, err
+		}
+		for _, action := range actions {
+			if commitInfo, ok := action.(CommitInfo); ok {
+				info.CommitInfo = commitInfo
+				if timestamp, ok := commitInfo.InCommitTimestamp(); ok {
+					info.Timestamp = timestamp
+				}
+				break
+			}
+		}
+		history = append(history, info)
+	}
+	return history, nil
+}
+
+// WalkLogReverse calls fn for the actions of the commits of the table from the latest commit backwards, with the
+// actions of each commit also in reverse order, so that the first occurrence of an action is the one that wins.
+// The walk stops when fn returns an error; if the error is ErrorStopWalk, WalkLogReverse returns nil.
+// Commits that were removed by log cleanup are not visited, checkpoints are not read.
+func (table *DeltaTable) WalkLogReverse(fn func(version state.DeltaDataTypeVersion, action Action) error) error {
+	versions, err := table.ListVersions()
+	if err != nil {
+		return err
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i].Version
+		actions, err := table.readLogEntry(table.CommitUriFromVersion(version))
+		if err != nil {
+			return err
+		}
+		for j := len(actions) - 1; j >= 0; j-- {
+			if err := fn(version, actions[j]); err != nil {
+				if errors.Is(err, ErrorStopWalk) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MetadataChange describes a version of the table that committed a metaData action
+type MetadataChange struct {
+	Version state.DeltaDataTypeVersion
+	// The metadata before the version, nil for the first metaData action found in the log
+	Before *MetaData
+	// The metadata committed by the version
+	After MetaData
+	// The schema of Before, nil when Before is, and of After
+	BeforeSchema *Schema
+	AfterSchema  Schema
+	// The table properties added, modified or removed by the version, by key
+	ChangedProperties map[string]PropertyChange
+}
+
+// PropertyChange is the change of the value of a table property
+type PropertyChange struct {
+	// The value before and after the change, empty when the property was not set
+	Before string
+	After  string
+}
+
+// MetadataHistory returns the versions of the table that committed a metaData action in ascending order, with the
+// metadata and schema before and after each version and the table properties it changed.
+// Like WalkLogReverse, only the commits in the log are read: changes made by commits that were removed by log cleanup
+// are not returned, and the first change returned has no metadata before it.
+func (table *DeltaTable) MetadataHistory() ([]MetadataChange, error) {
+	var reversed []MetadataChange
+	err := table.WalkLogReverse(func(version state.DeltaDataTypeVersion, action Action) error {
+		metaData, ok := action.(MetaData)
+		// The last metaData action of a commit is the one that wins, and is visited first
+		if !ok || (len(reversed) > 0 && reversed[len(reversed)-1].Version == version) {
+			return nil
+		}
+		reversed = append(reversed, MetadataChange{Version: version, After: metaData})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]MetadataChange, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		change := reversed[i]
+		schema, err := change.After.GetSchema()
+		if err != nil {
+			return nil, errors.Join(ErrorInvalidSchema, fmt.Errorf("version %d: %w", change.Version, err))
+		}
+		change.AfterSchema = schema
+		before := make(map[string]string)
+		if len(changes) > 0 {
+			previous := changes[len(changes)-1]
+			change.Before = &previous.After
+			change.BeforeSchema = &previous.AfterSchema
+			before = previous.After.Configuration
+		}
+		change.ChangedProperties = make(map[string]PropertyChange)
+		for key, value := range change.After.Configuration {
+			if previousValue, ok := before[key]; !ok || previousValue != value {
+				change.ChangedProperties[key] = PropertyChange{Before: previousValue, After: value}
+			}
+		}
+		for key, previousValue := range before {
+			if _, ok := change.After.Configuration[key]; !ok {
+				change.ChangedProperties[key] = PropertyChange{Before: previousValue}
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Timestamp returns the commit timestamp recorded in the commit info, or false if it has none
+func (commitInfo CommitInfo) Timestamp() (time.Time, bool) {
+	return commitInfo.millis("timestamp")
+}
+
+// InCommitTimestamp returns the in-commit timestamp recorded in the commit info by writers of tables with the
+// inCommitTimestamp table feature, or false if it has none.
+// Unlike the timestamp, the in-commit timestamps of a table are guaranteed to increase with the version.
+func (commitInfo CommitInfo) InCommitTimestamp() (time.Time, bool) {
+	return commitInfo.millis(IN_COMMIT_TIMESTAMP_KEY)
+}
+
+// millis returns the value of the key as a time given in milliseconds since the epoch
+func (commitInfo CommitInfo) millis(key string) (time.Time, bool) {
+	var millis int64
+	switch value := commitInfo[key].(type) {
+	case int64:
+		millis = value
+	case int:
+		millis = int64(value)
+	case float64:
+		millis = int64(value)
+	case json.Number:
+		var err error
+		if millis, err = value.Int64(); err != nil {
+			return time.Time{}, false
+		}
+	default:
+		return time.Time{}, false
+	}
+	return time.UnixMilli(millis), true
+}
+
+// authoritativeTimestamp returns the in-commit timestamp of the commit info, or else its timestamp
+func (commitInfo CommitInfo) authoritativeTimestamp() (time.Time, bool) {
+	if timestamp, ok := commitInfo.InCommitTimestamp(); ok {
+		return timestamp, true
+	}
+	return commitInfo.Timestamp()
+}
+
+// commitTimestamp returns the in-commit timestamp of the given version, or else the timestamp of its commit info,
+// or the last modified time of the commit file if the commit has neither, as for commits written before the
+// inCommitTimestamp feature was enabled. Timestamps are cached since commits are immutable.
+func (table *DeltaTable) commitTimestamp(version state.DeltaDataTypeVersion, meta storage.ObjectMeta) (time.Time, error) {
+	if timestamp, ok := table.VersionTimestamp[DeltaDataTypeVersion(version)]; ok {
+		return timestamp, nil
+	}
+	actions, err := table.readLogEntry(table.CommitUriFromVersion(version))
+	if err != nil {
+		return time.Time{}, err
+	}
+	timestamp := meta.LastModified
+	for _, action := range actions {
+		if commitInfo, ok := action.(CommitInfo); ok {
+			if commitTimestamp, ok := commitInfo.authoritativeTimestamp(); ok {
+				timestamp = commitTimestamp
+			}
+			break
+		}
+	}
+	table.cacheCommitTimestamp(version, timestamp)
+	return timestamp, nil
+}
+
+// VersionAtTimestamp returns the latest version of the table committed at or before the timestamp.
+// Commit timestamps must increase with the version; a commit written by a client whose clock went backward is
+// treated as committed 1ms after the previous commit, as writers of delta-go do when committing.
+// The commits of the table are read the first time their timestamp is needed.
+func (table *DeltaTable) VersionAtTimestamp(timestamp time.Time) (state.DeltaDataTypeVersion, error) {
+	commits, _, err := table.listLogFiles()
+	if err != nil {
+		return -1, err
+	}
+	versions := make([]state.DeltaDataTypeVersion, 0, len(commits))
+	for version := range commits {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	var found state.DeltaDataTypeVersion = -1
+	var previous time.Time
+	for i, version := range versions {
+		commitTimestamp, err := table.commitTimestamp(version, commits[version])
+		if err != nil {
+			return -1, err
+		}
+		if i > 0 && !commitTimestamp.After(previous) {
+			commitTimestamp = previous.Add(time.Millisecond)
+		}
+		if commitTimestamp.After(timestamp) {
+			break
+		}
+		found = version
+		previous = commitTimestamp
+	}
+	if found < 0 {
+		return -1, errors.Join(ErrorInvalidVersion, fmt.Errorf("no version committed at or before %s", timestamp))
+	}
+	return found, nil
+}
+
+// LoadWithTimestamp loads the table state of the latest version committed at or before the timestamp
+func (table *DeltaTable) LoadWithTimestamp(timestamp time.Time) error {
+	version, err := table.VersionAtTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+	return table.LoadVersion(&version)
+}
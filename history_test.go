@@ -0,0 +1,374 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	"github.com/rivian/delta-go/storage/filestore"
+)
+
+func TestListVersions(t *testing.T) {
+	tmpDir := copyTestTable(t, "testdata/compacted_log")
+	table := NewDeltaTable(filestore.New(storage.NewPath(tmpDir)), nil, nil)
+
+	versions, err := table.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 4 {
+		t.Fatalf("want 4 versions, has %d", len(versions))
+	}
+	for i, info := range versions {
+		if info.Version != state.DeltaDataTypeVersion(i) {
+			t.Errorf("want version %d, has %d", i, info.Version)
+		}
+		if info.Timestamp.IsZero() {
+			t.Errorf("version %d should have a timestamp", info.Version)
+		}
+		if info.CommitInfo != nil {
+			t.Errorf("version %d should not have commit info", info.Version)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	tmpDir := copyTestTable(t, "testdata/compacted_log")
+	table := NewDeltaTable(filestore.New(storage.NewPath(tmpDir)), nil, nil)
+
+	history, err := table.History(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Version != 3 || history[1].Version != 2 {
+		t.Fatalf("want versions 3 and 2, has %+v", history)
+	}
+	for _, info := range history {
+		if info.CommitInfo["operation"] == nil {
+			t.Errorf("version %d should have an operation, has %v", info.Version, info.CommitInfo)
+		}
+	}
+
+	history, err = table.History(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || history[0].Version != 3 || history[3].Version != 0 {
+		t.Errorf("want all versions newest first, has %+v", history)
+	}
+
+	empty := NewDeltaTable(filestore.New(storage.NewPath(t.TempDir())), nil, nil)
+	versions, err := empty.ListVersions()
+	if err != nil || len(versions) != 0 {
+		t.Errorf("want no versions, has %v, %v", versions, err)
+	}
+}
+
+func TestWalkLogReverse(t *testing.T) {
+	table, _, _ := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	for version := 1; version <= 3; version++ {
+		transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+		transaction.AddAction(Txn{AppId: "app", Version: DeltaDataTypeVersion(version * 10)})
+		if version == 1 {
+			transaction.AddAction(Txn{AppId: "other", Version: 1})
+		}
+		if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The latest txn of an app is found without reading the older commits
+	var latest Txn
+	visited := make(map[state.DeltaDataTypeVersion]bool)
+	err := table.WalkLogReverse(func(version state.DeltaDataTypeVersion, action Action) error {
+		visited[version] = true
+		if txn, ok := action.(Txn); ok && txn.AppId == "app" {
+			latest = txn
+			return ErrorStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != 30 {
+		t.Errorf("want txn version 30, has %d", latest.Version)
+	}
+	if len(visited) != 1 || !visited[3] {
+		t.Errorf("only version 3 should be visited, has %v", visited)
+	}
+
+	// All commits are visited newest first
+	var versions []state.DeltaDataTypeVersion
+	err = table.WalkLogReverse(func(version state.DeltaDataTypeVersion, action Action) error {
+		if len(versions) == 0 || versions[len(versions)-1] != version {
+			versions = append(versions, version)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 4 || versions[0] != 3 || versions[3] != 0 {
+		t.Errorf("want versions 3 to 0, has %v", versions)
+	}
+
+	// Other errors are returned
+	walkErr := errors.New("walk failed")
+	err = table.WalkLogReverse(func(version state.DeltaDataTypeVersion, action Action) error {
+		return walkErr
+	})
+	if !errors.Is(err, walkErr) {
+		t.Errorf("want the callback error, has %v", err)
+	}
+}
+
+func TestMetadataHistory(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("Test Table", "", new(Format).Default(), schema, nil, map[string]string{"delta.appendOnly": "true"})
+	if err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{}); err != nil {
+		t.Fatal(err)
+	}
+	// Version 1 has no metadata
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-0.parquet", Size: 1, DataChange: true})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Version 2 adds a column
+	metadata.Schema.Fields = append(metadata.Schema.Fields, SchemaField{Name: "name", Type: String, Nullable: true})
+	transaction = table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(metadata.ToMetaData())
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	// Version 3 flips a property and sets another, version 4 removes it
+	if _, err := table.SetProperties(map[string]string{"delta.appendOnly": "false", "owner": "data eng"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.UnsetProperties([]string{"owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := table.MetadataHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Fatalf("want 4 changes, has %+v", changes)
+	}
+	expectedVersions := []state.DeltaDataTypeVersion{0, 2, 3, 4}
+	for i, change := range changes {
+		if change.Version != expectedVersions[i] {
+			t.Errorf("want version %d, has %d", expectedVersions[i], change.Version)
+		}
+		if i > 0 && (change.Before == nil || change.Before.SchemaString != changes[i-1].After.SchemaString) {
+			t.Errorf("version %d: the metadata before should be that of version %d", change.Version, changes[i-1].Version)
+		}
+	}
+
+	if changes[0].Before != nil || changes[0].BeforeSchema != nil || len(changes[0].AfterSchema.Fields) != 1 {
+		t.Errorf("unexpected creation %+v", changes[0])
+	}
+	if !reflect.DeepEqual(changes[0].ChangedProperties, map[string]PropertyChange{"delta.appendOnly": {After: "true"}}) {
+		t.Errorf("unexpected properties of version 0 %v", changes[0].ChangedProperties)
+	}
+	if len(changes[1].BeforeSchema.Fields) != 1 || len(changes[1].AfterSchema.Fields) != 2 || changes[1].AfterSchema.Fields[1].Name != "name" {
+		t.Errorf("version 2 should add a column, has %+v to %+v", changes[1].BeforeSchema, changes[1].AfterSchema)
+	}
+	if len(changes[1].ChangedProperties) != 0 {
+		t.Errorf("version 2 should not change properties, has %v", changes[1].ChangedProperties)
+	}
+	expected := map[string]PropertyChange{"delta.appendOnly": {Before: "true", After: "false"}, "owner": {After: "data eng"}}
+	if !reflect.DeepEqual(changes[2].ChangedProperties, expected) {
+		t.Errorf("want properties %v, has %v", expected, changes[2].ChangedProperties)
+	}
+	expected = map[string]PropertyChange{"owner": {Before: "data eng"}}
+	if !reflect.DeepEqual(changes[3].ChangedProperties, expected) {
+		t.Errorf("want properties %v, has %v", expected, changes[3].ChangedProperties)
+	}
+}
+
+func TestMonotonicCommitTimestamps(t *testing.T) {
+	table, _, _ := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	created, err := table.commitTimestamp(0, storage.ObjectMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { timeNow = time.Now }()
+	commitAt := func(now time.Time) {
+		t.Helper()
+		timeNow = func() time.Time { return now }
+		transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+		if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The clock goes backward between the commits of version 1 and 2
+	t1 := created.Add(time.Hour)
+	commitAt(t1)
+	commitAt(t1.Add(-30 * time.Minute))
+
+	history, err := table.History(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp, ok := history[0].CommitInfo.Timestamp()
+	if !ok || !timestamp.Equal(t1.Add(time.Millisecond)) {
+		t.Errorf("want the timestamp of version 2 bumped to %s, has %s", t1.Add(time.Millisecond), timestamp)
+	}
+
+	// A commit written with a backward timestamp by another client is read as 1ms after the previous commit
+	err = table.WriteCommitRaw(3, []byte(fmt.Sprintf(`{"commitInfo":{"timestamp":%d}}`, created.UnixMilli())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for expected, at := range map[state.DeltaDataTypeVersion]time.Time{
+		0: created.Add(time.Minute),
+		1: t1,
+		2: t1.Add(time.Millisecond),
+		3: t1.Add(time.Hour),
+	} {
+		version, err := table.VersionAtTimestamp(at)
+		if err != nil {
+			t.Error(err)
+		}
+		if version != expected {
+			t.Errorf("at %s: want version %d, has %d", at, expected, version)
+		}
+	}
+	if _, err := table.VersionAtTimestamp(created.Add(-time.Minute)); !errors.Is(err, ErrorInvalidVersion) {
+		t.Errorf("want ErrorInvalidVersion before the table was created, has %v", err)
+	}
+
+	if err := table.LoadWithTimestamp(t1); err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 1 {
+		t.Errorf("want version 1 loaded, has %d", table.State.Version)
+	}
+}
+
+func TestCommitTimestampAfterConcurrentCommit(t *testing.T) {
+	table, _, _ := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	created, err := table.commitTimestamp(0, storage.ObjectMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return created.Add(time.Minute) }
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	commit, err := transaction.PrepareCommit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another writer with a clock ahead commits version 1 before the prepared commit is committed
+	writer, err := OpenTable(table.Store, table.LockClient, table.StateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeNow = func() time.Time { return created.Add(time.Hour) }
+	if _, err := writer.CreateTransaction(NewDeltaTransactionOptions()).Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err = transaction.TryCommitLoop(&commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.version != 2 {
+		t.Fatalf("want version 2, has %d", transaction.version)
+	}
+	reader, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp, err := reader.commitTimestamp(2, storage.ObjectMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !timestamp.Equal(created.Add(time.Hour + time.Millisecond)) {
+		t.Errorf("want the timestamp of version 2 after version 1, has %s", timestamp)
+	}
+}
+
+func TestInCommitTimestamps(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	created, err := table.commitTimestamp(0, storage.ObjectMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 1 predates the inCommitTimestamp feature and has no commit info, so its modification time is used
+	table.WriteCommitRaw(1, []byte(`{"txn":{"appId":"app","version":1}}`))
+	os.Chtimes(filepath.Join(tmpDir, table.CommitUriFromVersion(1).Raw), created.Add(time.Hour), created.Add(time.Hour))
+	// The in-commit timestamp of version 2 is preferred over its timestamp and over the modification time of the
+	// copied commit file
+	inCommitTimestamp := created.Add(2 * time.Hour)
+	table.WriteCommitRaw(2, []byte(fmt.Sprintf(`{"commitInfo":{"timestamp":%d,"inCommitTimestamp":%d}}`, created.Add(5*time.Hour).UnixMilli(), inCommitTimestamp.UnixMilli())))
+
+	table, err = OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for expected, at := range map[state.DeltaDataTypeVersion]time.Time{
+		0: created.Add(time.Minute),
+		1: created.Add(90 * time.Minute),
+		2: inCommitTimestamp,
+	} {
+		version, err := table.VersionAtTimestamp(at)
+		if err != nil {
+			t.Error(err)
+		}
+		if version != expected {
+			t.Errorf("at %s: want version %d, has %d", at, expected, version)
+		}
+	}
+
+	history, err := table.History(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !history[0].Timestamp.Equal(inCommitTimestamp) {
+		t.Errorf("want the in-commit timestamp %s, has %s", inCommitTimestamp, history[0].Timestamp)
+	}
+	if timestamp, ok := history[0].CommitInfo.InCommitTimestamp(); !ok || !timestamp.Equal(inCommitTimestamp) {
+		t.Errorf("want the in-commit timestamp %s, has %s", inCommitTimestamp, timestamp)
+	}
+	if _, ok := (CommitInfo{"timestamp": int64(1)}).InCommitTimestamp(); ok {
+		t.Error("a commit info without an in-commit timestamp should have none")
+	}
+}
@@ -16,13 +16,19 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/rivian/delta-go/storage"
 	"github.com/rivian/delta-go/storage/filestore"
 )
@@ -34,6 +40,14 @@ type S3MockClient struct {
 	s3StorePath string
 	// For testing: if MockError is set, any S3ClientAPI function called will return that error
 	MockError error
+	// For testing: if MockUploadPartError is set, UploadPart will return that error
+	MockUploadPartError error
+	// Parts of the multipart uploads in progress, by upload id and part number
+	uploads   map[string]map[int32][]byte
+	uploadsMu sync.Mutex
+	// The number of multipart uploads that were completed and aborted
+	CompletedUploads int
+	AbortedUploads   int
 }
 
 // newS3MockClient creates a mock S3 client that uses a filestore in a temporary directory to
@@ -44,15 +58,16 @@ func NewS3MockClient(t *testing.T, baseURI *storage.Path) (*S3MockClient, error)
 	fileStore := filestore.FileObjectStore{BaseURI: tmpPath}
 	client := new(S3MockClient)
 	client.fileStore = fileStore
+	client.uploads = make(map[string]map[int32][]byte)
 	// The mock client needs information about the S3 store's path to avoid edge cases during List
 	baseURL, err := baseURI.ParseURL()
 	if err != nil {
 		return nil, err
 	}
-	if strings.HasSuffix(baseURL.Path, "/") {
-		client.s3StorePath = baseURL.Path
-	} else {
-		client.s3StorePath = baseURL.Path + "/"
+	// Keys do not start with a /
+	client.s3StorePath = strings.TrimPrefix(baseURL.Path, "/")
+	if !strings.HasSuffix(client.s3StorePath, "/") {
+		client.s3StorePath += "/"
 	}
 	return client, nil
 }
@@ -83,6 +98,7 @@ func (m *S3MockClient) HeadObject(ctx context.Context, input *s3.HeadObjectInput
 	headObjectOutput := new(s3.HeadObjectOutput)
 	headObjectOutput.LastModified = &meta.LastModified
 	headObjectOutput.ContentLength = meta.Size
+	headObjectOutput.ETag = &meta.ETag
 	return headObjectOutput, nil
 }
 
@@ -112,14 +128,19 @@ func (m *S3MockClient) GetObject(ctx context.Context, input *s3.GetObjectInput,
 	if err != nil {
 		return nil, err
 	}
-	data, err := m.fileStore.Get(filePath)
+	data, meta, err := m.fileStore.GetWithMeta(filePath)
 	if err != nil {
 		return nil, err
 	}
+	if input.IfNoneMatch != nil && *input.IfNoneMatch == meta.ETag {
+		return nil, NewResponseError(http.StatusNotModified)
+	}
 
 	getObjectOutput := new(s3.GetObjectOutput)
 	getObjectOutput.Body = io.NopCloser(bytes.NewReader(data))
 	getObjectOutput.ContentLength = int64(len(data))
+	getObjectOutput.LastModified = &meta.LastModified
+	getObjectOutput.ETag = &meta.ETag
 	return getObjectOutput, nil
 }
 
@@ -166,6 +187,101 @@ func (m *S3MockClient) DeleteObject(ctx context.Context, input *s3.DeleteObjectI
 	return deleteObjectOutput, nil
 }
 
+func (m *S3MockClient) DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if m.MockError != nil {
+		return nil, m.MockError
+	}
+
+	deleteObjectsOutput := new(s3.DeleteObjectsOutput)
+	for _, object := range input.Delete.Objects {
+		filePath, err := getFilePathFromS3Input(*input.Bucket, *object.Key)
+		if err == nil {
+			err = m.fileStore.Delete(filePath)
+		}
+		if err != nil {
+			deleteObjectsOutput.Errors = append(deleteObjectsOutput.Errors, types.Error{Key: object.Key, Code: aws.String("InternalError"), Message: aws.String(err.Error())})
+		} else if !input.Delete.Quiet {
+			deleteObjectsOutput.Deleted = append(deleteObjectsOutput.Deleted, types.DeletedObject{Key: object.Key})
+		}
+	}
+	return deleteObjectsOutput, nil
+}
+
+func (m *S3MockClient) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if m.MockError != nil {
+		return nil, m.MockError
+	}
+
+	m.uploadsMu.Lock()
+	defer m.uploadsMu.Unlock()
+	uploadId := fmt.Sprintf("upload-%d", len(m.uploads)+m.CompletedUploads+m.AbortedUploads)
+	m.uploads[uploadId] = make(map[int32][]byte)
+	return &s3.CreateMultipartUploadOutput{Bucket: input.Bucket, Key: input.Key, UploadId: aws.String(uploadId)}, nil
+}
+
+func (m *S3MockClient) UploadPart(ctx context.Context, input *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if m.MockError != nil {
+		return nil, m.MockError
+	}
+	if m.MockUploadPartError != nil && input.PartNumber > 1 {
+		return nil, m.MockUploadPartError
+	}
+
+	buffer := new(bytes.Buffer)
+	buffer.ReadFrom(input.Body)
+	m.uploadsMu.Lock()
+	defer m.uploadsMu.Unlock()
+	parts, ok := m.uploads[*input.UploadId]
+	if !ok {
+		return nil, errors.New("NoSuchUpload")
+	}
+	parts[input.PartNumber] = buffer.Bytes()
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", input.PartNumber))}, nil
+}
+
+func (m *S3MockClient) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if m.MockError != nil {
+		return nil, m.MockError
+	}
+
+	m.uploadsMu.Lock()
+	defer m.uploadsMu.Unlock()
+	parts, ok := m.uploads[*input.UploadId]
+	if !ok {
+		return nil, errors.New("NoSuchUpload")
+	}
+	var data []byte
+	for i, part := range input.MultipartUpload.Parts {
+		if part.PartNumber != int32(i+1) {
+			return nil, errors.New("InvalidPartOrder")
+		}
+		data = append(data, parts[part.PartNumber]...)
+	}
+	filePath, err := getFilePathFromS3Input(*input.Bucket, *input.Key)
+	if err != nil {
+		return nil, err
+	}
+	err = m.fileStore.Put(filePath, data)
+	if err != nil {
+		return nil, err
+	}
+	delete(m.uploads, *input.UploadId)
+	m.CompletedUploads++
+	return &s3.CompleteMultipartUploadOutput{Bucket: input.Bucket, Key: input.Key}, nil
+}
+
+func (m *S3MockClient) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	if m.MockError != nil {
+		return nil, m.MockError
+	}
+
+	m.uploadsMu.Lock()
+	defer m.uploadsMu.Unlock()
+	delete(m.uploads, *input.UploadId)
+	m.AbortedUploads++
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
 func (m *S3MockClient) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
 	if m.MockError != nil {
 		return nil, m.MockError
@@ -181,22 +297,48 @@ func (m *S3MockClient) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV
 		return nil, err
 	}
 
+	// Like S3, keys are listed in order, in pages of at most MaxKeys keys continuing after the last key of the
+	// previous page
+	maxKeys := int(input.MaxKeys)
+	if maxKeys <= 0 || maxKeys > 1000 {
+		maxKeys = 1000
+	}
 	listObjectsOutput := new(s3.ListObjectsV2Output)
 	listObjectsOutput.Contents = make([]types.Object, 0, len(output))
 	for _, r := range output {
-		key := strings.TrimPrefix(r.Location.Raw, *input.Bucket)
-		if key != m.s3StorePath {
-			lastModified := r.LastModified
-			listObjectsOutput.Contents = append(listObjectsOutput.Contents, types.Object{
-				Key:          &key,
-				Size:         r.Size,
-				LastModified: &lastModified})
+		key := strings.TrimPrefix(r.Location.Raw, *input.Bucket+"/")
+		if key == m.s3StorePath || (input.ContinuationToken != nil && key <= *input.ContinuationToken) {
+			continue
 		}
+		if len(listObjectsOutput.Contents) == maxKeys {
+			listObjectsOutput.IsTruncated = true
+			listObjectsOutput.NextContinuationToken = listObjectsOutput.Contents[maxKeys-1].Key
+			break
+		}
+		lastModified := r.LastModified
+		listObjectsOutput.Contents = append(listObjectsOutput.Contents, types.Object{
+			Key:          &key,
+			Size:         r.Size,
+			LastModified: &lastModified})
 	}
 	listObjectsOutput.KeyCount = int32(len(listObjectsOutput.Contents))
+	listObjectsOutput.MaxKeys = int32(maxKeys)
 	return listObjectsOutput, nil
 }
 
+// NewResponseError creates an AWS response error with the given HTTP status code, as returned by the S3 client
+func NewResponseError(statusCode int) error {
+	response := new(http.Response)
+	response.StatusCode = statusCode
+	smithyResponse := new(smithyhttp.Response)
+	smithyResponse.Response = response
+	smithyResponseError := new(smithyhttp.ResponseError)
+	smithyResponseError.Response = smithyResponse
+	responseError := new(awshttp.ResponseError)
+	responseError.ResponseError = smithyResponseError
+	return responseError
+}
+
 // getFilePath returns the path of the location on the baseURI, ignoring the URI scheme
 func getFilePath(baseURI *storage.Path, location *storage.Path) (*storage.Path, error) {
 	baseURL, err := baseURI.ParseURL()
@@ -13,6 +13,7 @@
 package dynamolock
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -29,6 +30,7 @@ type DynamoLock struct {
 	Key          string
 	DynamoClient dynamodbiface.DynamoDBAPI
 	Options      LockOptions
+	renewer      lock.AutoRenewer
 }
 
 // Compile time check that FileLock implements lock.Locker
@@ -83,6 +85,7 @@ func (l *DynamoLock) TryLock() (bool, error) {
 }
 
 func (l *DynamoLock) Unlock() error {
+	l.renewer.Stop()
 	success, err := l.LockClient.ReleaseLock(l.LockedItem)
 	if !success {
 		return fmt.Errorf("%w", lock.ErrorUnableToUnlock)
@@ -93,3 +96,14 @@ func (l *DynamoLock) Unlock() error {
 	}
 	return nil
 }
+
+// AutoRenew sends heartbeats for the held lock until Unlock is called.
+// This complements the heartbeat of the lock client by detecting that the lease has been lost.
+func (l *DynamoLock) AutoRenew(ctx context.Context) <-chan error {
+	return l.renewer.Start(ctx, l.Options.TTL, func() error {
+		if l.LockedItem == nil || l.LockedItem.IsExpired() {
+			return fmt.Errorf("dynamo lock %s has expired", l.Key)
+		}
+		return l.LockClient.SendHeartbeatWithContext(ctx, l.LockedItem)
+	})
+}
@@ -13,7 +13,9 @@
 package filelock
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"path/filepath"
 	"time"
 
@@ -27,6 +29,9 @@ type FileLock struct {
 	Key     string
 	lock    *flock.Flock
 	Options LockOptions
+	// enforces the TTL of a blocking lock
+	ttlTimer *time.Timer
+	renewer  lock.AutoRenewer
 }
 
 // Compile time check that FileLock implements lock.Locker
@@ -72,10 +77,9 @@ func (l *FileLock) TryLock() (bool, error) {
 	case true:
 		err = l.lock.Lock()
 		//Enforce a TTL
-		go func() {
-			time.Sleep(l.Options.TTL)
+		l.ttlTimer = time.AfterFunc(l.Options.TTL, func() {
 			l.Unlock()
-		}()
+		})
 		locked = true
 	case false:
 		locked, err = l.lock.TryLock()
@@ -88,9 +92,26 @@ func (l *FileLock) TryLock() (bool, error) {
 }
 
 func (l *FileLock) Unlock() error {
+	l.renewer.Stop()
 	err := l.lock.Unlock()
 	if err != nil {
 		return errors.Join(lock.ErrorUnableToUnlock, err)
 	}
 	return nil
 }
+
+// AutoRenew keeps the lock held beyond its TTL by pushing back the TTL of a blocking lock until Unlock is called.
+// Renewal fails once the lock has been released.
+func (l *FileLock) AutoRenew(ctx context.Context) <-chan error {
+	return l.renewer.Start(ctx, l.Options.TTL, l.renew)
+}
+
+func (l *FileLock) renew() error {
+	if l.lock == nil || !l.lock.Locked() {
+		return fmt.Errorf("file lock %s is not held", l.Key)
+	}
+	if l.ttlTimer != nil {
+		l.ttlTimer.Reset(l.Options.TTL)
+	}
+	return nil
+}
@@ -13,9 +13,12 @@
 package filelock
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/rivian/delta-go/lock"
 	"github.com/rivian/delta-go/storage"
 )
 
@@ -88,3 +91,40 @@ func TestTryLockBlocking(t *testing.T) {
 	}
 
 }
+
+func TestAutoRenew(t *testing.T) {
+
+	tmpDir := t.TempDir()
+
+	tmpPath := storage.NewPath(tmpDir)
+	fl := New(tmpPath, "_commit.lock", LockOptions{TTL: 150 * time.Millisecond, Block: true})
+
+	locked, err := fl.TryLock()
+	if err != nil || !locked {
+		t.Fatalf("locked = %v, err = %v; want true", locked, err)
+	}
+	lost := fl.AutoRenew(context.Background())
+
+	// The lock is held beyond its TTL while it is renewed
+	time.Sleep(400 * time.Millisecond)
+	otherFileLock := FileLock{BaseURI: tmpPath, Key: "_commit.lock"}
+	hasLock, err := otherFileLock.TryLock()
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	if hasLock {
+		t.Errorf("hasLock = %v; want false", hasLock)
+	}
+
+	// Releasing the lock behind the renewal's back is detected as a lost lock
+	fl.lock.Unlock()
+	select {
+	case err := <-lost:
+		if !errors.Is(err, lock.ErrorLockLost) {
+			t.Errorf("want ErrorLockLost, has %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("lock loss should be signaled")
+	}
+	fl.Unlock()
+}
@@ -13,12 +13,17 @@
 package lock
 
 import (
+	"context"
 	"errors"
+	"math/rand"
+	"sync"
+	"time"
 )
 
 var (
 	ErrorLockNotObtained error = errors.New("the lock could not be obtained")
 	ErrorUnableToUnlock  error = errors.New("the lock could not be released")
+	ErrorLockLost        error = errors.New("the lock was lost")
 )
 
 // Locker is the abstract interface for providing a lock client that stores data in the lock
@@ -32,4 +37,73 @@ type Locker interface {
 	// Attempts to acquire lock. If successful, returns the true.
 	// Otherwise returns false, ErrorLockNotObtained.
 	TryLock() (bool, error)
+
+	// Renews the lease of the held lock in a background goroutine until Unlock is called or ctx is cancelled.
+	// If a renewal fails the lock must be considered lost: an error wrapping ErrorLockLost is sent on the returned channel
+	// and renewal stops.
+	AutoRenew(ctx context.Context) <-chan error
+}
+
+// AutoRenewer runs the lease renewal goroutine of a Locker.
+// The zero value is ready to use.
+type AutoRenewer struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start spawns a goroutine that calls renew every lease/3, with jitter, until ctx is cancelled or Stop is called.
+// A renewal already running for this AutoRenewer is stopped first.
+func (r *AutoRenewer) Start(ctx context.Context, lease time.Duration, renew func() error) <-chan error {
+	r.Stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	lost := make(chan error, 1)
+	r.cancel = cancel
+	r.done = done
+
+	go func() {
+		defer close(done)
+		for {
+			timer := time.NewTimer(renewInterval(lease))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if err := renew(); err != nil {
+				// A renewal interrupted by cancellation does not mean the lock was lost
+				if ctx.Err() != nil {
+					return
+				}
+				lost <- errors.Join(ErrorLockLost, err)
+				return
+			}
+		}
+	}()
+	return lost
+}
+
+// Stop stops the renewal goroutine, if any, and waits for it to exit
+func (r *AutoRenewer) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// renewInterval returns a third of the lease with up to 10% jitter either way,
+// so that lock clients sharing a lease duration do not renew in lockstep
+func renewInterval(lease time.Duration) time.Duration {
+	interval := lease / 3
+	jitter := time.Duration((rand.Float64()*2 - 1) * 0.1 * float64(interval))
+	return interval + jitter
 }
@@ -0,0 +1,77 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAutoRenewer(t *testing.T) {
+	var renewals atomic.Int32
+	var renewer AutoRenewer
+	lost := renewer.Start(context.Background(), 30*time.Millisecond, func() error {
+		renewals.Add(1)
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+	renewer.Stop()
+	count := renewals.Load()
+	if count < 2 {
+		t.Errorf("want at least 2 renewals, has %d", count)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if renewals.Load() != count {
+		t.Error("renewal should stop after Stop")
+	}
+	select {
+	case err := <-lost:
+		t.Errorf("lock should not be lost, has %v", err)
+	default:
+	}
+}
+
+func TestAutoRenewerLockLost(t *testing.T) {
+	var renewer AutoRenewer
+	lost := renewer.Start(context.Background(), 30*time.Millisecond, func() error {
+		return errors.New("lease expired")
+	})
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrorLockLost) {
+			t.Errorf("want ErrorLockLost, has %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("lock loss should be signaled")
+	}
+	renewer.Stop()
+}
+
+func TestAutoRenewerContextCancel(t *testing.T) {
+	var renewals atomic.Int32
+	var renewer AutoRenewer
+	ctx, cancel := context.WithCancel(context.Background())
+	renewer.Start(ctx, 30*time.Millisecond, func() error {
+		renewals.Add(1)
+		return nil
+	})
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if renewals.Load() != 0 {
+		t.Errorf("renewal should stop when the context is cancelled, has %d renewals", renewals.Load())
+	}
+	renewer.Stop()
+}
@@ -13,6 +13,7 @@
 package redislock
 
 import (
+	"context"
 	"errors"
 	"math"
 	"math/rand"
@@ -27,6 +28,8 @@ import (
 type RedisLock struct {
 	Key          string
 	redsyncMutex *redsync.Mutex
+	ttl          time.Duration
+	renewer      lock.AutoRenewer
 }
 
 type Options struct {
@@ -70,6 +73,7 @@ func New(rs *redsync.Redsync, key string, opt *Options) *RedisLock {
 	mutex.redsyncMutex = rs.NewMutex(key, redsync.WithExpiry(opt.TTL),
 		redsync.WithRetryDelayFunc(exponentialBackoff))
 	mutex.Key = key
+	mutex.ttl = opt.TTL
 
 	return mutex
 }
@@ -85,6 +89,7 @@ func (mutex *RedisLock) TryLock() (bool, error) {
 }
 
 func (mutex *RedisLock) Unlock() error {
+	mutex.renewer.Stop()
 	// Release the lock so other processes or threads can obtain a lock.
 	if ok, err := mutex.redsyncMutex.Unlock(); !ok || err != nil {
 		return errors.Join(lock.ErrorUnableToUnlock, err)
@@ -93,6 +98,16 @@ func (mutex *RedisLock) Unlock() error {
 	return nil
 }
 
+// AutoRenew extends the expiry of the held lock until Unlock is called
+func (mutex *RedisLock) AutoRenew(ctx context.Context) <-chan error {
+	return mutex.renewer.Start(ctx, mutex.ttl, func() error {
+		if ok, err := mutex.redsyncMutex.ExtendContext(ctx); !ok || err != nil {
+			return errors.Join(errors.New("unable to extend the redis lock"), err)
+		}
+		return nil
+	})
+}
+
 func exponentialBackoff(tries int) time.Duration {
 	// Computes base * (multiplier ^ tries) + random_number_milliseconds
 	return time.Duration(baseMilliSec*math.Pow(multiplier, float64(tries))+
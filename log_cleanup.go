@@ -0,0 +1,292 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Table property setting how long commit files are kept once a checkpoint covers them, e.g. "interval 30 days"
+	LOG_RETENTION_DURATION_PROPERTY = "delta.logRetentionDuration"
+	// Table property enabling the cleanup of expired log files
+	ENABLE_EXPIRED_LOG_CLEANUP_PROPERTY = "delta.enableExpiredLogCleanup"
+	// Table property setting how long tombstones are kept, e.g. "interval 1 week"
+	DELETED_FILE_RETENTION_DURATION_PROPERTY = "delta.deletedFileRetentionDuration"
+
+	DEFAULT_LOG_RETENTION_DURATION          = 30 * 24 * time.Hour
+	DEFAULT_DELETED_FILE_RETENTION_DURATION = 7 * 24 * time.Hour
+)
+
+// The units of a calendar interval table property and their duration
+var intervalUnits = map[string]time.Duration{
+	"nanosecond":  time.Nanosecond,
+	"microsecond": time.Microsecond,
+	"millisecond": time.Millisecond,
+	"second":      time.Second,
+	"minute":      time.Minute,
+	"hour":        time.Hour,
+	"day":         24 * time.Hour,
+	"week":        7 * 24 * time.Hour,
+}
+
+// parseInterval parses a calendar interval table property such as "interval 30 days"
+func parseInterval(value string) (time.Duration, error) {
+	parts := strings.Fields(strings.ToLower(value))
+	if len(parts) == 3 && parts[0] == "interval" {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid interval %q", value)
+	}
+	count, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q", value)
+	}
+	unit, ok := intervalUnits[strings.TrimSuffix(parts[1], "s")]
+	if !ok {
+		return 0, fmt.Errorf("invalid interval unit %q", parts[1])
+	}
+	return time.Duration(count) * unit, nil
+}
+
+// durationProperty returns the duration set by an interval table property, or the default if it is not set
+func (dtmd *DeltaTableMetaData) durationProperty(property string, defaultDuration time.Duration) (time.Duration, error) {
+	value, ok := dtmd.Configuration[property]
+	if !ok {
+		return defaultDuration, nil
+	}
+	duration, err := parseInterval(value)
+	if err != nil {
+		return 0, errors.Join(ErrorInvalidTableProperty, fmt.Errorf("%s: %w", property, err))
+	}
+	return duration, nil
+}
+
+// LogRetentionDuration returns how long commit files are kept once a checkpoint covers them
+func (dtmd *DeltaTableMetaData) LogRetentionDuration() (time.Duration, error) {
+	return dtmd.durationProperty(LOG_RETENTION_DURATION_PROPERTY, DEFAULT_LOG_RETENTION_DURATION)
+}
+
+// DeletedFileRetentionDuration returns how long tombstones are kept
+func (dtmd *DeltaTableMetaData) DeletedFileRetentionDuration() (time.Duration, error) {
+	return dtmd.durationProperty(DELETED_FILE_RETENTION_DURATION_PROPERTY, DEFAULT_DELETED_FILE_RETENTION_DURATION)
+}
+
+// ExpiredLogCleanupEnabled returns whether expired log files may be cleaned up, which is the default
+func (dtmd *DeltaTableMetaData) ExpiredLogCleanupEnabled() (bool, error) {
+	value, ok := dtmd.Configuration[ENABLE_EXPIRED_LOG_CLEANUP_PROPERTY]
+	if !ok {
+		return true, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Join(ErrorInvalidTableProperty, fmt.Errorf("%s=%s", ENABLE_EXPIRED_LOG_CLEANUP_PROPERTY, value))
+	}
+	return enabled, nil
+}
+
+// readLastCheckpoint reads the _last_checkpoint pointer, returning false if there is none
+func (table *DeltaTable) readLastCheckpoint() (CheckPoint, bool, error) {
+	path := storage.PathFromIter([]string{table.BaseCommitUri().Raw, LAST_CHECKPOINT_FILE})
+	data, err := table.Store.Get(&path)
+	if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		return CheckPoint{}, false, nil
+	}
+	if err != nil {
+		return CheckPoint{}, false, errors.Join(ErrorReadingCheckpoint, err)
+	}
+	var checkpoint CheckPoint
+	err = json.Unmarshal(data, &checkpoint)
+	if err != nil {
+		return CheckPoint{}, false, errors.Join(ErrorReadingCheckpoint, err)
+	}
+	return checkpoint, true, nil
+}
+
+// LastCheckpointOptions configures WriteLastCheckpointWithOptions
+type LastCheckpointOptions struct {
+	// Verify the checkpoint against the log with VerifyCheckpoint before pointing _last_checkpoint to it, so that
+	// readers never load a checkpoint that does not match the commits it covers
+	Verify bool
+}
+
+// NewLastCheckpointOptions returns the default options, which do not verify the checkpoint
+func NewLastCheckpointOptions() *LastCheckpointOptions {
+	return &LastCheckpointOptions{}
+}
+
+// WriteLastCheckpoint points _last_checkpoint to the checkpoint. The pointer is replaced atomically, so that readers
+// never observe a partial pointer, and a pointer to a newer checkpoint is kept, as concurrent checkpoint writers
+// may finish out of order.
+func (table *DeltaTable) WriteLastCheckpoint(checkpoint CheckPoint) error {
+	return table.WriteLastCheckpointWithOptions(checkpoint, nil)
+}
+
+// WriteLastCheckpointWithOptions is WriteLastCheckpoint with options. When the checkpoint is verified, the error of
+// VerifyCheckpoint is returned and _last_checkpoint is left unchanged if the checkpoint cannot be read or does not
+// match the log.
+func (table *DeltaTable) WriteLastCheckpointWithOptions(checkpoint CheckPoint, options *LastCheckpointOptions) error {
+	if options == nil {
+		options = NewLastCheckpointOptions()
+	}
+	if lastCheckpoint, ok, err := table.readLastCheckpoint(); err == nil && ok && lastCheckpoint.Version > checkpoint.Version {
+		return nil
+	}
+	if options.Verify {
+		if err := table.VerifyCheckpoint(checkpoint.Version); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	location := storage.PathFromIter([]string{table.BaseCommitUri().Raw, LAST_CHECKPOINT_FILE})
+	return putObjectAtomic(table.Store, &location, data)
+}
+
+// putObjectAtomic replaces the object at the location so that it is never observed partially written: with
+// PutReader for the stores implementing storage.ReaderPutter, which write to a temporary file or upload the object
+// whole, and otherwise by renaming a temporary object over it
+func putObjectAtomic(store storage.ObjectStore, location *storage.Path, data []byte) error {
+	if putter, ok := store.(storage.ReaderPutter); ok {
+		return putter.PutReader(location, bytes.NewReader(data))
+	}
+	tmpPath := storage.PathFromIter([]string{path.Dir(location.Raw), fmt.Sprintf(".%s.%s.tmp", location.Base(), uuid.New())})
+	if err := store.Put(&tmpPath, data); err != nil {
+		return err
+	}
+	if err := store.Rename(&tmpPath, location); err != nil {
+		if deleteErr := store.Delete(&tmpPath); deleteErr != nil {
+			log.Warnf("delta-go: unable to remove temporary file %s: %v", tmpPath.Raw, deleteErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// latestCheckpointVersion returns the version of the latest complete checkpoint, preferring the one referenced
+// by _last_checkpoint. Returns false if the table has no usable checkpoint.
+func (table *DeltaTable) latestCheckpointVersion(logFiles []storage.ObjectMeta) (state.DeltaDataTypeVersion, bool, error) {
+	if lastCheckpoint, ok, err := table.readLastCheckpoint(); err != nil {
+		return 0, false, err
+	} else if ok {
+		if _, err := table.checkpointParts(lastCheckpoint.Version); err == nil {
+			return lastCheckpoint.Version, true, nil
+		}
+	}
+
+	var versions []state.DeltaDataTypeVersion
+	for _, meta := range logFiles {
+		kind, version, err := classifyLogFile(meta.Location.Raw)
+		if err != nil {
+			return 0, false, err
+		}
+		if kind == LogFileCheckpoint {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	for _, version := range versions {
+		if _, err := table.checkpointParts(version); err == nil {
+			return version, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// expiredLogFile is a log file that may be removed once it has expired
+type expiredLogFile struct {
+	// the last version of the log covered by the file
+	version state.DeltaDataTypeVersion
+	meta    storage.ObjectMeta
+}
+
+// CleanupExpiredLogs deletes the commit, checkpoint and log compaction files that are older than the log retention
+// duration of the table (delta.logRetentionDuration) and are made unnecessary by a newer checkpoint.
+// Files of the latest checkpoint's version or newer are never deleted, nor is the _last_checkpoint pointer.
+// Files are deleted from the oldest version on, stopping at the first file that has not expired, so the remaining
+// log never has gaps. Nothing is deleted if the table has no checkpoint or delta.enableExpiredLogCleanup is false.
+// The table metadata must be loaded. Returns the number of deleted files.
+func (table *DeltaTable) CleanupExpiredLogs() (int, error) {
+	metadata := table.State.CurrentMetadata
+	enabled, err := metadata.ExpiredLogCleanupEnabled()
+	if err != nil || !enabled {
+		return 0, err
+	}
+	retention, err := metadata.LogRetentionDuration()
+	if err != nil {
+		return 0, err
+	}
+
+	logFiles, err := table.Store.List(table.BaseCommitUri())
+	if err != nil {
+		return 0, err
+	}
+	checkpointVersion, ok, err := table.latestCheckpointVersion(logFiles)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	var candidates []expiredLogFile
+	for _, meta := range logFiles {
+		kind, version, err := classifyLogFile(meta.Location.Raw)
+		if err != nil {
+			return 0, err
+		}
+		if kind != LogFileCommit && kind != LogFileCheckpoint && kind != LogFileCompaction {
+			continue
+		}
+		if version < checkpointVersion {
+			meta.Location = storage.PathFromIter([]string{table.BaseCommitUri().Raw, meta.Location.Base()})
+			candidates = append(candidates, expiredLogFile{version: version, meta: meta})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].version < candidates[j].version })
+
+	cutoff := time.Now().Add(-retention)
+	deleted := 0
+	for i := 0; i < len(candidates); {
+		// All files of a version are deleted together, and only if all of them have expired
+		j := i
+		expired := true
+		for j < len(candidates) && candidates[j].version == candidates[i].version {
+			expired = expired && candidates[j].meta.LastModified.Before(cutoff)
+			j++
+		}
+		if !expired {
+			break
+		}
+		for ; i < j; i++ {
+			if err := table.Store.Delete(&candidates[i].meta.Location); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
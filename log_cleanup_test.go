@@ -0,0 +1,217 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+)
+
+func TestParseInterval(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"interval 30 days": 30 * 24 * time.Hour,
+		"interval 1 week":  7 * 24 * time.Hour,
+		"INTERVAL 2 HOURS": 2 * time.Hour,
+		"15 minutes":       15 * time.Minute,
+	} {
+		duration, err := parseInterval(value)
+		if err != nil {
+			t.Error(err)
+		}
+		if duration != expected {
+			t.Errorf("%s: want %s, has %s", value, expected, duration)
+		}
+	}
+	for _, value := range []string{"", "interval days", "interval 1 fortnight", "interval 1 2 days"} {
+		if _, err := parseInterval(value); err == nil {
+			t.Errorf("%s: want an error", value)
+		}
+	}
+
+	metadata := DeltaTableMetaData{Configuration: map[string]string{LOG_RETENTION_DURATION_PROPERTY: "forever"}}
+	_, err := metadata.LogRetentionDuration()
+	if !errors.Is(err, ErrorInvalidTableProperty) {
+		t.Errorf("want ErrorInvalidTableProperty, has %v", err)
+	}
+}
+
+// Helper function to set up a table with commits 0 to 4 and checkpoints of versions 1 and 3
+func setupLogCleanupTable(t *testing.T, configuration map[string]string) (*DeltaTable, string) {
+	t.Helper()
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	commit := func(transaction *DeltaTransaction) {
+		transaction.AddAction(Add{Path: "part-00000.parquet", Size: 984, ModificationTime: DeltaDataTypeTimestamp(time.Now().UnixMilli())})
+	}
+	table, tmpDir := setupTestTable(t, schema, nil, configuration, nil, commit, commit, commit, commit)
+	for _, version := range []state.DeltaDataTypeVersion{1, 3} {
+		if err := table.LoadVersion(&version); err != nil {
+			t.Fatal(err)
+		}
+		var rows []checkpointRow
+		for _, action := range table.State.actions() {
+			if row, ok := newCheckpointRow(action); ok {
+				rows = append(rows, row)
+			}
+		}
+		writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(version), rows)
+	}
+	table.Store.Put(storage.NewPath("_delta_log/"+LAST_CHECKPOINT_FILE), []byte(`{"version":3,"size":1}`))
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return table, tmpDir
+}
+
+// Helper function to set the modification time of log files
+func ageLogFiles(t *testing.T, tmpDir string, age time.Duration, names ...string) {
+	t.Helper()
+	modTime := time.Now().Add(-age)
+	for _, name := range names {
+		err := os.Chtimes(filepath.Join(tmpDir, "_delta_log", name), modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCleanupExpiredLogs(t *testing.T) {
+	table, tmpDir := setupLogCleanupTable(t, map[string]string{LOG_RETENTION_DURATION_PROPERTY: "interval 1 day"})
+	if table.State.LogRetention != 24*time.Hour {
+		t.Errorf("want log retention of 1 day, has %s", table.State.LogRetention)
+	}
+	// Everything has expired, but the files of the latest checkpoint version and newer must be kept
+	ageLogFiles(t, tmpDir, 48*time.Hour,
+		"00000000000000000000.json", "00000000000000000001.json", "00000000000000000002.json", "00000000000000000003.json", "00000000000000000004.json",
+		"00000000000000000001.checkpoint.parquet", "00000000000000000003.checkpoint.parquet", LAST_CHECKPOINT_FILE)
+
+	deleted, err := table.CleanupExpiredLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 4 {
+		t.Errorf("want 4 deleted files, has %d", deleted)
+	}
+	for name, expected := range map[string]bool{
+		"00000000000000000000.json":               false,
+		"00000000000000000001.json":               false,
+		"00000000000000000001.checkpoint.parquet": false,
+		"00000000000000000002.json":               false,
+		"00000000000000000003.json":               true,
+		"00000000000000000003.checkpoint.parquet": true,
+		"00000000000000000004.json":               true,
+		LAST_CHECKPOINT_FILE:                      true,
+	} {
+		if fileExists(filepath.Join(tmpDir, "_delta_log", name)) != expected {
+			t.Errorf("%s should exist: %v", name, expected)
+		}
+	}
+
+	// The table can still be opened from the checkpoint referenced by _last_checkpoint
+	reopened, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.State.Version != 4 || reopened.LastCheckPoint.Version != 3 {
+		t.Errorf("want version 4 from checkpoint 3, has %d from %d", reopened.State.Version, reopened.LastCheckPoint.Version)
+	}
+	if reopened.State.CurrentMetadata.Id != table.State.CurrentMetadata.Id || len(reopened.State.Files) != len(table.State.Files) {
+		t.Errorf("unexpected state %+v", reopened.State)
+	}
+}
+
+func TestCleanupExpiredLogsKeepsUnexpiredFiles(t *testing.T) {
+	table, tmpDir := setupLogCleanupTable(t, map[string]string{LOG_RETENTION_DURATION_PROPERTY: "interval 1 day"})
+	// Version 1 has not expired, so nothing after it may be deleted either
+	ageLogFiles(t, tmpDir, 48*time.Hour, "00000000000000000000.json", "00000000000000000001.json", "00000000000000000002.json")
+
+	deleted, err := table.CleanupExpiredLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("want 1 deleted file, has %d", deleted)
+	}
+	if !fileExists(filepath.Join(tmpDir, "_delta_log", "00000000000000000002.json")) {
+		t.Error("version 2 should be kept to avoid a gap in the log")
+	}
+}
+
+func TestCleanupExpiredLogsDisabled(t *testing.T) {
+	for name, configuration := range map[string]map[string]string{
+		"disabled":      {ENABLE_EXPIRED_LOG_CLEANUP_PROPERTY: "false", LOG_RETENTION_DURATION_PROPERTY: "interval 1 day"},
+		"not expired":   {},
+		"no checkpoint": {LOG_RETENTION_DURATION_PROPERTY: "interval 1 day"},
+	} {
+		table, tmpDir := setupLogCleanupTable(t, configuration)
+		ageLogFiles(t, tmpDir, 48*time.Hour, "00000000000000000000.json", "00000000000000000001.json")
+		if name == "no checkpoint" {
+			for _, checkpoint := range []state.DeltaDataTypeVersion{1, 3} {
+				table.Store.Delete(table.CheckpointUriFromVersion(checkpoint))
+			}
+		}
+
+		deleted, err := table.CleanupExpiredLogs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 0 {
+			t.Errorf("%s: want no deleted files, has %d", name, deleted)
+		}
+	}
+}
+
+func TestWriteLastCheckpoint(t *testing.T) {
+	table, _ := setupLogCleanupTable(t, nil)
+	path := storage.NewPath("_delta_log/" + LAST_CHECKPOINT_FILE)
+	// Stores without PutReader rename a temporary object over the pointer
+	for name, store := range map[string]storage.ObjectStore{"filestore": table.Store, "rename": struct{ storage.ObjectStore }{table.Store}} {
+		table.Store.Put(path, []byte(`{"version":3,"size":1}`))
+		table.Store = store
+
+		// A pointer to a newer checkpoint is kept
+		if err := table.WriteLastCheckpoint(CheckPoint{Version: 1, Size: 4}); err != nil {
+			t.Fatal(err)
+		}
+		checkpoint, _, err := table.readLastCheckpoint()
+		if err != nil || checkpoint.Version != 3 {
+			t.Errorf("%s: want the pointer to version 3 to be kept, has %+v %v", name, checkpoint, err)
+		}
+
+		if err := table.WriteLastCheckpoint(CheckPoint{Version: 4, Size: 5, Parts: 2}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := table.Store.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != `{"version":4,"size":5,"parts":2}` {
+			t.Errorf("%s: unexpected pointer %s", name, data)
+		}
+		logFiles, err := table.Store.List(table.BaseCommitUri())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, meta := range logFiles {
+			if strings.HasSuffix(meta.Location.Raw, ".tmp") {
+				t.Errorf("%s: temporary file %s is left", name, meta.Location.Raw)
+			}
+		}
+	}
+}
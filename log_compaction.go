@@ -0,0 +1,171 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+)
+
+var (
+	ErrorInvalidCompactionRange error = errors.New("invalid log compaction range")
+)
+
+// LogCompactionMetrics describes the commits summarized by a log compaction file
+type LogCompactionMetrics struct {
+	// The number of commits in the compacted range
+	NumCommits int64
+	// The number of actions read from the commits
+	NumActionsRead int64
+	// The number of actions written to the compaction file
+	NumActionsWritten int64
+	// The number of actions that were superseded by later actions, such as all but the last commitInfo
+	NumActionsCoalesced int64
+}
+
+// CompactLog writes a log compaction file <from>.<to>.compacted.json that summarizes the commits from the from
+// version to the to version inclusive, which is used by Load to skip over the individual commits.
+// The compaction file holds the latest commitInfo, protocol, metadata, app transactions and metadata domains of the
+// range, the last add or remove of each file, and every cdc and unknown action in commit order, so that a state loaded
+// through the compaction file equals the state loaded from the commits. The commits are left in place and the
+// compaction file can be deleted at any time.
+// An existing compaction file of the same range is replaced.
+func (table *DeltaTable) CompactLog(from state.DeltaDataTypeVersion, to state.DeltaDataTypeVersion) (LogCompactionMetrics, error) {
+	if from < 0 || to < from {
+		return LogCompactionMetrics{}, errors.Join(ErrorInvalidCompactionRange, fmt.Errorf("versions %d to %d", from, to))
+	}
+	commits, _, err := table.listLogFiles()
+	if err != nil {
+		return LogCompactionMetrics{}, err
+	}
+	for version := from; version <= to; version++ {
+		if _, ok := commits[version]; !ok {
+			return LogCompactionMetrics{}, errors.Join(ErrorInvalidCompactionRange, ErrorInvalidVersion, fmt.Errorf("version %d does not exist", version))
+		}
+	}
+
+	metrics := LogCompactionMetrics{NumCommits: int64(to - from + 1)}
+	compaction := newLogCompactor()
+	for version := from; version <= to; version++ {
+		actions, err := table.readLogEntry(table.CommitUriFromVersion(version))
+		if err != nil {
+			return LogCompactionMetrics{}, err
+		}
+		for _, action := range actions {
+			compaction.add(action)
+		}
+		metrics.NumActionsRead += int64(len(actions))
+	}
+
+	actions := compaction.actions()
+	metrics.NumActionsWritten = int64(len(actions))
+	metrics.NumActionsCoalesced = metrics.NumActionsRead - metrics.NumActionsWritten
+	data, err := LogEntryFromActions(actions)
+	if err != nil {
+		return LogCompactionMetrics{}, err
+	}
+
+	// Write to a temporary file first so that a partially written compaction file is never read
+	tmpPath := storage.PathFromIter([]string{"_delta_log", fmt.Sprintf("_compaction_%s.json.tmp", uuid.New().String())})
+	if err := table.Store.Put(&tmpPath, data); err != nil {
+		return LogCompactionMetrics{}, err
+	}
+	if err := table.Store.Rename(&tmpPath, table.CompactedUriFromVersions(from, to)); err != nil {
+		if deleteErr := table.Store.Delete(&tmpPath); deleteErr != nil {
+			err = errors.Join(err, deleteErr)
+		}
+		return LogCompactionMetrics{}, err
+	}
+	return metrics, nil
+}
+
+// logCompactor folds the actions of consecutive commits into the actions that have the same effect on the table state
+type logCompactor struct {
+	commitInfo CommitInfo
+	protocol   *Protocol
+	metaData   *MetaData
+	txns       map[string]Txn
+	domains    map[string]DomainMetadata
+	// the last add or remove of each file
+	files map[string]Action
+	// cdc and unknown actions, which cannot be folded, in commit order
+	unfolded []Action
+}
+
+func newLogCompactor() *logCompactor {
+	return &logCompactor{
+		txns:    make(map[string]Txn),
+		domains: make(map[string]DomainMetadata),
+		files:   make(map[string]Action),
+	}
+}
+
+func (compactor *logCompactor) add(action Action) {
+	switch action := action.(type) {
+	case Add:
+		compactor.files[action.Path] = action
+	case Remove:
+		compactor.files[action.Path] = action
+	case MetaData:
+		compactor.metaData = &action
+	case Protocol:
+		compactor.protocol = &action
+	case Txn:
+		compactor.txns[action.AppId] = action
+	case DomainMetadata:
+		// Removed domains are kept, since the domain may have been added before the compacted range
+		compactor.domains[action.Domain] = action
+	case CommitInfo:
+		compactor.commitInfo = action
+	case Cdc, UnknownAction:
+		compactor.unfolded = append(compactor.unfolded, action)
+	}
+}
+
+// actions returns the folded actions in a deterministic order
+func (compactor *logCompactor) actions() []Action {
+	var actions []Action
+	if compactor.commitInfo != nil {
+		actions = append(actions, compactor.commitInfo)
+	}
+	if compactor.protocol != nil {
+		actions = append(actions, *compactor.protocol)
+	}
+	if compactor.metaData != nil {
+		actions = append(actions, *compactor.metaData)
+	}
+	for _, appId := range sortedKeys(compactor.txns) {
+		actions = append(actions, compactor.txns[appId])
+	}
+	for _, domain := range sortedKeys(compactor.domains) {
+		actions = append(actions, compactor.domains[domain])
+	}
+	for _, path := range sortedKeys(compactor.files) {
+		actions = append(actions, compactor.files[path])
+	}
+	return append(actions, compactor.unfolded...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
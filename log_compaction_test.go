@@ -0,0 +1,149 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rivian/delta-go/state"
+)
+
+func TestCompactLog(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	if err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{{Path: "a.parquet", Size: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	commits := [][]Action{
+		{Add{Path: "b.parquet", Size: 2}, Add{Path: "c.parquet", Size: 3}, Txn{AppId: "app", Version: 1}},
+		{Remove{Path: "a.parquet"}, Remove{Path: "b.parquet"}, Txn{AppId: "app", Version: 2}},
+		{Add{Path: "b.parquet", Size: 4}, DomainMetadata{Domain: "domain", Configuration: "{}"}},
+		{Remove{Path: "c.parquet"}, Add{Path: "d.parquet", Size: 5}},
+	}
+	for _, actions := range commits {
+		transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+		transaction.AddActions(actions)
+		if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := table.CompactLog(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 11 actions are folded into the last commitInfo, txn, domain, and action of a, b and c
+	wantMetrics := LogCompactionMetrics{NumCommits: 3, NumActionsRead: 11, NumActionsWritten: 6, NumActionsCoalesced: 5}
+	if metrics != wantMetrics {
+		t.Errorf("want metrics %+v, has %+v", wantMetrics, metrics)
+	}
+	if tmpFiles, _ := filepath.Glob(filepath.Join(tmpDir, "_delta_log", "_compaction_*.json.tmp")); len(tmpFiles) != 0 {
+		t.Errorf("temporary compaction files should be removed, found %v", tmpFiles)
+	}
+
+	// With the compacted commits gone the table can only be loaded through the compaction file
+	for version := 1; version <= 3; version++ {
+		os.Remove(filepath.Join(tmpDir, table.CommitUriFromVersion(state.DeltaDataTypeVersion(version)).Raw))
+	}
+	compacted, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compacted.State.Version != 4 {
+		t.Errorf("want version 4, has %d", compacted.State.Version)
+	}
+	if !reflect.DeepEqual(compacted.State.Files, want.State.Files) || !reflect.DeepEqual(compacted.State.Tombstones, want.State.Tombstones) {
+		t.Errorf("want files %v and tombstones %v, has %v and %v", want.State.Files, want.State.Tombstones, compacted.State.Files, compacted.State.Tombstones)
+	}
+	if !reflect.DeepEqual(compacted.State.AppTransactionVersion, want.State.AppTransactionVersion) || !reflect.DeepEqual(compacted.State.Domains, want.State.Domains) {
+		t.Errorf("want transactions %v and domains %v, has %v and %v", want.State.AppTransactionVersion, want.State.Domains, compacted.State.AppTransactionVersion, compacted.State.Domains)
+	}
+}
+
+func TestCompactLogKeepsState(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	if err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{{Path: "a.parquet", Size: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddActions([]Action{Remove{Path: "a.parquet"}, Add{Path: "b.parquet", Size: 2}, Cdc{Path: "_change_data/cdc-1.parquet", Size: 3}, Txn{AppId: "app", Version: 1}})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// A commit with an action type that delta-go does not model and a commitInfo of its own
+	err := table.WriteCommitRaw(2, []byte(`{"commitInfo":{"operation":"EXTERNAL"}}
+{"futureAction":{"key":"value"}}
+{"add":{"path":"c.parquet","partitionValues":{},"size":4,"modificationTime":0,"dataChange":true}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.CompactLog(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := table.readLogEntry(table.CompactedUriFromVersions(1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cdcs, unknown int
+	for _, action := range actions {
+		switch action.(type) {
+		case Cdc:
+			cdcs++
+		case UnknownAction:
+			unknown++
+		}
+	}
+	if cdcs != 1 || unknown != 1 {
+		t.Errorf("want 1 cdc and 1 unknown action in the compaction file, has %d and %d", cdcs, unknown)
+	}
+
+	compacted, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, table.CompactedUriFromVersions(1, 2).Raw)); err != nil {
+		t.Fatal(err)
+	}
+	want, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(compacted.State, want.State) {
+		t.Errorf("want state %+v, has %+v", want.State, compacted.State)
+	}
+}
+
+func TestCompactLogInvalidRange(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	for _, versions := range [][2]state.DeltaDataTypeVersion{{-1, 0}, {1, 0}, {0, 1}} {
+		if _, err := table.CompactLog(versions[0], versions[1]); !errors.Is(err, ErrorInvalidCompactionRange) {
+			t.Errorf("%v: want ErrorInvalidCompactionRange, has %v", versions, err)
+		}
+	}
+	if compactions, _ := filepath.Glob(filepath.Join(tmpDir, "_delta_log", "*.compacted.json")); len(compactions) != 0 {
+		t.Errorf("no compaction file should be written, found %v", compactions)
+	}
+}
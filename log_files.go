@@ -0,0 +1,224 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// LogFileKind classifies the files of the Delta log
+type LogFileKind string
+
+const (
+	LogFileCommit     LogFileKind = "commit"
+	LogFileCheckpoint LogFileKind = "checkpoint"
+	LogFileCompaction LogFileKind = "compaction"
+	LogFileSidecar    LogFileKind = "sidecar"
+	LogFileChecksum   LogFileKind = "crc"
+)
+
+// SIDECAR_DIRECTORY is the directory of the log holding the sidecar files of V2 checkpoints
+const SIDECAR_DIRECTORY = "_sidecars"
+
+// The names of the files of the log, by kind
+var (
+	commitFileRegex     = regexp.MustCompile(`^(\d{20})\.json$`)
+	checkpointFileRegex = regexp.MustCompile(`^(\d{20})\.checkpoint(\.\d{10}\.\d{10})?\.parquet$`)
+	compactedFileRegex  = regexp.MustCompile(`^(\d{20})\.(\d{20})\.compacted\.json$`)
+	checksumFileRegex   = regexp.MustCompile(`^(\d{20})\.crc$`)
+)
+
+// LogFileMeta describes a file of the Delta log
+type LogFileMeta struct {
+	// Version of the commit, checkpoint or checksum; the end version of a log compaction.
+	// Sidecar files are not tied to a version and have version -1.
+	Version state.DeltaDataTypeVersion
+	Kind    LogFileKind
+	Meta    storage.ObjectMeta
+}
+
+// LogFiles lists the commit, checkpoint, log compaction, sidecar and checksum files of the log, ordered by
+// version. Other files in the log directory, such as temporary commits and _last_checkpoint, are not returned.
+func (table *DeltaTable) LogFiles() ([]LogFileMeta, error) {
+	results, err := table.Store.List(table.BaseCommitUri())
+	if err != nil {
+		return nil, err
+	}
+
+	var logFiles []LogFileMeta
+	for _, meta := range results {
+		kind, version, err := classifyLogFile(meta.Location.Raw)
+		if err != nil {
+			return nil, err
+		}
+		base := meta.Location.Base()
+		switch kind {
+		case "":
+			continue
+		case LogFileSidecar:
+			meta.Location = storage.PathFromIter([]string{table.BaseCommitUri().Raw, SIDECAR_DIRECTORY, base})
+		default:
+			meta.Location = storage.PathFromIter([]string{table.BaseCommitUri().Raw, base})
+		}
+		logFiles = append(logFiles, LogFileMeta{Version: version, Kind: kind, Meta: meta})
+	}
+
+	sort.Slice(logFiles, func(i, j int) bool {
+		if logFiles[i].Version != logFiles[j].Version {
+			return logFiles[i].Version < logFiles[j].Version
+		}
+		return logFiles[i].Meta.Location.Raw < logFiles[j].Meta.Location.Raw
+	})
+	return logFiles, nil
+}
+
+// classifyLogFile classifies a file listed in the log directory by the suffix of its name, so that the checksum,
+// checkpoint or log compaction of a version is never mistaken for its commit, and returns the version of a commit,
+// checkpoint or checksum, the end version of a log compaction, and -1 for a sidecar.
+// The kind is empty for other files, such as temporary commits, _last_checkpoint and the files of subdirectories
+// of the log other than the sidecars, whatever their name.
+func classifyLogFile(location string) (LogFileKind, state.DeltaDataTypeVersion, error) {
+	if isSidecarPath(location) {
+		return LogFileSidecar, -1, nil
+	}
+	location = strings.ReplaceAll(location, "\\", "/")
+	if strings.Contains(location, "_delta_log/") && path.Base(path.Dir(location)) != "_delta_log" {
+		return "", -1, nil
+	}
+
+	base := path.Base(location)
+	var kind LogFileKind
+	var match []string
+	if match = commitFileRegex.FindStringSubmatch(base); match != nil {
+		kind = LogFileCommit
+	} else if match = checkpointFileRegex.FindStringSubmatch(base); match != nil {
+		kind = LogFileCheckpoint
+	} else if match = compactedFileRegex.FindStringSubmatch(base); match != nil {
+		kind = LogFileCompaction
+		match = match[1:]
+	} else if match = checksumFileRegex.FindStringSubmatch(base); match != nil {
+		kind = LogFileChecksum
+	} else {
+		return "", -1, nil
+	}
+	version, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return "", -1, err
+	}
+	return kind, state.DeltaDataTypeVersion(version), nil
+}
+
+// isSidecarPath returns true if the path is a parquet file in the sidecar directory of the log
+func isSidecarPath(path string) bool {
+	path = strings.ReplaceAll(path, "\\", "/")
+	return strings.Contains(path, "_delta_log/"+SIDECAR_DIRECTORY+"/") && strings.HasSuffix(path, ".parquet")
+}
+
+// OpenTableFromLogFiles loads the latest version of the table from a list of its log files, such as the one
+// returned by LogFiles, without listing the log. The table has no lock or state store.
+func OpenTableFromLogFiles(store storage.ObjectStore, logFiles []LogFileMeta) (*DeltaTable, error) {
+	table := NewDeltaTable(store, nil, nil)
+	err := table.LoadFromLogFiles(logFiles)
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// LoadFromLogFiles loads the table state at the latest commit in logFiles, from the latest checkpoint in logFiles
+// that can be read followed by the commits after it, without listing the log.
+// ErrorInvalidVersion is returned if a commit between the checkpoint, or version 0, and the latest commit is
+// missing from logFiles.
+func (table *DeltaTable) LoadFromLogFiles(logFiles []LogFileMeta) error {
+	commits := make(map[state.DeltaDataTypeVersion]bool)
+	var checkpoints []storage.ObjectMeta
+	var checkpointVersions []state.DeltaDataTypeVersion
+	var compactions []logCompaction
+	var latestVersion state.DeltaDataTypeVersion = -1
+	for _, logFile := range logFiles {
+		switch logFile.Kind {
+		case LogFileCommit:
+			commits[logFile.Version] = true
+			latestVersion = max(latestVersion, logFile.Version)
+		case LogFileCheckpoint:
+			if len(checkpointVersions) == 0 || checkpointVersions[len(checkpointVersions)-1] != logFile.Version {
+				checkpointVersions = append(checkpointVersions, logFile.Version)
+			}
+			checkpoints = append(checkpoints, logFile.Meta)
+		case LogFileCompaction:
+			match := compactedFileRegex.FindStringSubmatch(logFile.Meta.Location.Base())
+			if match == nil {
+				continue
+			}
+			start, err := strconv.ParseInt(match[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			compactions = append(compactions, logCompaction{Start: state.DeltaDataTypeVersion(start), End: logFile.Version, Path: logFile.Meta.Location})
+		}
+	}
+	if len(commits) == 0 {
+		return ErrorNotATable
+	}
+
+	var tableState *DeltaTableState
+	checkpoint := CheckPoint{}
+	for i := len(checkpointVersions) - 1; i >= 0 && tableState == nil; i-- {
+		version := checkpointVersions[i]
+		if version > latestVersion {
+			continue
+		}
+		paths, err := table.checkpointPartsFrom(version, checkpoints)
+		if err == nil {
+			var actions []Action
+			actions, checkpoint, err = table.readCheckpointFiles(version, paths)
+			if err == nil {
+				tableState = NewDeltaTableState(version)
+				err = tableState.applyActions(actions)
+			}
+		}
+		if err != nil {
+			log.Debugf("delta-go: unable to load checkpoint version %d, falling back to an older version: %v", version, err)
+			tableState = nil
+			checkpoint = CheckPoint{}
+		}
+	}
+	startVersion := checkpoint.Version.Next()
+	if tableState == nil {
+		tableState = NewDeltaTableState(-1)
+		startVersion = 0
+	}
+
+	for v := startVersion; v <= latestVersion; v++ {
+		if !commits[v] {
+			return errors.Join(ErrorInvalidVersion, fmt.Errorf("version %d is missing", v))
+		}
+	}
+	err := table.replayLog(tableState, startVersion, latestVersion, compactions)
+	if err != nil {
+		return err
+	}
+	table.State = *tableState
+	table.LastCheckPoint = checkpoint
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	"github.com/rivian/delta-go/storage/filestore"
+)
+
+func TestLogFiles(t *testing.T) {
+	table, _, _ := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	for _, path := range []string{
+		"_delta_log/00000000000000000001.json",
+		"_delta_log/00000000000000000001.checkpoint.parquet",
+		"_delta_log/00000000000000000001.crc",
+		"_delta_log/00000000000000000000.00000000000000000001.compacted.json",
+		"_delta_log/_sidecars/3a0d65cd-4056-49b8-937b-95f9e3ee90e5.parquet",
+		"_delta_log/_last_checkpoint",
+		"_delta_log/_commit_3a0d65cd.json.tmp",
+	} {
+		if err := table.Store.Put(storage.NewPath(path), []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logFiles, err := table.LogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []LogFileMeta{
+		{Version: -1, Kind: LogFileSidecar, Meta: storage.ObjectMeta{Location: storage.Path{Raw: "_delta_log/_sidecars/3a0d65cd-4056-49b8-937b-95f9e3ee90e5.parquet"}}},
+		{Version: 0, Kind: LogFileCommit, Meta: storage.ObjectMeta{Location: storage.Path{Raw: "_delta_log/00000000000000000000.json"}}},
+		{Version: 1, Kind: LogFileCompaction, Meta: storage.ObjectMeta{Location: storage.Path{Raw: "_delta_log/00000000000000000000.00000000000000000001.compacted.json"}}},
+		{Version: 1, Kind: LogFileCheckpoint, Meta: storage.ObjectMeta{Location: storage.Path{Raw: "_delta_log/00000000000000000001.checkpoint.parquet"}}},
+		{Version: 1, Kind: LogFileChecksum, Meta: storage.ObjectMeta{Location: storage.Path{Raw: "_delta_log/00000000000000000001.crc"}}},
+		{Version: 1, Kind: LogFileCommit, Meta: storage.ObjectMeta{Location: storage.Path{Raw: "_delta_log/00000000000000000001.json"}}},
+	}
+	if len(logFiles) != len(expected) {
+		t.Fatalf("want %d log files, has %v", len(expected), logFiles)
+	}
+	for i, logFile := range logFiles {
+		if logFile.Version != expected[i].Version || logFile.Kind != expected[i].Kind || logFile.Meta.Location.Raw != expected[i].Meta.Location.Raw {
+			t.Errorf("want %v, has %v", expected[i], logFile)
+		}
+		if logFile.Meta.Size <= 0 || logFile.Meta.LastModified.IsZero() {
+			t.Errorf("%s should have a size and modification time", logFile.Meta.Location.Raw)
+		}
+	}
+}
+
+func TestClassifyLogFile(t *testing.T) {
+	tests := []struct {
+		location string
+		kind     LogFileKind
+		version  state.DeltaDataTypeVersion
+	}{
+		{"_delta_log/00000000000000000005.json", LogFileCommit, 5},
+		{"_delta_log/00000000000000000005.crc", LogFileChecksum, 5},
+		{"_delta_log/00000000000000000005.checkpoint.parquet", LogFileCheckpoint, 5},
+		{"_delta_log/00000000000000000005.checkpoint.0000000001.0000000002.parquet", LogFileCheckpoint, 5},
+		{"_delta_log/00000000000000000003.00000000000000000005.compacted.json", LogFileCompaction, 5},
+		// Sidecars are classified by their directory, whatever their name
+		{"_delta_log/_sidecars/00000000000000000005.checkpoint.parquet", LogFileSidecar, -1},
+		{"_delta_log/_sidecars/00000000000000000005.json", "", -1},
+		{"_delta_log/_staged/00000000000000000005.json", "", -1},
+		{"_delta_log/00000000000000000005.json.tmp", "", -1},
+		{"_delta_log/00000000000000000005.crc.json", "", -1},
+		{"_delta_log/x00000000000000000005.json", "", -1},
+		{"_delta_log/_last_checkpoint", "", -1},
+	}
+	for _, test := range tests {
+		kind, version, err := classifyLogFile(test.location)
+		if err != nil || kind != test.kind || version != test.version {
+			t.Errorf("%s: want %q %d, has %q %d %v", test.location, test.kind, test.version, kind, version, err)
+		}
+	}
+}
+
+func TestLogFilesOfSameVersion(t *testing.T) {
+	table, _, _ := setupTest(t)
+	if err := table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{}); err != nil {
+		t.Fatal(err)
+	}
+	// The checksum, checkpoint and sidecar of version 1 are not commits, the table stays at version 0
+	for _, path := range []string{
+		"_delta_log/00000000000000000001.crc",
+		"_delta_log/00000000000000000001.checkpoint.parquet",
+		"_delta_log/_sidecars/00000000000000000001.checkpoint.parquet",
+		"_delta_log/_sidecars/00000000000000000001.json",
+	} {
+		if err := table.Store.Put(storage.NewPath(path), []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := table.IsValidCommitUri(storage.NewPath(path)); ok || err != nil {
+			t.Errorf("%s should not be a commit, has %t %v", path, ok, err)
+		}
+	}
+	if ok, err := table.IsValidCommitUri(table.CommitUriFromVersion(1)); !ok || err != nil {
+		t.Errorf("want a commit, has %t %v", ok, err)
+	}
+
+	logFiles, err := table.LogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commits []state.DeltaDataTypeVersion
+	for _, logFile := range logFiles {
+		if logFile.Kind == LogFileCommit {
+			commits = append(commits, logFile.Version)
+		}
+	}
+	if len(commits) != 1 || commits[0] != 0 {
+		t.Errorf("want the commit of version 0 only, has %v", commits)
+	}
+	if versions, err := table.ListVersions(); err != nil || len(versions) != 1 || versions[0].Version != 0 {
+		t.Errorf("want version 0 only, has %v %v", versions, err)
+	}
+	if err := table.Load(); err != nil || table.State.Version != 0 {
+		t.Errorf("want version 0 loaded, has %d %v", table.State.Version, err)
+	}
+}
+
+// noListStore is an ObjectStore that fails the listings
+type noListStore struct {
+	storage.ObjectStore
+}
+
+func (s noListStore) List(prefix *storage.Path) ([]storage.ObjectMeta, error) {
+	return nil, errors.New("unexpected listing")
+}
+
+func (s noListStore) ListModifiedAfter(prefix *storage.Path, since time.Time) ([]storage.ObjectMeta, error) {
+	return nil, errors.New("unexpected listing")
+}
+
+func TestOpenTableFromLogFiles(t *testing.T) {
+	table, rows := setupCheckpointTable(t)
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(1), rows)
+	// Commit 0 is not needed with the checkpoint
+	if err := table.Store.Delete(table.CommitUriFromVersion(0)); err != nil {
+		t.Fatal(err)
+	}
+	logFiles, err := table.LogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logTable, err := OpenTableFromLogFiles(noListStore{table.Store}, logFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logTable.State.Version != 2 || logTable.LastCheckPoint.Version != 1 {
+		t.Errorf("want version 2 from checkpoint 1, has %d from %+v", logTable.State.Version, logTable.LastCheckPoint)
+	}
+	if logTable.State.AppTransactionVersion["stream"] != 4 {
+		t.Error("the state should be loaded from the checkpoint")
+	}
+	assertActiveFiles(t, logTable, []string{"date=2023-01-01/part-0.snappy.parquet", "date=2023-01-02/part-1.snappy.parquet", "date=2023-01-03/part-2.snappy.parquet"})
+
+	// Without the checkpoint, the log starts with a gap
+	var commits []LogFileMeta
+	for _, logFile := range logFiles {
+		if logFile.Kind == LogFileCommit {
+			commits = append(commits, logFile)
+		}
+	}
+	if _, err := OpenTableFromLogFiles(noListStore{table.Store}, commits); !errors.Is(err, ErrorInvalidVersion) {
+		t.Errorf("want ErrorInvalidVersion, has %v", err)
+	}
+	if _, err := OpenTableFromLogFiles(noListStore{table.Store}, nil); !errors.Is(err, ErrorNotATable) {
+		t.Errorf("want ErrorNotATable, has %v", err)
+	}
+}
+
+func TestOpenTableFromLogFilesWithGap(t *testing.T) {
+	store := filestore.New(storage.NewPath("testdata/compacted_log"))
+	logFiles, err := NewDeltaTable(store, nil, nil).LogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := OpenTable(store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := OpenTableFromLogFiles(noListStore{store}, logFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != expected.State.Version || len(table.State.Files) != len(expected.State.Files) {
+		t.Errorf("want version %d with %d files, has version %d with %d files", expected.State.Version, len(expected.State.Files), table.State.Version, len(table.State.Files))
+	}
+
+	// The compaction file does not make up for a missing commit
+	var withGap []LogFileMeta
+	for _, logFile := range logFiles {
+		if logFile.Kind != LogFileCommit || logFile.Version != 2 {
+			withGap = append(withGap, logFile)
+		}
+	}
+	_, err = OpenTableFromLogFiles(noListStore{store}, withGap)
+	if !errors.Is(err, ErrorInvalidVersion) || err.Error() != "invalid version\nversion 2 is missing" {
+		t.Errorf("want version 2 to be missing, has %v", err)
+	}
+}
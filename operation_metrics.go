@@ -0,0 +1,263 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileSizeMetrics summarizes the sizes of the files added or removed by an operation, as reported in the
+// operationMetrics of Delta's OPTIMIZE
+type FileSizeMetrics struct {
+	Avg        float64 `json:"avg"`
+	Min        int64   `json:"min"`
+	Max        int64   `json:"max"`
+	TotalFiles int64   `json:"totalFiles"`
+	TotalSize  int64   `json:"totalSize"`
+}
+
+// add includes a file of the given size in the metrics
+func (m *FileSizeMetrics) add(size int64) {
+	if m.TotalFiles == 0 || size < m.Min {
+		m.Min = size
+	}
+	if size > m.Max {
+		m.Max = size
+	}
+	m.TotalFiles++
+	m.TotalSize += size
+	m.Avg = float64(m.TotalSize) / float64(m.TotalFiles)
+}
+
+// OptimizeMetrics are the operationMetrics of an Optimize commit
+type OptimizeMetrics struct {
+	NumFilesAdded        int64
+	NumFilesRemoved      int64
+	FilesAdded           FileSizeMetrics
+	FilesRemoved         FileSizeMetrics
+	PartitionsOptimized  int64
+	NumBatches           int64
+	TotalConsideredFiles int64
+	TotalFilesSkipped    int64
+}
+
+// NewOptimizeMetrics computes the metrics of an Optimize commit from its actions.
+// The files that were considered but not compacted are counted as skipped.
+func NewOptimizeMetrics(actions []Action, totalConsideredFiles int64) OptimizeMetrics {
+	metrics := OptimizeMetrics{NumBatches: 1, TotalConsideredFiles: totalConsideredFiles}
+	partitions := make(map[string]bool)
+	for _, action := range actions {
+		switch action := action.(type) {
+		case Add:
+			metrics.NumFilesAdded++
+			metrics.FilesAdded.add(int64(action.Size))
+		case Remove:
+			metrics.NumFilesRemoved++
+			metrics.FilesRemoved.add(int64(action.Size))
+			partitions[partitionKey(action.PartitionValues)] = true
+		}
+	}
+	metrics.PartitionsOptimized = int64(len(partitions))
+	if metrics.TotalConsideredFiles < metrics.NumFilesRemoved {
+		metrics.TotalConsideredFiles = metrics.NumFilesRemoved
+	}
+	metrics.TotalFilesSkipped = metrics.TotalConsideredFiles - metrics.NumFilesRemoved
+	return metrics
+}
+
+// operationMetrics returns the metrics in the format of the commitInfo, where every value is a string
+func (m OptimizeMetrics) operationMetrics() map[string]string {
+	filesAdded, _ := json.Marshal(m.FilesAdded)
+	filesRemoved, _ := json.Marshal(m.FilesRemoved)
+	return map[string]string{
+		"numFilesAdded":        strconv.FormatInt(m.NumFilesAdded, 10),
+		"numFilesRemoved":      strconv.FormatInt(m.NumFilesRemoved, 10),
+		"filesAdded":           string(filesAdded),
+		"filesRemoved":         string(filesRemoved),
+		"partitionsOptimized":  strconv.FormatInt(m.PartitionsOptimized, 10),
+		"numBatches":           strconv.FormatInt(m.NumBatches, 10),
+		"totalConsideredFiles": strconv.FormatInt(m.TotalConsideredFiles, 10),
+		"totalFilesSkipped":    strconv.FormatInt(m.TotalFilesSkipped, 10),
+	}
+}
+
+// DeleteMetrics are the operationMetrics of a Delete commit
+type DeleteMetrics struct {
+	NumRemovedFiles     int64
+	NumAddedFiles       int64
+	NumAddedChangeFiles int64
+	NumRemovedBytes     int64
+	NumAddedBytes       int64
+	// Row counts are only known by the engine that rewrote the files and are left to the caller
+	NumDeletedRows int64
+	NumCopiedRows  int64
+	// Files that got a deletion vector, files whose deletion vector was replaced, and files with a deletion vector
+	// that were removed
+	NumDeletionVectorsAdded   int64
+	NumDeletionVectorsUpdated int64
+	NumDeletionVectorsRemoved int64
+}
+
+// NewDeleteMetrics computes the file metrics of a Delete commit from its actions.
+// A file removed and added back, with a new deletion vector, is neither a removed nor an added file.
+func NewDeleteMetrics(actions []Action) DeleteMetrics {
+	removed := make(map[string]Remove)
+	added := make(map[string]Add)
+	for _, action := range actions {
+		switch action := action.(type) {
+		case Add:
+			added[action.Path] = action
+		case Remove:
+			removed[action.Path] = action
+		}
+	}
+
+	var metrics DeleteMetrics
+	for _, action := range actions {
+		switch action := action.(type) {
+		case Add:
+			if remove, ok := removed[action.Path]; ok {
+				if deletionVector, _ := remove.DeletionVector(); deletionVector != nil {
+					metrics.NumDeletionVectorsUpdated++
+				} else {
+					metrics.NumDeletionVectorsAdded++
+				}
+				continue
+			}
+			metrics.NumAddedFiles++
+			metrics.NumAddedBytes += int64(action.Size)
+		case Remove:
+			if _, ok := added[action.Path]; ok {
+				continue
+			}
+			metrics.NumRemovedFiles++
+			metrics.NumRemovedBytes += int64(action.Size)
+			if deletionVector, _ := action.DeletionVector(); deletionVector != nil {
+				metrics.NumDeletionVectorsRemoved++
+			}
+		case Cdc:
+			metrics.NumAddedChangeFiles++
+		}
+	}
+	return metrics
+}
+
+// operationMetrics returns the metrics in the format of the commitInfo, where every value is a string
+func (m DeleteMetrics) operationMetrics() map[string]string {
+	return map[string]string{
+		"numRemovedFiles":           strconv.FormatInt(m.NumRemovedFiles, 10),
+		"numAddedFiles":             strconv.FormatInt(m.NumAddedFiles, 10),
+		"numAddedChangeFiles":       strconv.FormatInt(m.NumAddedChangeFiles, 10),
+		"numRemovedBytes":           strconv.FormatInt(m.NumRemovedBytes, 10),
+		"numAddedBytes":             strconv.FormatInt(m.NumAddedBytes, 10),
+		"numDeletedRows":            strconv.FormatInt(m.NumDeletedRows, 10),
+		"numCopiedRows":             strconv.FormatInt(m.NumCopiedRows, 10),
+		"numDeletionVectorsAdded":   strconv.FormatInt(m.NumDeletionVectorsAdded, 10),
+		"numDeletionVectorsUpdated": strconv.FormatInt(m.NumDeletionVectorsUpdated, 10),
+		"numDeletionVectorsRemoved": strconv.FormatInt(m.NumDeletionVectorsRemoved, 10),
+	}
+}
+
+// partitionKey returns a string identifying the partition of the partition values
+func partitionKey(partitionValues map[string]string) string {
+	pairs := make([]string, 0, len(partitionValues))
+	for column, value := range partitionValues {
+		pairs = append(pairs, fmt.Sprintf("%q=%q", column, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// metricsOperation is implemented by operations whose commitInfo reports metrics computed from the committed actions
+type metricsOperation interface {
+	DeltaOperation
+	withMetrics(actions []Action) DeltaOperation
+}
+
+// / Represents a Delta `Optimize` operation, compacting small files into larger ones.
+// / Optimize operations remove the compacted files and add the files they were compacted into, without data change.
+type Optimize struct {
+	/// The predicate selecting the partitions that were optimized
+	Predicate []string `json:"predicate"`
+	/// The columns the data was clustered by
+	ZOrderBy []string `json:"zOrderBy"`
+	/// The number of files considered for compaction, including the ones that were skipped
+	TotalConsideredFiles int64 `json:"-"`
+	/// The metrics of the operation. When nil, they are computed with NewOptimizeMetrics from the committed
+	/// actions when the commit is prepared.
+	Metrics *OptimizeMetrics `json:"-"`
+}
+
+func (op Optimize) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "OPTIMIZE"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = op
+	if op.Metrics != nil {
+		commitInfo["operationMetrics"] = op.Metrics.operationMetrics()
+	}
+
+	return commitInfo
+}
+
+func (op Optimize) withMetrics(actions []Action) DeltaOperation {
+	if op.Metrics != nil {
+		return op
+	}
+	metrics := NewOptimizeMetrics(actions, op.TotalConsideredFiles)
+	op.Metrics = &metrics
+	return op
+}
+
+// / Represents a Delta `Delete` operation.
+// / Delete operations remove the files containing deleted rows and add the rewritten files, or add the files back
+// / with deletion vectors marking the deleted rows.
+type Delete struct {
+	/// The predicate selecting the deleted rows
+	Predicate []string `json:"predicate"`
+	/// The number of deleted and copied rows, provided by the engine that rewrote the files
+	NumDeletedRows int64 `json:"-"`
+	NumCopiedRows  int64 `json:"-"`
+	/// The metrics of the operation. When nil, they are computed with NewDeleteMetrics from the committed
+	/// actions when the commit is prepared.
+	Metrics *DeleteMetrics `json:"-"`
+}
+
+func (op Delete) GetCommitInfo() CommitInfo {
+	commitInfo := make(CommitInfo)
+
+	operation := "DELETE"
+	commitInfo["operation"] = operation
+	commitInfo["operationParameters"] = op
+	if op.Metrics != nil {
+		commitInfo["operationMetrics"] = op.Metrics.operationMetrics()
+	}
+
+	return commitInfo
+}
+
+func (op Delete) withMetrics(actions []Action) DeltaOperation {
+	if op.Metrics != nil {
+		return op
+	}
+	metrics := NewDeleteMetrics(actions)
+	metrics.NumDeletedRows = op.NumDeletedRows
+	metrics.NumCopiedRows = op.NumCopiedRows
+	op.Metrics = &metrics
+	return op
+}
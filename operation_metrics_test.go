@@ -0,0 +1,105 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOptimizeMetrics(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: String}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{"date"}, map[string]string{})
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	for _, remove := range []Remove{
+		{Path: "date=2023-01-01/part-0.parquet", Size: 10, PartitionValues: map[string]string{"date": "2023-01-01"}},
+		{Path: "date=2023-01-01/part-1.parquet", Size: 30, PartitionValues: map[string]string{"date": "2023-01-01"}},
+		{Path: "date=2023-01-02/part-2.parquet", Size: 20, PartitionValues: map[string]string{"date": "2023-01-02"}},
+	} {
+		transaction.AddAction(remove)
+	}
+	transaction.AddAction(Add{Path: "date=2023-01-01/part-3.parquet", Size: 35, PartitionValues: map[string]string{"date": "2023-01-01"}})
+	transaction.AddAction(Add{Path: "date=2023-01-02/part-4.parquet", Size: 19, PartitionValues: map[string]string{"date": "2023-01-02"}})
+	_, err = transaction.Commit(Optimize{TotalConsideredFiles: 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := NewOptimizeMetrics(transaction.Actions, 5)
+	expected := OptimizeMetrics{
+		NumFilesAdded: 2, NumFilesRemoved: 3,
+		FilesAdded:          FileSizeMetrics{Avg: 27, Min: 19, Max: 35, TotalFiles: 2, TotalSize: 54},
+		FilesRemoved:        FileSizeMetrics{Avg: 20, Min: 10, Max: 30, TotalFiles: 3, TotalSize: 60},
+		PartitionsOptimized: 2, NumBatches: 1, TotalConsideredFiles: 5, TotalFilesSkipped: 2,
+	}
+	if metrics != expected {
+		t.Errorf("want %+v, has %+v", expected, metrics)
+	}
+
+	history, err := table.History(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitInfo := history[0].CommitInfo
+	if commitInfo["operation"] != "OPTIMIZE" {
+		t.Errorf("unexpected operation %v", commitInfo["operation"])
+	}
+	operationMetrics, ok := commitInfo["operationMetrics"].(map[string]any)
+	if !ok {
+		t.Fatalf("unexpected operation metrics %v", commitInfo["operationMetrics"])
+	}
+	if operationMetrics["numFilesAdded"] != "2" || operationMetrics["numFilesRemoved"] != "3" || operationMetrics["totalFilesSkipped"] != "2" {
+		t.Errorf("unexpected operation metrics %v", operationMetrics)
+	}
+	var filesRemoved FileSizeMetrics
+	err = json.Unmarshal([]byte(operationMetrics["filesRemoved"].(string)), &filesRemoved)
+	if err != nil || filesRemoved != expected.FilesRemoved {
+		t.Errorf("unexpected filesRemoved %v", operationMetrics["filesRemoved"])
+	}
+}
+
+func TestDeleteMetrics(t *testing.T) {
+	actions := []Action{
+		Remove{Path: "part-0.parquet", Size: 10},
+		Remove{Path: "part-1.parquet", Size: 30},
+		Add{Path: "part-2.parquet", Size: 25},
+		Cdc{Path: "_change_data/cdc-0.parquet"},
+	}
+	operation := Delete{Predicate: []string{"id > 10"}, NumDeletedRows: 4, NumCopiedRows: 6}.withMetrics(actions)
+	commitInfo := operation.GetCommitInfo()
+	if commitInfo["operation"] != "DELETE" {
+		t.Errorf("unexpected operation %v", commitInfo["operation"])
+	}
+	expected := map[string]string{
+		"numRemovedFiles": "2", "numAddedFiles": "1", "numAddedChangeFiles": "1",
+		"numRemovedBytes": "40", "numAddedBytes": "25", "numDeletedRows": "4", "numCopiedRows": "6",
+	}
+	operationMetrics := commitInfo["operationMetrics"].(map[string]string)
+	for name, value := range expected {
+		if operationMetrics[name] != value {
+			t.Errorf("%s: want %s, has %s", name, value, operationMetrics[name])
+		}
+	}
+
+	// Metrics set by the caller are kept
+	operation = Delete{Metrics: &DeleteMetrics{NumRemovedFiles: 7}}.withMetrics(actions)
+	if operation.(Delete).Metrics.NumRemovedFiles != 7 {
+		t.Error("metrics set by the caller should be kept")
+	}
+}
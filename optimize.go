@@ -0,0 +1,233 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrorOptimize error = errors.New("error optimizing the table")
+)
+
+// The default size of the files written by Optimize, as in Delta's delta.optimize.maxFileSize
+const DEFAULT_OPTIMIZE_TARGET_SIZE = 1024 * 1024 * 1024
+
+// OptimizeOptions configures Optimize
+type OptimizeOptions struct {
+	// Files smaller than the target size are compacted into files of up to about the target size.
+	// Defaults to DEFAULT_OPTIMIZE_TARGET_SIZE when zero.
+	TargetSize int64
+	// The options of the transaction committing the compaction. The compacted files are written under its
+	// DataDirectory.
+	TransactionOptions *DeltaTransactionOptions
+}
+
+// NewOptimizeOptions returns the default optimize options
+func NewOptimizeOptions() *OptimizeOptions {
+	return &OptimizeOptions{TargetSize: DEFAULT_OPTIMIZE_TARGET_SIZE, TransactionOptions: NewDeltaTransactionOptions()}
+}
+
+// Optimize compacts the small data files of each partition into larger files, and commits an OPTIMIZE operation
+// removing the compacted files and adding the files they were compacted into, without data change.
+// Files with deletion vectors are not compacted, nor are files whose schema differs from the other files of
+// their batch. Tables with row tracking are not supported, since the rows of the compacted files would get new ids.
+// The table state must be loaded; it is updated to the latest version first. The commit fails with
+// ErrorConcurrentModification, removing the compacted files, if a concurrent commit removed one of the files it
+// compacts.
+// Returns the metrics of the operation, also recorded in the commitInfo. Nothing is committed if there is nothing
+// to compact.
+func (table *DeltaTable) Optimize(options *OptimizeOptions) (OptimizeMetrics, error) {
+	return table.OptimizeWithContext(context.Background(), options)
+}
+
+// OptimizeWithContext is Optimize stopping promptly when the context is cancelled. No more files are rewritten once
+// the context is done, the files written so far are removed and the error of the context is returned. The compaction
+// is committed atomically, so the table is unchanged unless the commit happened.
+func (table *DeltaTable) OptimizeWithContext(ctx context.Context, options *OptimizeOptions) (OptimizeMetrics, error) {
+	if options == nil {
+		options = NewOptimizeOptions()
+	}
+	targetSize := options.TargetSize
+	if targetSize <= 0 {
+		targetSize = DEFAULT_OPTIMIZE_TARGET_SIZE
+	}
+	if table.State.Version < 0 {
+		return OptimizeMetrics{}, ErrorNotATable
+	}
+	if err := table.Update(); err != nil {
+		return OptimizeMetrics{}, errors.Join(ErrorOptimize, err)
+	}
+	if table.State.RowTrackingEnabled() {
+		return OptimizeMetrics{}, errors.Join(ErrorUnsupportedProtocol, errors.New("optimizing tables with row tracking is not supported"))
+	}
+
+	batches, err := table.optimizeBatches(targetSize)
+	if err != nil {
+		return OptimizeMetrics{}, errors.Join(ErrorOptimize, err)
+	}
+	consideredFiles := int64(len(table.State.Files))
+	if len(batches) == 0 {
+		return NewOptimizeMetrics(nil, consideredFiles), nil
+	}
+
+	transaction := table.createCheckedTransaction(options.TransactionOptions)
+	for _, batch := range batches {
+		if err := ctx.Err(); err != nil {
+			transaction.AbortWrite()
+			return OptimizeMetrics{}, err
+		}
+		err := transaction.compactFiles(batch)
+		if errors.Is(err, parquet.ErrRowGroupSchemaMismatch) {
+			log.Debugf("delta-go: not compacting files with different schemas: %v", err)
+			continue
+		}
+		if err != nil {
+			transaction.AbortWrite()
+			return OptimizeMetrics{}, errors.Join(ErrorOptimize, err)
+		}
+	}
+	if len(transaction.Actions) == 0 {
+		return NewOptimizeMetrics(nil, consideredFiles), nil
+	}
+
+	metrics := NewOptimizeMetrics(transaction.Actions, consideredFiles)
+	_, err = transaction.CommitWithContext(ctx, Optimize{TotalConsideredFiles: consideredFiles, Metrics: &metrics}, nil)
+	if err != nil {
+		return OptimizeMetrics{}, err
+	}
+	return metrics, nil
+}
+
+// optimizeBatches groups the files smaller than the target size by partition, in batches of up to about the target
+// size. Batches of a single file are left out since there is nothing to compact.
+func (table *DeltaTable) optimizeBatches(targetSize int64) ([][]Add, error) {
+	partitions := make(map[string][]Add)
+	for _, add := range table.State.Files {
+		if int64(add.Size) >= targetSize {
+			continue
+		}
+		if deletionVector, err := add.DeletionVector(); err != nil {
+			return nil, err
+		} else if deletionVector != nil {
+			continue
+		}
+		key := partitionKey(add.PartitionValues)
+		partitions[key] = append(partitions[key], add)
+	}
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var batches [][]Add
+	for _, key := range keys {
+		files := partitions[key]
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		var batch []Add
+		var batchSize int64
+		for _, add := range files {
+			if len(batch) > 0 && batchSize+int64(add.Size) > targetSize {
+				if len(batch) > 1 {
+					batches = append(batches, batch)
+				}
+				batch, batchSize = nil, 0
+			}
+			batch = append(batch, add)
+			batchSize += int64(add.Size)
+		}
+		if len(batch) > 1 {
+			batches = append(batches, batch)
+		}
+	}
+	return batches, nil
+}
+
+// compactFiles writes the rows of the files, which must be of the same partition, into a single data file, and adds
+// the actions removing the files and adding the compacted file to the transaction.
+// Returns parquet.ErrRowGroupSchemaMismatch without changing the transaction if the schemas of the files differ.
+func (transaction *DeltaTransaction) compactFiles(files []Add) error {
+	var rowGroups []parquet.RowGroup
+	for _, add := range files {
+		data, err := transaction.DeltaTable.dataStore().Get(storage.NewPath(unescapedDataPath(add.Path)))
+		if err != nil {
+			return err
+		}
+		file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("%s: %w", add.Path, err)
+		}
+		rowGroups = append(rowGroups, file.RowGroups()...)
+	}
+	merged, err := parquet.MergeRowGroups(rowGroups)
+	if err != nil {
+		return fmt.Errorf("%s: %w", files[0].Path, err)
+	}
+
+	var buf bytes.Buffer
+	writer := parquet.NewWriter(&buf, merged.Schema(), parquet.Compression(&parquet.Snappy))
+	numRecords, err := writer.WriteRowGroup(merged)
+	if err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	directory := ""
+	if transaction.Options != nil {
+		directory = transaction.Options.DataDirectory
+	}
+	partitionValues := files[0].PartitionValues
+	fileName := fmt.Sprintf("part-00000-%s-c000.snappy.parquet", uuid.New())
+	dataPath, err := DataFilePath(directory, transaction.DeltaTable.State.CurrentMetadata.PartitionColumns, partitionValues, fileName)
+	if err != nil {
+		return err
+	}
+	if err := transaction.PutDataFile(storage.NewPath(dataPath), buf.Bytes()); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	for _, add := range files {
+		transaction.AddAction(Remove{
+			Path:                 add.Path,
+			DeletionTimestamp:    DeltaDataTypeTimestamp(now),
+			DataChange:           false,
+			ExtendedFileMetadata: true,
+			PartitionValues:      add.PartitionValues,
+			Size:                 add.Size,
+			Tags:                 add.Tags,
+		})
+	}
+	transaction.AddAction(Add{
+		Path:             DataFileUri(dataPath),
+		Size:             DeltaDataTypeLong(buf.Len()),
+		PartitionValues:  partitionValues,
+		ModificationTime: DeltaDataTypeTimestamp(now),
+		DataChange:       false,
+		Stats:            fmt.Sprintf(`{"numRecords":%d}`, numRecords),
+	})
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+)
+
+type optimizeRow struct {
+	Id   int64  `parquet:"id"`
+	Date string `parquet:"date"`
+}
+
+type optimizeOtherRow struct {
+	Label string `parquet:"label"`
+}
+
+// Helper function to write a parquet data file with the given rows and add it to the transaction
+func addOptimizeFile[T any](t *testing.T, transaction *DeltaTransaction, path string, date string, rows []T, extras map[string]json.RawMessage) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := transaction.PutDataFile(storage.NewPath(path), buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	transaction.AddAction(Add{Path: path, Size: DeltaDataTypeLong(buf.Len()), PartitionValues: map[string]string{"date": date}, DataChange: true, Extras: extras})
+}
+
+// Helper function to set up a partitioned table with three small files in 2023-01-01, one of them with a deletion
+// vector, one file in 2023-01-02, and two files with different schemas in 2023-01-03
+func setupOptimizeTable(t *testing.T) (*DeltaTable, string) {
+	t.Helper()
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: String}}}
+	deletionVector := map[string]json.RawMessage{
+		"deletionVector": json.RawMessage(`{"storageType":"u","pathOrInlineDv":"ab^-aqEH.-t@S}K{vb[*k^","offset":1,"sizeInBytes":36,"cardinality":1}`),
+	}
+	return setupTestTable(t, schema, []string{"date"}, nil, nil, func(transaction *DeltaTransaction) {
+		addOptimizeFile(t, transaction, "date=2023-01-01/part-1.parquet", "2023-01-01", []optimizeRow{{1, "2023-01-01"}, {2, "2023-01-01"}}, nil)
+		addOptimizeFile(t, transaction, "date=2023-01-01/part-2.parquet", "2023-01-01", []optimizeRow{{3, "2023-01-01"}}, nil)
+		addOptimizeFile(t, transaction, "date=2023-01-01/part-3.parquet", "2023-01-01", []optimizeRow{{4, "2023-01-01"}}, deletionVector)
+		addOptimizeFile(t, transaction, "date=2023-01-02/part-4.parquet", "2023-01-02", []optimizeRow{{5, "2023-01-02"}}, nil)
+		addOptimizeFile(t, transaction, "date=2023-01-03/part-5.parquet", "2023-01-03", []optimizeRow{{6, "2023-01-03"}}, nil)
+		addOptimizeFile(t, transaction, "date=2023-01-03/part-6.parquet", "2023-01-03", []optimizeOtherRow{{"other"}}, nil)
+	})
+}
+
+func TestOptimize(t *testing.T) {
+	table, tmpDir := setupOptimizeTable(t)
+
+	metrics, err := table.Optimize(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.NumFilesRemoved != 2 || metrics.NumFilesAdded != 1 || metrics.TotalConsideredFiles != 6 || metrics.TotalFilesSkipped != 4 || metrics.PartitionsOptimized != 1 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+	if table.State.Version != 2 {
+		t.Errorf("want version 2, has %d", table.State.Version)
+	}
+
+	loaded, err := OpenTable(table.Store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compacted Add
+	var paths []string
+	for path, add := range loaded.State.Files {
+		paths = append(paths, path)
+		if deletionVector, _ := add.DeletionVector(); add.PartitionValues["date"] == "2023-01-01" && deletionVector == nil {
+			compacted = add
+		}
+	}
+	sort.Strings(paths)
+	if len(paths) != 5 || compacted.Path == "" || compacted.DataChange {
+		t.Fatalf("unexpected files %v, compacted %+v", paths, compacted)
+	}
+	if compacted.Stats != `{"numRecords":3}` {
+		t.Errorf("unexpected stats %s", compacted.Stats)
+	}
+	rows, err := parquet.ReadFile[optimizeRow](filepath.Join(tmpDir, unescapedDataPath(compacted.Path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0].Id != 1 || rows[2].Id != 3 {
+		t.Errorf("unexpected compacted rows %v", rows)
+	}
+	for _, path := range []string{"date=2023-01-01/part-1.parquet", "date=2023-01-01/part-2.parquet"} {
+		remove, ok := loaded.State.Tombstones[path]
+		if !ok || remove.DataChange {
+			t.Errorf("%s should be removed without data change, has %+v", path, remove)
+		}
+	}
+	operationMetrics := loaded.State.CommitInfos[len(loaded.State.CommitInfos)-1]["operationMetrics"].(map[string]any)
+	if operationMetrics["numFilesRemoved"] != "2" || operationMetrics["numFilesAdded"] != "1" {
+		t.Errorf("unexpected operation metrics %v", operationMetrics)
+	}
+
+	// Nothing is left to compact
+	metrics, err = table.Optimize(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.NumFilesRemoved != 0 || table.State.Version != 2 {
+		t.Errorf("nothing should be committed, has %+v at version %d", metrics, table.State.Version)
+	}
+
+	_, err = NewDeltaTable(table.Store, nil, nil).Optimize(nil)
+	if !errors.Is(err, ErrorNotATable) {
+		t.Errorf("want ErrorNotATable, has %v", err)
+	}
+}
+
+func TestOptimizeWithContextCancelled(t *testing.T) {
+	table, tmpDir := setupOptimizeTable(t)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := table.OptimizeWithContext(cancelled, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, has %v", err)
+	}
+	if fileExists(filepath.Join(tmpDir, "_delta_log", "00000000000000000002.json")) {
+		t.Error("version 2 should not be committed")
+	}
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "date=2023-01-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("no compacted file should be left, has %d files", len(entries))
+	}
+}
+
+func TestOptimizeConcurrentRemove(t *testing.T) {
+	table, tmpDir := setupOptimizeTable(t)
+	other, err := OpenTable(table.Store, table.LockClient, table.StateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Store = &concurrentCommitStore{ObjectStore: table.Store, prefix: "date=2023-01-01/part-00000-", commit: func() error {
+		transaction := other.CreateTransaction(NewDeltaTransactionOptions())
+		transaction.AddAction(Remove{Path: "date=2023-01-01/part-2.parquet", DataChange: true})
+		_, err := transaction.Commit(Delete{}, nil)
+		return err
+	}}
+
+	// Adding the rows of the removed file to the compacted file would restore them
+	if _, err := table.Optimize(nil); !errors.Is(err, ErrorConcurrentModification) {
+		t.Fatalf("want ErrorConcurrentModification, has %v", err)
+	}
+	if fileExists(filepath.Join(tmpDir, "_delta_log", "00000000000000000003.json")) {
+		t.Error("version 3 should not be committed")
+	}
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "date=2023-01-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("the compacted file should be removed, has %d files", len(entries))
+	}
+}
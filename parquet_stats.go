@@ -0,0 +1,234 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+	"github.com/segmentio/parquet-go/format"
+)
+
+// ParquetStats returns the statistics of a Parquet data file: its number of records, and the min and max values
+// and null counts of its top-level columns, with the values converted to the types they have in parsed JSON stats.
+// The statistics of a column chunk are read from the footer, or computed from the pages of the chunk if the footer has
+// none, decompressing them with any codec of the Parquet format, e.g. snappy, gzip or zstd.
+// Min and max values are left out for the columns that Delta does not collect them for, such as booleans, binary
+// and decimal columns.
+func ParquetStats(data []byte) (*Stats, error) {
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return parquetFileStats(file)
+}
+
+// DataFileStats returns the statistics of the data file of the add action, for files whose add action has none,
+// limited to the columns indexed by the table. When the data store is a storage.RangeGetter, only the footer is read
+// from files with statistics in their footer, and the pages of the columns without.
+func (table *DeltaTable) DataFileStats(add *Add) (*Stats, error) {
+	location := storage.NewPath(unescapedDataPath(add.Path))
+	var reader io.ReaderAt
+	var size int64
+	if rangeGetter, ok := table.dataStore().(storage.RangeGetter); ok {
+		meta, err := table.dataStore().Head(location)
+		if err != nil {
+			return nil, err
+		}
+		reader, size = &objectReaderAt{store: rangeGetter, location: location}, meta.Size
+	} else {
+		data, err := table.dataStore().Get(location)
+		if err != nil {
+			return nil, err
+		}
+		reader, size = bytes.NewReader(data), int64(len(data))
+	}
+	file, err := parquet.OpenFile(reader, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", add.Path, err)
+	}
+	stats, err := parquetFileStats(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", add.Path, err)
+	}
+
+	if len(table.State.CurrentMetadata.Schema.Fields) > 0 {
+		columns, err := table.State.CurrentMetadata.IndexedColumns()
+		if err != nil {
+			return nil, err
+		}
+		stats.LimitToColumns(columns)
+	}
+	return stats, nil
+}
+
+// objectReaderAt reads an object by ranges
+type objectReaderAt struct {
+	store    storage.RangeGetter
+	location *storage.Path
+}
+
+func (r *objectReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	data, err := r.store.GetRange(r.location, storage.Range{Start: offset, End: offset + int64(len(p))})
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// parquetFileStats returns the statistics of an opened Parquet file, see ParquetStats
+func parquetFileStats(file *parquet.File) (*Stats, error) {
+	stats := &Stats{
+		NumRecords:  file.NumRows(),
+		TightBounds: true,
+		MinValues:   make(map[string]any),
+		MaxValues:   make(map[string]any),
+		NullCount:   make(map[string]int64),
+	}
+	for _, field := range file.Schema().Fields() {
+		if !field.Leaf() {
+			continue
+		}
+		leaf, ok := file.Schema().Lookup(field.Name())
+		if !ok {
+			continue
+		}
+		bounds := columnBounds{columnType: field.Type()}
+		for i, rowGroup := range file.RowGroups() {
+			metadata := &file.Metadata().RowGroups[i].Columns[leaf.ColumnIndex]
+			if err := bounds.addChunk(metadata, rowGroup.ColumnChunks()[leaf.ColumnIndex]); err != nil {
+				return nil, fmt.Errorf("column %s: %w", field.Name(), err)
+			}
+		}
+		stats.NullCount[field.Name()] = bounds.nullCount
+		if bounds.hasValues && hasMinMaxStats(field) {
+			stats.MinValues[field.Name()] = parquetStatsValue(field, bounds.min, false)
+			stats.MaxValues[field.Name()] = parquetStatsValue(field, bounds.max, true)
+		}
+	}
+	return stats, nil
+}
+
+// columnBounds accumulates the min and max values and the null count of the chunks of a column
+type columnBounds struct {
+	columnType parquet.Type
+	min        parquet.Value
+	max        parquet.Value
+	hasValues  bool
+	nullCount  int64
+}
+
+func (bounds *columnBounds) add(min parquet.Value, max parquet.Value) {
+	if !bounds.hasValues || bounds.columnType.Compare(min, bounds.min) < 0 {
+		bounds.min = min.Clone()
+	}
+	if !bounds.hasValues || bounds.columnType.Compare(max, bounds.max) > 0 {
+		bounds.max = max.Clone()
+	}
+	bounds.hasValues = true
+}
+
+// addChunk adds the statistics of a column chunk from the footer, or else from the pages of the chunk
+func (bounds *columnBounds) addChunk(metadata *format.ColumnChunk, chunk parquet.ColumnChunk) error {
+	statistics := metadata.MetaData.Statistics
+	minValue, maxValue := statistics.MinValue, statistics.MaxValue
+	// The deprecated min and max are sorted as signed values, which differs for byte arrays
+	if minValue == nil && maxValue == nil && bounds.columnType.Kind() != parquet.ByteArray {
+		minValue, maxValue = statistics.Min, statistics.Max
+	}
+	switch {
+	case minValue != nil && maxValue != nil:
+		bounds.add(bounds.columnType.Kind().Value(minValue), bounds.columnType.Kind().Value(maxValue))
+		bounds.nullCount += statistics.NullCount
+		return nil
+	case statistics.NullCount > 0 && statistics.NullCount == metadata.MetaData.NumValues:
+		// The chunk only has nulls
+		bounds.nullCount += statistics.NullCount
+		return nil
+	}
+
+	// The null counts of the page index cannot be relied on, as some writers get them wrong
+	pages := chunk.Pages()
+	defer pages.Close()
+	for {
+		page, err := pages.ReadPage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		bounds.nullCount += page.NumNulls()
+		if min, max, ok := page.Bounds(); ok {
+			bounds.add(min, max)
+		}
+	}
+}
+
+// hasMinMaxStats returns whether Delta collects the min and max values of the column: numbers, strings, dates
+// and timestamps
+func hasMinMaxStats(node parquet.Node) bool {
+	logicalType := node.Type().LogicalType()
+	if logicalType != nil && logicalType.Decimal != nil {
+		return false
+	}
+	switch node.Type().Kind() {
+	case parquet.Int32, parquet.Int64, parquet.Float, parquet.Double:
+		return true
+	case parquet.ByteArray:
+		return logicalType != nil && logicalType.UTF8 != nil
+	}
+	return false
+}
+
+// parquetStatsValue converts a min or max value of a column to the type it has in parsed JSON stats.
+// Timestamps have a millisecond precision in the statistics, so max values are rounded up as by the writer.
+func parquetStatsValue(node parquet.Node, value parquet.Value, isMax bool) any {
+	var v any
+	switch value.Kind() {
+	case parquet.Int32:
+		v = value.Int32()
+	case parquet.Int64:
+		v = value.Int64()
+	case parquet.Float:
+		v = value.Float()
+	case parquet.Double:
+		v = value.Double()
+	case parquet.ByteArray:
+		v = value.ByteArray()
+	}
+	if logicalType := node.Type().LogicalType(); logicalType != nil && logicalType.Timestamp != nil {
+		if timestamp, ok := v.(int64); ok {
+			var t time.Time
+			switch {
+			case logicalType.Timestamp.Unit.Micros != nil:
+				t = time.UnixMicro(timestamp)
+			case logicalType.Timestamp.Unit.Nanos != nil:
+				t = time.Unix(0, timestamp)
+			default:
+				t = time.UnixMilli(timestamp)
+			}
+			return statsValue(SchemaField{Type: Timestamp}, t, isMax)
+		}
+	}
+	return parsedStatsValue(node, v)
+}
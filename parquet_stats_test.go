@@ -0,0 +1,131 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+	"github.com/segmentio/parquet-go/format"
+)
+
+// The statistics of the files of testdata/parquet_stats, which have 3 row groups of several pages each.
+// The footer_ files have statistics in their footer; the pages_ files have neither statistics nor a page index.
+const parquetStatsFixture = `{"numRecords":120,"tightBounds":true,` +
+	`"minValues":{"day":"2022-01-08","id":1,"name":"name-002","score":0.5,"ts":"2023-01-01T00:00:00.001Z"},` +
+	`"maxValues":{"day":"2022-02-06","id":120,"name":"name-120","score":60,"ts":"2023-01-01T00:00:00.121Z"},` +
+	`"nullCount":{"day":0,"flag":0,"id":0,"name":12,"score":0,"ts":0}}`
+
+func TestParquetStats(t *testing.T) {
+	codecs := map[string]format.CompressionCodec{
+		"uncompressed": format.Uncompressed,
+		"snappy":       format.Snappy,
+		"gzip":         format.Gzip,
+		"zstd":         format.Zstd,
+	}
+	for name, codec := range codecs {
+		for _, source := range []string{"footer", "pages"} {
+			path := fmt.Sprintf("testdata/parquet_stats/%s_%s.parquet", source, name)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Metadata().RowGroups[0].Columns[0].MetaData.Codec != codec {
+				t.Fatalf("%s: want codec %s, has %s", path, codec, file.Metadata().RowGroups[0].Columns[0].MetaData.Codec)
+			}
+
+			stats, err := ParquetStats(data)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			if string(stats.Json()) != parquetStatsFixture {
+				t.Errorf("%s: want %s, has %s", path, parquetStatsFixture, stats.Json())
+			}
+		}
+	}
+
+	// The pages are not read when the footer has statistics
+	data, err := os.ReadFile("testdata/parquet_stats/footer_zstd.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	footerStart := len(data) - 8 - int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	for i := 4; i < footerStart; i++ {
+		data[i] = 0
+	}
+	stats, err := ParquetStats(data)
+	if err != nil || string(stats.Json()) != parquetStatsFixture {
+		t.Errorf("want the statistics of the footer, has %s %v", stats.Json(), err)
+	}
+}
+
+// rangeStore is an ObjectStore reading byte ranges of its objects, counting the bytes read
+type rangeStore struct {
+	storage.ObjectStore
+	bytesRead int
+}
+
+func (s *rangeStore) GetRange(location *storage.Path, r storage.Range) ([]byte, error) {
+	data, err := s.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if r.End > int64(len(data)) {
+		r.End = int64(len(data))
+	}
+	s.bytesRead += int(r.End - r.Start)
+	return data[r.Start:r.End], nil
+}
+
+func TestDataFileStats(t *testing.T) {
+	table := setupWriteBatchTable(t)
+	ts := time.Date(2023, 1, 1, 12, 0, 0, 1000, time.UTC)
+	day := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []Row{{"id": int64(3), "name": "c", "ts": ts, "date": day}, {"id": int64(1), "ts": ts.Add(time.Hour), "date": day}}
+	if _, err := table.WriteBatch(sendRows(rows), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	store := &rangeStore{ObjectStore: table.Store}
+	table.DataStore = store
+
+	for _, add := range table.State.Files {
+		var expected Stats
+		if err := json.Unmarshal([]byte(add.Stats), &expected); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := table.DataFileStats(&add)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stats.Json()) != string(expected.Json()) {
+			t.Errorf("want the statistics of the writer %s, has %s", expected.Json(), stats.Json())
+		}
+		if store.bytesRead == 0 || store.bytesRead > int(add.Size) {
+			t.Errorf("want the file to be read by ranges, has %d bytes read of %d", store.bytesRead, add.Size)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/constraints"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrorInvalidPartitionValue   error = errors.New("invalid partition value")
+	ErrorPartitionColumnNotFound error = errors.New("partition column not found in schema")
+	ErrorPartitionPathMismatch   error = errors.New("data file path does not match its partition values")
+	ErrorNotAPartitionColumn     error = errors.New("column is not a partition column")
+)
+
+// HIVE_DEFAULT_PARTITION is written by Hive-style writers in place of a null partition value
+const HIVE_DEFAULT_PARTITION = "__HIVE_DEFAULT_PARTITION__"
+
+// Layouts used to serialize partition values
+// https://github.com/delta-io/delta/blob/master/PROTOCOL.md#partition-value-serialization
+const (
+	partitionDateLayout      = "2006-01-02"
+	partitionTimestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// TypedPartitionValues parses the partition values of the Add action into Go values according to the
+// type of each partition column in the table schema.
+// Null partition values are returned as nil.
+// Dates and timestamps are returned as a time.Time in UTC, decimals as a Decimal with the scale of the column type.
+func (add *Add) TypedPartitionValues(schema Schema) (map[string]any, error) {
+	return typedPartitionValues(schema, add.PartitionValues)
+}
+
+// typedPartitionValues parses the partition values of an action as TypedPartitionValues does
+func typedPartitionValues(schema Schema, partitionValues map[string]string) (map[string]any, error) {
+	typedValues := make(map[string]any, len(partitionValues))
+	for column, value := range partitionValues {
+		field, ok := schema.GetField(column)
+		if !ok {
+			return nil, errors.Join(ErrorPartitionColumnNotFound, fmt.Errorf("column %s", column))
+		}
+		typedValue, err := parsePartitionValue(field.Type, value)
+		if err != nil {
+			return nil, errors.Join(ErrorInvalidPartitionValue, fmt.Errorf("column %s value %q", column, value), err)
+		}
+		typedValues[column] = typedValue
+	}
+	return typedValues, nil
+}
+
+// IsNullPartitionValue reports whether a serialized partition value represents SQL NULL.
+// Writers encode a null partition value either as an empty string (a JSON null also decodes to
+// an empty string) or as the Hive default partition marker.
+func IsNullPartitionValue(value string) bool {
+	return value == "" || value == HIVE_DEFAULT_PARTITION
+}
+
+// partitionValue returns the partition value of a column for the Add action, and false if the value is null.
+// A partition column missing from partitionValues is also null.
+func (add *Add) partitionValue(column string) (string, bool) {
+	value, ok := add.PartitionValues[column]
+	if !ok || IsNullPartitionValue(value) {
+		return "", false
+	}
+	return value, true
+}
+
+// PartitionPath returns the Hive-style directory of the partition values, e.g. "date=2023-01-01/region=us",
+// with the columns in the order of partitionColumns. Null values are written as the Hive default partition.
+func PartitionPath(partitionColumns []string, partitionValues map[string]string) (string, error) {
+	segments := make([]string, 0, len(partitionColumns))
+	for _, column := range partitionColumns {
+		value, ok := partitionValues[column]
+		if !ok {
+			return "", errors.Join(ErrorInvalidPartitionValue, fmt.Errorf("missing value for partition column %s", column))
+		}
+		if IsNullPartitionValue(value) {
+			value = HIVE_DEFAULT_PARTITION
+		} else {
+			value = escapePartitionPathValue(value)
+		}
+		segments = append(segments, escapePartitionPathValue(column)+"="+value)
+	}
+	if len(partitionValues) > len(partitionColumns) {
+		return "", errors.Join(ErrorPartitionColumnNotFound, fmt.Errorf("partition values %v for partition columns %v", partitionValues, partitionColumns))
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// escapePartitionPathValue percent-encodes the characters that Hive escapes in partition directory names
+func escapePartitionPathValue(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte("\"#%'*/:=?\\{[]^", c) >= 0 {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		} else {
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+// unescapePartitionPathValue decodes the percent-encoded characters of a partition directory name,
+// leaving malformed escapes as they are
+func unescapePartitionPathValue(value string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) {
+			if c, err := strconv.ParseUint(value[i+1:i+3], 16, 8); err == nil {
+				builder.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		builder.WriteByte(value[i])
+	}
+	return builder.String()
+}
+
+// PartitionValuesFromPath returns the partition values of the Hive-style col=value directories of a data file path,
+// as returned by DataFilePath. The URI encoded path of an Add action must be decoded first, the directory names
+// are only decoded once. Directories of the Hive default partition have an empty (null) value.
+func PartitionValuesFromPath(dataPath string) map[string]string {
+	values := make(map[string]string)
+	segments := strings.Split(dataPath, "/")
+	// The last segment is the file name
+	for _, segment := range segments[:len(segments)-1] {
+		column, value, found := strings.Cut(segment, "=")
+		if !found || column == "" {
+			continue
+		}
+		value = unescapePartitionPathValue(value)
+		if value == HIVE_DEFAULT_PARTITION {
+			value = ""
+		}
+		values[unescapePartitionPathValue(column)] = value
+	}
+	return values
+}
+
+// ValidatePartitionPath checks that the partition values embedded in the path of the file agree with its
+// partitionValues. Paths without partition directories, such as randomized file prefixes, are valid, as are
+// directories for columns that are not partition columns of the file.
+func (add *Add) ValidatePartitionPath() error {
+	for column, pathValue := range PartitionValuesFromPath(unescapedDataPath(add.Path)) {
+		value, ok := add.PartitionValues[column]
+		if !ok {
+			continue
+		}
+		if IsNullPartitionValue(value) {
+			value = ""
+		}
+		if value != pathValue {
+			return errors.Join(ErrorPartitionPathMismatch, fmt.Errorf("%s has %s=%q, partition values have %q", add.Path, column, pathValue, value))
+		}
+	}
+	return nil
+}
+
+// The comparison applied by a PartitionFilter
+type PartitionFilterOperator string
+
+const (
+	PartitionEqual     PartitionFilterOperator = "="
+	PartitionNotEqual  PartitionFilterOperator = "!="
+	PartitionIsNull    PartitionFilterOperator = "IS NULL"
+	PartitionIsNotNull PartitionFilterOperator = "IS NOT NULL"
+)
+
+// PartitionFilter is a predicate on the serialized value of a partition column.
+// Null partition values follow SQL semantics: they only match PartitionIsNull, and never
+// match PartitionEqual or PartitionNotEqual.
+type PartitionFilter struct {
+	Column   string
+	Operator PartitionFilterOperator
+	// Value is compared with the serialized partition value, it is unused for the null checks
+	Value string
+}
+
+// Matches reports whether the Add action satisfies the filter
+func (filter PartitionFilter) Matches(add *Add) bool {
+	value, notNull := add.partitionValue(filter.Column)
+	switch filter.Operator {
+	case PartitionIsNull:
+		return !notNull
+	case PartitionIsNotNull:
+		return notNull
+	case PartitionEqual:
+		return notNull && value == filter.Value
+	case PartitionNotEqual:
+		return notNull && value != filter.Value
+	default:
+		return false
+	}
+}
+
+// parsePartitionValue converts the serialized string form of a partition value into a value of the given type
+func parsePartitionValue(dataType SchemaDataType, value string) (any, error) {
+	if IsNullPartitionValue(value) {
+		return nil, nil
+	}
+	if precision, scale, ok := dataType.DecimalPrecisionScale(); ok {
+		return ParseDecimal(value, precision, scale)
+	}
+
+	switch dataType {
+	case String:
+		return value, nil
+	case Long:
+		return strconv.ParseInt(value, 10, 64)
+	case Integer:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return int32(v), err
+	case Short:
+		v, err := strconv.ParseInt(value, 10, 16)
+		return int16(v), err
+	case Byte:
+		v, err := strconv.ParseInt(value, 10, 8)
+		return int8(v), err
+	case Float:
+		v, err := strconv.ParseFloat(value, 32)
+		return float32(v), err
+	case Double:
+		return strconv.ParseFloat(value, 64)
+	case Boolean:
+		return strconv.ParseBool(value)
+	case Binary:
+		return []byte(value), nil
+	case Date:
+		return time.ParseInLocation(partitionDateLayout, value, time.UTC)
+	case Timestamp:
+		t, err := time.ParseInLocation(partitionTimestampLayout, value, time.UTC)
+		if err != nil {
+			// Some writers use the ISO 8601 format instead
+			return time.Parse(time.RFC3339Nano, value)
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unsupported partition column type %s", dataType)
+	}
+}
+
+// formatPartitionValue serializes a partition value of the given type as parsed by parsePartitionValue.
+// Null values are serialized as an empty string.
+func formatPartitionValue(dataType SchemaDataType, value any) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(value), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(value), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(value), 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(value), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case []byte:
+		return string(value), nil
+	case Decimal:
+		return value.String(), nil
+	case time.Time:
+		if dataType == Date {
+			return value.UTC().Format(partitionDateLayout), nil
+		}
+		return value.UTC().Format(partitionTimestampLayout), nil
+	default:
+		return "", fmt.Errorf("unsupported partition value %T for %s", value, dataType)
+	}
+}
+
+// PartitionValues returns the distinct values of a partition column among the active files of the table, parsed
+// into Go values as by TypedPartitionValues, in ascending order. Null is the first value if a file has a null
+// partition value. Only the table state is read, no data file is scanned.
+func (tableState *DeltaTableState) PartitionValues(column string) ([]any, error) {
+	metadata := tableState.CurrentMetadata
+	if !slices.Contains(metadata.PartitionColumns, column) {
+		return nil, errors.Join(ErrorNotAPartitionColumn, fmt.Errorf("column %s", column))
+	}
+	field, ok := metadata.Schema.GetField(column)
+	if !ok {
+		return nil, errors.Join(ErrorPartitionColumnNotFound, fmt.Errorf("column %s", column))
+	}
+
+	serialized := make(map[string]bool)
+	for path := range tableState.Files {
+		add := tableState.Files[path]
+		value, _ := add.partitionValue(column)
+		serialized[value] = true
+	}
+	values := make([]any, 0, len(serialized))
+	for value := range serialized {
+		typedValue, err := parsePartitionValue(field.Type, value)
+		if err != nil {
+			return nil, errors.Join(ErrorInvalidPartitionValue, fmt.Errorf("column %s value %q", column, value), err)
+		}
+		values = append(values, typedValue)
+	}
+	sort.Slice(values, func(i, j int) bool { return compareTypedValues(values[i], values[j]) < 0 })
+
+	// Different serializations of the same value, such as 1 and 01, are only returned once
+	distinct := values[:0]
+	for i, value := range values {
+		if i == 0 || compareTypedValues(values[i-1], value) != 0 {
+			distinct = append(distinct, value)
+		}
+	}
+	return distinct, nil
+}
+
+// compareTypedValues compares two values of the same column as parsed by parsePartitionValue,
+// with nil first
+func compareTypedValues(a any, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch a := a.(type) {
+	case string:
+		return compareOrdered(a, b.(string))
+	case int64:
+		return compareOrdered(a, b.(int64))
+	case int32:
+		return compareOrdered(a, b.(int32))
+	case int16:
+		return compareOrdered(a, b.(int16))
+	case int8:
+		return compareOrdered(a, b.(int8))
+	case float32:
+		return compareOrdered(a, b.(float32))
+	case float64:
+		return compareOrdered(a, b.(float64))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case Decimal:
+		return a.Cmp(b.(Decimal))
+	default:
+		return 0
+	}
+}
+
+func compareOrdered[T constraints.Ordered](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedPartitionValues(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{
+		{Name: "name", Type: String},
+		{Name: "id", Type: Long},
+		{Name: "count", Type: Integer},
+		{Name: "ratio", Type: Double},
+		{Name: "enabled", Type: Boolean},
+		{Name: "date", Type: Date},
+		{Name: "ts", Type: Timestamp},
+		{Name: "missing", Type: Long},
+	}}
+	add := Add{PartitionValues: map[string]string{
+		"name":    "a",
+		"id":      "9223372036854775807",
+		"count":   "-12",
+		"ratio":   "1.5",
+		"enabled": "true",
+		"date":    "2023-03-01",
+		"ts":      "2023-03-01 10:11:12.123456",
+		"missing": HIVE_DEFAULT_PARTITION,
+	}}
+
+	values, err := add.TypedPartitionValues(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["name"] != "a" {
+		t.Errorf("name: has %v", values["name"])
+	}
+	if values["id"] != int64(9223372036854775807) {
+		t.Errorf("id: has %v", values["id"])
+	}
+	if values["count"] != int32(-12) {
+		t.Errorf("count: has %v", values["count"])
+	}
+	if values["ratio"] != 1.5 {
+		t.Errorf("ratio: has %v", values["ratio"])
+	}
+	if values["enabled"] != true {
+		t.Errorf("enabled: has %v", values["enabled"])
+	}
+	if date := values["date"].(time.Time); !date.Equal(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date: has %v", date)
+	}
+	if ts := values["ts"].(time.Time); !ts.Equal(time.Date(2023, 3, 1, 10, 11, 12, 123456000, time.UTC)) {
+		t.Errorf("ts: has %v", ts)
+	}
+	if v, ok := values["missing"]; !ok || v != nil {
+		t.Errorf("missing: want nil, has %v", v)
+	}
+}
+
+func TestTypedPartitionValuesErrors(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+
+	add := Add{PartitionValues: map[string]string{"id": "abc"}}
+	_, err := add.TypedPartitionValues(schema)
+	if !errors.Is(err, ErrorInvalidPartitionValue) {
+		t.Errorf("want ErrorInvalidPartitionValue, has %v", err)
+	}
+
+	add = Add{PartitionValues: map[string]string{"other": "1"}}
+	_, err = add.TypedPartitionValues(schema)
+	if !errors.Is(err, ErrorPartitionColumnNotFound) {
+		t.Errorf("want ErrorPartitionColumnNotFound, has %v", err)
+	}
+}
+
+func TestNullPartitionValues(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "region", Type: String}}}
+	tableState := NewDeltaTableState(0)
+	tableState.applyActions([]Action{
+		Add{Path: "region=us/part-0.parquet", PartitionValues: map[string]string{"region": "us"}},
+		Add{Path: "region=eu/part-1.parquet", PartitionValues: map[string]string{"region": "eu"}},
+		Add{Path: "region=__HIVE_DEFAULT_PARTITION__/part-2.parquet", PartitionValues: map[string]string{"region": HIVE_DEFAULT_PARTITION}},
+		Add{Path: "part-3.parquet", PartitionValues: map[string]string{"region": ""}},
+		Add{Path: "part-4.parquet", PartitionValues: map[string]string{}},
+	})
+
+	tests := []struct {
+		name   string
+		filter PartitionFilter
+		want   int
+	}{
+		{name: "is null", filter: PartitionFilter{Column: "region", Operator: PartitionIsNull}, want: 3},
+		{name: "is not null", filter: PartitionFilter{Column: "region", Operator: PartitionIsNotNull}, want: 2},
+		{name: "equal", filter: PartitionFilter{Column: "region", Operator: PartitionEqual, Value: "us"}, want: 1},
+		{name: "not equal excludes nulls", filter: PartitionFilter{Column: "region", Operator: PartitionNotEqual, Value: "us"}, want: 1},
+		{name: "marker is not a literal value", filter: PartitionFilter{Column: "region", Operator: PartitionEqual, Value: HIVE_DEFAULT_PARTITION}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := tableState.FilesMatchingPartitions([]PartitionFilter{tt.filter})
+			if len(files) != tt.want {
+				t.Errorf("want %d files, has %d", tt.want, len(files))
+			}
+		})
+	}
+
+	// The typed values agree with the filters on which files are null
+	for _, add := range tableState.FilesMatchingPartitions([]PartitionFilter{{Column: "region", Operator: PartitionIsNull}}) {
+		values, err := add.TypedPartitionValues(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values["region"] != nil {
+			t.Errorf("%s: want a nil typed value, has %v", add.Path, values["region"])
+		}
+	}
+}
+
+func TestPartitionPath(t *testing.T) {
+	partitionPath, err := PartitionPath([]string{"date", "region"}, map[string]string{"region": "us/east=1", "date": "2023-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitionPath != "date=2023-01-01/region=us%2Feast%3D1" {
+		t.Errorf("unexpected partition path %s", partitionPath)
+	}
+
+	partitionPath, _ = PartitionPath([]string{"date"}, map[string]string{"date": ""})
+	if partitionPath != "date="+HIVE_DEFAULT_PARTITION {
+		t.Errorf("unexpected null partition path %s", partitionPath)
+	}
+
+	_, err = PartitionPath([]string{"date", "region"}, map[string]string{"date": "2023-01-01"})
+	if !errors.Is(err, ErrorInvalidPartitionValue) {
+		t.Errorf("want ErrorInvalidPartitionValue, has %v", err)
+	}
+	_, err = PartitionPath([]string{}, map[string]string{"date": "2023-01-01"})
+	if !errors.Is(err, ErrorPartitionColumnNotFound) {
+		t.Errorf("want ErrorPartitionColumnNotFound, has %v", err)
+	}
+}
+
+func TestMultiLevelPartitionPath(t *testing.T) {
+	partitionColumns := []string{"date", "region", "team"}
+	partitionValues := map[string]string{"date": "2023-01-01 10:00:00", "region": "us/east=1", "team": ""}
+	dataPath, err := DataFilePath("data", partitionColumns, partitionValues, "part-0.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "data/date=2023-01-01 10%3A00%3A00/region=us%2Feast%3D1/team=" + HIVE_DEFAULT_PARTITION + "/part-0.parquet"
+	if dataPath != expected {
+		t.Errorf("want %s, has %s", expected, dataPath)
+	}
+
+	// The partition values round trip through the path, and through the URI encoded path of the Add action
+	values := PartitionValuesFromPath(dataPath)
+	if !reflect.DeepEqual(values, partitionValues) {
+		t.Errorf("want %v, has %v", partitionValues, values)
+	}
+	add := Add{Path: DataFileUri(dataPath), PartitionValues: partitionValues}
+	if err := add.ValidatePartitionPath(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPartitionPathWithPercent(t *testing.T) {
+	partitionValues := map[string]string{"code": "%41", "rate": "5%"}
+	dataPath, err := DataFilePath("", []string{"code", "rate"}, partitionValues, "part-0.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "code=%2541/rate=5%25/part-0.parquet"; dataPath != expected {
+		t.Errorf("want %s, has %s", expected, dataPath)
+	}
+	// The escaped percent sign is not decoded again
+	if values := PartitionValuesFromPath(dataPath); !reflect.DeepEqual(values, partitionValues) {
+		t.Errorf("want %v, has %v", partitionValues, values)
+	}
+	add := Add{Path: DataFileUri(dataPath), PartitionValues: partitionValues}
+	if err := add.ValidatePartitionPath(); err != nil {
+		t.Error(err)
+	}
+	add.PartitionValues = map[string]string{"code": "A", "rate": "5%"}
+	if err := add.ValidatePartitionPath(); !errors.Is(err, ErrorPartitionPathMismatch) {
+		t.Errorf("want ErrorPartitionPathMismatch, has %v", err)
+	}
+}
+
+func TestValidatePartitionPath(t *testing.T) {
+	tests := []struct {
+		add   Add
+		valid bool
+	}{
+		{Add{Path: "date=2023-01-01/region=us/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01", "region": "us"}}, true},
+		{Add{Path: "date=2023-01-01/region=eu/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01", "region": "us"}}, false},
+		{Add{Path: "region=us/date=2023-01-02/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01", "region": "us"}}, false},
+		// Null values match the Hive default partition
+		{Add{Path: "date=" + HIVE_DEFAULT_PARTITION + "/part-0.parquet", PartitionValues: map[string]string{"date": ""}}, true},
+		{Add{Path: "date=" + HIVE_DEFAULT_PARTITION + "/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01"}}, false},
+		// Paths without partition directories are valid
+		{Add{Path: "a1b2/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01"}}, true},
+		{Add{Path: "date=2023-01-01/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01", "region": "us"}}, true},
+		// Directories of other columns and file names are ignored
+		{Add{Path: "s3://bucket/env=prod/date=2023-01-01/a=b.parquet", PartitionValues: map[string]string{"date": "2023-01-01"}}, true},
+	}
+	for _, test := range tests {
+		err := test.add.ValidatePartitionPath()
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", test.add.Path, err)
+		}
+		if !test.valid && !errors.Is(err, ErrorPartitionPathMismatch) {
+			t.Errorf("%s: want ErrorPartitionPathMismatch, has %v", test.add.Path, err)
+		}
+	}
+}
+
+func TestStrictPartitionValidation(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: String}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{"date"}, map[string]string{})
+	// The writer put the file in the directory of another date
+	mismatched := Add{Path: "date=2023-01-02/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01"}}
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{mismatched})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mismatches are only logged by default
+	if _, err := OpenTable(table.Store, nil, nil); err != nil {
+		t.Errorf("want the table loaded, has %v", err)
+	}
+	strict := NewDeltaTable(table.Store, nil, nil)
+	strict.Config.StrictPartitionValidation = true
+	if err := strict.Load(); !errors.Is(err, ErrorPartitionPathMismatch) {
+		t.Errorf("want ErrorPartitionPathMismatch, has %v", err)
+	}
+
+	// Files added by later commits are validated when the table is updated
+	table, _, _ = setupTest(t)
+	valid := Add{Path: "date=2023-01-01/part-0.parquet", PartitionValues: map[string]string{"date": "2023-01-01"}}
+	err = table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{valid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	strict = NewDeltaTable(table.Store, nil, nil)
+	strict.Config.StrictPartitionValidation = true
+	if err := strict.Load(); err != nil {
+		t.Fatal(err)
+	}
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(mismatched)
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := strict.Update(); !errors.Is(err, ErrorPartitionPathMismatch) {
+		t.Errorf("want ErrorPartitionPathMismatch, has %v", err)
+	}
+}
+
+func TestDecimalPartitionValues(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "price", Type: DecimalType(10, 2)}}}
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"12.34", "12.34"},
+		{"12.3", "12.30"},
+		{"-7", "-7.00"},
+		{"0.05", "0.05"},
+	}
+	for _, test := range tests {
+		add := Add{PartitionValues: map[string]string{"price": test.value}}
+		values, err := add.TypedPartitionValues(schema)
+		if err != nil {
+			t.Errorf("%s: %v", test.value, err)
+			continue
+		}
+		price, ok := values["price"].(Decimal)
+		if !ok || price.Scale != 2 || price.String() != test.expected {
+			t.Errorf("%s: want %s, has %v", test.value, test.expected, values["price"])
+		}
+	}
+
+	// Partitions with the same value in different representations compare equal
+	a, _ := (&Add{PartitionValues: map[string]string{"price": "12.3"}}).TypedPartitionValues(schema)
+	b, _ := (&Add{PartitionValues: map[string]string{"price": "12.30"}}).TypedPartitionValues(schema)
+	if a["price"].(Decimal).Cmp(b["price"].(Decimal)) != 0 {
+		t.Error("12.3 and 12.30 should be equal")
+	}
+
+	for _, value := range []string{"12.345", "123456789.00", "abc"} {
+		add := Add{PartitionValues: map[string]string{"price": value}}
+		_, err := add.TypedPartitionValues(schema)
+		if !errors.Is(err, ErrorInvalidPartitionValue) || !errors.Is(err, ErrorInvalidDecimal) {
+			t.Errorf("%s: want ErrorInvalidPartitionValue, has %v", value, err)
+		}
+	}
+
+	add := Add{PartitionValues: map[string]string{"price": HIVE_DEFAULT_PARTITION}}
+	values, err := add.TypedPartitionValues(schema)
+	if err != nil || values["price"] != nil {
+		t.Errorf("null decimal partition: has %v %v", values["price"], err)
+	}
+}
+
+func TestPartitionValues(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "day", Type: Integer}, {Name: "region", Type: String}}}
+	tableState := NewDeltaTableState(0)
+	tableState.CurrentMetadata = *NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{"day", "region"}, map[string]string{})
+	for i, values := range []map[string]string{
+		{"day": "10", "region": "us"},
+		{"day": "2", "region": "eu"},
+		{"day": "02", "region": "us"},
+		{"day": HIVE_DEFAULT_PARTITION, "region": "us"},
+		{"day": "10", "region": "ap"},
+	} {
+		path := fmt.Sprintf("part-%d.parquet", i)
+		tableState.Files[path] = Add{Path: path, PartitionValues: values}
+	}
+
+	days, err := tableState.PartitionValues("day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(days, []any{nil, int32(2), int32(10)}) {
+		t.Errorf("want [<nil> 2 10], has %v", days)
+	}
+	regions, err := tableState.PartitionValues("region")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(regions, []any{"ap", "eu", "us"}) {
+		t.Errorf("want [ap eu us], has %v", regions)
+	}
+
+	if _, err := tableState.PartitionValues("id"); !errors.Is(err, ErrorNotAPartitionColumn) {
+		t.Errorf("want ErrorNotAPartitionColumn, has %v", err)
+	}
+	tableState.Files["part-bad.parquet"] = Add{Path: "part-bad.parquet", PartitionValues: map[string]string{"day": "monday"}}
+	if _, err := tableState.PartitionValues("day"); !errors.Is(err, ErrorInvalidPartitionValue) {
+		t.Errorf("want ErrorInvalidPartitionValue, has %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/rivian/delta-go/state"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	// Table property rejecting the removal of data from the table
+	APPEND_ONLY_PROPERTY = "delta.appendOnly"
+	// Table property setting the number of commits between checkpoints
+	CHECKPOINT_INTERVAL_PROPERTY = "delta.checkpointInterval"
+)
+
+// The validators of the values of the table properties known to delta-go
+var tablePropertyValidators = map[string]func(value string) error{
+	APPEND_ONLY_PROPERTY:                     validateBoolProperty,
+	CHANGE_DATA_FEED_PROPERTY:                validateBoolProperty,
+	ROW_TRACKING_PROPERTY:                    validateBoolProperty,
+	ENABLE_EXPIRED_LOG_CLEANUP_PROPERTY:      validateBoolProperty,
+	CHECKPOINT_INTERVAL_PROPERTY:             validateIntProperty(1),
+	DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY:  validateIntProperty(-1),
+	LOG_RETENTION_DURATION_PROPERTY:          validateIntervalProperty,
+	DELETED_FILE_RETENTION_DURATION_PROPERTY: validateIntervalProperty,
+}
+
+func validateBoolProperty(value string) error {
+	if value != "true" && value != "false" {
+		return fmt.Errorf("%q is not true or false", value)
+	}
+	return nil
+}
+
+func validateIntProperty(min int) func(value string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < min {
+			return fmt.Errorf("%q is not an integer of at least %d", value, min)
+		}
+		return nil
+	}
+}
+
+func validateIntervalProperty(value string) error {
+	_, err := parseInterval(value)
+	return err
+}
+
+// SetProperties commits a Metadata action setting the table properties, keeping the other properties and the rest
+// of the metadata of the loaded table state, and returns the committed version.
+// The values of the properties known to delta-go are validated, and the protocol is upgraded in the same commit
+// if a property set to a new value requires a table feature the table does not have, e.g. delta.enableRowTracking.
+// ErrorInvalidTableProperty is returned for an invalid value, and ErrorUnsupportedProtocol if delta-go does not
+// support the table feature a property requires, e.g. delta.enableChangeDataFeed.
+// Nothing is committed if the properties already have the given values.
+func (table *DeltaTable) SetProperties(properties map[string]string) (state.DeltaDataTypeVersion, error) {
+	configuration := table.State.Properties()
+	if configuration == nil {
+		configuration = make(map[string]string)
+	}
+	for key, value := range properties {
+		configuration[key] = value
+	}
+	return table.commitProperties(configuration, SetTableProperties{Properties: properties})
+}
+
+// UnsetProperties commits a Metadata action removing the table properties, keeping the rest of the metadata of the
+// loaded table state, and returns the committed version. Keys that are not set are ignored, and nothing is
+// committed if none of them is set. The protocol is never downgraded.
+func (table *DeltaTable) UnsetProperties(keys []string) (state.DeltaDataTypeVersion, error) {
+	configuration := table.State.Properties()
+	for _, key := range keys {
+		delete(configuration, key)
+	}
+	return table.commitProperties(configuration, UnsetTableProperties{PropertyKeys: keys})
+}
+
+// commitProperties commits the metadata of the table state with the configuration, with a protocol upgrade if the
+// changed properties require table features
+func (table *DeltaTable) commitProperties(configuration map[string]string, operation DeltaOperation) (state.DeltaDataTypeVersion, error) {
+	if table.State.Version < 0 {
+		return table.State.Version, ErrorNotATable
+	}
+	if maps.Equal(configuration, table.State.CurrentMetadata.Configuration) {
+		return table.State.Version, nil
+	}
+	if err := table.State.validateProperties(configuration); err != nil {
+		return table.State.Version, err
+	}
+	protocol, upgrade, err := upgradeProtocol(table.State.protocol(), changedPropertyFeatures(table.State.CurrentMetadata.Configuration, configuration)...)
+	if err != nil {
+		return table.State.Version, err
+	}
+	metadata := table.State.CurrentMetadata
+	metadata.Configuration = configuration
+	metaData := metadata.ToMetaData()
+	if err := metaData.Validate(); err != nil {
+		return table.State.Version, err
+	}
+
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	if upgrade {
+		transaction.AddAction(protocol)
+	}
+	transaction.AddAction(metaData)
+	return transaction.Commit(operation, nil)
+}
+
+// validateProperties checks the values of the known table properties of the configuration, and that the properties
+// changed from the table state can be changed by delta-go
+func (tableState *DeltaTableState) validateProperties(configuration map[string]string) error {
+	for key, value := range configuration {
+		if validate, ok := tablePropertyValidators[key]; ok {
+			if err := validate(value); err != nil {
+				return errors.Join(ErrorInvalidTableProperty, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	}
+
+	current, _ := tableState.Property(COLUMN_MAPPING_MODE_PROPERTY)
+	if configuration[COLUMN_MAPPING_MODE_PROPERTY] != current {
+		return errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("changing %s", COLUMN_MAPPING_MODE_PROPERTY))
+	}
+
+	// Row tracking may only be enabled once every file has been assigned row ids
+	if enabled, _ := tableState.Property(ROW_TRACKING_PROPERTY); configuration[ROW_TRACKING_PROPERTY] == "true" && enabled != "true" {
+		for path, add := range tableState.Files {
+			if add.BaseRowId == nil {
+				return errors.Join(ErrorInvalidTableProperty, fmt.Errorf("%s: the file %s has no row ids", ROW_TRACKING_PROPERTY, path))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetProperties(t *testing.T) {
+	table, stateStore, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	table, err := CreateTable(table.Store, table.LockClient, stateStore, schema, nil, map[string]string{"com.example.owner": "data-eng"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := table.State.CurrentMetadata.Id
+
+	version, err := table.SetProperties(map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "20", APPEND_ONLY_PROPERTY: "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("want version 1, has %d", version)
+	}
+	loaded, err := OpenTable(table.Store, table.LockClient, stateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"com.example.owner": "data-eng", CHECKPOINT_INTERVAL_PROPERTY: "20", APPEND_ONLY_PROPERTY: "true"}
+	if !reflect.DeepEqual(loaded.State.Properties(), expected) || !reflect.DeepEqual(table.State.Properties(), expected) {
+		t.Errorf("unexpected properties %v", loaded.State.Properties())
+	}
+	// The rest of the metadata is kept
+	if loaded.State.CurrentMetadata.Id != id || !reflect.DeepEqual(loaded.State.CurrentMetadata.Schema, schema) {
+		t.Errorf("unexpected metadata %+v", loaded.State.CurrentMetadata)
+	}
+	if loaded.State.CommitInfos[0]["operation"] != "SET TBLPROPERTIES" {
+		t.Errorf("unexpected commit info %v", loaded.State.CommitInfos[0])
+	}
+
+	// Setting the current values commits nothing
+	version, err = loaded.SetProperties(map[string]string{APPEND_ONLY_PROPERTY: "true"})
+	if err != nil || version != 1 {
+		t.Errorf("want version 1, has %d %v", version, err)
+	}
+
+	version, err = loaded.UnsetProperties([]string{APPEND_ONLY_PROPERTY, "com.example.missing"})
+	if err != nil || version != 2 {
+		t.Errorf("want version 2, has %d %v", version, err)
+	}
+	if _, ok := loaded.State.Property(APPEND_ONLY_PROPERTY); ok {
+		t.Error("delta.appendOnly should be unset")
+	}
+	version, err = loaded.UnsetProperties([]string{APPEND_ONLY_PROPERTY})
+	if err != nil || version != 2 {
+		t.Errorf("want version 2, has %d %v", version, err)
+	}
+}
+
+func TestSetPropertiesInvalid(t *testing.T) {
+	table, stateStore, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	table, err := CreateTable(table.Store, table.LockClient, stateStore, schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		properties map[string]string
+		expected   error
+	}{
+		{map[string]string{CHANGE_DATA_FEED_PROPERTY: "yes"}, ErrorInvalidTableProperty},
+		{map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "0"}, ErrorInvalidTableProperty},
+		{map[string]string{LOG_RETENTION_DURATION_PROPERTY: "interval 1 fortnight"}, ErrorInvalidTableProperty},
+		{map[string]string{COLUMN_MAPPING_MODE_PROPERTY: "name"}, ErrorUnsupportedProtocol},
+		// delta-go cannot write the changeDataFeed feature
+		{map[string]string{CHANGE_DATA_FEED_PROPERTY: "true"}, ErrorUnsupportedProtocol},
+	}
+	for _, test := range tests {
+		version, err := table.SetProperties(test.properties)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%v: want %v, has %v", test.properties, test.expected, err)
+		}
+		if version != 0 {
+			t.Errorf("%v: nothing should be committed, has version %d", test.properties, version)
+		}
+	}
+}
+
+func TestSetPropertiesUpgradesProtocol(t *testing.T) {
+	table, stateStore, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	table, err := CreateTable(table.Store, table.LockClient, stateStore, schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := table.SetProperties(map[string]string{ROW_TRACKING_PROPERTY: "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("want version 1, has %d", version)
+	}
+	loaded, err := OpenTable(table.Store, table.LockClient, stateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.State.RowTrackingEnabled() || !table.State.RowTrackingEnabled() {
+		t.Errorf("row tracking should be enabled, has %v %v", loaded.State.WriterFeatures, loaded.State.Properties())
+	}
+}
+
+func TestSetPropertiesWithChangeDataFeed(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{CHANGE_DATA_FEED_PROPERTY: "true"})
+	// Writer version 4 enables the change data feed, as written by other engines
+	if err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 4}, CommitInfo{}, []Add{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The properties the table already has are not validated again
+	if version, err := table.SetProperties(map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "20"}); err != nil || version != 1 {
+		t.Errorf("want version 1, has %d %v", version, err)
+	}
+	if version, err := table.UnsetProperties([]string{CHECKPOINT_INTERVAL_PROPERTY}); err != nil || version != 2 {
+		t.Errorf("want version 2, has %d %v", version, err)
+	}
+	batch := table.NewBatchTransaction(NewDeltaTransactionOptions())
+	if err := batch.SetProperties(map[string]string{CHECKPOINT_INTERVAL_PROPERTY: "20"}); err != nil {
+		t.Error(err)
+	}
+	if table.State.MinWriterVersion != 4 {
+		t.Errorf("the protocol should be unchanged, has writer version %d", table.State.MinWriterVersion)
+	}
+
+	// Enabling the change data feed still requires delta-go to support it
+	table, stateStore, _ := setupTest(t)
+	table, err := CreateTable(table.Store, table.LockClient, stateStore, schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.SetProperties(map[string]string{CHANGE_DATA_FEED_PROPERTY: "true"}); !errors.Is(err, ErrorUnsupportedProtocol) {
+		t.Errorf("want ErrorUnsupportedProtocol, has %v", err)
+	}
+}
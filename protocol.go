@@ -0,0 +1,288 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rivian/delta-go/state"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrorUnsupportedProtocol error = errors.New("the protocol is not supported by delta-go")
+	ErrorInvalidProtocol     error = errors.New("invalid protocol")
+)
+
+const (
+	// Reader version that lists the table features required to read in the protocol
+	TABLE_FEATURES_MIN_READER_VERSION = 3
+	// Legacy writer features of writer version 2
+	APPEND_ONLY_FEATURE = "appendOnly"
+	INVARIANTS_FEATURE  = "invariants"
+	// Writer feature storing named metadata domains in the log
+	DOMAIN_METADATA_FEATURE = "domainMetadata"
+	// Table property enabling row tracking, which requires the rowTracking feature
+	ROW_TRACKING_PROPERTY = "delta.enableRowTracking"
+	// Writer feature recording the changes of the rows of the table, which delta-go does not support
+	CHANGE_DATA_FEED_FEATURE = "changeDataFeed"
+	// Table property enabling the change data feed, which requires the changeDataFeed feature
+	CHANGE_DATA_FEED_PROPERTY = "delta.enableChangeDataFeed"
+	// Reader and writer feature marking deleted rows in deletion vector files referenced by the data files
+	DELETION_VECTORS_FEATURE = "deletionVectors"
+	// Reader and writer feature requiring vacuum to support all the features of the table, so that it never deletes
+	// a file still referenced by a feature it does not know about
+	VACUUM_PROTOCOL_CHECK_FEATURE = "vacuumProtocolCheck"
+)
+
+// The table features delta-go can write, with the features each of them depends on
+var supportedWriterFeatures = map[string][]string{
+	APPEND_ONLY_FEATURE:      {},
+	INVARIANTS_FEATURE:       {},
+	DOMAIN_METADATA_FEATURE:  {},
+	ROW_TRACKING_FEATURE:     {DOMAIN_METADATA_FEATURE},
+	DELETION_VECTORS_FEATURE: {},
+}
+
+// The supported table features that readers must support too, which are also listed as reader features
+var readerWriterFeatures = []string{DELETION_VECTORS_FEATURE}
+
+// The features of writer version 2, which must be listed when such a table is upgraded to table features
+var legacyWriterFeatures = []string{APPEND_ONLY_FEATURE, INVARIANTS_FEATURE}
+
+// The legacy features enabled by writer versions 3 to 6, in addition to the features of the lower versions
+var legacyVersionWriterFeatures = map[DeltaDataTypeInt][]string{
+	3: {"checkConstraints"},
+	4: {CHANGE_DATA_FEED_FEATURE, "generatedColumns"},
+	5: {"columnMapping"},
+	6: {"identityColumns"},
+}
+
+// Validate checks that delta-go supports the protocol and that the protocol is consistent:
+// table features are only listed with reader version 3 and writer version 7, reader features are also writer
+// features, reader and writer features are listed as both, and the features a feature depends on are listed too.
+// Reader version 2 and writer versions 3 to 6 enable legacy features delta-go does not support.
+func (protocol *Protocol) Validate() error {
+	switch protocol.MinReaderVersion {
+	case 1, TABLE_FEATURES_MIN_READER_VERSION:
+	default:
+		return errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("reader version %d", protocol.MinReaderVersion))
+	}
+	switch protocol.MinWriterVersion {
+	case 1, 2, TABLE_FEATURES_MIN_WRITER_VERSION:
+	default:
+		return errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("writer version %d", protocol.MinWriterVersion))
+	}
+
+	if protocol.MinReaderVersion == TABLE_FEATURES_MIN_READER_VERSION && protocol.MinWriterVersion != TABLE_FEATURES_MIN_WRITER_VERSION {
+		return errors.Join(ErrorInvalidProtocol, fmt.Errorf("reader version %d requires writer version %d", TABLE_FEATURES_MIN_READER_VERSION, TABLE_FEATURES_MIN_WRITER_VERSION))
+	}
+	if len(protocol.ReaderFeatures) > 0 && protocol.MinReaderVersion != TABLE_FEATURES_MIN_READER_VERSION {
+		return errors.Join(ErrorInvalidProtocol, fmt.Errorf("reader features %v require reader version %d", protocol.ReaderFeatures, TABLE_FEATURES_MIN_READER_VERSION))
+	}
+	if len(protocol.WriterFeatures) > 0 && protocol.MinWriterVersion != TABLE_FEATURES_MIN_WRITER_VERSION {
+		return errors.Join(ErrorInvalidProtocol, fmt.Errorf("writer features %v require writer version %d", protocol.WriterFeatures, TABLE_FEATURES_MIN_WRITER_VERSION))
+	}
+
+	for _, feature := range protocol.ReaderFeatures {
+		if !protocol.HasWriterFeature(feature) {
+			return errors.Join(ErrorInvalidProtocol, fmt.Errorf("reader feature %s is not a writer feature", feature))
+		}
+	}
+	for _, feature := range protocol.WriterFeatures {
+		dependencies, ok := supportedWriterFeatures[feature]
+		if !ok {
+			return errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("table feature %s", feature))
+		}
+		for _, dependency := range dependencies {
+			if !protocol.HasWriterFeature(dependency) {
+				return errors.Join(ErrorInvalidProtocol, fmt.Errorf("table feature %s requires %s", feature, dependency))
+			}
+		}
+		if slices.Contains(readerWriterFeatures, feature) && !protocol.HasReaderFeature(feature) {
+			return errors.Join(ErrorInvalidProtocol, fmt.Errorf("table feature %s is not a reader feature", feature))
+		}
+	}
+	return nil
+}
+
+// HasReaderFeature returns true if the protocol requires readers to implement the given table feature
+func (protocol *Protocol) HasReaderFeature(feature string) bool {
+	return protocol.MinReaderVersion >= TABLE_FEATURES_MIN_READER_VERSION && slices.Contains(protocol.ReaderFeatures, feature)
+}
+
+// ProtocolForFeatures returns the lowest protocol supporting the given writer features and the features they
+// depend on: writer version 2 without features, and writer version 7 otherwise. Features readers must support
+// too, such as deletionVectors, are also listed as reader features of reader version 3.
+func ProtocolForFeatures(features ...string) (Protocol, error) {
+	if len(features) == 0 {
+		return Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, nil
+	}
+	required := make(map[string]bool)
+	var add func(feature string) error
+	add = func(feature string) error {
+		dependencies, ok := supportedWriterFeatures[feature]
+		if !ok {
+			return errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("table feature %s", feature))
+		}
+		required[feature] = true
+		for _, dependency := range dependencies {
+			if err := add(dependency); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, feature := range features {
+		if err := add(feature); err != nil {
+			return Protocol{}, err
+		}
+	}
+
+	protocol := Protocol{MinReaderVersion: 1, MinWriterVersion: TABLE_FEATURES_MIN_WRITER_VERSION}
+	for feature := range required {
+		protocol.WriterFeatures = append(protocol.WriterFeatures, feature)
+		if slices.Contains(readerWriterFeatures, feature) {
+			protocol.MinReaderVersion = TABLE_FEATURES_MIN_READER_VERSION
+			protocol.ReaderFeatures = append(protocol.ReaderFeatures, feature)
+		}
+	}
+	sort.Strings(protocol.WriterFeatures)
+	sort.Strings(protocol.ReaderFeatures)
+	return protocol, nil
+}
+
+// hasTableFeature returns true if the protocol has the writer feature, listed or enabled by its legacy writer version
+func (protocol *Protocol) hasTableFeature(feature string) bool {
+	if protocol.MinWriterVersion >= TABLE_FEATURES_MIN_WRITER_VERSION {
+		return protocol.HasWriterFeature(feature)
+	}
+	if protocol.MinWriterVersion >= 2 && slices.Contains(legacyWriterFeatures, feature) {
+		return true
+	}
+	for version := DeltaDataTypeInt(3); version <= protocol.MinWriterVersion; version++ {
+		if slices.Contains(legacyVersionWriterFeatures[version], feature) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedPropertyFeatures returns the table features required by the properties of configuration whose value
+// differs from the current configuration
+func changedPropertyFeatures(current map[string]string, configuration map[string]string) []string {
+	changed := make(map[string]string)
+	for key, value := range configuration {
+		if current[key] != value {
+			changed[key] = value
+		}
+	}
+	return tablePropertyFeatures(changed)
+}
+
+// tablePropertyFeatures returns the table features required by the table properties
+func tablePropertyFeatures(properties map[string]string) []string {
+	var features []string
+	if properties[ROW_TRACKING_PROPERTY] == "true" {
+		features = append(features, ROW_TRACKING_FEATURE)
+	}
+	if properties[CHANGE_DATA_FEED_PROPERTY] == "true" {
+		features = append(features, CHANGE_DATA_FEED_FEATURE)
+	}
+	return features
+}
+
+// protocol returns the protocol of the table state
+func (tableState *DeltaTableState) protocol() Protocol {
+	return Protocol{
+		MinReaderVersion: DeltaDataTypeInt(tableState.MinReaderVersion),
+		MinWriterVersion: DeltaDataTypeInt(tableState.MinWriterVersion),
+		ReaderFeatures:   tableState.ReaderFeatures,
+		WriterFeatures:   tableState.WriterFeatures,
+	}
+}
+
+// upgradeProtocol returns the protocol with the table features, and the features they depend on, added.
+// Features the protocol already has, listed or enabled by its legacy writer version, are skipped; only the missing
+// features must be supported by delta-go.
+// Protocols with writer version 1 or 2 are upgraded to writer version 7, listing the features of their version,
+// and protocols with reader version 1 to reader version 3 when a feature readers must support is added.
+// Returns false if the protocol already has all the features.
+func upgradeProtocol(current Protocol, features ...string) (Protocol, bool, error) {
+	var missing []string
+	for _, feature := range features {
+		if !current.hasTableFeature(feature) {
+			missing = append(missing, feature)
+		}
+	}
+	if len(missing) == 0 {
+		return current, false, nil
+	}
+	for _, feature := range missing {
+		if _, ok := supportedWriterFeatures[feature]; !ok {
+			return current, false, errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("table feature %s", feature))
+		}
+	}
+	if err := current.Validate(); err != nil {
+		return current, false, err
+	}
+
+	upgraded := Protocol{
+		MinReaderVersion: current.MinReaderVersion,
+		MinWriterVersion: TABLE_FEATURES_MIN_WRITER_VERSION,
+		ReaderFeatures:   append([]string(nil), current.ReaderFeatures...),
+		WriterFeatures:   append([]string(nil), current.WriterFeatures...),
+	}
+	if current.MinWriterVersion == 2 {
+		upgraded.WriterFeatures = append(upgraded.WriterFeatures, legacyWriterFeatures...)
+	}
+	required, err := ProtocolForFeatures(missing...)
+	if err != nil {
+		return current, false, err
+	}
+	for _, feature := range required.WriterFeatures {
+		if !upgraded.HasWriterFeature(feature) {
+			upgraded.WriterFeatures = append(upgraded.WriterFeatures, feature)
+		}
+	}
+	for _, feature := range required.ReaderFeatures {
+		upgraded.MinReaderVersion = TABLE_FEATURES_MIN_READER_VERSION
+		if !upgraded.HasReaderFeature(feature) {
+			upgraded.ReaderFeatures = append(upgraded.ReaderFeatures, feature)
+		}
+	}
+	if err := upgraded.Validate(); err != nil {
+		return current, false, err
+	}
+	return upgraded, true, nil
+}
+
+// EnableFeature commits a Protocol action adding the table feature, and the features it depends on, to the
+// protocol of the loaded table state, and returns the committed version.
+// Tables with writer version 1 or 2 are upgraded to writer version 7, listing the features of their version.
+// The protocol is never downgraded; nothing is committed if the table already has the feature.
+func (table *DeltaTable) EnableFeature(name string) (state.DeltaDataTypeVersion, error) {
+	if table.State.Version < 0 {
+		return table.State.Version, ErrorNotATable
+	}
+	upgraded, changed, err := upgradeProtocol(table.State.protocol(), name)
+	if err != nil || !changed {
+		return table.State.Version, err
+	}
+
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(upgraded)
+	return transaction.Commit(UpgradeProtocol{NewProtocol: upgraded}, nil)
+}
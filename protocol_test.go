@@ -0,0 +1,173 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rivian/delta-go/lock/filelock"
+	"github.com/rivian/delta-go/state/filestate"
+	"github.com/rivian/delta-go/storage"
+	"github.com/rivian/delta-go/storage/filestore"
+)
+
+func TestProtocolValidate(t *testing.T) {
+	tests := []struct {
+		protocol Protocol
+		expected error
+	}{
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, nil},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{DOMAIN_METADATA_FEATURE}}, nil},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{ROW_TRACKING_FEATURE, DOMAIN_METADATA_FEATURE}}, nil},
+		{Protocol{MinReaderVersion: 3, MinWriterVersion: 7}, nil},
+		{Protocol{MinReaderVersion: 3, MinWriterVersion: 7, ReaderFeatures: []string{DELETION_VECTORS_FEATURE}, WriterFeatures: []string{DELETION_VECTORS_FEATURE}}, nil},
+		// Legacy features delta-go does not implement
+		{Protocol{MinReaderVersion: 2, MinWriterVersion: 5}, ErrorUnsupportedProtocol},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 4}, ErrorUnsupportedProtocol},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{"columnMapping"}}, ErrorUnsupportedProtocol},
+		// Inconsistent protocols
+		{Protocol{MinReaderVersion: 3, MinWriterVersion: 2}, ErrorInvalidProtocol},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 2, WriterFeatures: []string{DOMAIN_METADATA_FEATURE}}, ErrorInvalidProtocol},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 7, ReaderFeatures: []string{DOMAIN_METADATA_FEATURE}, WriterFeatures: []string{DOMAIN_METADATA_FEATURE}}, ErrorInvalidProtocol},
+		{Protocol{MinReaderVersion: 3, MinWriterVersion: 7, ReaderFeatures: []string{DOMAIN_METADATA_FEATURE}}, ErrorInvalidProtocol},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{ROW_TRACKING_FEATURE}}, ErrorInvalidProtocol},
+		{Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{DELETION_VECTORS_FEATURE}}, ErrorInvalidProtocol},
+	}
+	for _, test := range tests {
+		err := test.protocol.Validate()
+		if test.expected == nil && err != nil {
+			t.Errorf("%v: unexpected error %v", test.protocol, err)
+		}
+		if test.expected != nil && !errors.Is(err, test.expected) {
+			t.Errorf("%v: want %v, has %v", test.protocol, test.expected, err)
+		}
+	}
+}
+
+func TestProtocolForFeatures(t *testing.T) {
+	protocol, err := ProtocolForFeatures()
+	if err != nil || protocol.MinReaderVersion != 1 || protocol.MinWriterVersion != 2 {
+		t.Errorf("unexpected protocol %v %v", protocol, err)
+	}
+
+	protocol, err = ProtocolForFeatures(ROW_TRACKING_FEATURE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protocol.MinReaderVersion != 1 || protocol.MinWriterVersion != 7 || !reflect.DeepEqual(protocol.WriterFeatures, []string{DOMAIN_METADATA_FEATURE, ROW_TRACKING_FEATURE}) {
+		t.Errorf("unexpected protocol %v", protocol)
+	}
+	if err := protocol.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// Deletion vectors are also a reader feature
+	protocol, err = ProtocolForFeatures(DELETION_VECTORS_FEATURE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protocol.MinReaderVersion != 3 || !reflect.DeepEqual(protocol.ReaderFeatures, []string{DELETION_VECTORS_FEATURE}) || !reflect.DeepEqual(protocol.WriterFeatures, []string{DELETION_VECTORS_FEATURE}) {
+		t.Errorf("unexpected protocol %v", protocol)
+	}
+
+	_, err = ProtocolForFeatures("columnMapping")
+	if !errors.Is(err, ErrorUnsupportedProtocol) {
+		t.Errorf("want ErrorUnsupportedProtocol, has %v", err)
+	}
+}
+
+func TestCreateTableWithProtocol(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	rowTracking := map[string]string{ROW_TRACKING_PROPERTY: "true"}
+
+	// The protocol is derived from the table properties
+	store := filestore.New(storage.NewPath(t.TempDir()))
+	table, err := CreateTable(store, nil, nil, schema, nil, rowTracking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.State.RowTrackingEnabled() {
+		t.Error("the table should have the rowTracking feature")
+	}
+
+	store = filestore.New(storage.NewPath(t.TempDir()))
+	protocol := Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{DOMAIN_METADATA_FEATURE}}
+	table, err = CreateTableWithProtocol(store, nil, nil, schema, nil, nil, protocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.MinWriterVersion != 7 || !reflect.DeepEqual(table.State.WriterFeatures, []string{DOMAIN_METADATA_FEATURE}) {
+		t.Errorf("unexpected protocol %d %v", table.State.MinWriterVersion, table.State.WriterFeatures)
+	}
+
+	// The protocol must have the features required by the table properties
+	store = filestore.New(storage.NewPath(t.TempDir()))
+	_, err = CreateTableWithProtocol(store, nil, nil, schema, nil, rowTracking, protocol)
+	if !errors.Is(err, ErrorInvalidProtocol) {
+		t.Errorf("want ErrorInvalidProtocol, has %v", err)
+	}
+	_, err = CreateTableWithProtocol(store, nil, nil, schema, nil, nil, Protocol{MinReaderVersion: 2, MinWriterVersion: 5})
+	if !errors.Is(err, ErrorUnsupportedProtocol) {
+		t.Errorf("want ErrorUnsupportedProtocol, has %v", err)
+	}
+	if exists, _ := NewDeltaTable(store, nil, nil).Exists(); exists {
+		t.Error("no table should be created for an invalid protocol")
+	}
+}
+
+func TestEnableFeature(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := storage.NewPath(tmpDir)
+	store := filestore.New(tmpPath)
+	lock := filelock.New(tmpPath, "_delta_log/_commit.lock", filelock.LockOptions{})
+	stateStore := filestate.New(tmpPath, "_delta_log/_commit.state")
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	table, err := CreateTable(store, lock, stateStore, schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = table.EnableFeature("columnMapping")
+	if !errors.Is(err, ErrorUnsupportedProtocol) {
+		t.Errorf("want ErrorUnsupportedProtocol, has %v", err)
+	}
+
+	version, err := table.EnableFeature(ROW_TRACKING_FEATURE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("want version 1, has %d", version)
+	}
+	if !table.State.RowTrackingEnabled() {
+		t.Error("the table state should have the rowTracking feature")
+	}
+
+	// The legacy features of writer version 2 are listed after the upgrade
+	loaded, err := OpenTable(store, lock, stateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{APPEND_ONLY_FEATURE, INVARIANTS_FEATURE, DOMAIN_METADATA_FEATURE, ROW_TRACKING_FEATURE}
+	if loaded.State.MinReaderVersion != 1 || loaded.State.MinWriterVersion != 7 || !reflect.DeepEqual(loaded.State.WriterFeatures, expected) {
+		t.Errorf("unexpected protocol %d %d %v", loaded.State.MinReaderVersion, loaded.State.MinWriterVersion, loaded.State.WriterFeatures)
+	}
+
+	// Enabling a feature the table has commits nothing
+	version, err = loaded.EnableFeature(DOMAIN_METADATA_FEATURE)
+	if err != nil || version != 1 {
+		t.Errorf("want version 1, has %d %v", version, err)
+	}
+}
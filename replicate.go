@@ -0,0 +1,257 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+)
+
+var (
+	ErrorReplicaDiverged     error = errors.New("the replica has diverged from the source table")
+	ErrorReplicationNotValid error = errors.New("the replicated object does not match the source")
+)
+
+// The log file pointing to the latest checkpoint
+const LAST_CHECKPOINT_FILE = "_last_checkpoint"
+
+// replicatedVersion holds the log files of a single version of a table
+type replicatedVersion struct {
+	Commit      *storage.Path
+	Checkpoints []storage.Path
+}
+
+// ReplicateLog mirrors the log of the table in src to dst, starting at fromVersion.
+// Commits are copied in order with RenameIfNotExists, followed by the checkpoints of their version, and each
+// copy is read back and verified before the next version is copied.
+// Replication is resumable: versions already present in dst with the same content are skipped.
+// If dst has a version whose content differs from src, ErrorReplicaDiverged is returned and nothing more is copied.
+// The data files referenced by the log should be replicated first with ReplicateDataFiles so that the
+// replica never references missing files.
+// Returns the last version present in dst, or fromVersion - 1 if no version was replicated.
+func ReplicateLog(src storage.ObjectStore, dst storage.ObjectStore, fromVersion state.DeltaDataTypeVersion) (state.DeltaDataTypeVersion, error) {
+	srcTable := NewDeltaTable(src, nil, nil)
+	dstTable := NewDeltaTable(dst, nil, nil)
+	lastVersion := fromVersion - 1
+
+	versions, err := listReplicatedVersions(src)
+	if err != nil {
+		return lastVersion, err
+	}
+	var sorted []state.DeltaDataTypeVersion
+	for version := range versions {
+		if version >= fromVersion {
+			sorted = append(sorted, version)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, version := range sorted {
+		files := versions[version]
+		if files.Commit != nil {
+			data, err := src.Get(files.Commit)
+			if err != nil {
+				return lastVersion, err
+			}
+			err = replicateCommit(dstTable, version, data)
+			if err != nil {
+				return lastVersion, err
+			}
+			lastVersion = version
+		}
+		for i := range files.Checkpoints {
+			err := replicateObject(src, dst, &files.Checkpoints[i])
+			if err != nil {
+				return lastVersion, err
+			}
+		}
+	}
+
+	// The checkpoint pointer is copied last so that it never points to a checkpoint missing from the replica
+	lastCheckpoint := storage.PathFromIter([]string{srcTable.BaseCommitUri().Raw, LAST_CHECKPOINT_FILE})
+	if _, err := src.Head(&lastCheckpoint); err == nil {
+		data, err := src.Get(&lastCheckpoint)
+		if err != nil {
+			return lastVersion, err
+		}
+		// The pointer is replaced atomically, as readers of the replica may load it at any time
+		err = putObjectAtomic(dst, &lastCheckpoint, data)
+		if err != nil {
+			return lastVersion, err
+		}
+	}
+	return lastVersion, nil
+}
+
+// ReplicateOptions configures ReplicateDataFiles
+type ReplicateOptions struct {
+	// Skip the data files that are missing from the source, such as files vacuumed since they were added, instead
+	// of failing. The replica may then reference files that do not exist.
+	SkipMissingFiles bool
+}
+
+// ReplicateDataFiles copies the data files added by the commits of src starting at fromVersion to dst, with the
+// change data files and the deletion vector files of the commits.
+// Files already present in dst with the same size are skipped, so replication is resumable.
+// Absolute paths, such as those of shallow clones, are not part of the table and are not copied.
+// A file missing from the source fails the replication with ErrorObjectDoesNotExist, unless
+// options.SkipMissingFiles is set.
+// Returns the number of files copied.
+func ReplicateDataFiles(src storage.ObjectStore, dst storage.ObjectStore, fromVersion state.DeltaDataTypeVersion, options *ReplicateOptions) (int, error) {
+	if options == nil {
+		options = new(ReplicateOptions)
+	}
+	srcTable := NewDeltaTable(src, nil, nil)
+	versions, err := listReplicatedVersions(src)
+	if err != nil {
+		return 0, err
+	}
+
+	copied := 0
+	for version, files := range versions {
+		if version < fromVersion || files.Commit == nil {
+			continue
+		}
+		actions, err := srcTable.readLogEntry(files.Commit)
+		if err != nil {
+			return copied, err
+		}
+		for _, action := range actions {
+			paths, err := replicatedPaths(action)
+			if err != nil {
+				return copied, fmt.Errorf("version %d: %w", version, err)
+			}
+			for _, path := range paths {
+				location := storage.NewPath(path)
+				srcMeta, err := src.Head(location)
+				if errors.Is(err, storage.ErrorObjectDoesNotExist) && options.SkipMissingFiles {
+					continue
+				}
+				if err != nil {
+					return copied, fmt.Errorf("version %d: %w", version, err)
+				}
+				if dstMeta, err := dst.Head(location); err == nil && dstMeta.Size == srcMeta.Size {
+					continue
+				}
+				err = replicateObject(src, dst, location)
+				if err != nil {
+					return copied, err
+				}
+				copied++
+			}
+		}
+	}
+	return copied, nil
+}
+
+// replicatedPaths returns the keys of the files of the table referenced by an add or cdc action: the data file
+// and the deletion vector file. Absolute paths are not part of the table and are left out.
+func replicatedPaths(action Action) ([]string, error) {
+	var paths []string
+	switch action := action.(type) {
+	case Add:
+		paths = append(paths, unescapedDataPath(action.Path))
+		dvPath, ok, err := deletionVectorPath(action.DeletionVector())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			paths = append(paths, dvPath)
+		}
+	case Cdc:
+		paths = append(paths, unescapedDataPath(action.Path))
+	}
+	relative := paths[:0]
+	for _, path := range paths {
+		if !strings.Contains(path, "://") {
+			relative = append(relative, path)
+		}
+	}
+	return relative, nil
+}
+
+// replicateCommit writes a commit to the replica unless the replica already has the same commit
+func replicateCommit(dstTable *DeltaTable, version state.DeltaDataTypeVersion, data []byte) error {
+	err := dstTable.WriteCommitRaw(version, data)
+	if err != nil && !errors.Is(err, storage.ErrorVersionAlreadyExists) {
+		return err
+	}
+	// Whether the commit was just written or was already there, the replica must hold the source content.
+	// The raw objects are compared since the commit may be gzipped.
+	existing, err := dstTable.Store.Get(dstTable.CommitUriFromVersion(version))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(existing, data) {
+		return errors.Join(ErrorReplicaDiverged, fmt.Errorf("version %d differs", version))
+	}
+	return nil
+}
+
+// replicateObject copies an object from src to dst and verifies the copy
+func replicateObject(src storage.ObjectStore, dst storage.ObjectStore, location *storage.Path) error {
+	data, err := src.Get(location)
+	if err != nil {
+		return err
+	}
+	err = dst.Put(location, data)
+	if err != nil {
+		return err
+	}
+	meta, err := dst.Head(location)
+	if err != nil {
+		return err
+	}
+	if meta.Size != int64(len(data)) {
+		return errors.Join(ErrorReplicationNotValid, fmt.Errorf("%s has %d bytes, want %d", location.Raw, meta.Size, len(data)))
+	}
+	return nil
+}
+
+// listReplicatedVersions lists the commit and checkpoint files of the log by version
+func listReplicatedVersions(store storage.ObjectStore) (map[state.DeltaDataTypeVersion]*replicatedVersion, error) {
+	table := NewDeltaTable(store, nil, nil)
+	results, err := store.List(table.BaseCommitUri())
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[state.DeltaDataTypeVersion]*replicatedVersion)
+	getVersion := func(version state.DeltaDataTypeVersion) *replicatedVersion {
+		if _, ok := versions[version]; !ok {
+			versions[version] = new(replicatedVersion)
+		}
+		return versions[version]
+	}
+	for _, result := range results {
+		kind, v, err := classifyLogFile(result.Location.Raw)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case LogFileCommit:
+			getVersion(v).Commit = table.CommitUriFromVersion(v)
+		case LogFileCheckpoint:
+			checkpoint := storage.PathFromIter([]string{table.BaseCommitUri().Raw, result.Location.Base()})
+			version := getVersion(v)
+			version.Checkpoints = append(version.Checkpoints, checkpoint)
+		}
+	}
+	return versions, nil
+}
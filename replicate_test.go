@@ -0,0 +1,210 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/rivian/delta-go/storage/filestore"
+)
+
+// Helper function to set up a table with two commits writing data files, and a checkpoint
+func setupReplicatedTable(t *testing.T) (*DeltaTable, string) {
+	t.Helper()
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	for _, path := range []string{"part-1.snappy.parquet", "date=2023-01-01/part-2.snappy.parquet"} {
+		transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+		err := transaction.PutDataFile(storage.NewPath(path), []byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		transaction.AddAction(Add{Path: path, Size: 4, DataChange: true})
+		_, err = transaction.Commit(Write{Mode: Append}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(tmpDir, "_delta_log", "00000000000000000001.checkpoint.parquet"), []byte("checkpoint"), 0700)
+	os.WriteFile(filepath.Join(tmpDir, "_delta_log", LAST_CHECKPOINT_FILE), []byte(`{"version":1,"size":2}`), 0700)
+	return table, tmpDir
+}
+
+func TestReplicateLog(t *testing.T) {
+	table, _ := setupReplicatedTable(t)
+	replica, _, replicaDir := setupTest(t)
+
+	copied, err := ReplicateDataFiles(table.Store, replica.Store, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 2 {
+		t.Errorf("want 2 data files copied, has %d", copied)
+	}
+	lastVersion, err := ReplicateLog(table.Store, replica.Store, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastVersion != 2 {
+		t.Errorf("want last version 2, has %d", lastVersion)
+	}
+	for _, path := range []string{"_delta_log/00000000000000000001.checkpoint.parquet", "_delta_log/" + LAST_CHECKPOINT_FILE} {
+		if !fileExists(filepath.Join(replicaDir, path)) {
+			t.Errorf("%s should be replicated", path)
+		}
+	}
+
+	err = replica.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveFiles(t, replica, []string{"part-1.snappy.parquet", "date=2023-01-01/part-2.snappy.parquet"})
+
+	// Replicating again resumes from the replicated state without copying anything
+	copied, err = ReplicateDataFiles(table.Store, replica.Store, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 0 {
+		t.Errorf("want no data files copied, has %d", copied)
+	}
+	lastVersion, err = ReplicateLog(table.Store, replica.Store, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastVersion != 2 {
+		t.Errorf("want last version 2, has %d", lastVersion)
+	}
+}
+
+func TestReplicateLogFromVersion(t *testing.T) {
+	table, _ := setupReplicatedTable(t)
+	replicaDir := t.TempDir()
+	replicaStore := filestore.New(storage.NewPath(replicaDir))
+
+	lastVersion, err := ReplicateLog(table.Store, replicaStore, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastVersion != 2 {
+		t.Errorf("want last version 2, has %d", lastVersion)
+	}
+	for version, expected := range map[string]bool{"00000000000000000001.json": false, "00000000000000000002.json": true} {
+		if fileExists(filepath.Join(replicaDir, "_delta_log", version)) != expected {
+			t.Errorf("%s replicated should be %v", version, expected)
+		}
+	}
+}
+
+func TestReplicateLogDiverged(t *testing.T) {
+	table, _ := setupReplicatedTable(t)
+	replica, _, replicaDir := setupTest(t)
+	os.MkdirAll(filepath.Join(replicaDir, "_delta_log"), 0700)
+	os.WriteFile(filepath.Join(replicaDir, "_delta_log", "00000000000000000001.json"), []byte(`{"commitInfo":{}}`), 0700)
+
+	lastVersion, err := ReplicateLog(table.Store, replica.Store, 0)
+	if !errors.Is(err, ErrorReplicaDiverged) {
+		t.Errorf("want ErrorReplicaDiverged, has %v", err)
+	}
+	if lastVersion != 0 {
+		t.Errorf("want last version 0, has %d", lastVersion)
+	}
+	if fileExists(filepath.Join(replicaDir, "_delta_log", "00000000000000000002.json")) {
+		t.Error("versions after the divergence should not be replicated")
+	}
+}
+
+func TestReplicateGzipCommit(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(`{"commitInfo":{}}` + "\n"))
+	writer.Close()
+	os.MkdirAll(filepath.Join(tmpDir, "_delta_log"), 0700)
+	os.WriteFile(filepath.Join(tmpDir, "_delta_log", "00000000000000000000.json"), compressed.Bytes(), 0700)
+	replica, _, _ := setupTest(t)
+
+	// Replicating again compares the gzipped commits without reporting a divergence
+	for i := 0; i < 2; i++ {
+		lastVersion, err := ReplicateLog(table.Store, replica.Store, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastVersion != 0 {
+			t.Errorf("want last version 0, has %d", lastVersion)
+		}
+	}
+}
+
+func TestReplicateDataFilesEscapedPaths(t *testing.T) {
+	table, _, _ := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+	deletionVector := json.RawMessage(`{"storageType":"u","pathOrInlineDv":"ab^-aqEH.-t@S}K{vb[*k^","offset":1,"sizeInBytes":36,"cardinality":2}`)
+	dvPath := "ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin"
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	for _, path := range []string{"city=San Jose/part-1.snappy.parquet", dvPath} {
+		err := transaction.PutDataFile(storage.NewPath(path), []byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	transaction.AddAction(Add{Path: "city=San%20Jose/part-1.snappy.parquet", Size: 4, DataChange: true,
+		Extras: map[string]json.RawMessage{"deletionVector": deletionVector}})
+	_, err := transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, _, replicaDir := setupTest(t)
+
+	copied, err := ReplicateDataFiles(table.Store, replica.Store, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 2 {
+		t.Errorf("want 2 files copied, has %d", copied)
+	}
+	for _, path := range []string{"city=San Jose/part-1.snappy.parquet", dvPath} {
+		if !fileExists(filepath.Join(replicaDir, path)) {
+			t.Errorf("%s should be replicated", path)
+		}
+	}
+}
+
+func TestReplicateDataFilesMissing(t *testing.T) {
+	table, tmpDir := setupReplicatedTable(t)
+	os.Remove(filepath.Join(tmpDir, "part-1.snappy.parquet"))
+	replica, _, _ := setupTest(t)
+
+	_, err := ReplicateDataFiles(table.Store, replica.Store, 0, nil)
+	if !errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		t.Errorf("want ErrorObjectDoesNotExist, has %v", err)
+	}
+
+	// Versions are not replicated in order, so a fresh replica is used
+	replica, _, _ = setupTest(t)
+	options := &ReplicateOptions{SkipMissingFiles: true}
+	copied, err := ReplicateDataFiles(table.Store, replica.Store, 0, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 1 {
+		t.Errorf("want 1 data file copied, has %d", copied)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrorRowTrackingNotEnabled error = errors.New("the table does not support the rowTracking feature")
+)
+
+const (
+	// Writer version that lists the table features required to write in the protocol
+	TABLE_FEATURES_MIN_WRITER_VERSION = 7
+	// Writer feature assigning a stable row id and commit version to every row of the table
+	ROW_TRACKING_FEATURE = "rowTracking"
+)
+
+// HasWriterFeature returns true if the protocol requires writers to implement the given table feature
+func (protocol *Protocol) HasWriterFeature(feature string) bool {
+	if protocol.MinWriterVersion < TABLE_FEATURES_MIN_WRITER_VERSION {
+		return false
+	}
+	for _, writerFeature := range protocol.WriterFeatures {
+		if writerFeature == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// RowTrackingEnabled returns true if the table has the rowTracking writer feature
+func (tableState *DeltaTableState) RowTrackingEnabled() bool {
+	protocol := Protocol{MinWriterVersion: DeltaDataTypeInt(tableState.MinWriterVersion), WriterFeatures: tableState.WriterFeatures}
+	return protocol.HasWriterFeature(ROW_TRACKING_FEATURE)
+}
+
+// RowTrackingMetadata returns the base row id and default row commit version of the file.
+// It returns false if the table does not have the rowTracking feature or the file has not been assigned row ids.
+func (tableState *DeltaTableState) RowTrackingMetadata(add *Add) (baseRowId int64, defaultRowCommitVersion int64, ok bool) {
+	if !tableState.RowTrackingEnabled() || add.BaseRowId == nil || add.DefaultRowCommitVersion == nil {
+		return 0, 0, false
+	}
+	return *add.BaseRowId, *add.DefaultRowCommitVersion, true
+}
+
+// checkRowTracking rejects add actions with row tracking fields when neither the table nor a protocol action
+// of the transaction has the rowTracking feature
+func (transaction *DeltaTransaction) checkRowTracking() error {
+	if transaction.DeltaTable.State.RowTrackingEnabled() {
+		return nil
+	}
+	for _, action := range transaction.Actions {
+		if protocol, ok := action.(Protocol); ok && protocol.HasWriterFeature(ROW_TRACKING_FEATURE) {
+			return nil
+		}
+	}
+	for _, action := range transaction.Actions {
+		if add, ok := action.(Add); ok && (add.BaseRowId != nil || add.DefaultRowCommitVersion != nil) {
+			return errors.Join(ErrorRowTrackingNotEnabled, fmt.Errorf("add %s has row tracking fields", add.Path))
+		}
+	}
+	return nil
+}
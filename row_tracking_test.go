@@ -0,0 +1,73 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRowTracking(t *testing.T) {
+	baseRowId, commitVersion := int64(100), int64(1)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	add := Add{Path: "part-0.snappy.parquet", Size: 1, BaseRowId: &baseRowId, DefaultRowCommitVersion: &commitVersion}
+
+	// Row tracking fields are rejected without the rowTracking feature
+	table, _, _ := setupTest(t)
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{add})
+	if !errors.Is(err, ErrorRowTrackingNotEnabled) {
+		t.Errorf("want ErrorRowTrackingNotEnabled, has %v", err)
+	}
+
+	table, _, _ = setupTest(t)
+	protocol := Protocol{MinReaderVersion: 1, MinWriterVersion: 7, WriterFeatures: []string{"domainMetadata", ROW_TRACKING_FEATURE}}
+	err = table.Create(*metadata, protocol, CommitInfo{}, []Add{add})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.State.RowTrackingEnabled() {
+		t.Error("row tracking should be enabled")
+	}
+	loaded := table.State.Files[add.Path]
+	base, version, ok := table.State.RowTrackingMetadata(&loaded)
+	if !ok || base != baseRowId || version != commitVersion {
+		t.Errorf("unexpected row tracking metadata %d %d %v", base, version, ok)
+	}
+
+	// The fields survive a round trip through a checkpoint
+	var rows []checkpointRow
+	for _, action := range []Action{protocol, metadata.ToMetaData(), loaded} {
+		row, _ := newCheckpointRow(action)
+		rows = append(rows, row)
+	}
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(0), rows)
+	checkpointTable, err := OpenFromCheckpoint(table.Store, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded = checkpointTable.State.Files[add.Path]
+	base, version, ok = checkpointTable.State.RowTrackingMetadata(&loaded)
+	if !ok || base != baseRowId || version != commitVersion {
+		t.Errorf("unexpected row tracking metadata from checkpoint %d %d %v", base, version, ok)
+	}
+
+	// The feature is only listed in the protocol with writer version 7
+	if (&Protocol{MinWriterVersion: 6, WriterFeatures: []string{ROW_TRACKING_FEATURE}}).HasWriterFeature(ROW_TRACKING_FEATURE) {
+		t.Error("writer features require writer version 7")
+	}
+}
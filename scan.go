@@ -0,0 +1,574 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivian/delta-go/state"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrorColumnNotFound        error = errors.New("column not found in schema")
+	ErrorAmbiguousColumn       error = errors.New("the column name matches several columns")
+	ErrorInvalidPredicate      error = errors.New("invalid predicate")
+	ErrorInvalidDeletionVector error = errors.New("invalid deletion vector")
+)
+
+const (
+	// Table property setting the column mapping mode: none, name or id
+	COLUMN_MAPPING_MODE_PROPERTY = "delta.columnMapping.mode"
+	// Key of the column metadata holding the physical name of a column when column mapping is enabled
+	COLUMN_MAPPING_PHYSICAL_NAME_KEY = "delta.columnMapping.physicalName"
+)
+
+// Predicate is a filter on the rows of a table, used by Scan to skip the files that cannot contain a matching row.
+// It is one of Comparison, IsNull, IsNotNull, And and Or.
+type Predicate interface {
+	predicate()
+}
+
+// The operator of a Comparison
+type ComparisonOperator string
+
+const (
+	Equal              ComparisonOperator = "="
+	NotEqual           ComparisonOperator = "!="
+	LessThan           ComparisonOperator = "<"
+	LessThanOrEqual    ComparisonOperator = "<="
+	GreaterThan        ComparisonOperator = ">"
+	GreaterThanOrEqual ComparisonOperator = ">="
+)
+
+// Comparison compares a column with a value, which has the Go type of the column as in a Row, or is a Decimal for
+// decimal columns. Like in SQL, null column values never match a comparison.
+// Nested columns are named by their dot separated path, e.g. event.timestamp.
+type Comparison struct {
+	Column   string
+	Operator ComparisonOperator
+	Value    any
+}
+
+// IsNull matches the rows where the column is null
+type IsNull struct {
+	Column string
+}
+
+// IsNotNull matches the rows where the column is not null
+type IsNotNull struct {
+	Column string
+}
+
+// And matches the rows matching all of the predicates
+type And []Predicate
+
+// Or matches the rows matching any of the predicates
+type Or []Predicate
+
+func (Comparison) predicate() {}
+func (IsNull) predicate()     {}
+func (IsNotNull) predicate()  {}
+func (And) predicate()        {}
+func (Or) predicate()         {}
+
+// DeletionVectorDescriptor locates the deletion vector of a data file, marking the deleted rows of the file
+// https://github.com/delta-io/delta/blob/master/PROTOCOL.md#deletion-vector-descriptor-schema
+type DeletionVectorDescriptor struct {
+	// How the deletion vector is stored: u (relative path), i (inline) or p (absolute path)
+	StorageType string `json:"storageType"`
+	// The path of the file holding the deletion vector or the inline deletion vector, depending on StorageType
+	PathOrInlineDv string `json:"pathOrInlineDv"`
+	// Start of the deletion vector in the file, when stored in a file
+	Offset *int32 `json:"offset,omitempty"`
+	// Size of the serialized deletion vector in bytes
+	SizeInBytes int32 `json:"sizeInBytes"`
+	// Number of rows the deletion vector marks as deleted
+	Cardinality int64 `json:"cardinality"`
+}
+
+// DeletionVector returns the deletion vector of the file, or nil if the file has none
+func (add *Add) DeletionVector() (*DeletionVectorDescriptor, error) {
+	data, ok := add.Extras["deletionVector"]
+	if !ok || string(data) == "null" {
+		return nil, nil
+	}
+	deletionVector := new(DeletionVectorDescriptor)
+	if err := json.Unmarshal(data, deletionVector); err != nil {
+		return nil, errors.Join(ErrorInvalidDeletionVector, fmt.Errorf("%s: %w", add.Path, err))
+	}
+	return deletionVector, nil
+}
+
+// ScanColumn is a column projected by a ScanPlan
+type ScanColumn struct {
+	// Logical name of the column in the table schema
+	Name string
+	// Name of the column in the data files, statistics and partition values, which differs from Name when
+	// column mapping is enabled
+	PhysicalName string
+	Type         SchemaDataType
+	// Partition columns are not stored in the data files, their value is in the PartitionValues of each file
+	IsPartition bool
+}
+
+// ScanFile is a data file to read for a ScanPlan
+type ScanFile struct {
+	Add Add
+	// The partition values of the file parsed as by TypedPartitionValues, keyed by the logical column name
+	PartitionValues map[string]any
+	// The deletion vector of the rows to skip, nil if the file has none
+	DeletionVector *DeletionVectorDescriptor
+}
+
+// ScanPlan lists the files and columns a query engine reads to scan the table
+type ScanPlan struct {
+	Version state.DeltaDataTypeVersion
+	Columns []ScanColumn
+	// The files that might contain rows matching the predicate, ordered by path
+	Files []ScanFile
+	// The number of active files of the table
+	TotalFiles int
+	// The number of files skipped because of their partition values
+	PartitionPrunedFiles int
+	// The number of files skipped because of their statistics
+	StatsPrunedFiles int
+}
+
+// Scan plans a scan of the loaded table state: the columns of the projection are resolved to their physical
+// names, and the files that cannot contain a row matching the predicate are skipped, first based on their partition
+// values and then on their statistics. The returned files may still contain rows not matching the predicate, which
+// the query engine must filter. All columns are projected if the projection is empty, and no file is skipped if
+// the predicate is nil. Column names are matched case-insensitively, see ScanWithOptions.
+func (table *DeltaTable) Scan(projection []string, predicate Predicate) (ScanPlan, error) {
+	return table.ScanWithOptions(projection, predicate, nil)
+}
+
+// ScanOptions configures ScanWithOptions
+type ScanOptions struct {
+	// Match the column names of the projection and the predicate with the schema case-sensitively. By default, as in
+	// Spark, a column whose name only differs in case is matched when no column has the exact name, and
+	// ErrorAmbiguousColumn is returned if several columns match.
+	CaseSensitive bool
+}
+
+// NewScanOptions returns the default scan options, matching column names case-insensitively
+func NewScanOptions() *ScanOptions {
+	return &ScanOptions{}
+}
+
+// ScanWithOptions plans a scan of the loaded table state as Scan does. The columns of the plan have the names of the
+// schema, whatever the case of the projection.
+func (table *DeltaTable) ScanWithOptions(projection []string, predicate Predicate, options *ScanOptions) (ScanPlan, error) {
+	if options == nil {
+		options = NewScanOptions()
+	}
+	metadata := table.State.CurrentMetadata
+	columnMappingMode, _ := table.State.Property(COLUMN_MAPPING_MODE_PROPERTY)
+	scanner := &scanner{
+		schema:           metadata.Schema,
+		partitionColumns: metadata.PartitionColumns,
+		columnMapping:    columnMappingMode == "name" || columnMappingMode == "id",
+		caseSensitive:    options.CaseSensitive,
+	}
+	plan := ScanPlan{Version: table.State.Version, Columns: []ScanColumn{}, Files: []ScanFile{}, TotalFiles: len(table.State.Files)}
+
+	if len(projection) == 0 {
+		for _, field := range metadata.Schema.Fields {
+			projection = append(projection, field.Name)
+		}
+	}
+	for _, name := range projection {
+		column, err := scanner.resolve(name)
+		if err != nil {
+			return ScanPlan{}, err
+		}
+		plan.Columns = append(plan.Columns, column)
+	}
+
+	bound, err := scanner.bind(predicate)
+	if err != nil {
+		return ScanPlan{}, err
+	}
+
+	paths := make([]string, 0, len(table.State.Files))
+	for path := range table.State.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		add := table.State.Files[path]
+		file, err := scanner.newScanFile(&add)
+		if err != nil {
+			return ScanPlan{}, err
+		}
+		if bound != nil {
+			if match, err := file.mightMatch(bound, false); err != nil {
+				return ScanPlan{}, err
+			} else if !match {
+				plan.PartitionPrunedFiles++
+				continue
+			}
+			if match, err := file.mightMatch(bound, true); err != nil {
+				return ScanPlan{}, err
+			} else if !match {
+				plan.StatsPrunedFiles++
+				continue
+			}
+		}
+		deletionVector, err := add.DeletionVector()
+		if err != nil {
+			return ScanPlan{}, err
+		}
+		plan.Files = append(plan.Files, ScanFile{Add: add, PartitionValues: file.partitionValues, DeletionVector: deletionVector})
+	}
+	return plan, nil
+}
+
+// DeletedRows are the indexes of the rows of a data file marked as deleted by its deletion vector, in ascending order
+type DeletedRows []uint64
+
+// Contains returns true if the row at the index is deleted
+func (rows DeletedRows) Contains(row uint64) bool {
+	i := sort.Search(len(rows), func(i int) bool { return rows[i] >= row })
+	return i < len(rows) && rows[i] == row
+}
+
+// ResolvedScanFile is a file of a ScanPlan with its location and the rows of its deletion vector, as passed to the
+// callback of WalkScanFiles
+type ResolvedScanFile struct {
+	ScanFile
+	// The absolute URI of the data file
+	Uri string
+	// The rows the query engine must skip when reading the data file, nil if the file has no deletion vector
+	DeletedRows DeletedRows
+}
+
+// WalkScanFiles calls fn for each file of the scan plan in order, with its deletion vector read, so that query
+// engines get the rows to skip in each data file along with its location. Deletion vectors are read one file at a
+// time, as fn is called.
+// The walk stops when fn returns an error; if the error is ErrorStopWalk, WalkScanFiles returns nil.
+// Deletion vector files are read from the data store, see ReadDeletionVector.
+func (table *DeltaTable) WalkScanFiles(plan ScanPlan, fn func(file ResolvedScanFile) error) error {
+	return table.WalkScanFilesWithContext(context.Background(), plan, fn)
+}
+
+// WalkScanFilesWithContext is WalkScanFiles stopping with the error of the context once it is done
+func (table *DeltaTable) WalkScanFilesWithContext(ctx context.Context, plan ScanPlan, fn func(file ResolvedScanFile) error) error {
+	rootURI := table.dataStore().RootURI()
+	for _, file := range plan.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resolved := ResolvedScanFile{ScanFile: file, Uri: absolutePath(rootURI, unescapedDataPath(file.Add.Path))}
+		if file.DeletionVector != nil {
+			rows, err := table.ReadDeletionVector(file.DeletionVector)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file.Add.Path, err)
+			}
+			resolved.DeletedRows = rows
+		}
+		if err := fn(resolved); err != nil {
+			if errors.Is(err, ErrorStopWalk) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// scanner resolves the columns of a scan against the table schema
+type scanner struct {
+	schema           SchemaTypeStruct
+	partitionColumns []string
+	columnMapping    bool
+	caseSensitive    bool
+}
+
+// physicalName returns the name of the field in the data files
+func (scanner *scanner) physicalName(field SchemaField) string {
+	if scanner.columnMapping {
+		if name, ok := field.Metadata[COLUMN_MAPPING_PHYSICAL_NAME_KEY].(string); ok && name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// resolve finds the column at the dot separated path of the schema, returning it with the names of the schema.
+// The physical name of a nested column is the dot separated path of the physical names.
+func (scanner *scanner) resolve(path string) (ScanColumn, error) {
+	fields := scanner.schema.Fields
+	var logicalNames, physicalNames []string
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		index, ambiguous := fieldIndex(fields, name, scanner.caseSensitive)
+		if ambiguous {
+			return ScanColumn{}, errors.Join(ErrorAmbiguousColumn, fmt.Errorf("column %s", path))
+		}
+		if index < 0 || (i < len(names)-1 && fields[index].Type != Struct) {
+			return ScanColumn{}, errors.Join(ErrorColumnNotFound, fmt.Errorf("column %s", path))
+		}
+		field := fields[index]
+		logicalNames = append(logicalNames, field.Name)
+		physicalNames = append(physicalNames, scanner.physicalName(field))
+		if i == len(names)-1 {
+			return ScanColumn{
+				Name:         strings.Join(logicalNames, "."),
+				PhysicalName: strings.Join(physicalNames, "."),
+				Type:         field.Type,
+				IsPartition:  len(names) == 1 && slices.Contains(scanner.partitionColumns, field.Name),
+			}, nil
+		}
+		fields = field.Fields
+	}
+	return ScanColumn{}, errors.Join(ErrorColumnNotFound, fmt.Errorf("column %s", path))
+}
+
+// boundComparison, boundIsNull and boundIsNotNull are predicates on a resolved column
+type boundComparison struct {
+	column   ScanColumn
+	operator ComparisonOperator
+	value    any
+}
+
+type boundIsNull struct {
+	column ScanColumn
+	isNull bool
+}
+
+// bind resolves the columns of the predicate and checks the type of its values
+func (scanner *scanner) bind(predicate Predicate) (any, error) {
+	switch predicate := predicate.(type) {
+	case nil:
+		return nil, nil
+	case Comparison:
+		column, err := scanner.resolve(predicate.Column)
+		if err != nil {
+			return nil, err
+		}
+		switch predicate.Operator {
+		case Equal, NotEqual, LessThan, LessThanOrEqual, GreaterThan, GreaterThanOrEqual:
+		default:
+			return nil, errors.Join(ErrorInvalidPredicate, fmt.Errorf("operator %q", predicate.Operator))
+		}
+		value, err := comparisonValue(column, predicate.Value)
+		if err != nil {
+			return nil, errors.Join(ErrorInvalidPredicate, err)
+		}
+		return boundComparison{column: column, operator: predicate.Operator, value: value}, nil
+	case IsNull:
+		column, err := scanner.resolve(predicate.Column)
+		return boundIsNull{column: column, isNull: true}, err
+	case IsNotNull:
+		column, err := scanner.resolve(predicate.Column)
+		return boundIsNull{column: column, isNull: false}, err
+	case And:
+		bound, err := scanner.bindAll(predicate)
+		return boundAnd(bound), err
+	case Or:
+		bound, err := scanner.bindAll(predicate)
+		return boundOr(bound), err
+	default:
+		return nil, errors.Join(ErrorInvalidPredicate, fmt.Errorf("unsupported predicate %T", predicate))
+	}
+}
+
+// bindAll binds each of the predicates
+func (scanner *scanner) bindAll(predicates []Predicate) ([]any, error) {
+	bound := make([]any, 0, len(predicates))
+	for _, predicate := range predicates {
+		b, err := scanner.bind(predicate)
+		if err != nil {
+			return nil, err
+		}
+		bound = append(bound, b)
+	}
+	return bound, nil
+}
+
+type boundAnd []any
+type boundOr []any
+
+// comparisonValue checks that the value of a comparison has the Go type of the column, converting ints
+func comparisonValue(column ScanColumn, value any) (any, error) {
+	if value == nil {
+		return nil, fmt.Errorf("column %s is compared with null, use IsNull", column.Name)
+	}
+	if _, _, ok := column.Type.DecimalPrecisionScale(); ok {
+		if _, ok := value.(Decimal); !ok {
+			return nil, fmt.Errorf("column %s of type %s has a %T value", column.Name, column.Type, value)
+		}
+		return value, nil
+	}
+	return columnValue(SchemaField{Name: column.Name, Type: column.Type}, value)
+}
+
+// scanFile holds the partition values and statistics of a file being pruned
+type scanFile struct {
+	add             *Add
+	partitionValues map[string]any
+	statsParsed     bool
+	stats           *Stats
+	minValues       map[string]any
+	maxValues       map[string]any
+	nullCount       map[string]int64
+}
+
+func (scanner *scanner) newScanFile(add *Add) (*scanFile, error) {
+	file := &scanFile{add: add, partitionValues: make(map[string]any, len(scanner.partitionColumns))}
+	for _, column := range scanner.partitionColumns {
+		resolved, err := scanner.resolve(column)
+		if err != nil {
+			return nil, errors.Join(ErrorPartitionColumnNotFound, err)
+		}
+		value, err := parsePartitionValue(resolved.Type, add.PartitionValues[resolved.PhysicalName])
+		if err != nil {
+			return nil, errors.Join(ErrorInvalidPartitionValue, fmt.Errorf("%s column %s value %q", add.Path, column, add.PartitionValues[resolved.PhysicalName]), err)
+		}
+		file.partitionValues[resolved.Name] = value
+	}
+	return file, nil
+}
+
+// parseStats parses the statistics of the file the first time they are needed.
+// Files without statistics, or with statistics that cannot be parsed, are never skipped based on them.
+func (file *scanFile) parseStats() {
+	if file.statsParsed {
+		return
+	}
+	file.statsParsed = true
+	stats, err := file.add.ParseStats()
+	if err != nil || stats == nil {
+		return
+	}
+	file.stats = stats
+	file.minValues = stats.LeafMinValues()
+	file.maxValues = stats.LeafMaxValues()
+	file.nullCount = stats.LeafNullCount()
+}
+
+// mightMatch returns false if no row of the file can match the predicate, based on the partition values of the
+// file and, if useStats is set, on its statistics
+func (file *scanFile) mightMatch(predicate any, useStats bool) (bool, error) {
+	switch predicate := predicate.(type) {
+	case boundAnd:
+		for _, p := range predicate {
+			if match, err := file.mightMatch(p, useStats); err != nil || !match {
+				return false, err
+			}
+		}
+		return true, nil
+	case boundOr:
+		for _, p := range predicate {
+			if match, err := file.mightMatch(p, useStats); err != nil || match {
+				return match, err
+			}
+		}
+		return len(predicate) == 0, nil
+	case boundIsNull:
+		if predicate.column.IsPartition {
+			return (file.partitionValues[predicate.column.Name] == nil) == predicate.isNull, nil
+		}
+		if !useStats {
+			return true, nil
+		}
+		file.parseStats()
+		nullCount, ok := file.nullCount[predicate.column.PhysicalName]
+		if file.stats == nil || !ok {
+			return true, nil
+		}
+		if predicate.isNull {
+			return nullCount > 0, nil
+		}
+		return file.stats.NumRecords == 0 || nullCount < file.stats.NumRecords, nil
+	case boundComparison:
+		if predicate.column.IsPartition {
+			value := file.partitionValues[predicate.column.Name]
+			if value == nil {
+				return false, nil
+			}
+			cmp := compareTypedValues(value, predicate.value)
+			return compareMatches(predicate.operator, cmp, cmp), nil
+		}
+		if !useStats {
+			return true, nil
+		}
+		return file.statsMightMatch(predicate)
+	default:
+		return true, nil
+	}
+}
+
+// statsMightMatch returns false if the min and max values of the column show that no row of the file matches
+func (file *scanFile) statsMightMatch(predicate boundComparison) (bool, error) {
+	file.parseStats()
+	if file.stats == nil {
+		return true, nil
+	}
+	path := predicate.column.PhysicalName
+	if nullCount, ok := file.nullCount[path]; ok && file.stats.NumRecords > 0 && nullCount == file.stats.NumRecords {
+		// All the values are null
+		return false, nil
+	}
+	if !file.stats.TightBounds {
+		// Wide bounds are not exact, so they are not used to skip the file
+		return true, nil
+	}
+	rawMin, hasMin := file.minValues[path]
+	rawMax, hasMax := file.maxValues[path]
+	if !hasMin || !hasMax || rawMin == nil || rawMax == nil {
+		return true, nil
+	}
+	min, err := parseStatsValue(predicate.column.Type, rawMin)
+	if err != nil {
+		return true, nil
+	}
+	max, err := parseStatsValue(predicate.column.Type, rawMax)
+	if err != nil {
+		return true, nil
+	}
+	if t, ok := max.(time.Time); ok && predicate.column.Type == Timestamp {
+		// Timestamp statistics are truncated to milliseconds
+		max = t.Add(time.Millisecond - time.Nanosecond)
+	}
+	return compareMatches(predicate.operator, compareTypedValues(min, predicate.value), compareTypedValues(max, predicate.value)), nil
+}
+
+// compareMatches returns true if some value between a min and a max might satisfy the operator, given how the min
+// and the max compare with the value of the comparison. For a single value, the min and the max are the same.
+func compareMatches(operator ComparisonOperator, minCmp int, maxCmp int) bool {
+	switch operator {
+	case Equal:
+		return minCmp <= 0 && maxCmp >= 0
+	case NotEqual:
+		return minCmp != 0 || maxCmp != 0
+	case LessThan:
+		return minCmp < 0
+	case LessThanOrEqual:
+		return minCmp <= 0
+	case GreaterThan:
+		return maxCmp > 0
+	case GreaterThanOrEqual:
+		return maxCmp >= 0
+	default:
+		return true
+	}
+}
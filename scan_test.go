@@ -0,0 +1,319 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/rivian/delta-go/storage/filestore"
+)
+
+// Helper function to set up a table state partitioned by date with statistics on id, name and ts
+func setupScanTable(t *testing.T, configuration map[string]string, fields []SchemaField, files []Add) *DeltaTable {
+	t.Helper()
+	table := NewDeltaTable(nil, nil, nil)
+	table.State = *NewDeltaTableState(3)
+	table.State.CurrentMetadata = *NewDeltaTableMetaData("", "", new(Format).Default(), SchemaTypeStruct{Fields: fields}, []string{"date"}, configuration)
+	for _, add := range files {
+		table.State.Files[add.Path] = add
+	}
+	return table
+}
+
+func scanPaths(plan ScanPlan) []string {
+	paths := []string{}
+	for _, file := range plan.Files {
+		paths = append(paths, file.Add.Path)
+	}
+	return paths
+}
+
+func TestScan(t *testing.T) {
+	fields := []SchemaField{{Name: "id", Type: Long}, {Name: "name", Type: String, Nullable: true}, {Name: "ts", Type: Timestamp}, {Name: "date", Type: Date}}
+	table := setupScanTable(t, nil, fields, []Add{
+		{Path: "date=2023-01-01/a.parquet", PartitionValues: map[string]string{"date": "2023-01-01"},
+			Stats: `{"numRecords":2,"minValues":{"id":1,"name":"a","ts":"2023-01-01T01:00:00.000Z"},"maxValues":{"id":10,"name":"c","ts":"2023-01-01T02:00:00.000Z"},"nullCount":{"id":0,"name":0,"ts":0}}`},
+		{Path: "date=2023-01-01/b.parquet", PartitionValues: map[string]string{"date": "2023-01-01"},
+			Stats: `{"numRecords":2,"minValues":{"id":11,"ts":"2023-01-01T03:00:00.000Z"},"maxValues":{"id":20,"ts":"2023-01-01T04:00:00.000Z"},"nullCount":{"id":0,"name":2,"ts":0}}`},
+		{Path: "date=2023-01-02/c.parquet", PartitionValues: map[string]string{"date": "2023-01-02"},
+			Stats: `{"numRecords":2,"minValues":{"id":5,"name":"b"},"maxValues":{"id":15,"name":"z"},"nullCount":{"id":0,"name":1}}`},
+		{Path: "date=__HIVE_DEFAULT_PARTITION__/d.parquet", PartitionValues: map[string]string{"date": ""}},
+	})
+	day1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	plan, err := table.Scan([]string{"id", "date"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Files) != 4 || plan.TotalFiles != 4 || plan.Version != 3 {
+		t.Errorf("want all 4 files of version 3, has %v", scanPaths(plan))
+	}
+	if !reflect.DeepEqual(plan.Columns, []ScanColumn{{Name: "id", PhysicalName: "id", Type: Long}, {Name: "date", PhysicalName: "date", Type: Date, IsPartition: true}}) {
+		t.Errorf("unexpected columns %v", plan.Columns)
+	}
+	if plan.Files[0].PartitionValues["date"] != day1 || plan.Files[3].PartitionValues["date"] != nil {
+		t.Errorf("unexpected partition values %v %v", plan.Files[0].PartitionValues, plan.Files[3].PartitionValues)
+	}
+
+	for _, test := range []struct {
+		predicate       Predicate
+		want            []string
+		partitionPruned int
+		statsPruned     int
+	}{
+		{Comparison{Column: "date", Operator: Equal, Value: day1}, []string{"date=2023-01-01/a.parquet", "date=2023-01-01/b.parquet"}, 2, 0},
+		{Comparison{Column: "id", Operator: GreaterThan, Value: 10}, []string{"date=2023-01-01/b.parquet", "date=2023-01-02/c.parquet", "date=__HIVE_DEFAULT_PARTITION__/d.parquet"}, 0, 1},
+		{And{Comparison{Column: "date", Operator: Equal, Value: day1}, Comparison{Column: "id", Operator: LessThanOrEqual, Value: int64(10)}}, []string{"date=2023-01-01/a.parquet"}, 2, 1},
+		{Or{Comparison{Column: "id", Operator: Equal, Value: int64(20)}, IsNull{Column: "date"}}, []string{"date=2023-01-01/b.parquet", "date=__HIVE_DEFAULT_PARTITION__/d.parquet"}, 0, 2},
+		{Comparison{Column: "name", Operator: Equal, Value: "b"}, []string{"date=2023-01-01/a.parquet", "date=2023-01-02/c.parquet", "date=__HIVE_DEFAULT_PARTITION__/d.parquet"}, 0, 1},
+		{IsNull{Column: "name"}, []string{"date=2023-01-01/b.parquet", "date=2023-01-02/c.parquet", "date=__HIVE_DEFAULT_PARTITION__/d.parquet"}, 0, 1},
+		{IsNotNull{Column: "name"}, []string{"date=2023-01-01/a.parquet", "date=2023-01-02/c.parquet", "date=__HIVE_DEFAULT_PARTITION__/d.parquet"}, 0, 1},
+		// Timestamp statistics are truncated to milliseconds
+		{Comparison{Column: "ts", Operator: Equal, Value: day1.Add(2*time.Hour + time.Microsecond)}, []string{"date=2023-01-01/a.parquet", "date=2023-01-02/c.parquet", "date=__HIVE_DEFAULT_PARTITION__/d.parquet"}, 0, 1},
+	} {
+		plan, err := table.Scan(nil, test.predicate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(scanPaths(plan), test.want) || plan.PartitionPrunedFiles != test.partitionPruned || plan.StatsPrunedFiles != test.statsPruned {
+			t.Errorf("%v: want %v with %d partition and %d stats pruned files, has %v with %d and %d", test.predicate, test.want, test.partitionPruned, test.statsPruned, scanPaths(plan), plan.PartitionPrunedFiles, plan.StatsPrunedFiles)
+		}
+	}
+}
+
+func TestScanExactStats(t *testing.T) {
+	fields := []SchemaField{{Name: "id", Type: Long}, {Name: "price", Type: DecimalType(38, 2)}, {Name: "date", Type: Date}}
+	table := setupScanTable(t, nil, fields, []Add{
+		{Path: "a.parquet", Stats: `{"numRecords":1,"minValues":{"id":9223372036854775807,"price":123456789012345678901234567890123456.78},` +
+			`"maxValues":{"id":9223372036854775807,"price":123456789012345678901234567890123456.78}}`},
+		{Path: "b.parquet", Stats: `{"numRecords":1,"minValues":{"id":9223372036854775806,"price":1.5E+1},"maxValues":{"id":9223372036854775806,"price":1.5E+1}}`},
+	})
+	price, _ := ParseDecimal("123456789012345678901234567890123456.78", 38, 2)
+	fifteen, _ := ParseDecimal("15", 38, 2)
+
+	// Rounding the statistics through float64 would prune the files that hold the values
+	for _, test := range []struct {
+		predicate Predicate
+		want      []string
+	}{
+		{Comparison{Column: "id", Operator: Equal, Value: int64(math.MaxInt64)}, []string{"a.parquet"}},
+		{Comparison{Column: "id", Operator: Equal, Value: int64(math.MaxInt64 - 1)}, []string{"b.parquet"}},
+		{Comparison{Column: "price", Operator: Equal, Value: price}, []string{"a.parquet"}},
+		{Comparison{Column: "price", Operator: Equal, Value: fifteen}, []string{"b.parquet"}},
+	} {
+		plan, err := table.Scan(nil, test.predicate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(scanPaths(plan), test.want) {
+			t.Errorf("%v: want %v, has %v", test.predicate, test.want, scanPaths(plan))
+		}
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	table := setupScanTable(t, nil, []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: Date}}, nil)
+	if _, err := table.Scan([]string{"missing"}, nil); !errors.Is(err, ErrorColumnNotFound) {
+		t.Errorf("want ErrorColumnNotFound, has %v", err)
+	}
+	for _, predicate := range []Predicate{
+		Comparison{Column: "id", Operator: Equal, Value: "1"},
+		Comparison{Column: "id", Operator: Equal, Value: nil},
+		Comparison{Column: "id", Operator: "~", Value: int64(1)},
+	} {
+		if _, err := table.Scan(nil, predicate); !errors.Is(err, ErrorInvalidPredicate) {
+			t.Errorf("%v: want ErrorInvalidPredicate, has %v", predicate, err)
+		}
+	}
+}
+
+func TestScanCaseSensitivity(t *testing.T) {
+	fields := []SchemaField{{Name: "id", Type: Long}, {Name: "ID", Type: Long}, {Name: "Name", Type: String}, {Name: "date", Type: Date}}
+	table := setupScanTable(t, nil, fields, []Add{
+		{Path: "date=2023-01-01/a.parquet", PartitionValues: map[string]string{"date": "2023-01-01"},
+			Stats: `{"numRecords":1,"minValues":{"id":1,"ID":10,"Name":"a"},"maxValues":{"id":1,"ID":10,"Name":"a"},"nullCount":{"id":0,"ID":0,"Name":0}}`},
+		{Path: "date=2023-01-02/b.parquet", PartitionValues: map[string]string{"date": "2023-01-02"},
+			Stats: `{"numRecords":1,"minValues":{"id":2,"ID":20,"Name":"b"},"maxValues":{"id":2,"ID":20,"Name":"b"},"nullCount":{"id":0,"ID":0,"Name":0}}`},
+	})
+
+	// Names are matched case-insensitively by default, and the columns keep the case of the schema
+	plan, err := table.Scan([]string{"NAME", "Date", "ID"}, And{Comparison{Column: "name", Operator: Equal, Value: "b"}, IsNotNull{Column: "DATE"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScanColumn{{Name: "Name", PhysicalName: "Name", Type: String}, {Name: "date", PhysicalName: "date", Type: Date, IsPartition: true}, {Name: "ID", PhysicalName: "ID", Type: Long}}
+	if !reflect.DeepEqual(plan.Columns, expected) {
+		t.Errorf("want %v, has %v", expected, plan.Columns)
+	}
+	if !reflect.DeepEqual(scanPaths(plan), []string{"date=2023-01-02/b.parquet"}) || plan.Files[0].PartitionValues["date"] == nil {
+		t.Errorf("want b.parquet, has %v", scanPaths(plan))
+	}
+	// The columns differing only in case are matched by their exact name
+	plan, err = table.Scan(nil, Comparison{Column: "ID", Operator: Equal, Value: int64(10)})
+	if err != nil || !reflect.DeepEqual(scanPaths(plan), []string{"date=2023-01-01/a.parquet"}) {
+		t.Errorf("want a.parquet, has %v %v", scanPaths(plan), err)
+	}
+	if _, err := table.Scan([]string{"Id"}, nil); !errors.Is(err, ErrorAmbiguousColumn) {
+		t.Errorf("want ErrorAmbiguousColumn, has %v", err)
+	}
+
+	options := &ScanOptions{CaseSensitive: true}
+	if _, err := table.ScanWithOptions([]string{"name"}, nil, options); !errors.Is(err, ErrorColumnNotFound) {
+		t.Errorf("want ErrorColumnNotFound, has %v", err)
+	}
+	plan, err = table.ScanWithOptions([]string{"id"}, Comparison{Column: "id", Operator: Equal, Value: int64(2)}, options)
+	if err != nil || !reflect.DeepEqual(scanPaths(plan), []string{"date=2023-01-02/b.parquet"}) {
+		t.Errorf("want b.parquet, has %v %v", scanPaths(plan), err)
+	}
+}
+
+func TestScanColumnMapping(t *testing.T) {
+	physical := func(name string) map[string]any {
+		return map[string]any{COLUMN_MAPPING_PHYSICAL_NAME_KEY: name}
+	}
+	fields := []SchemaField{
+		{Name: "id", Type: Long, Metadata: physical("col-1")},
+		{Name: "event", Type: Struct, Metadata: physical("col-2"), Fields: []SchemaField{{Name: "kind", Type: String, Metadata: physical("col-3")}}},
+		{Name: "date", Type: Date, Metadata: physical("col-4")},
+	}
+	deletionVector := json.RawMessage(`{"storageType":"u","pathOrInlineDv":"ab^-aqEH.-t@S}K{vb[*k^","offset":4,"sizeInBytes":40,"cardinality":6}`)
+	table := setupScanTable(t, map[string]string{COLUMN_MAPPING_MODE_PROPERTY: "name"}, fields, []Add{
+		{Path: "a.parquet", PartitionValues: map[string]string{"col-4": "2023-01-01"},
+			Stats:  `{"numRecords":1,"minValues":{"col-1":1,"col-2":{"col-3":"click"}},"maxValues":{"col-1":1,"col-2":{"col-3":"click"}}}`,
+			Extras: map[string]json.RawMessage{"deletionVector": deletionVector}},
+		{Path: "b.parquet", PartitionValues: map[string]string{"col-4": "2023-01-02"},
+			Stats: `{"numRecords":1,"minValues":{"col-1":2,"col-2":{"col-3":"view"}},"maxValues":{"col-1":2,"col-2":{"col-3":"view"}}}`},
+	})
+
+	plan, err := table.Scan([]string{"event.kind", "date"}, Comparison{Column: "event.kind", Operator: Equal, Value: "click"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Columns[0].PhysicalName != "col-2.col-3" || plan.Columns[1].PhysicalName != "col-4" {
+		t.Errorf("unexpected columns %v", plan.Columns)
+	}
+	if len(plan.Files) != 1 || plan.Files[0].Add.Path != "a.parquet" || plan.Files[0].PartitionValues["date"] != time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC) {
+		t.Fatalf("want a.parquet, has %v", scanPaths(plan))
+	}
+	dv := plan.Files[0].DeletionVector
+	if dv == nil || dv.StorageType != "u" || *dv.Offset != 4 || dv.SizeInBytes != 40 || dv.Cardinality != 6 {
+		t.Errorf("unexpected deletion vector %+v", dv)
+	}
+
+	plan, err = table.Scan(nil, Comparison{Column: "date", Operator: GreaterThan, Value: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scanPaths(plan), []string{"b.parquet"}) || plan.Files[0].DeletionVector != nil {
+		t.Errorf("want b.parquet without deletion vector, has %v", scanPaths(plan))
+	}
+}
+
+func TestScanWriterStats(t *testing.T) {
+	afterTwo := time.Date(2023, 1, 1, 2, 30, 0, 0, time.UTC)
+	tests := []struct {
+		table       string
+		predicate   Predicate
+		want        []string
+		statsPruned int
+	}{
+		{"spark_stats", Comparison{Column: "id", Operator: GreaterThan, Value: int64(10)}, []string{"part-00001-b.snappy.parquet"}, 1},
+		// The wide bounds of b do not rule out ids of 10 or less
+		{"spark_stats", Comparison{Column: "id", Operator: LessThanOrEqual, Value: int64(10)}, []string{"part-00000-a.snappy.parquet", "part-00001-b.snappy.parquet"}, 0},
+		{"spark_stats", Comparison{Column: "ts", Operator: LessThan, Value: afterTwo}, []string{"part-00000-a.snappy.parquet", "part-00001-b.snappy.parquet"}, 0},
+		{"spark_stats", Comparison{Column: "ts", Operator: GreaterThan, Value: afterTwo}, []string{"part-00001-b.snappy.parquet"}, 1},
+		// Statistics without tightBounds have tight bounds
+		{"delta_rs_stats", Comparison{Column: "id", Operator: LessThanOrEqual, Value: int64(10)}, []string{"part-00000-c.parquet"}, 1},
+		// Timestamps without a time zone are in UTC
+		{"delta_rs_stats", Comparison{Column: "ts", Operator: GreaterThan, Value: afterTwo}, []string{"part-00001-d.parquet"}, 1},
+		{"delta_rs_stats", Comparison{Column: "score", Operator: GreaterThan, Value: 100.0}, []string{"part-00000-c.parquet"}, 1},
+		// The null count of the nested column is keyed by its path
+		{"delta_rs_stats", IsNotNull{Column: "event.region"}, []string{"part-00000-c.parquet"}, 1},
+		{"delta_rs_stats", Comparison{Column: "event.region", Operator: Equal, Value: "fr"}, []string{"part-00000-c.parquet"}, 1},
+	}
+	for _, test := range tests {
+		table, err := OpenTable(filestore.New(storage.NewPath("testdata/"+test.table)), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := table.Scan(nil, test.predicate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(scanPaths(plan), test.want) || plan.StatsPrunedFiles != test.statsPruned {
+			t.Errorf("%s %v: want %v with %d stats pruned files, has %v with %d", test.table, test.predicate, test.want, test.statsPruned, scanPaths(plan), plan.StatsPrunedFiles)
+		}
+	}
+}
+
+func TestWalkScanFiles(t *testing.T) {
+	table, err := OpenTable(filestore.New(storage.NewPath("testdata/deletion_vectors")), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := table.Scan(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The deletion vector file outside of the table cannot be read
+	var files []ResolvedScanFile
+	collect := func(file ResolvedScanFile) error {
+		files = append(files, file)
+		return nil
+	}
+	if err := table.WalkScanFiles(plan, collect); !errors.Is(err, ErrorInvalidDeletionVector) || len(files) != 2 {
+		t.Errorf("want ErrorInvalidDeletionVector after 2 files, has %v after %d files", err, len(files))
+	}
+
+	files = nil
+	plan.Files = append(plan.Files[:2], plan.Files[3:]...)
+	if err := table.WalkScanFiles(plan, collect); err != nil {
+		t.Fatal(err)
+	}
+	root := table.Store.RootURI()
+	want := []struct {
+		uri     string
+		deleted DeletedRows
+	}{
+		{root + "/part-00000-u.snappy.parquet", DeletedRows{0, 9}},
+		{root + "/part-00001-i.snappy.parquet", DeletedRows{3, 4, 7, 11, 18, 29}},
+		{root + "/part-00003.snappy.parquet", nil},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("want %d files, has %d", len(want), len(files))
+	}
+	for i, file := range files {
+		if file.Uri != want[i].uri || !reflect.DeepEqual(file.DeletedRows, want[i].deleted) {
+			t.Errorf("want %s with rows %v deleted, has %s with rows %v deleted", want[i].uri, want[i].deleted, file.Uri, file.DeletedRows)
+		}
+	}
+	if !files[1].DeletedRows.Contains(18) || files[1].DeletedRows.Contains(19) || files[2].DeletedRows.Contains(0) {
+		t.Errorf("unexpected deleted rows %v %v", files[1].DeletedRows, files[2].DeletedRows)
+	}
+
+	// The walk stops on ErrorStopWalk
+	files = nil
+	if err := table.WalkScanFiles(plan, func(file ResolvedScanFile) error {
+		files = append(files, file)
+		return ErrorStopWalk
+	}); err != nil || len(files) != 1 {
+		t.Errorf("want the walk stopped after 1 file, has %v after %d files", err, len(files))
+	}
+}
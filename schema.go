@@ -15,6 +15,7 @@ package delta
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -68,6 +69,45 @@ func (s *SchemaTypeStruct) Json() []byte {
 	return b
 }
 
+// GetField returns the top level field with the given name, matched case-insensitively as by LookupField
+func (s *SchemaTypeStruct) GetField(name string) (SchemaField, bool) {
+	return s.LookupField(name, false)
+}
+
+// LookupField returns the top level field with the given name. Unless caseSensitive, a field whose name only
+// differs in case is matched when no field has the exact name, as long as it is the only such field.
+// The returned field keeps the case of the schema.
+func (s *SchemaTypeStruct) LookupField(name string, caseSensitive bool) (SchemaField, bool) {
+	index, _ := fieldIndex(s.Fields, name, caseSensitive)
+	if index < 0 {
+		return SchemaField{}, false
+	}
+	return s.Fields[index], true
+}
+
+// fieldIndex returns the index of the field with the given name as matched by LookupField, or -1 if there is none.
+// Returns true if the name is ambiguous, matching several fields case-insensitively.
+func fieldIndex(fields []SchemaField, name string, caseSensitive bool) (int, bool) {
+	for i, field := range fields {
+		if field.Name == name {
+			return i, false
+		}
+	}
+	index := -1
+	if caseSensitive {
+		return index, false
+	}
+	for i, field := range fields {
+		if strings.EqualFold(field.Name, name) {
+			if index >= 0 {
+				return -1, true
+			}
+			index = i
+		}
+	}
+	return index, false
+}
+
 // Describes a specific field of the Delta table schema.
 type SchemaField struct {
 	// Name of this (possibly nested) column
@@ -83,6 +123,31 @@ type SchemaField struct {
 	Fields []SchemaField `json:"fields,omitempty"`
 }
 
+// Keys of the field metadata holding the default value expressions of a column
+const (
+	// The SQL expression used for the column when a write does not provide a value
+	CURRENT_DEFAULT_METADATA_KEY = "CURRENT_DEFAULT"
+	// The SQL expression of the value of the column in the rows written before the column was added
+	EXISTS_DEFAULT_METADATA_KEY = "EXISTS_DEFAULT"
+)
+
+// CurrentDefault returns the default value expression that writers use for the column when a write does not provide
+// a value, or false if the column has no default. The expression is not evaluated.
+func (field *SchemaField) CurrentDefault() (string, bool) {
+	return field.defaultExpression(CURRENT_DEFAULT_METADATA_KEY)
+}
+
+// ExistenceDefault returns the default value expression of the column in the data files written before the column was
+// added, which readers use for the rows of those files, or false if the column has none. The expression is not evaluated.
+func (field *SchemaField) ExistenceDefault() (string, bool) {
+	return field.defaultExpression(EXISTS_DEFAULT_METADATA_KEY)
+}
+
+func (field *SchemaField) defaultExpression(key string) (string, bool) {
+	expression, ok := field.Metadata[key].(string)
+	return expression, ok
+}
+
 // / Enum with variants for each top level schema data type.
 // / Variant representing non-array, non-map, non-struct fields. Wrapped value will contain the
 // / the string name of the primitive type.
@@ -216,3 +281,139 @@ func getFieldDataType(t any) SchemaDataType {
 		return String
 	}
 }
+
+// / Enum with variants for each kind of difference between a table schema and an incoming schema.
+type IncompatibilityKind string
+
+const (
+	AddedColumn          IncompatibilityKind = "added column"          // * the incoming schema has a column the table does not
+	MissingColumn        IncompatibilityKind = "missing column"        // * the table has a column the incoming schema does not
+	TypeMismatch         IncompatibilityKind = "type mismatch"         // * the column types differ
+	NullabilityViolation IncompatibilityKind = "nullability violation" // * the incoming column is nullable but the table column is not
+	ColumnReordered      IncompatibilityKind = "column reordered"      // * the column is at a different position
+)
+
+// Incompatibility describes a single difference found by IsWriteCompatible
+type Incompatibility struct {
+	// Dotted path of the (possibly nested) column
+	Path string
+	Kind IncompatibilityKind
+	// Allowed is true if data with the incoming schema can still be appended to the table
+	Allowed bool
+	Message string
+}
+
+func (i Incompatibility) String() string {
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// widenings lists the types each type can be losslessly widened to when writing
+var widenings = map[SchemaDataType][]SchemaDataType{
+	Byte:    {Short, Integer, Long},
+	Short:   {Integer, Long},
+	Integer: {Long},
+	Float:   {Double},
+}
+
+// IsWriteCompatible compares the incoming schema to this table schema and reports every difference.
+// Columns are matched by name, so reordering is always allowed; the returned bool is false if any
+// of the differences would prevent data with the incoming schema from being appended to the table.
+func (s *SchemaTypeStruct) IsWriteCompatible(incoming Schema) ([]Incompatibility, bool) {
+	incompatibilities := compareFields("", s.Fields, incoming.Fields)
+	for _, incompatibility := range incompatibilities {
+		if !incompatibility.Allowed {
+			return incompatibilities, false
+		}
+	}
+	return incompatibilities, true
+}
+
+func compareFields(prefix string, existing []SchemaField, incoming []SchemaField) []Incompatibility {
+	var incompatibilities []Incompatibility
+	existingPositions := make(map[string]int, len(existing))
+	for i, field := range existing {
+		existingPositions[field.Name] = i
+	}
+	incomingNames := make(map[string]bool, len(incoming))
+	for _, field := range incoming {
+		incomingNames[field.Name] = true
+	}
+
+	// Positions are compared among the columns both schemas share, so an added column does not count as a reorder
+	position := 0
+	for _, field := range incoming {
+		path := prefix + field.Name
+		i, ok := existingPositions[field.Name]
+		if !ok {
+			message := fmt.Sprintf("column of type %s is not in the table schema", field.Type)
+			if !field.Nullable {
+				message += " and cannot be added because it is not nullable"
+			}
+			incompatibilities = append(incompatibilities, Incompatibility{
+				Path: path, Kind: AddedColumn, Allowed: field.Nullable, Message: message,
+			})
+			continue
+		}
+		existingField := existing[i]
+		if sharedPosition(existing, incomingNames, i) != position {
+			incompatibilities = append(incompatibilities, Incompatibility{
+				Path: path, Kind: ColumnReordered, Allowed: true,
+				Message: "column is at a different position than in the table schema",
+			})
+		}
+		position++
+		incompatibilities = append(incompatibilities, compareField(path, existingField, field)...)
+	}
+
+	for _, field := range existing {
+		if incomingNames[field.Name] {
+			continue
+		}
+		message := "column is not in the incoming schema"
+		if !field.Nullable {
+			message += " and is not nullable"
+		}
+		incompatibilities = append(incompatibilities, Incompatibility{
+			Path: prefix + field.Name, Kind: MissingColumn, Allowed: field.Nullable, Message: message,
+		})
+	}
+	return incompatibilities
+}
+
+// sharedPosition returns the position of existing[index] among the existing columns that are also in the incoming schema
+func sharedPosition(existing []SchemaField, incomingNames map[string]bool, index int) int {
+	position := 0
+	for _, field := range existing[:index] {
+		if incomingNames[field.Name] {
+			position++
+		}
+	}
+	return position
+}
+
+func compareField(path string, existing SchemaField, incoming SchemaField) []Incompatibility {
+	var incompatibilities []Incompatibility
+	if incoming.Nullable && !existing.Nullable {
+		incompatibilities = append(incompatibilities, Incompatibility{
+			Path: path, Kind: NullabilityViolation, Allowed: false,
+			Message: "incoming column is nullable but the table column is not",
+		})
+	}
+	if existing.Type != incoming.Type {
+		allowed := false
+		for _, widened := range widenings[incoming.Type] {
+			if widened == existing.Type {
+				allowed = true
+			}
+		}
+		message := fmt.Sprintf("incoming type %s does not match table type %s", incoming.Type, existing.Type)
+		if allowed {
+			message = fmt.Sprintf("incoming type %s can be widened to table type %s", incoming.Type, existing.Type)
+		}
+		return append(incompatibilities, Incompatibility{Path: path, Kind: TypeMismatch, Allowed: allowed, Message: message})
+	}
+	if existing.Type == Struct {
+		incompatibilities = append(incompatibilities, compareFields(path+".", existing.Fields, incoming.Fields)...)
+	}
+	return incompatibilities
+}
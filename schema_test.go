@@ -13,6 +13,7 @@
 package delta
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 )
@@ -102,3 +103,129 @@ func TestGetSchemaWithWeirdTypes(t *testing.T) {
 	}
 
 }
+
+func TestLookupField(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "ID", Type: String}, {Name: "Name", Type: String}}}
+	for _, test := range []struct {
+		name          string
+		caseSensitive bool
+		want          string
+	}{
+		{"ID", false, "ID"},
+		{"id", false, "id"},
+		{"name", false, "Name"},
+		{"NAME", false, "Name"},
+		{"Id", false, ""},
+		{"name", true, ""},
+		{"Name", true, "Name"},
+		{"ID", true, "ID"},
+	} {
+		field, ok := schema.LookupField(test.name, test.caseSensitive)
+		if ok != (test.want != "") || field.Name != test.want {
+			t.Errorf("%s case-sensitive %t: want %q, has %q %t", test.name, test.caseSensitive, test.want, field.Name, ok)
+		}
+	}
+	if field, ok := schema.GetField("name"); !ok || field.Name != "Name" {
+		t.Errorf("GetField should match case-insensitively, has %q %t", field.Name, ok)
+	}
+}
+
+func TestIsWriteCompatible(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{
+		{Name: "id", Type: Long, Nullable: false},
+		{Name: "label", Type: String, Nullable: true},
+		{Name: "nested", Type: Struct, Nullable: true, Fields: []SchemaField{
+			{Name: "value", Type: Double, Nullable: true},
+		}},
+		{Name: "required", Type: String, Nullable: false},
+	}}
+
+	incompatibilities, ok := schema.IsWriteCompatible(schema)
+	if !ok || len(incompatibilities) != 0 {
+		t.Errorf("schema should be compatible with itself, has %v", incompatibilities)
+	}
+
+	// Reordered, widened, nested widened, added nullable column, missing nullable column
+	incoming := SchemaTypeStruct{Fields: []SchemaField{
+		{Name: "required", Type: String, Nullable: false},
+		{Name: "id", Type: Integer, Nullable: false},
+		{Name: "nested", Type: Struct, Nullable: true, Fields: []SchemaField{
+			{Name: "value", Type: Float, Nullable: true},
+		}},
+		{Name: "extra", Type: String, Nullable: true},
+	}}
+	incompatibilities, ok = schema.IsWriteCompatible(incoming)
+	if !ok {
+		t.Errorf("schema should be append compatible, has %v", incompatibilities)
+	}
+	kinds := make(map[string]IncompatibilityKind)
+	for _, incompatibility := range incompatibilities {
+		if incompatibility.Kind != ColumnReordered {
+			kinds[incompatibility.Path] = incompatibility.Kind
+		}
+	}
+	expected := map[string]IncompatibilityKind{
+		"id": TypeMismatch, "nested.value": TypeMismatch, "extra": AddedColumn, "label": MissingColumn,
+	}
+	if fmt.Sprint(kinds) != fmt.Sprint(expected) {
+		t.Errorf("want %v, has %v", expected, kinds)
+	}
+
+	// Narrowed type, nullable into a required column, missing required column, added required column
+	incoming = SchemaTypeStruct{Fields: []SchemaField{
+		{Name: "id", Type: Long, Nullable: true},
+		{Name: "label", Type: String, Nullable: true},
+		{Name: "nested", Type: Struct, Nullable: true, Fields: []SchemaField{
+			{Name: "value", Type: String, Nullable: true},
+		}},
+		{Name: "extra", Type: String, Nullable: false},
+	}}
+	incompatibilities, ok = schema.IsWriteCompatible(incoming)
+	if ok {
+		t.Error("schema should not be compatible")
+	}
+	disallowed := make(map[string]IncompatibilityKind)
+	for _, incompatibility := range incompatibilities {
+		if !incompatibility.Allowed {
+			disallowed[incompatibility.Path] = incompatibility.Kind
+		}
+	}
+	expected = map[string]IncompatibilityKind{
+		"id": NullabilityViolation, "nested.value": TypeMismatch, "extra": AddedColumn, "required": MissingColumn,
+	}
+	if fmt.Sprint(disallowed) != fmt.Sprint(expected) {
+		t.Errorf("want %v, has %v", expected, disallowed)
+	}
+}
+
+func TestSchemaFieldDefaults(t *testing.T) {
+	var schema SchemaTypeStruct
+	err := json.Unmarshal([]byte(`{"fields":[
+		{"name":"id","type":"long","nullable":false,"metadata":{}},
+		{"name":"status","type":"string","nullable":true,"metadata":{"CURRENT_DEFAULT":"'active'","EXISTS_DEFAULT":"'unknown'"}},
+		{"name":"score","type":"integer","nullable":true,"metadata":{"EXISTS_DEFAULT":"0"}}]}`), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		currentDefault string
+		currentOk      bool
+		existsDefault  string
+		existsOk       bool
+	}{
+		{"id", "", false, "", false},
+		{"status", "'active'", true, "'unknown'", true},
+		{"score", "", false, "0", true},
+	}
+	for _, test := range tests {
+		field, _ := schema.GetField(test.name)
+		if expression, ok := field.CurrentDefault(); expression != test.currentDefault || ok != test.currentOk {
+			t.Errorf("%s: want current default %q %t, has %q %t", test.name, test.currentDefault, test.currentOk, expression, ok)
+		}
+		if expression, ok := field.ExistenceDefault(); expression != test.existsDefault || ok != test.existsOk {
+			t.Errorf("%s: want existence default %q %t, has %q %t", test.name, test.existsDefault, test.existsOk, expression, ok)
+		}
+	}
+}
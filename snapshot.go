@@ -0,0 +1,180 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rivian/delta-go/state"
+)
+
+// SNAPSHOT_FORMAT_VERSION is the version of the serialized table state format written by Marshal
+const SNAPSHOT_FORMAT_VERSION = 1
+
+var (
+	ErrorSnapshotFormat error = errors.New("unsupported table state snapshot format")
+)
+
+// snapshot is the serialized form of a table state: the actions that reconstruct the state of a version
+type snapshot struct {
+	FormatVersion int                        `json:"formatVersion"`
+	Version       state.DeltaDataTypeVersion `json:"version"`
+	Actions       []LogEntry                 `json:"actions"`
+}
+
+// Marshal serializes the table state, so that it can be cached and restored with UnmarshalDeltaTableState
+// instead of replaying the log.
+// The protocol, metadata, app transaction versions, metadata domains, active files and tombstones are kept;
+// commit infos are not.
+func (tableState *DeltaTableState) Marshal() ([]byte, error) {
+	if tableState.Version < 0 {
+		return nil, ErrorNotATable
+	}
+	actions := tableState.actions()
+	entries := make([]LogEntry, 0, len(actions))
+	for _, action := range actions {
+		entries = append(entries, LogEntry{Action: action})
+	}
+	return json.Marshal(snapshot{FormatVersion: SNAPSHOT_FORMAT_VERSION, Version: tableState.Version, Actions: entries})
+}
+
+// UnmarshalDeltaTableState restores a table state serialized by Marshal.
+// Use DeltaTable.Update to apply the commits written after the state was serialized.
+func UnmarshalDeltaTableState(data []byte) (*DeltaTableState, error) {
+	var header struct {
+		FormatVersion int `json:"formatVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, errors.Join(ErrorSnapshotFormat, err)
+	}
+	if header.FormatVersion != SNAPSHOT_FORMAT_VERSION {
+		return nil, errors.Join(ErrorSnapshotFormat, fmt.Errorf("format version %d", header.FormatVersion))
+	}
+
+	var serialized snapshot
+	if err := json.Unmarshal(data, &serialized); err != nil {
+		return nil, errors.Join(ErrorSnapshotFormat, err)
+	}
+	tableState := NewDeltaTableState(serialized.Version)
+	for _, entry := range serialized.Actions {
+		if err := tableState.processAction(entry.Action); err != nil {
+			return nil, errors.Join(ErrorSnapshotFormat, err)
+		}
+	}
+	return tableState, nil
+}
+
+// actions returns the actions that reconstruct the table state when applied to an empty state,
+// with the files sorted by path
+func (tableState *DeltaTableState) actions() []Action {
+	actions := []Action{
+		Protocol{
+			MinReaderVersion: DeltaDataTypeInt(tableState.MinReaderVersion),
+			MinWriterVersion: DeltaDataTypeInt(tableState.MinWriterVersion),
+			ReaderFeatures:   tableState.ReaderFeatures,
+			WriterFeatures:   tableState.WriterFeatures,
+		},
+		tableState.CurrentMetadata.ToMetaData(),
+	}
+
+	appIds := make([]string, 0, len(tableState.AppTransactionVersion))
+	for appId := range tableState.AppTransactionVersion {
+		appIds = append(appIds, appId)
+	}
+	sort.Strings(appIds)
+	for _, appId := range appIds {
+		actions = append(actions, Txn{AppId: appId, Version: DeltaDataTypeVersion(tableState.AppTransactionVersion[appId])})
+	}
+
+	domains := make([]string, 0, len(tableState.Domains))
+	for domain := range tableState.Domains {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		actions = append(actions, tableState.Domains[domain])
+	}
+
+	paths := make([]string, 0, len(tableState.Files))
+	for path := range tableState.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		actions = append(actions, tableState.Files[path])
+	}
+
+	paths = paths[:0]
+	for path := range tableState.Tombstones {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		actions = append(actions, tableState.Tombstones[path])
+	}
+	return actions
+}
+
+// Update applies the commits written since the version of the loaded table state, without replaying the
+// log from the start. The table state is left unchanged if the commits cannot be applied.
+// If no state is loaded, the latest version is loaded.
+func (table *DeltaTable) Update() error {
+	if table.State.Version < 0 {
+		return table.Load()
+	}
+	commits, compactions, err := table.listLogFiles()
+	if err != nil {
+		return err
+	}
+	targetVersion := table.State.Version
+	for v := range commits {
+		targetVersion = max(targetVersion, v)
+	}
+	if targetVersion == table.State.Version {
+		return nil
+	}
+
+	tableState := table.State.clone()
+	err = table.replayLog(tableState, table.State.Version+1, targetVersion, compactions)
+	if err != nil {
+		return err
+	}
+	table.State = *tableState
+	return nil
+}
+
+// clone returns a copy of the table state that can be updated without changing the original
+func (tableState *DeltaTableState) clone() *DeltaTableState {
+	cloned := *tableState
+	cloned.Files = make(map[string]Add, len(tableState.Files))
+	for path, add := range tableState.Files {
+		cloned.Files[path] = add
+	}
+	cloned.Tombstones = make(map[string]Remove, len(tableState.Tombstones))
+	for path, remove := range tableState.Tombstones {
+		cloned.Tombstones[path] = remove
+	}
+	cloned.AppTransactionVersion = make(map[string]state.DeltaDataTypeVersion, len(tableState.AppTransactionVersion))
+	for appId, version := range tableState.AppTransactionVersion {
+		cloned.AppTransactionVersion[appId] = version
+	}
+	cloned.Domains = make(map[string]DomainMetadata, len(tableState.Domains))
+	for domain, domainMetadata := range tableState.Domains {
+		cloned.Domains[domain] = domainMetadata
+	}
+	cloned.CommitInfos = append([]CommitInfo(nil), tableState.CommitInfos...)
+	return &cloned
+}
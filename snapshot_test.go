@@ -0,0 +1,173 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeltaTableStateMarshal(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("test", "", new(Format).Default(), schema, []string{}, map[string]string{"delta.logRetentionDuration": "interval 2 days"})
+	table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	transaction, operation, appMetaData := setupTransaction(t, table, NewDeltaTransactionOptions())
+	transaction.AddAction(Txn{AppId: "app", Version: 3})
+	transaction.AddAction(DomainMetadata{Domain: "delta.test", Configuration: `{"a":1}`})
+	_, err := transaction.Commit(operation, appMetaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := table.State.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serializedFiles := table.State.Files
+
+	// Commit a new version after the state was serialized
+	transaction = table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Remove{Path: "part-00000-80a9bb40-ec43-43b6-bb8a-fc66ef7cd768-c000.snappy.parquet", DeletionTimestamp: DeltaDataTypeTimestamp(time.Now().UnixMilli()), DataChange: true})
+	transaction.AddAction(Add{Path: "part-00001.snappy.parquet", Size: 10, DataChange: true})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := UnmarshalDeltaTableState(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Version != 1 {
+		t.Errorf("want version 1, has %d", restored.Version)
+	}
+	if !reflect.DeepEqual(restored.Files, serializedFiles) {
+		t.Errorf("want files %v, has %v", serializedFiles, restored.Files)
+	}
+	if restored.CurrentMetadata.Id != table.State.CurrentMetadata.Id || restored.LogRetention != 2*24*time.Hour {
+		t.Error("metadata was not restored")
+	}
+	if restored.AppTransactionVersion["app"] != 3 {
+		t.Error("app transaction version was not restored")
+	}
+	if configuration, ok := restored.DomainMetadata("delta.test"); !ok || configuration != `{"a":1}` {
+		t.Error("domain metadata was not restored")
+	}
+
+	cached := NewDeltaTable(table.Store, nil, nil)
+	cached.State = *restored
+	err = cached.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.State.Version != 2 {
+		t.Errorf("want version 2, has %d", cached.State.Version)
+	}
+	if !reflect.DeepEqual(cached.State.Files, table.State.Files) {
+		t.Errorf("want files %v, has %v", table.State.Files, cached.State.Files)
+	}
+	if len(cached.State.Tombstones) != 1 {
+		t.Errorf("want 1 tombstone, has %d", len(cached.State.Tombstones))
+	}
+	if len(restored.Files) != 1 {
+		t.Error("Update should not change the restored state")
+	}
+}
+
+func TestUnmarshalDeltaTableStateFormat(t *testing.T) {
+	_, err := UnmarshalDeltaTableState([]byte(`{"formatVersion":2,"version":1,"actions":[]}`))
+	if !errors.Is(err, ErrorSnapshotFormat) {
+		t.Errorf("want ErrorSnapshotFormat, has %v", err)
+	}
+	_, err = UnmarshalDeltaTableState([]byte(`not json`))
+	if !errors.Is(err, ErrorSnapshotFormat) {
+		t.Errorf("want ErrorSnapshotFormat, has %v", err)
+	}
+	_, err = NewDeltaTableState(-1).Marshal()
+	if !errors.Is(err, ErrorNotATable) {
+		t.Errorf("want ErrorNotATable, has %v", err)
+	}
+}
+
+func TestCommitUpdatesState(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	metadata := NewDeltaTableMetaData("test", "", new(Format).Default(), schema, []string{}, map[string]string{})
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 0 || table.State.CurrentMetadata.Id != metadata.Id {
+		t.Errorf("the created version should be applied, has version %d", table.State.Version)
+	}
+
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-1.snappy.parquet", Size: 1, DataChange: true})
+	version, err := transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 || table.State.Version != 1 {
+		t.Errorf("want version 1, has %d and state version %d", version, table.State.Version)
+	}
+	assertActiveFiles(t, table, []string{"part-1.snappy.parquet"})
+
+	// Another writer commits version 2, which Update reads after the committed version
+	writer, err := OpenTable(table.Store, table.LockClient, table.StateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction = writer.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-2.snappy.parquet", Size: 1, DataChange: true})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 2 {
+		t.Errorf("want version 2, has %d", table.State.Version)
+	}
+	assertActiveFiles(t, table, []string{"part-1.snappy.parquet", "part-2.snappy.parquet"})
+
+	// A commit after versions of other writers updates the state from the log
+	transaction = writer.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-3.snappy.parquet", Size: 1, DataChange: true})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction = table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-4.snappy.parquet", Size: 1, DataChange: true})
+	version, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 4 || table.State.Version != 4 {
+		t.Errorf("want version 4, has %d and state version %d", version, table.State.Version)
+	}
+	assertActiveFiles(t, table, []string{"part-1.snappy.parquet", "part-2.snappy.parquet", "part-3.snappy.parquet", "part-4.snappy.parquet"})
+}
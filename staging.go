@@ -0,0 +1,118 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrorStagedTransactionClosed error = errors.New("the staged transaction has already been published or aborted")
+	ErrorPublishStagedFile       error = errors.New("unable to publish staged file")
+)
+
+// Staged files are written under this directory of the table. Delta readers ignore paths starting with `_`,
+// so staged files are never visible as table data.
+const STAGING_DIRECTORY = "_delta_staging"
+
+// StagedTransaction writes data files to a staging area of the table and publishes them all at once.
+// Publish moves the staged files into the table layout and commits their Add actions in a single commit,
+// giving all-or-nothing multi-file writes on object stores that lack cross-key transactions.
+type StagedTransaction struct {
+	Transaction *DeltaTransaction
+	// staging prefix unique to this transaction
+	prefix storage.Path
+	staged []stagedFile
+	closed bool
+}
+
+type stagedFile struct {
+	location storage.Path
+	add      Add
+}
+
+// StageFiles creates a staged transaction on the table
+// Transaction behavior may be customized by passing an instance of `DeltaTransactionOptions`.
+func (table *DeltaTable) StageFiles(options *DeltaTransactionOptions) *StagedTransaction {
+	staged := new(StagedTransaction)
+	staged.Transaction = table.CreateTransaction(options)
+	staged.prefix = storage.Path{Raw: filepath.Join(STAGING_DIRECTORY, uuid.New().String())}
+	return staged
+}
+
+// Put writes the data of a file to the staging area.
+// The file is moved to add.Path and the Add action is committed when the transaction is published.
+func (staged *StagedTransaction) Put(add Add, data []byte) error {
+	if staged.closed {
+		return ErrorStagedTransactionClosed
+	}
+	location := storage.Path{Raw: filepath.Join(staged.prefix.Raw, add.Path)}
+	err := staged.Transaction.DeltaTable.dataStore().Put(&location, data)
+	if err != nil {
+		return err
+	}
+	staged.staged = append(staged.staged, stagedFile{location: location, add: add})
+	return nil
+}
+
+// Publish moves the staged files into the table layout and commits their Add actions,
+// along with any other actions added to the underlying transaction.
+// If publishing fails before the commit, the staged and moved files are removed.
+func (staged *StagedTransaction) Publish(operation DeltaOperation, appMetadata map[string]any) (state.DeltaDataTypeVersion, error) {
+	if staged.closed {
+		return staged.Transaction.DeltaTable.State.Version, ErrorStagedTransactionClosed
+	}
+	staged.closed = true
+
+	transaction := staged.Transaction
+	for i := range staged.staged {
+		file := &staged.staged[i]
+		target := storage.Path{Raw: file.add.Path}
+		err := transaction.DeltaTable.dataStore().Rename(&file.location, &target)
+		if err != nil {
+			staged.removeStagedFiles(staged.staged[i:])
+			transaction.AbortWrite()
+			return transaction.DeltaTable.State.Version, errors.Join(ErrorPublishStagedFile, fmt.Errorf("%s: %w", file.add.Path, err))
+		}
+		transaction.TrackDataFile(&target)
+		transaction.AddAction(file.add)
+	}
+
+	// Commit removes the published files if the commit is known not to have happened
+	return transaction.Commit(operation, appMetadata)
+}
+
+// Abort removes the staged files without publishing them
+func (staged *StagedTransaction) Abort() {
+	if staged.closed {
+		return
+	}
+	staged.closed = true
+	staged.removeStagedFiles(staged.staged)
+}
+
+// removeStagedFiles deletes staged files on a best-effort basis
+func (staged *StagedTransaction) removeStagedFiles(files []stagedFile) {
+	for i := range files {
+		if err := staged.Transaction.DeltaTable.dataStore().Delete(&files[i].location); err != nil {
+			log.Warnf("delta-go: unable to remove staged file %s: %v", files[i].location.Raw, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStagedTransactionPublish(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+
+	staged := table.StageFiles(NewDeltaTransactionOptions())
+	paths := []string{"date=2023-01-01/part-1.snappy.parquet", "date=2023-01-02/part-2.snappy.parquet"}
+	for _, path := range paths {
+		err := staged.Put(Add{Path: path, Size: 4, DataChange: true}, []byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fileExists(filepath.Join(tmpDir, path)) {
+			t.Errorf("%s should not be in the table layout before publishing", path)
+		}
+	}
+
+	version, err := staged.Publish(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("want version 1, has %d", version)
+	}
+	for _, path := range paths {
+		if !fileExists(filepath.Join(tmpDir, path)) {
+			t.Errorf("%s should be published", path)
+		}
+	}
+	assertNoStagedFiles(t, tmpDir)
+
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveFiles(t, table, paths)
+
+	_, err = staged.Publish(Write{Mode: Append}, nil)
+	if !errors.Is(err, ErrorStagedTransactionClosed) {
+		t.Errorf("want ErrorStagedTransactionClosed, has %v", err)
+	}
+}
+
+func TestStagedTransactionPublishFailure(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	table.Create(DeltaTableMetaData{}, Protocol{}, CommitInfo{}, []Add{})
+
+	staged := table.StageFiles(NewDeltaTransactionOptions())
+	paths := []string{"part-1.snappy.parquet", "part-2.snappy.parquet", "part-3.snappy.parquet"}
+	for _, path := range paths {
+		err := staged.Put(Add{Path: path, Size: 4, DataChange: true}, []byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Remove the second staged file so that publishing it fails
+	err := table.Store.Delete(&staged.staged[1].location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = staged.Publish(Write{Mode: Append}, nil)
+	if !errors.Is(err, ErrorPublishStagedFile) {
+		t.Errorf("want ErrorPublishStagedFile, has %v", err)
+	}
+	for _, path := range paths {
+		if fileExists(filepath.Join(tmpDir, path)) {
+			t.Errorf("%s should be removed after publishing failed", path)
+		}
+	}
+	assertNoStagedFiles(t, tmpDir)
+
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.State.Version != 0 {
+		t.Errorf("want version 0, has %d", table.State.Version)
+	}
+}
+
+func TestStagedTransactionAbort(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	staged := table.StageFiles(NewDeltaTransactionOptions())
+	err := staged.Put(Add{Path: "part-1.snappy.parquet"}, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged.Abort()
+	assertNoStagedFiles(t, tmpDir)
+
+	err = staged.Put(Add{Path: "part-2.snappy.parquet"}, []byte("data"))
+	if !errors.Is(err, ErrorStagedTransactionClosed) {
+		t.Errorf("want ErrorStagedTransactionClosed, has %v", err)
+	}
+}
+
+func assertNoStagedFiles(t *testing.T, tmpDir string) {
+	t.Helper()
+	var staged []string
+	filepath.Walk(filepath.Join(tmpDir, STAGING_DIRECTORY), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			staged = append(staged, path)
+		}
+		return nil
+	})
+	if len(staged) != 0 {
+		t.Errorf("staged files should be removed, found %v", staged)
+	}
+}
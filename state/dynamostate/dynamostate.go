@@ -13,8 +13,10 @@
 package dynamostate
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -25,14 +27,21 @@ import (
 const KEY string = "key"
 const VERSION string = "version"
 
+// Separates the key of the store from the version in the keys of history records
+const HISTORY_SEPARATOR string = "#"
+
 type DynamoState struct {
 	Table  string
 	Key    string
 	Client dynamodbiface.DynamoDBAPI
+	// When History is set, Put also writes a record of every version under the key <Key>#<version>,
+	// which can be removed with Prune
+	History bool
 }
 
-// Compile time check that DynamoState implements state.StateStore
+// Compile time check that DynamoState implements state.StateStore and state.Pruner
 var _ state.StateStore = (*DynamoState)(nil)
+var _ state.Pruner = (*DynamoState)(nil)
 
 func New(client dynamodbiface.DynamoDBAPI, tableName string, key string) (*DynamoState, error) {
 	tb := new(DynamoState)
@@ -87,7 +96,7 @@ func (l *DynamoState) Get() (state.CommitState, error) {
 }
 
 func (l *DynamoState) Put(commitS state.CommitState) error {
-	versionString := fmt.Sprintf("%v", commitS.Version)
+	versionString := fmt.Sprintf("%d", commitS.Version)
 
 	// Create a PutItemInput object with the item data
 	input := &dynamodb.PutItemInput{
@@ -109,5 +118,67 @@ func (l *DynamoState) Put(commitS state.CommitState) error {
 		fmt.Println("Error inserting item.", err)
 		return err
 	}
+
+	if l.History {
+		input.Item[KEY] = &dynamodb.AttributeValue{S: aws.String(l.historyKey(commitS.Version))}
+		_, err = l.Client.PutItem(input)
+		if err != nil {
+			return errors.Join(state.ErrorCanNotWriteState, err)
+		}
+	}
 	return nil
 }
+
+// historyKey returns the key of the history record of the version
+func (l *DynamoState) historyKey(version state.DeltaDataTypeVersion) string {
+	return fmt.Sprintf("%s%s%d", l.Key, HISTORY_SEPARATOR, version)
+}
+
+// Prune deletes the history records of versions before beforeVersion.
+// The latest commit state and the history record of its version are always kept.
+// History records are found with a Scan of the table, so Prune should be run as periodic maintenance.
+func (l *DynamoState) Prune(beforeVersion state.DeltaDataTypeVersion) error {
+	latest, err := l.Get()
+	if err != nil {
+		return errors.Join(state.ErrorCanNotPruneState, err)
+	}
+	if latest.Version < beforeVersion {
+		beforeVersion = latest.Version
+	}
+
+	prefix := l.Key + HISTORY_SEPARATOR
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(l.Table),
+		FilterExpression:          aws.String("begins_with(#key, :prefix)"),
+		ExpressionAttributeNames:  map[string]*string{"#key": aws.String(KEY)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":prefix": {S: aws.String(prefix)}},
+		ProjectionExpression:      aws.String("#key"),
+	}
+	for {
+		output, err := l.Client.Scan(input)
+		if err != nil {
+			return errors.Join(state.ErrorCanNotPruneState, err)
+		}
+		for _, item := range output.Items {
+			key := aws.StringValue(item[KEY].S)
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			version, err := strconv.ParseInt(strings.TrimPrefix(key, prefix), 10, 64)
+			if err != nil || state.DeltaDataTypeVersion(version) >= beforeVersion {
+				continue
+			}
+			_, err = l.Client.DeleteItem(&dynamodb.DeleteItemInput{
+				TableName: aws.String(l.Table),
+				Key:       map[string]*dynamodb.AttributeValue{KEY: {S: aws.String(key)}},
+			})
+			if err != nil {
+				return errors.Join(state.ErrorCanNotPruneState, err)
+			}
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
@@ -14,6 +14,7 @@ package dynamostate
 
 import (
 	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -75,3 +76,92 @@ func TestPut(t *testing.T) {
 		t.Errorf("Error occurred in PUT.")
 	}
 }
+
+// memoryDynamoDBClient keeps items in memory and returns scan results in pages of 2 items
+type memoryDynamoDBClient struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *memoryDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	item := make(map[string]*dynamodb.AttributeValue)
+	for name, value := range input.Item {
+		item[name] = value
+	}
+	m.items[*input.Item[KEY].S] = item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *memoryDynamoDBClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.items[*input.Key[KEY].S]}, nil
+}
+
+func (m *memoryDynamoDBClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	delete(m.items, *input.Key[KEY].S)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *memoryDynamoDBClient) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	var keys []string
+	for key := range m.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	start := 0
+	if input.ExclusiveStartKey != nil {
+		start = sort.Search(len(keys), func(i int) bool { return keys[i] > *input.ExclusiveStartKey[KEY].S })
+	}
+	output := &dynamodb.ScanOutput{}
+	for i := start; i < len(keys) && i < start+2; i++ {
+		output.Items = append(output.Items, map[string]*dynamodb.AttributeValue{KEY: {S: aws.String(keys[i])}})
+	}
+	if start+2 < len(keys) {
+		output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{KEY: {S: aws.String(keys[start+1])}}
+	}
+	return output, nil
+}
+
+func TestPrune(t *testing.T) {
+	client := &memoryDynamoDBClient{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	dynamoState, err := New(client, "storage-table", "_commit.state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynamoState.History = true
+	for version := 0; version <= 5; version++ {
+		err = dynamoState.Put(state.CommitState{Version: state.DeltaDataTypeVersion(version)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.items["other_table#1"] = map[string]*dynamodb.AttributeValue{KEY: {S: aws.String("other_table#1")}}
+	if len(client.items) != 8 {
+		t.Errorf("want 8 items, has %d", len(client.items))
+	}
+
+	err = dynamoState.Prune(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"_commit.state", "_commit.state#3", "_commit.state#4", "_commit.state#5", "other_table#1"} {
+		if _, ok := client.items[key]; !ok {
+			t.Errorf("%s should be kept", key)
+		}
+	}
+	if len(client.items) != 5 {
+		t.Errorf("want 5 items, has %d", len(client.items))
+	}
+
+	// The history record of the latest version is kept
+	err = dynamoState.Prune(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.items["_commit.state#5"]; !ok || len(client.items) != 3 {
+		t.Errorf("unexpected items after pruning %v", client.items)
+	}
+	commitState, err := dynamoState.Get()
+	if err != nil || commitState.Version != 5 {
+		t.Errorf("want version 5, has %d", commitState.Version)
+	}
+}
@@ -27,8 +27,9 @@ type FileStateStore struct {
 	Key     string
 }
 
-// Compile time check that FileStateStore implements state.StateStore
+// Compile time check that FileStateStore implements state.StateStore and state.Pruner
 var _ state.StateStore = (*FileStateStore)(nil)
+var _ state.Pruner = (*FileStateStore)(nil)
 
 func New(baseURI *storage.Path, key string) *FileStateStore {
 	fs := new(FileStateStore)
@@ -69,3 +70,8 @@ func (s *FileStateStore) Put(commitState state.CommitState) error {
 	}
 	return nil
 }
+
+// Prune is a no-op, the store only keeps the latest commit state
+func (s *FileStateStore) Prune(beforeVersion state.DeltaDataTypeVersion) error {
+	return nil
+}
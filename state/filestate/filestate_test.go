@@ -64,3 +64,25 @@ func TestGetPutData(t *testing.T) {
 	}
 
 }
+
+func TestPrune(t *testing.T) {
+	tmpDir := t.TempDir()
+	fl := New(storage.NewPath(tmpDir), "_delta_log/_commit.state")
+	err := fl.Put(state.CommitState{Version: 3})
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+
+	// Prune keeps the latest commit state
+	err = fl.Prune(10)
+	if err != nil {
+		t.Error(err)
+	}
+	out, err := fl.Get()
+	if err != nil {
+		t.Error(err)
+	}
+	if out.Version != 3 {
+		t.Error("Version is not 3")
+	}
+}
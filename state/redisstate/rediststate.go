@@ -27,8 +27,9 @@ type RedisStateStore struct {
 	ctx         context.Context
 }
 
-// Compile time check that FileStateStore implements state.StateStore
+// Compile time check that RedisStateStore implements state.StateStore and state.Pruner
 var _ state.StateStore = (*RedisStateStore)(nil)
+var _ state.Pruner = (*RedisStateStore)(nil)
 
 func New(client redis.UniversalClient, key string) *RedisStateStore {
 	s := new(RedisStateStore)
@@ -66,3 +67,8 @@ func (s *RedisStateStore) Put(commitState state.CommitState) error {
 	}
 	return nil
 }
+
+// Prune is a no-op, the store only keeps the latest commit state
+func (s *RedisStateStore) Prune(beforeVersion state.DeltaDataTypeVersion) error {
+	return nil
+}
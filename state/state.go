@@ -14,6 +14,7 @@ package state
 
 import (
 	"errors"
+	"fmt"
 )
 
 // / Type alias for i64/Delta long
@@ -22,10 +23,52 @@ type DeltaDataTypeLong int64
 // / Type alias representing the expected type (i64) of a Delta table version.
 type DeltaDataTypeVersion DeltaDataTypeLong
 
+// Next returns the version following the version
+func (version DeltaDataTypeVersion) Next() DeltaDataTypeVersion {
+	return version + 1
+}
+
+// Prev returns the version preceding the version, -1 for version 0 as for a table without commits
+func (version DeltaDataTypeVersion) Prev() DeltaDataTypeVersion {
+	return version - 1
+}
+
+// Compare returns -1 if the version is before other, 0 if they are equal and +1 if the version is after other
+func (version DeltaDataTypeVersion) Compare(other DeltaDataTypeVersion) int {
+	switch {
+	case version < other:
+		return -1
+	case version > other:
+		return 1
+	}
+	return 0
+}
+
+// Equal returns whether the versions are equal
+func (version DeltaDataTypeVersion) Equal(other DeltaDataTypeVersion) bool {
+	return version == other
+}
+
+// Before returns whether the version is before other
+func (version DeltaDataTypeVersion) Before(other DeltaDataTypeVersion) bool {
+	return version < other
+}
+
+// After returns whether the version is after other
+func (version DeltaDataTypeVersion) After(other DeltaDataTypeVersion) bool {
+	return version > other
+}
+
+// String returns the version zero-padded to 20 digits, as in the names of the files of the Delta log
+func (version DeltaDataTypeVersion) String() string {
+	return fmt.Sprintf("%020d", int64(version))
+}
+
 var (
 	ErrorStateIsEmpty     error = errors.New("the state is empty")
 	ErrorCanNotReadState  error = errors.New("the state is could not be read")
 	ErrorCanNotWriteState error = errors.New("the state is could not be written")
+	ErrorCanNotPruneState error = errors.New("the state history could not be pruned")
 )
 
 // CommitState stores an attempt to  `source` into `destination` and `version` for the latest commit.
@@ -44,3 +87,10 @@ type StateStore interface {
 	// for a DeltaTable, the data will contain the current or prior locked commit version.
 	Put(CommitState) error
 }
+
+// Pruner is implemented by StateStores that can remove the commit states of past versions.
+// Stores that only keep the latest commit state implement it as a no-op.
+type Pruner interface {
+	// Prune deletes the commit states of versions before beforeVersion, always keeping the latest commit state.
+	Prune(beforeVersion DeltaDataTypeVersion) error
+}
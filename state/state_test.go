@@ -11,3 +11,27 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 package state
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeltaDataTypeVersion(t *testing.T) {
+	version := DeltaDataTypeVersion(9)
+	if version.Next() != 10 || version.Prev() != 8 || DeltaDataTypeVersion(0).Prev() != -1 {
+		t.Errorf("unexpected next %d and previous %d versions", version.Next(), version.Prev())
+	}
+	if version.Compare(10) != -1 || version.Compare(9) != 0 || version.Compare(8) != 1 {
+		t.Error("unexpected comparison")
+	}
+	if !version.Equal(9) || version.Equal(10) || !version.Before(10) || version.Before(9) || !version.After(8) || version.After(9) {
+		t.Error("unexpected comparison")
+	}
+	if version.String() != "00000000000000000009" || fmt.Sprintf("%v.json", version.Next()) != "00000000000000000010.json" {
+		t.Errorf("unexpected string %s", version)
+	}
+	if fmt.Sprintf("%d", version) != "9" {
+		t.Errorf("want the number with %%d, has %d", version)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrorInvalidTableProperty error = errors.New("invalid table property")
+	ErrorInvalidStatsValue    error = errors.New("invalid statistics value")
+)
+
+const (
+	// Table property limiting statistics collection to the given number of leading columns, -1 collects all columns
+	DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY = "delta.dataSkippingNumIndexedCols"
+	// The number of leading columns statistics are collected for when the property is not set
+	DEFAULT_DATA_SKIPPING_NUM_INDEXED_COLS = 32
+)
+
+// DataSkippingNumIndexedCols returns the number of leading columns that statistics are collected for,
+// as set by the delta.dataSkippingNumIndexedCols table property. -1 means all columns.
+func (dtmd *DeltaTableMetaData) DataSkippingNumIndexedCols() (int, error) {
+	value, ok := dtmd.Configuration[DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY]
+	if !ok {
+		return DEFAULT_DATA_SKIPPING_NUM_INDEXED_COLS, nil
+	}
+	numIndexedCols, err := strconv.Atoi(value)
+	if err != nil || numIndexedCols < -1 {
+		return 0, errors.Join(ErrorInvalidTableProperty, fmt.Errorf("%s=%s", DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY, value))
+	}
+	return numIndexedCols, nil
+}
+
+// IndexedColumns returns the columns that statistics are collected for: the leading leaf columns of the schema,
+// up to delta.dataSkippingNumIndexedCols. Partition columns are not part of the data files and are skipped.
+// Nested columns are named by their dot separated path, e.g. event.timestamp.
+func (dtmd *DeltaTableMetaData) IndexedColumns() ([]string, error) {
+	numIndexedCols, err := dtmd.DataSkippingNumIndexedCols()
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	var collect func(fields []SchemaField, prefix string)
+	collect = func(fields []SchemaField, prefix string) {
+		for _, field := range fields {
+			if numIndexedCols >= 0 && len(columns) >= numIndexedCols {
+				return
+			}
+			if prefix == "" && slices.Contains(dtmd.PartitionColumns, field.Name) {
+				continue
+			}
+			if field.Type == Struct {
+				collect(field.Fields, prefix+field.Name+".")
+				continue
+			}
+			columns = append(columns, prefix+field.Name)
+		}
+	}
+	collect(dtmd.Schema.Fields, "")
+	return columns, nil
+}
+
+// LimitToColumns removes the min, max and null count statistics of the columns that are not in columns.
+// Nested statistics are matched by their dot separated path. Returns true if any statistics were removed.
+func (s *Stats) LimitToColumns(columns []string) bool {
+	indexed := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		indexed[column] = true
+		// Keep the parents of nested columns
+		for i := strings.LastIndex(column, "."); i > 0; i = strings.LastIndex(column[:i], ".") {
+			indexed[column[:i]] = true
+		}
+	}
+	removedMin := limitStatsMap(s.MinValues, indexed, "")
+	removedMax := limitStatsMap(s.MaxValues, indexed, "")
+	removedNullCount := false
+	for _, counts := range []map[string]int64{s.NullCount, s.NestedNullCount} {
+		for column := range counts {
+			if !indexed[column] {
+				delete(counts, column)
+				removedNullCount = true
+			}
+		}
+	}
+	return removedMin || removedMax || removedNullCount
+}
+
+// limitStatsMap removes the entries of values, and of the nested maps within it, whose path is not indexed
+func limitStatsMap(values map[string]any, indexed map[string]bool, prefix string) bool {
+	removed := false
+	for key, value := range values {
+		path := prefix + key
+		if !indexed[path] {
+			delete(values, key)
+			removed = true
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok {
+			if limitStatsMap(nested, indexed, path+".") {
+				removed = true
+			}
+		}
+	}
+	return removed
+}
+
+// limitAddStats removes the statistics of columns that are not indexed by the table from the Add actions of
+// the transaction, so that written files match the statistics other Delta engines collect.
+// Statistics are left unchanged if the table metadata has not been loaded or they cannot be parsed.
+func (transaction *DeltaTransaction) limitAddStats() error {
+	metadata := transaction.DeltaTable.State.CurrentMetadata
+	if len(metadata.Schema.Fields) == 0 {
+		return nil
+	}
+	columns, err := metadata.IndexedColumns()
+	if err != nil {
+		return err
+	}
+	for i, action := range transaction.Actions {
+		add, ok := action.(Add)
+		if !ok || add.Stats == "" {
+			continue
+		}
+		var stats Stats
+		if err := json.Unmarshal([]byte(add.Stats), &stats); err != nil {
+			continue
+		}
+		if stats.LimitToColumns(columns) {
+			add.Stats = string(stats.Json())
+			transaction.Actions[i] = add
+		}
+	}
+	return nil
+}
+
+// TypedMinValues returns the min values of the leaf columns, keyed by their dot separated path, converted to Go
+// values according to the column types of the schema like TypedPartitionValues does.
+// Binary values are base64 encoded in the statistics. Columns that are not in the schema are skipped.
+func (s *Stats) TypedMinValues(schema Schema) (map[string]any, error) {
+	return typedStatsValues(s.LeafMinValues(), schema)
+}
+
+// TypedMaxValues returns the max values of the leaf columns converted like TypedMinValues
+func (s *Stats) TypedMaxValues(schema Schema) (map[string]any, error) {
+	return typedStatsValues(s.LeafMaxValues(), schema)
+}
+
+// typedStatsValues converts the leaf values of statistics to the types of their columns
+func typedStatsValues(values map[string]any, schema Schema) (map[string]any, error) {
+	typedValues := make(map[string]any, len(values))
+	for path, value := range values {
+		field, ok := leafField(schema.Fields, path)
+		if !ok {
+			continue
+		}
+		typedValue, err := parseStatsValue(field.Type, value)
+		if err != nil {
+			return nil, errors.Join(ErrorInvalidStatsValue, fmt.Errorf("column %s value %v", path, value), err)
+		}
+		typedValues[path] = typedValue
+	}
+	return typedValues, nil
+}
+
+// leafField returns the field at the dot separated path of nested struct fields
+func leafField(fields []SchemaField, path string) (SchemaField, bool) {
+	for _, field := range fields {
+		if field.Name == path {
+			return field, true
+		}
+		if field.Type == Struct && strings.HasPrefix(path, field.Name+".") {
+			return leafField(field.Fields, strings.TrimPrefix(path, field.Name+"."))
+		}
+	}
+	return SchemaField{}, false
+}
+
+// Layouts of timestamp statistics without a time zone, which are in UTC, as written by some versions of delta-rs
+var statsTimestampLayoutsWithoutZone = []string{"2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"}
+
+// parseStatsTimestamp parses an RFC 3339 timestamp statistic, or one without a time zone
+func parseStatsTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		return t, nil
+	}
+	for _, layout := range statsTimestampLayoutsWithoutZone {
+		if t, layoutErr := time.ParseInLocation(layout, value, time.UTC); layoutErr == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
+
+// plainNumber returns the literal of a JSON number without an exponent, so that it can be parsed as a decimal
+func plainNumber(value json.Number) string {
+	literal := value.String()
+	i := strings.IndexAny(literal, "eE")
+	if i < 0 {
+		return literal
+	}
+	exponent, err := strconv.Atoi(literal[i+1:])
+	if err != nil {
+		return literal
+	}
+	_, fraction, _ := strings.Cut(literal[:i], ".")
+	r, ok := new(big.Rat).SetString(literal)
+	if !ok {
+		return literal
+	}
+	return r.FloatString(max(len(fraction)-exponent, 0))
+}
+
+// parseStatsValue converts a value decoded from the JSON statistics into a value of the given type.
+// Numbers are decoded from JSON as json.Number and parsed from their exact literal, dates, timestamps and binary
+// values as strings. The NaN and infinite statistics of float and double columns, which JSON numbers cannot
+// represent, are strings. Statistics built in memory may also hold float64 numbers.
+func parseStatsValue(dataType SchemaDataType, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if precision, scale, ok := dataType.DecimalPrecisionScale(); ok {
+		switch value := value.(type) {
+		case json.Number:
+			return ParseDecimal(plainNumber(value), precision, scale)
+		case float64:
+			return ParseDecimal(strconv.FormatFloat(value, 'f', -1, 64), precision, scale)
+		case string:
+			return ParseDecimal(value, precision, scale)
+		}
+		return nil, fmt.Errorf("unexpected %T for %s", value, dataType)
+	}
+
+	switch value := value.(type) {
+	case json.Number:
+		switch dataType {
+		case Long:
+			return strconv.ParseInt(value.String(), 10, 64)
+		case Integer:
+			i, err := strconv.ParseInt(value.String(), 10, 32)
+			return int32(i), err
+		case Short:
+			i, err := strconv.ParseInt(value.String(), 10, 16)
+			return int16(i), err
+		case Byte:
+			i, err := strconv.ParseInt(value.String(), 10, 8)
+			return int8(i), err
+		case Float:
+			f, err := strconv.ParseFloat(value.String(), 32)
+			return float32(f), err
+		case Double:
+			return value.Float64()
+		}
+	case float64:
+		switch dataType {
+		case Long:
+			return int64(value), nil
+		case Integer:
+			return int32(value), nil
+		case Short:
+			return int16(value), nil
+		case Byte:
+			return int8(value), nil
+		case Float:
+			return float32(value), nil
+		case Double:
+			return value, nil
+		}
+	case bool:
+		if dataType == Boolean {
+			return value, nil
+		}
+	case string:
+		switch dataType {
+		case Binary:
+			return base64.StdEncoding.DecodeString(value)
+		case String:
+			return value, nil
+		case Date:
+			return time.ParseInLocation(partitionDateLayout, value, time.UTC)
+		case Timestamp:
+			return parseStatsTimestamp(value)
+		case Float, Double:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, err
+			}
+			if dataType == Float {
+				return float32(f), nil
+			}
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %T for %s", value, dataType)
+}
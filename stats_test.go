@@ -0,0 +1,214 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/rivian/delta-go/storage/filestore"
+)
+
+func TestDataSkippingNumIndexedCols(t *testing.T) {
+	for value, expected := range map[string]int{"": DEFAULT_DATA_SKIPPING_NUM_INDEXED_COLS, "5": 5, "0": 0, "-1": -1} {
+		metadata := DeltaTableMetaData{Configuration: map[string]string{}}
+		if value != "" {
+			metadata.Configuration[DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY] = value
+		}
+		numIndexedCols, err := metadata.DataSkippingNumIndexedCols()
+		if err != nil {
+			t.Error(err)
+		}
+		if numIndexedCols != expected {
+			t.Errorf("%s: want %d, has %d", value, expected, numIndexedCols)
+		}
+	}
+
+	for _, value := range []string{"abc", "-2"} {
+		metadata := DeltaTableMetaData{Configuration: map[string]string{DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY: value}}
+		_, err := metadata.DataSkippingNumIndexedCols()
+		if !errors.Is(err, ErrorInvalidTableProperty) {
+			t.Errorf("%s: want ErrorInvalidTableProperty, has %v", value, err)
+		}
+	}
+}
+
+func TestIndexedColumns(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{
+		{Name: "date", Type: String},
+		{Name: "id", Type: Long},
+		{Name: "event", Type: Struct, Fields: []SchemaField{{Name: "timestamp", Type: Timestamp}, {Name: "name", Type: String}}},
+		{Name: "value", Type: Double},
+	}}
+	for value, expected := range map[string][]string{
+		"-1": {"id", "event.timestamp", "event.name", "value"},
+		"2":  {"id", "event.timestamp"},
+		"0":  nil,
+	} {
+		metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{"date"}, map[string]string{DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY: value})
+		columns, err := metadata.IndexedColumns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(columns, expected) {
+			t.Errorf("%s: want %v, has %v", value, expected, columns)
+		}
+	}
+}
+
+func TestLimitToColumns(t *testing.T) {
+	var stats Stats
+	json.Unmarshal([]byte(`{"numRecords":2,"minValues":{"id":1,"event":{"timestamp":"2023-01-01","name":"a"},"value":1.5},"maxValues":{"id":2,"event":{"timestamp":"2023-01-02","name":"b"},"value":2.5},"nullCount":{"id":0,"value":1}}`), &stats)
+
+	if !stats.LimitToColumns([]string{"id", "event.timestamp"}) {
+		t.Error("stats should be removed")
+	}
+	expected := `{"numRecords":2,"tightBounds":true,"minValues":{"event":{"timestamp":"2023-01-01"},"id":1},"maxValues":{"event":{"timestamp":"2023-01-02"},"id":2},"nullCount":{"id":0}}`
+	if string(stats.Json()) != expected {
+		t.Errorf("want %s, has %s", expected, stats.Json())
+	}
+	if stats.LimitToColumns([]string{"id", "event.timestamp"}) {
+		t.Error("no stats should be removed")
+	}
+}
+
+func TestCommitLimitsStats(t *testing.T) {
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "label", Type: String}, {Name: "value", Type: Double}}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, map[string]string{DATA_SKIPPING_NUM_INDEXED_COLS_PROPERTY: "2"})
+	err := table.Create(*metadata, Protocol{}, CommitInfo{}, []Add{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "part-1.snappy.parquet", Stats: `{"numRecords":1,"minValues":{"id":1,"label":"a","value":1.5},"maxValues":{"id":1,"label":"a","value":1.5},"nullCount":{"id":0,"label":0,"value":0}}`})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	json.Unmarshal([]byte(table.State.Files["part-1.snappy.parquet"].Stats), &stats)
+	for _, values := range []map[string]any{stats.MinValues, stats.MaxValues} {
+		if _, ok := values["value"]; ok || len(values) != 2 {
+			t.Errorf("only the stats of id and label should be committed, has %v", values)
+		}
+	}
+	if _, ok := stats.NullCount["value"]; ok {
+		t.Errorf("only the null counts of id and label should be committed, has %v", stats.NullCount)
+	}
+}
+
+func TestNestedStats(t *testing.T) {
+	tmpDir := copyTestTable(t, "testdata/nested_stats")
+	table, err := OpenTable(filestore.New(storage.NewPath(tmpDir)), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := table.State.Files["part-00000.snappy.parquet"]
+	stats, err := add.ParseStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedMin := map[string]any{"id": json.Number("1"), "event.timestamp": "2023-01-01T00:00:00.000Z", "event.source.region": "eu"}
+	if !reflect.DeepEqual(stats.LeafMinValues(), expectedMin) {
+		t.Errorf("want %v, has %v", expectedMin, stats.LeafMinValues())
+	}
+	expectedMax := map[string]any{"id": json.Number("3"), "event.timestamp": "2023-01-02T00:00:00.000Z", "event.source.region": "us"}
+	if !reflect.DeepEqual(stats.LeafMaxValues(), expectedMax) {
+		t.Errorf("want %v, has %v", expectedMax, stats.LeafMaxValues())
+	}
+	expectedNullCount := map[string]int64{"id": 0, "event.timestamp": 1, "event.source.region": 0}
+	if !reflect.DeepEqual(stats.LeafNullCount(), expectedNullCount) {
+		t.Errorf("want %v, has %v", expectedNullCount, stats.LeafNullCount())
+	}
+
+	// Nested null counts are serialized back in the shape of the struct
+	var roundTrip Stats
+	err = json.Unmarshal(stats.Json(), &roundTrip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip, *stats) {
+		t.Errorf("want %+v, has %+v", *stats, roundTrip)
+	}
+
+	if !stats.LimitToColumns([]string{"id", "event.timestamp"}) {
+		t.Error("stats of event.source.region should be removed")
+	}
+	if _, ok := stats.LeafNullCount()["event.source.region"]; ok {
+		t.Errorf("null count of event.source.region should be removed, has %v", stats.LeafNullCount())
+	}
+}
+
+func TestTypedStatsValues(t *testing.T) {
+	schema := SchemaTypeStruct{Fields: []SchemaField{
+		{Name: "price", Type: DecimalType(10, 2)},
+		{Name: "enabled", Type: Boolean},
+		{Name: "payload", Type: Binary},
+		{Name: "event", Type: Struct, Fields: []SchemaField{{Name: "count", Type: Integer}, {Name: "ts", Type: Timestamp}}},
+	}}
+	add := Add{Stats: `{"numRecords":2,"minValues":{"price":12.3,"enabled":false,"payload":"AQID","event":{"count":1,"ts":"2023-01-01T00:00:00.000Z"},"other":1},` +
+		`"maxValues":{"price":1234.56,"enabled":true,"payload":"BAU=","event":{"count":5,"ts":"2023-01-02T00:00:00.000Z"}}}`}
+	stats, err := add.ParseStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minValues, err := stats.TypedMinValues(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price := minValues["price"].(Decimal); price.String() != "12.30" || price.Unscaled.Int64() != 1230 {
+		t.Errorf("price: has %v", price)
+	}
+	if minValues["enabled"] != false || !reflect.DeepEqual(minValues["payload"], []byte{1, 2, 3}) || minValues["event.count"] != int32(1) {
+		t.Errorf("unexpected min values %v", minValues)
+	}
+	if _, ok := minValues["other"]; ok {
+		t.Error("columns that are not in the schema should be skipped")
+	}
+
+	maxValues, err := stats.TypedMaxValues(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price := maxValues["price"].(Decimal); price.Unscaled.Int64() != 123456 {
+		t.Errorf("price: has %v", price)
+	}
+
+	// The decimal scale is applied when comparing the bounds of files
+	literal, _ := ParseDecimal("12.30", 10, 2)
+	if literal.Cmp(minValues["price"].(Decimal)) != 0 {
+		t.Errorf("12.30 should equal the min price %v", minValues["price"])
+	}
+
+	add = Add{Stats: `{"numRecords":1,"minValues":{"price":12.345}}`}
+	stats, _ = add.ParseStats()
+	_, err = stats.TypedMinValues(schema)
+	if !errors.Is(err, ErrorInvalidStatsValue) {
+		t.Errorf("want ErrorInvalidStatsValue, has %v", err)
+	}
+}
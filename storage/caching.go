@@ -0,0 +1,326 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"container/list"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The files of the Delta log that are never modified once written: commits, checkpoints, log compactions and checksums
+var immutableLogFileRegex = regexp.MustCompile(`^\d{20}\.(json|crc|\d{20}\.compacted\.json|checkpoint(\.[^/]+)?\.(parquet|json))$`)
+
+// IsImmutable returns whether the object at the location is never modified once written, so that it can be cached:
+// the commits, checkpoints, sidecars, log compactions and checksums of the Delta log, and the parquet data files and
+// deletion vector files of the table. Other files, such as _last_checkpoint, temporary commits, and the lock and state
+// files, are mutable.
+func IsImmutable(location *Path) bool {
+	location = NewPath(strings.ReplaceAll(location.Raw, "\\", "/"))
+	dir, base := path.Split(location.Raw)
+	switch {
+	case strings.HasSuffix(dir, "_delta_log/_sidecars/"):
+		return strings.HasSuffix(base, ".parquet")
+	case strings.HasSuffix(dir, "_delta_log/"):
+		return immutableLogFileRegex.MatchString(base)
+	case strings.Contains(dir, "_delta_log/"):
+		return false
+	}
+	return strings.HasSuffix(base, ".parquet") || strings.HasSuffix(base, ".bin")
+}
+
+// RangeGetter is implemented by object stores that can read a byte range of an object without reading it whole
+type RangeGetter interface {
+	/// Return the bytes of the object at the location from r.Start up to r.End exclusive
+	GetRange(location *Path, r Range) ([]byte, error)
+}
+
+// CacheMetrics counts the lookups of an LRUCache
+type CacheMetrics struct {
+	// The number of lookups that found the entry in the cache
+	Hits int64
+	// The number of lookups that did not find the entry in the cache
+	Misses int64
+	// The number of entries evicted to make room for newer entries
+	Evictions int64
+	// The number of entries and the total size in bytes of the entries in the cache
+	Entries int
+	Size    int64
+}
+
+// LRUCache is a cache of object data and metadata bounded by the total size of the cached data.
+// The least recently used entries are evicted first. It is safe for concurrent use.
+type LRUCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	order    *list.List
+	metrics  CacheMetrics
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+	meta ObjectMeta
+}
+
+// The size of an entry without data, accounting for its metadata
+const cacheEntryOverhead = 64
+
+func (entry *cacheEntry) size() int64 {
+	return int64(len(entry.data)) + int64(len(entry.key)) + cacheEntryOverhead
+}
+
+// NewLRUCache creates a cache holding up to maxBytes of data
+func NewLRUCache(maxBytes int64) *LRUCache {
+	cache := new(LRUCache)
+	cache.maxBytes = maxBytes
+	cache.entries = make(map[string]*list.Element)
+	cache.order = list.New()
+	return cache
+}
+
+// Metrics returns the counts of the cache lookups and the current content of the cache
+func (cache *LRUCache) Metrics() CacheMetrics {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	metrics := cache.metrics
+	metrics.Entries = cache.order.Len()
+	metrics.Size = cache.size
+	return metrics
+}
+
+func (cache *LRUCache) get(key string) (*cacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		cache.metrics.Misses++
+		return nil, false
+	}
+	cache.metrics.Hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*cacheEntry), true
+}
+
+// peek is get without counting a miss, for lookups that fall back to another entry
+func (cache *LRUCache) peek(key string) (*cacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cache.metrics.Hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*cacheEntry), true
+}
+
+// put adds the entry to the cache, unless it is larger than the whole cache
+func (cache *LRUCache) put(entry *cacheEntry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if entry.size() > cache.maxBytes {
+		return
+	}
+	if element, ok := cache.entries[entry.key]; ok {
+		cache.removeElement(element)
+	}
+	cache.entries[entry.key] = cache.order.PushFront(entry)
+	cache.size += entry.size()
+	for cache.size > cache.maxBytes {
+		cache.removeElement(cache.order.Back())
+		cache.metrics.Evictions++
+	}
+}
+
+// remove removes the entries whose key starts with the prefix
+func (cache *LRUCache) remove(prefix string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for key, element := range cache.entries {
+		if strings.HasPrefix(key, prefix) {
+			cache.removeElement(element)
+		}
+	}
+}
+
+func (cache *LRUCache) removeElement(element *list.Element) {
+	entry := cache.order.Remove(element).(*cacheEntry)
+	delete(cache.entries, entry.key)
+	cache.size -= entry.size()
+}
+
+// CachingStore is an ObjectStore that caches the data and metadata read from the inner store for the objects that are
+// immutable, according to IsImmutable unless Immutable is set. Reads of other objects, and all writes, go to the
+// inner store; the cached entries of deleted and overwritten objects are dropped.
+// The data returned for cached objects is shared between the readers and must not be modified.
+type CachingStore struct {
+	ObjectStore
+	Cache *LRUCache
+	// Predicate of the objects that are cached, IsImmutable when nil
+	Immutable func(location *Path) bool
+}
+
+// Compile time check that CachingStore implements ObjectStore and RangeGetter
+var _ ObjectStore = (*CachingStore)(nil)
+var _ RangeGetter = (*CachingStore)(nil)
+
+// NewCachingStore creates a store caching the immutable objects of the inner store in the cache.
+// A cache may be shared by the stores of different tables as long as their locations do not overlap.
+func NewCachingStore(inner ObjectStore, cache *LRUCache) *CachingStore {
+	store := new(CachingStore)
+	store.ObjectStore = inner
+	store.Cache = cache
+	return store
+}
+
+func (s *CachingStore) cached(location *Path) bool {
+	if s.Immutable == nil {
+		return IsImmutable(location)
+	}
+	return s.Immutable(location)
+}
+
+// The cache keys of an object, all starting with the location followed by a NUL, which cannot occur in a location
+func objectKey(location *Path) string {
+	return location.Raw + "\x00"
+}
+
+func rangeKey(location *Path, r Range) string {
+	return fmt.Sprintf("%s\x00%d-%d", location.Raw, r.Start, r.End)
+}
+
+func headKey(location *Path) string {
+	return location.Raw + "\x00head"
+}
+
+func (s *CachingStore) Get(location *Path) ([]byte, error) {
+	if !s.cached(location) {
+		return s.ObjectStore.Get(location)
+	}
+	data, _, err := s.GetWithMeta(location)
+	return data, err
+}
+
+func (s *CachingStore) GetWithMeta(location *Path) ([]byte, ObjectMeta, error) {
+	if !s.cached(location) {
+		return s.ObjectStore.GetWithMeta(location)
+	}
+	if entry, ok := s.Cache.get(objectKey(location)); ok {
+		return entry.data, entry.meta, nil
+	}
+	data, meta, err := s.ObjectStore.GetWithMeta(location)
+	if err != nil {
+		return nil, meta, err
+	}
+	s.Cache.put(&cacheEntry{key: objectKey(location), data: data, meta: meta})
+	return data, meta, nil
+}
+
+// GetIfNoneMatch returns a cached immutable object as not modified if etag is its ETag, or else the cached object
+func (s *CachingStore) GetIfNoneMatch(location *Path, etag string) ([]byte, ObjectMeta, bool, error) {
+	if !s.cached(location) {
+		return s.ObjectStore.GetIfNoneMatch(location, etag)
+	}
+	data, meta, err := s.GetWithMeta(location)
+	if err != nil {
+		return nil, meta, false, err
+	}
+	if etag != "" && etag == meta.ETag {
+		return nil, meta, true, nil
+	}
+	return data, meta, false, nil
+}
+
+// GetRange returns the bytes of the object at the location from r.Start up to r.End exclusive, from the cached object
+// if it was read whole. Ranges are read with the inner store's GetRange if it is a RangeGetter, and from the whole
+// object otherwise.
+func (s *CachingStore) GetRange(location *Path, r Range) ([]byte, error) {
+	if s.cached(location) {
+		if entry, ok := s.Cache.peek(objectKey(location)); ok {
+			return sliceRange(entry.data, r), nil
+		}
+		if entry, ok := s.Cache.get(rangeKey(location, r)); ok {
+			return entry.data, nil
+		}
+	}
+	rangeGetter, ok := s.ObjectStore.(RangeGetter)
+	if !ok {
+		data, err := s.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		return sliceRange(data, r), nil
+	}
+	data, err := rangeGetter.GetRange(location, r)
+	if err != nil {
+		return nil, err
+	}
+	if s.cached(location) {
+		s.Cache.put(&cacheEntry{key: rangeKey(location, r), data: data})
+	}
+	return data, nil
+}
+
+// sliceRange returns the bytes of data from r.Start up to r.End exclusive
+func sliceRange(data []byte, r Range) []byte {
+	start, end := r.Start, r.End
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	if start >= end {
+		return nil
+	}
+	return data[start:end]
+}
+
+func (s *CachingStore) Head(location *Path) (ObjectMeta, error) {
+	if !s.cached(location) {
+		return s.ObjectStore.Head(location)
+	}
+	if entry, ok := s.Cache.get(headKey(location)); ok {
+		return entry.meta, nil
+	}
+	meta, err := s.ObjectStore.Head(location)
+	if err != nil {
+		return meta, err
+	}
+	s.Cache.put(&cacheEntry{key: headKey(location), meta: meta})
+	return meta, nil
+}
+
+func (s *CachingStore) Put(location *Path, data []byte) error {
+	s.Cache.remove(objectKey(location))
+	return s.ObjectStore.Put(location, data)
+}
+
+func (s *CachingStore) Delete(location *Path) error {
+	s.Cache.remove(objectKey(location))
+	return s.ObjectStore.Delete(location)
+}
+
+func (s *CachingStore) Rename(from *Path, to *Path) error {
+	s.Cache.remove(objectKey(from))
+	s.Cache.remove(objectKey(to))
+	return s.ObjectStore.Rename(from, to)
+}
+
+func (s *CachingStore) RenameIfNotExists(from *Path, to *Path) error {
+	s.Cache.remove(objectKey(from))
+	return s.ObjectStore.RenameIfNotExists(from, to)
+}
@@ -0,0 +1,305 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory ObjectStore counting the reads of each object.
+// Its methods may be called concurrently, e.g. by the calls a TimeoutStore left running after their timeout.
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	reads   map[string]int
+}
+
+func newMemoryStore(objects map[string]string) *memoryStore {
+	store := &memoryStore{objects: make(map[string][]byte), reads: make(map[string]int)}
+	for location, data := range objects {
+		store.objects[location] = []byte(data)
+	}
+	return store
+}
+
+func (s *memoryStore) meta(location *Path) (ObjectMeta, error) {
+	data, ok := s.objects[location.Raw]
+	if !ok {
+		return ObjectMeta{}, NewStorageError("get", location, ErrorNotFound, errors.New("no such object"))
+	}
+	return ObjectMeta{Location: *location, Size: int64(len(data)), ETag: location.Raw}, nil
+}
+
+func (s *memoryStore) Put(location *Path, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[location.Raw] = data
+	return nil
+}
+
+func (s *memoryStore) Get(location *Path) ([]byte, error) {
+	data, _, err := s.GetWithMeta(location)
+	return data, err
+}
+
+func (s *memoryStore) GetWithMeta(location *Path) ([]byte, ObjectMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads[location.Raw]++
+	meta, err := s.meta(location)
+	return s.objects[location.Raw], meta, err
+}
+
+func (s *memoryStore) GetIfNoneMatch(location *Path, etag string) ([]byte, ObjectMeta, bool, error) {
+	data, meta, err := s.GetWithMeta(location)
+	return data, meta, err == nil && etag == meta.ETag, err
+}
+
+func (s *memoryStore) Head(location *Path) (ObjectMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads[location.Raw]++
+	return s.meta(location)
+}
+
+func (s *memoryStore) Delete(location *Path) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, location.Raw)
+	return nil
+}
+
+func (s *memoryStore) List(prefix *Path) ([]ObjectMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var metas []ObjectMeta
+	for location := range s.objects {
+		if strings.HasPrefix(location, prefix.Raw) {
+			meta, _ := s.meta(NewPath(location))
+			metas = append(metas, meta)
+		}
+	}
+	return metas, nil
+}
+
+func (s *memoryStore) ListModifiedAfter(prefix *Path, since time.Time) ([]ObjectMeta, error) {
+	return s.List(prefix)
+}
+
+func (s *memoryStore) Rename(from *Path, to *Path) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[to.Raw] = s.objects[from.Raw]
+	delete(s.objects, from.Raw)
+	return nil
+}
+
+func (s *memoryStore) RenameIfNotExists(from *Path, to *Path) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[to.Raw]; ok {
+		return NewStorageError("rename", to, ErrorAlreadyExists, ErrorVersionAlreadyExists)
+	}
+	s.objects[to.Raw] = s.objects[from.Raw]
+	delete(s.objects, from.Raw)
+	return nil
+}
+
+func (s *memoryStore) RootURI() string {
+	return "memory://"
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// rangeMemoryStore is a memoryStore that can read byte ranges
+type rangeMemoryStore struct {
+	*memoryStore
+}
+
+func (s rangeMemoryStore) GetRange(location *Path, r Range) ([]byte, error) {
+	data, err := s.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, r), nil
+}
+
+func TestIsImmutable(t *testing.T) {
+	tests := []struct {
+		location string
+		expected bool
+	}{
+		{"_delta_log/00000000000000000001.json", true},
+		{"_delta_log/00000000000000000010.checkpoint.parquet", true},
+		{"_delta_log/00000000000000000010.checkpoint.0000000001.0000000002.parquet", true},
+		{"_delta_log/00000000000000000010.checkpoint.80a083e8-7026-4e79-81be-64bd76c43a11.json", true},
+		{"_delta_log/00000000000000000001.00000000000000000003.compacted.json", true},
+		{"_delta_log/00000000000000000001.crc", true},
+		{"_delta_log/_sidecars/3a0d65cd-4056-49b8-937b-95f9e3ee90e5.parquet", true},
+		{"tables/t1/_delta_log/00000000000000000001.json", true},
+		{"date=2023-01-01/part-00000.snappy.parquet", true},
+		{"part-00000.snappy.parquet", true},
+		{"deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin", true},
+		{"_delta_log/_last_checkpoint", false},
+		{"_delta_log/_commit.state", false},
+		{"_delta_log/_commit.lock", false},
+		{"_delta_log/_commit_80a083e8-7026-4e79-81be-64bd76c43a11.json.tmp", false},
+		{"_delta_log/1.json", false},
+		{"_delta_log/_staged_commits/00000000000000000001.parquet", false},
+		{"README.md", false},
+	}
+	for _, test := range tests {
+		if immutable := IsImmutable(NewPath(test.location)); immutable != test.expected {
+			t.Errorf("%s: want %t, has %t", test.location, test.expected, immutable)
+		}
+	}
+}
+
+func TestCachingStore(t *testing.T) {
+	inner := newMemoryStore(map[string]string{
+		"_delta_log/00000000000000000000.json": "commit",
+		"_delta_log/_last_checkpoint":          `{"version":0}`,
+	})
+	cache := NewLRUCache(1024)
+	store := NewCachingStore(inner, cache)
+
+	for i := 0; i < 3; i++ {
+		data, err := store.Get(NewPath("_delta_log/00000000000000000000.json"))
+		if err != nil || string(data) != "commit" {
+			t.Fatalf("unexpected commit %s %v", data, err)
+		}
+		if _, err := store.Get(NewPath("_delta_log/_last_checkpoint")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reads := inner.reads["_delta_log/00000000000000000000.json"]; reads != 1 {
+		t.Errorf("the commit should be read once, has %d reads", reads)
+	}
+	if reads := inner.reads["_delta_log/_last_checkpoint"]; reads != 3 {
+		t.Errorf("_last_checkpoint should never be cached, has %d reads", reads)
+	}
+	metrics := cache.Metrics()
+	if metrics.Hits != 2 || metrics.Misses != 1 || metrics.Entries != 1 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+
+	_, _, notModified, err := store.GetIfNoneMatch(NewPath("_delta_log/00000000000000000000.json"), "_delta_log/00000000000000000000.json")
+	if err != nil || !notModified {
+		t.Errorf("want not modified, has %t %v", notModified, err)
+	}
+
+	// Missing objects are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := store.Get(NewPath("_delta_log/00000000000000000001.json")); !errors.Is(err, ErrorObjectDoesNotExist) {
+			t.Errorf("want ErrorObjectDoesNotExist, has %v", err)
+		}
+	}
+	if reads := inner.reads["_delta_log/00000000000000000001.json"]; reads != 2 {
+		t.Errorf("a missing commit should not be cached, has %d reads", reads)
+	}
+
+	// Heads are cached separately
+	for i := 0; i < 2; i++ {
+		meta, err := store.Head(NewPath("_delta_log/00000000000000000000.json"))
+		if err != nil || meta.Size != 6 {
+			t.Errorf("unexpected metadata %+v %v", meta, err)
+		}
+	}
+	if reads := inner.reads["_delta_log/00000000000000000000.json"]; reads != 2 {
+		t.Errorf("the commit should be read and headed once, has %d reads", reads)
+	}
+
+	// Deleted and overwritten objects are dropped from the cache
+	if err := store.Delete(NewPath("_delta_log/00000000000000000000.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(NewPath("_delta_log/00000000000000000000.json")); !errors.Is(err, ErrorObjectDoesNotExist) {
+		t.Errorf("want ErrorObjectDoesNotExist after the delete, has %v", err)
+	}
+	if err := store.Put(NewPath("part-1.parquet"), []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	store.Get(NewPath("part-1.parquet"))
+	if err := store.Put(NewPath("part-1.parquet"), []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := store.Get(NewPath("part-1.parquet")); string(data) != "v2" {
+		t.Errorf("want the overwritten data, has %s", data)
+	}
+}
+
+func TestCachingStoreRanges(t *testing.T) {
+	inner := newMemoryStore(map[string]string{"part-1.parquet": "0123456789", "part-2.parquet": "abcdefghij"})
+	store := NewCachingStore(rangeMemoryStore{inner}, NewLRUCache(1024))
+
+	for i := 0; i < 2; i++ {
+		data, err := store.GetRange(NewPath("part-1.parquet"), Range{Start: 6, End: 10})
+		if err != nil || string(data) != "6789" {
+			t.Errorf("want 6789, has %s %v", data, err)
+		}
+	}
+	if reads := inner.reads["part-1.parquet"]; reads != 1 {
+		t.Errorf("the range should be read once, has %d reads", reads)
+	}
+
+	// Ranges of a whole cached object are served from it
+	store.Get(NewPath("part-2.parquet"))
+	data, err := store.GetRange(NewPath("part-2.parquet"), Range{Start: 0, End: 3})
+	if err != nil || string(data) != "abc" {
+		t.Errorf("want abc, has %s %v", data, err)
+	}
+	if reads := inner.reads["part-2.parquet"]; reads != 1 {
+		t.Errorf("the object should be read once, has %d reads", reads)
+	}
+
+	// Without a range getter the whole object is read, and cached
+	store = NewCachingStore(newMemoryStore(map[string]string{"part-3.parquet": "klmnopqrst"}), NewLRUCache(1024))
+	data, err = store.GetRange(NewPath("part-3.parquet"), Range{Start: 8, End: 20})
+	if err != nil || string(data) != "st" {
+		t.Errorf("want st, has %s %v", data, err)
+	}
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	inner := newMemoryStore(nil)
+	for _, location := range []string{"a.parquet", "b.parquet", "c.parquet"} {
+		inner.objects[location] = make([]byte, 100)
+	}
+	inner.objects["large.parquet"] = make([]byte, 1000)
+	// Room for two of the small objects only
+	cache := NewLRUCache(2 * (100 + int64(len("a.parquet\x00")) + cacheEntryOverhead))
+	store := NewCachingStore(inner, cache)
+
+	for _, location := range []string{"a.parquet", "b.parquet", "a.parquet", "c.parquet", "a.parquet", "b.parquet", "large.parquet", "large.parquet"} {
+		if _, err := store.Get(NewPath(location)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// b is evicted by c as a was used more recently, then c by b; the large object never fits
+	expected := map[string]int{"a.parquet": 1, "b.parquet": 2, "c.parquet": 1, "large.parquet": 2}
+	for location, reads := range expected {
+		if inner.reads[location] != reads {
+			t.Errorf("%s: want %d reads, has %d", location, reads, inner.reads[location])
+		}
+	}
+	metrics := cache.Metrics()
+	if metrics.Evictions != 2 || metrics.Entries != 2 || metrics.Size > 2*(100+int64(len("a.parquet\x00"))+cacheEntryOverhead) {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
@@ -15,11 +15,16 @@ package filestore
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/rivian/delta-go/storage"
 )
@@ -27,87 +32,291 @@ import (
 // FileObjectStore provides local file storage
 type FileObjectStore struct {
 	BaseURI *storage.Path
+	// IgnoreMissing makes Delete succeed when the file does not exist, so that deletes can be retried,
+	// for instance by a vacuum re-run after a partial failure. Other errors are still returned.
+	IgnoreMissing bool
 }
 
-// Compile time check that FileObjectStore implements storage.ObjectStore
+// Compile time check that FileObjectStore implements storage.ObjectStore, storage.BulkHeader, storage.ReaderPutter
+// and storage.Presigner
 var _ storage.ObjectStore = (*FileObjectStore)(nil)
+var _ storage.BulkHeader = (*FileObjectStore)(nil)
+var _ storage.ReaderPutter = (*FileObjectStore)(nil)
+var _ storage.Presigner = (*FileObjectStore)(nil)
 
+// New creates a store of the files under the baseURI directory.
+// The baseURI is stored in a canonical form, cleaned and without a trailing separator, so that "/data/table",
+// "/data/table/" and "/data/./table" are the same store.
 func New(baseURI *storage.Path) *FileObjectStore {
 	fs := new(FileObjectStore)
-	fs.BaseURI = baseURI
+	fs.BaseURI = storage.NewPath(filepath.Clean(baseURI.Raw))
 	return fs
 }
 
+// RootURI returns the absolute file:// URI of the store root
+func (s *FileObjectStore) RootURI() string {
+	absPath, err := filepath.Abs(s.BaseURI.Raw)
+	if err != nil {
+		absPath = s.BaseURI.Raw
+	}
+	rootURL := url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}
+	return rootURL.String()
+}
+
+// PresignGet returns ErrorUnsupported, local files cannot be presigned
+func (s *FileObjectStore) PresignGet(location *storage.Path, expiry time.Duration) (string, error) {
+	return "", storage.NewStorageError("presign", location, storage.ErrorUnknown, storage.ErrorUnsupported)
+}
+
+// PresignPut returns ErrorUnsupported, local files cannot be presigned
+func (s *FileObjectStore) PresignPut(location *storage.Path, expiry time.Duration) (string, error) {
+	return "", storage.NewStorageError("presign", location, storage.ErrorUnknown, storage.ErrorUnsupported)
+}
+
+// Close is a no-op, a FileObjectStore holds no resources
+func (s *FileObjectStore) Close() error {
+	return nil
+}
+
 func (s *FileObjectStore) Put(location *storage.Path, bytes []byte) error {
 	writePath := filepath.Join(s.BaseURI.Raw, location.Raw)
 	err := os.MkdirAll(filepath.Dir(writePath), 0700)
 	if err != nil {
-		return err
+		return storageError("put", location, storage.ErrorPutObject, err)
 	}
 	err = os.WriteFile(writePath, bytes, 0700)
-	return err
+	if err != nil {
+		return storageError("put", location, storage.ErrorPutObject, err)
+	}
+	return nil
+}
+
+// PutReader writes the data read from the reader to the location.
+// The data is written to a temporary file first so that a failed write does not leave a partial object.
+func (s *FileObjectStore) PutReader(location *storage.Path, reader io.Reader) error {
+	writePath := filepath.Join(s.BaseURI.Raw, location.Raw)
+	err := os.MkdirAll(filepath.Dir(writePath), 0700)
+	if err != nil {
+		return storageError("put", location, storage.ErrorPutObject, err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(writePath), "."+filepath.Base(writePath)+".*.tmp")
+	if err != nil {
+		return storageError("put", location, storage.ErrorPutObject, err)
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0700)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), writePath)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return storageError("put", location, storage.ErrorPutObject, err)
+	}
+	return nil
 }
 
+// RenameIfNotExists moves from to to, failing with ErrorVersionAlreadyExists if to exists.
+// The destination is created with a hard link, which atomically fails if it exists, so concurrent writers
+// cannot overwrite each other. On filesystems without hard links the destination is created exclusively
+// (O_CREATE|O_EXCL) and the data is copied into it.
 func (s *FileObjectStore) RenameIfNotExists(from *storage.Path, to *storage.Path) error {
+	f := s.BaseURI.Join(from)
+	t := s.BaseURI.Join(to)
+	// the destination may be in a directory that does not exist yet, e.g. a new partition
+	err := os.MkdirAll(filepath.Dir(t.Raw), 0700)
+	if err != nil {
+		return storageError("rename", to, storage.ErrorCopyObject, err)
+	}
 
-	// return ErrorVersionAlreadyExists if the destination file exists
-	_, err := s.Head(to)
-	if !errors.Is(err, storage.ErrorObjectDoesNotExist) {
-		return fmt.Errorf("error %w: Object at location %s already exists", storage.ErrorVersionAlreadyExists, to.Raw)
+	err = os.Link(f.Raw, t.Raw)
+	if err != nil && !errors.Is(err, fs.ErrExist) && !errors.Is(err, fs.ErrNotExist) {
+		err = copyIfNotExists(f.Raw, t.Raw)
+	}
+	// An existing destination is reported even when the source is missing
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, statErr := os.Lstat(t.Raw); statErr == nil {
+			err = fs.ErrExist
+		}
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return storage.NewStorageError("rename", to, storage.ErrorAlreadyExists,
+			fmt.Errorf("error %w: Object at location %s already exists", storage.ErrorVersionAlreadyExists, to.Raw))
 	}
-	// rename source to destination
-	err = s.Rename(from, to)
 	if err != nil {
-		return err
+		return storageError("rename", from, storage.ErrorCopyObject, err)
 	}
+
+	// The destination is in place, a leftover source is only a stray temporary file
+	os.Remove(f.Raw)
 	return nil
+}
 
+// copyIfNotExists copies the file at from to a new file at to, failing with fs.ErrExist if to exists
+func copyIfNotExists(from string, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	destination, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0700)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(destination, source)
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(to)
+	}
+	return err
 }
 
 func (s *FileObjectStore) Get(location *storage.Path) ([]byte, error) {
 	filePath := filepath.Join(s.BaseURI.Raw, location.Raw)
 	data, err := os.ReadFile(filePath)
-	return data, err
+	if err != nil {
+		if isDir(filePath) {
+			return nil, dirError("get", location, storage.ErrorGetObject)
+		}
+		return nil, storageError("get", location, storage.ErrorGetObject, err)
+	}
+	return data, nil
+}
+
+func (s *FileObjectStore) GetWithMeta(location *storage.Path) ([]byte, storage.ObjectMeta, error) {
+	meta, err := s.Head(location)
+	if err != nil {
+		return nil, meta, err
+	}
+	data, err := s.Get(location)
+	if err != nil {
+		return nil, meta, err
+	}
+	// The file may have been replaced between the stat and the read
+	meta.Size = int64(len(data))
+	return data, meta, nil
+}
+
+// GetIfNoneMatch compares etag with the modification time and size of the file, and only reads the
+// file if they differ
+func (s *FileObjectStore) GetIfNoneMatch(location *storage.Path, etag string) ([]byte, storage.ObjectMeta, bool, error) {
+	meta, err := s.Head(location)
+	if err != nil {
+		return nil, meta, false, err
+	}
+	if etag != "" && meta.ETag == etag {
+		return nil, meta, true, nil
+	}
+	data, meta, err := s.GetWithMeta(location)
+	return data, meta, false, err
+}
+
+// fileETag derives an entity tag for a file from its modification time and size
+func fileETag(info fs.FileInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size())
 }
 
 func (s *FileObjectStore) Head(location *storage.Path) (storage.ObjectMeta, error) {
 	filePath := filepath.Join(s.BaseURI.Raw, location.Raw)
 	var meta storage.ObjectMeta
 	info, err := os.Stat(filePath)
-	if os.IsNotExist(err) {
-		return meta, errors.Join(storage.ErrorObjectDoesNotExist, err)
+	if err != nil {
+		return meta, storageError("head", location, storage.ErrorHeadObject, err)
 	}
 	meta.Size = info.Size()
-	meta.Location = storage.Path{Raw: filePath}
+	// Relative to the store like the locations of List, whatever the form of the BaseURI
+	meta.Location = *location
 	meta.LastModified = info.ModTime()
+	meta.ETag = fileETag(info)
 
 	if info.IsDir() {
-		return meta, storage.ErrorObjectIsDir
+		return meta, storage.NewStorageError("head", location, storage.ErrorUnknown, storage.ErrorObjectIsDir)
 	}
 
 	return meta, nil
 }
 
+// HeadBulk returns the metadata of the files one after the other
+func (s *FileObjectStore) HeadBulk(locations []*storage.Path) ([]storage.ObjectMeta, []error) {
+	metas := make([]storage.ObjectMeta, len(locations))
+	errs := make([]error, len(locations))
+	for i, location := range locations {
+		metas[i], errs[i] = s.Head(location)
+	}
+	return metas, errs
+}
+
 func (s *FileObjectStore) Rename(from *storage.Path, to *storage.Path) error {
 	// rename source to destination
 	f := s.BaseURI.Join(from)
 	t := s.BaseURI.Join(to)
-	err := os.Rename(f.Raw, t.Raw)
+	// the destination may be in a directory that does not exist yet, e.g. a new partition
+	err := os.MkdirAll(filepath.Dir(t.Raw), 0700)
 	if err != nil {
-		return errors.Join(storage.ErrorObjectDoesNotExist, err)
+		return storageError("rename", to, storage.ErrorCopyObject, err)
 	}
-	return err
+	err = os.Rename(f.Raw, t.Raw)
+	if err != nil {
+		return storageError("rename", from, storage.ErrorCopyObject, err)
+	}
+	return nil
 }
 
 func (s *FileObjectStore) Delete(location *storage.Path) error {
 	filePath := filepath.Join(s.BaseURI.Raw, location.Raw)
+	// os.Remove would delete an empty directory
+	if isDir(filePath) {
+		return dirError("delete", location, storage.ErrorDeleteObject)
+	}
 	err := os.Remove(filePath)
 	if err != nil {
-		return errors.Join(storage.ErrorDeleteObject, err)
+		if s.IgnoreMissing && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return storageError("delete", location, storage.ErrorDeleteObject, err)
 	}
 	return nil
 }
 
+// storageError wraps the error of an os call in a storage.StorageError, categorized by its cause
+func storageError(operation string, location *storage.Path, sentinel error, err error) error {
+	return storage.NewStorageError(operation, location, errorCategory(err), errors.Join(sentinel, err))
+}
+
+// dirError reports that an operation was attempted on a directory rather than an object.
+// The error matches both storage.ErrorObjectIsDir and the sentinel of the operation.
+func dirError(operation string, location *storage.Path, sentinel error) error {
+	return storage.NewStorageError(operation, location, storage.ErrorUnknown, errors.Join(sentinel, storage.ErrorObjectIsDir))
+}
+
+// isDir returns whether the file path is an existing directory
+func isDir(filePath string) bool {
+	info, err := os.Stat(filePath)
+	return err == nil && info.IsDir()
+}
+
+// errorCategory maps an os error to a storage.StorageError category
+func errorCategory(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return storage.ErrorNotFound
+	case errors.Is(err, fs.ErrExist):
+		return storage.ErrorAlreadyExists
+	case errors.Is(err, fs.ErrPermission):
+		return storage.ErrorAccessDenied
+	case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR), errors.Is(err, os.ErrDeadlineExceeded):
+		return storage.ErrorTransient
+	default:
+		return storage.ErrorUnknown
+	}
+}
+
 // / Convert an fs.FileInfo to a storage.ObjectMeta
 func objectMetaFromFileInfo(info fs.FileInfo, name string, isDir bool, parentDir string, trimPrefix string) (*storage.ObjectMeta, error) {
 	meta := new(storage.ObjectMeta)
@@ -170,10 +379,18 @@ func listFilesInDirRecursively(baseURI string, dir string, prefix string) ([]sto
 	return out, nil
 }
 
+// List lists the files and directories with the given prefix, sorted lexicographically by location like the
+// listings of S3 and GCS, rather than in the order of the directory entries which depends on the filesystem
 func (s *FileObjectStore) List(prefix *storage.Path) ([]storage.ObjectMeta, error) {
 	dir, filePrefix := filepath.Split(prefix.Raw)
 
-	fullDir := filepath.Join(s.BaseURI.Raw, dir)
+	// The base of a store not created with New may not be in its canonical form
+	root := filepath.Clean(s.BaseURI.Raw)
+	fullDir := filepath.Join(root, dir)
+	// A prefix such as ../t1_backup/ would list a sibling directory of the store
+	if relDir, err := filepath.Rel(root, fullDir); err != nil || relDir == ".." || strings.HasPrefix(relDir, ".."+string(filepath.Separator)) {
+		return nil, storageError("list", prefix, storage.ErrorListObjects, storage.ErrorPathOutsideStore)
+	}
 
 	// If filePrefix was "", make sure fullDir includes a trailing separator.
 	// Otherwise we will return results in the parent directory that start with the same
@@ -184,30 +401,48 @@ func (s *FileObjectStore) List(prefix *storage.Path) ([]storage.ObjectMeta, erro
 
 	// baseURI will be trimmed from the beginning of the results returned.
 	// It must have a trailing separator.
-	baseURI := s.BaseURI.Raw
+	baseURI := root
 	if !os.IsPathSeparator(baseURI[len(baseURI)-1]) {
 		baseURI += string(filepath.Separator)
 	}
 
 	files, err := listFilesInDirRecursively(baseURI, fullDir, filePrefix)
 	if err != nil {
-		return nil, errors.Join(storage.ErrorListObjects, err)
+		return nil, storageError("list", prefix, storage.ErrorListObjects, err)
 	}
 
 	// If the prefix passed in was a directory, add the root directory explicitly
 	if dir != "" && filePrefix == "" {
-		info, err := os.Stat(filepath.Join(s.BaseURI.Raw, dir))
+		info, err := os.Stat(filepath.Join(root, dir))
 		// If we get an error the directory doesn't exist, that's okay
 		if err != nil && !os.IsNotExist(err) {
-			return nil, errors.Join(storage.ErrorListObjects, err)
+			return nil, storageError("list", prefix, storage.ErrorListObjects, err)
 		}
 		if err == nil {
 			meta, err := objectMetaFromFileInfo(info, dir, true, "", baseURI)
 			if err != nil {
-				return nil, errors.Join(storage.ErrorListObjects, err)
+				return nil, storageError("list", prefix, storage.ErrorListObjects, err)
 			}
 			files = append(files, *meta)
 		}
 	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Location.Raw < files[j].Location.Raw
+	})
 	return files, nil
 }
+
+// ListModifiedAfter lists the files and directories with the given prefix whose modification time is after since
+func (s *FileObjectStore) ListModifiedAfter(prefix *storage.Path, since time.Time) ([]storage.ObjectMeta, error) {
+	objects, err := s.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	modified := make([]storage.ObjectMeta, 0, len(objects))
+	for _, object := range objects {
+		if object.LastModified.After(since) {
+			modified = append(modified, object)
+		}
+	}
+	return modified, nil
+}
@@ -14,11 +14,16 @@ package filestore
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/rivian/delta-go/storage"
 )
@@ -51,6 +56,73 @@ func TestPut(t *testing.T) {
 	}
 }
 
+func TestPutReader(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := FileObjectStore{BaseURI: storage.NewPath(tmpDir)}
+
+	putPath := storage.NewPath("data/part-0.parquet")
+	err := store.PutReader(putPath, strings.NewReader("some data"))
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, putPath.Raw))
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	if string(data) != "some data" {
+		t.Errorf("file has: %s, want 'some data'", string(data))
+	}
+
+	// A failed read leaves no object behind
+	failedPath := storage.NewPath("data/part-1.parquet")
+	err = store.PutReader(failedPath, iotest.ErrReader(errors.New("read failed")))
+	if !errors.Is(err, storage.ErrorPutObject) {
+		t.Errorf("err = %e;", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "data"))
+	if len(entries) != 1 {
+		t.Errorf("want only part-0.parquet, has %v", entries)
+	}
+}
+
+func TestPresign(t *testing.T) {
+	store := FileObjectStore{BaseURI: storage.NewPath(t.TempDir())}
+	_, err := store.PresignGet(storage.NewPath("part-0.parquet"), time.Minute)
+	if !errors.Is(err, storage.ErrorUnsupported) {
+		t.Errorf("err = %e;", err)
+	}
+	_, err = store.PresignPut(storage.NewPath("part-0.parquet"), time.Minute)
+	if !errors.Is(err, storage.ErrorUnsupported) {
+		t.Errorf("err = %e;", err)
+	}
+}
+
+func TestHeadBulk(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := FileObjectStore{BaseURI: storage.NewPath(tmpDir)}
+
+	paths := []*storage.Path{storage.NewPath("first.json"), storage.NewPath("second.json"), storage.NewPath("missing.json")}
+	for i, path := range paths[:2] {
+		err := store.Put(path, []byte(strings.Repeat("x", i+1)))
+		if err != nil {
+			t.Fatalf("err = %e;", err)
+		}
+	}
+
+	metas, errs := store.HeadBulk(paths)
+	for i := 0; i < 2; i++ {
+		if errs[i] != nil {
+			t.Errorf("err = %e;", errs[i])
+		}
+		if metas[i].Size != int64(i+1) {
+			t.Errorf("file size: %d, want size=%d", metas[i].Size, i+1)
+		}
+	}
+	if !errors.Is(errs[2], storage.ErrorObjectDoesNotExist) {
+		t.Errorf("err = %e;", errs[2])
+	}
+}
+
 func TestHead(t *testing.T) {
 
 	tmpDir := t.TempDir()
@@ -90,6 +162,80 @@ func TestHead(t *testing.T) {
 
 }
 
+func TestGetWithMeta(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	tmpPath := storage.NewPath(tmpDir)
+	store := FileObjectStore{BaseURI: tmpPath}
+
+	putPath := storage.NewPath("test_file.json")
+	_, _, err := store.GetWithMeta(putPath)
+	if !errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		t.Errorf("err = %e;", err)
+	}
+
+	err = store.Put(putPath, []byte("some data"))
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+
+	data, meta, err := store.GetWithMeta(putPath)
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	if string(data) != "some data" {
+		t.Errorf("file has: %s, want 'some data'", string(data))
+	}
+	if meta.Size != 9 {
+		t.Errorf("file size: %d, want size=9", meta.Size)
+	}
+	if meta.LastModified.IsZero() {
+		t.Errorf("LastModified should be set")
+	}
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	tmpPath := storage.NewPath(tmpDir)
+	store := FileObjectStore{BaseURI: tmpPath}
+
+	putPath := storage.NewPath("_last_checkpoint")
+	err := store.Put(putPath, []byte("some data"))
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+
+	data, meta, notModified, err := store.GetIfNoneMatch(putPath, "")
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	if notModified || string(data) != "some data" {
+		t.Errorf("file has: %s, want 'some data'", string(data))
+	}
+
+	_, _, notModified, err = store.GetIfNoneMatch(putPath, meta.ETag)
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	if !notModified {
+		t.Errorf("file should not be modified")
+	}
+
+	// Changing the size changes the ETag
+	err = store.Put(putPath, []byte("some other data"))
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	data, _, notModified, err = store.GetIfNoneMatch(putPath, meta.ETag)
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	if notModified || string(data) != "some other data" {
+		t.Errorf("file has: %s, want 'some other data'", string(data))
+	}
+}
+
 func TestRenameIfNotExists(t *testing.T) {
 
 	tmpDir := t.TempDir()
@@ -126,6 +272,96 @@ func TestRenameIfNotExists(t *testing.T) {
 	}
 }
 
+func TestRenameIfNotExistsConcurrent(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := FileObjectStore{BaseURI: storage.NewPath(tmpDir)}
+	toPath := storage.NewPath("_delta_log/00000000000000000001.json")
+
+	const writers = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := []int{}
+	for i := 0; i < writers; i++ {
+		fromPath := storage.NewPath(fmt.Sprintf("_delta_log/_commit_%d.json.tmp", i))
+		err := store.Put(fromPath, []byte(fmt.Sprintf("writer %d", i)))
+		if err != nil {
+			t.Fatalf("err = %e;", err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.RenameIfNotExists(fromPath, toPath)
+			if err == nil {
+				mu.Lock()
+				winners = append(winners, i)
+				mu.Unlock()
+			} else if !errors.Is(err, storage.ErrorVersionAlreadyExists) {
+				t.Errorf("err = %e;", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(winners) != 1 {
+		t.Fatalf("want exactly one successful rename, has %d", len(winners))
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, toPath.Raw))
+	if err != nil {
+		t.Errorf("err = %e;", err)
+	}
+	if string(data) != fmt.Sprintf("writer %d", winners[0]) {
+		t.Errorf("file has: %s, want the data of writer %d", string(data), winners[0])
+	}
+}
+
+func TestStorageErrors(t *testing.T) {
+
+	tmpDir := t.TempDir()
+
+	tmpPath := storage.NewPath(tmpDir)
+	store := FileObjectStore{BaseURI: tmpPath}
+	missingPath := storage.NewPath("missing.json")
+
+	_, err := store.Get(missingPath)
+	var storageErr *storage.StorageError
+	if !errors.As(err, &storageErr) {
+		t.Fatalf("err = %v; want a StorageError", err)
+	}
+	if storageErr.Operation != "get" || storageErr.Location != missingPath.Raw {
+		t.Errorf("operation = %s, location = %s; want get %s", storageErr.Operation, storageErr.Location, missingPath.Raw)
+	}
+	for _, target := range []error{storage.ErrorNotFound, storage.ErrorObjectDoesNotExist, storage.ErrorGetObject} {
+		if !errors.Is(err, target) {
+			t.Errorf("err = %v; want %v", err, target)
+		}
+	}
+
+	for name, err := range map[string]error{
+		"delete": store.Delete(missingPath),
+		"rename": store.Rename(missingPath, storage.NewPath("other.json")),
+	} {
+		if !errors.Is(err, storage.ErrorNotFound) {
+			t.Errorf("%s: err = %v; want ErrorNotFound", name, err)
+		}
+	}
+
+	err = store.Put(missingPath, []byte("some data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(storage.NewPath("data.json.tmp"), []byte("some data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.RenameIfNotExists(storage.NewPath("data.json.tmp"), missingPath)
+	if !errors.Is(err, storage.ErrorAlreadyExists) || !errors.Is(err, storage.ErrorVersionAlreadyExists) {
+		t.Errorf("err = %v; want ErrorAlreadyExists and ErrorVersionAlreadyExists", err)
+	}
+	if errors.Is(err, storage.ErrorNotFound) {
+		t.Errorf("err = %v; should not be ErrorNotFound", err)
+	}
+}
+
 func TestDelete(t *testing.T) {
 
 	tmpDir := t.TempDir()
@@ -165,6 +401,102 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDirectoryErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := FileObjectStore{BaseURI: storage.NewPath(tmpDir)}
+	emptyDir := storage.NewPath("empty")
+	fullDir := storage.NewPath("date=2023-01-01")
+	os.Mkdir(filepath.Join(tmpDir, emptyDir.Raw), 0700)
+	store.Put(storage.NewPath("date=2023-01-01/part-0.parquet"), []byte("some data"))
+
+	for _, dir := range []*storage.Path{emptyDir, fullDir} {
+		if _, err := store.Head(dir); !errors.Is(err, storage.ErrorObjectIsDir) {
+			t.Errorf("Head %s: want ErrorObjectIsDir, has %v", dir.Raw, err)
+		}
+		if _, err := store.Get(dir); !errors.Is(err, storage.ErrorObjectIsDir) || !errors.Is(err, storage.ErrorGetObject) {
+			t.Errorf("Get %s: want ErrorObjectIsDir, has %v", dir.Raw, err)
+		}
+		if _, _, err := store.GetWithMeta(dir); !errors.Is(err, storage.ErrorObjectIsDir) {
+			t.Errorf("GetWithMeta %s: want ErrorObjectIsDir, has %v", dir.Raw, err)
+		}
+		if err := store.Delete(dir); !errors.Is(err, storage.ErrorObjectIsDir) || !errors.Is(err, storage.ErrorDeleteObject) {
+			t.Errorf("Delete %s: want ErrorObjectIsDir, has %v", dir.Raw, err)
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, dir.Raw)); err != nil {
+			t.Errorf("the directory %s should not be deleted", dir.Raw)
+		}
+	}
+
+	// Other failures are not reported as directories
+	if _, err := store.Get(storage.NewPath("missing")); errors.Is(err, storage.ErrorObjectIsDir) || !errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		t.Errorf("Get: want ErrorObjectDoesNotExist, has %v", err)
+	}
+}
+
+func TestListSiblingTables(t *testing.T) {
+	tmpDir := t.TempDir()
+	data := []byte("some data")
+	for _, table := range []string{"t1", "t1_backup"} {
+		store := FileObjectStore{BaseURI: storage.NewPath(filepath.Join(tmpDir, "data", table))}
+		for _, filePath := range []string{"_delta_log/00000000000000000000.json", "part-0.parquet"} {
+			if err := store.Put(storage.NewPath(filePath), data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	os.WriteFile(filepath.Join(tmpDir, "data", "t1.json"), data, 0644)
+
+	for _, baseURI := range []string{filepath.Join(tmpDir, "data", "t1"), filepath.Join(tmpDir, "data", "t1") + string(filepath.Separator)} {
+		store := FileObjectStore{BaseURI: storage.NewPath(baseURI)}
+		for prefix, want := range map[string][]string{
+			"":            {"_delta_log/", "_delta_log/00000000000000000000.json", "part-0.parquet"},
+			"_delta_log/": {"_delta_log/", "_delta_log/00000000000000000000.json"},
+			"part":        {"part-0.parquet"},
+		} {
+			results, err := store.List(storage.NewPath(prefix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareExpectedPaths(t, want, results)
+		}
+
+		// The prefix cannot leave the store
+		for _, prefix := range []string{"../t1_backup/", "../", "_delta_log/../../t1_backup/part"} {
+			if _, err := store.List(storage.NewPath(prefix)); !errors.Is(err, storage.ErrorPathOutsideStore) {
+				t.Errorf("%s: want ErrorPathOutsideStore, has %v", prefix, err)
+			}
+		}
+	}
+}
+
+func TestDeleteIgnoreMissing(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := FileObjectStore{BaseURI: storage.NewPath(tmpDir), IgnoreMissing: true}
+
+	filePath := storage.NewPath("data.json")
+	err := store.Put(filePath, []byte("some data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Delete(filePath); err != nil {
+			t.Errorf("attempt %d: unexpected error calling Delete: %v", i, err)
+		}
+	}
+	if fileExists(filepath.Join(tmpDir, filePath.Raw)) {
+		t.Error("File exists after Delete")
+	}
+
+	// Other errors are still returned
+	err = store.Put(storage.NewPath("dir/data.json"), []byte("some data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(storage.NewPath("dir")); !errors.Is(err, storage.ErrorDeleteObject) {
+		t.Errorf("want ErrorDeleteObject deleting a non-empty directory, has %v", err)
+	}
+}
+
 func compareExpectedPaths(t *testing.T, expected []string, results []storage.ObjectMeta) {
 	t.Helper()
 
@@ -236,6 +568,118 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListSorted(t *testing.T) {
+	store := FileObjectStore{BaseURI: storage.NewPath(t.TempDir())}
+	for _, filePath := range []string{"b/2.json", "a.json", "b/10.json", "c.json", "b/1.json"} {
+		if err := store.Put(storage.NewPath(filePath), []byte("some data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.List(storage.NewPath(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locations []string
+	for _, meta := range got {
+		locations = append(locations, meta.Location.Raw)
+	}
+	expected := []string{"a.json", "b/", "b/1.json", "b/10.json", "b/2.json", "c.json"}
+	if !reflect.DeepEqual(locations, expected) {
+		t.Errorf("want %v, has %v", expected, locations)
+	}
+}
+
+func TestNewCanonicalBaseURI(t *testing.T) {
+	tmpDir := t.TempDir()
+	setup := New(storage.NewPath(tmpDir))
+	for _, filePath := range []string{"_delta_log/00000000000000000000.json", "data/part-0.parquet", "part-1.parquet"} {
+		if err := setup.Put(storage.NewPath(filePath), []byte("some data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relDir, err := filepath.Rel(cwd, tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type snapshot struct {
+		lists [][]storage.ObjectMeta
+		data  []byte
+		head  storage.ObjectMeta
+	}
+	read := func(store *FileObjectStore) snapshot {
+		var result snapshot
+		for _, prefix := range []string{"", "_delta_log/", "data", "data/part", "part"} {
+			objects, err := store.List(storage.NewPath(prefix))
+			if err != nil {
+				t.Fatalf("%s: %v", store.BaseURI.Raw, err)
+			}
+			result.lists = append(result.lists, objects)
+		}
+		result.data, err = store.Get(storage.NewPath("data/part-0.parquet"))
+		if err != nil {
+			t.Fatalf("%s: %v", store.BaseURI.Raw, err)
+		}
+		result.head, err = store.Head(storage.NewPath("data/part-0.parquet"))
+		if err != nil {
+			t.Fatalf("%s: %v", store.BaseURI.Raw, err)
+		}
+		return result
+	}
+
+	expected := read(setup)
+	if len(expected.lists[0]) != 5 {
+		t.Fatalf("want 3 files and 2 directories, has %v", expected.lists[0])
+	}
+	sep := string(filepath.Separator)
+	for _, baseURI := range []string{tmpDir + sep, tmpDir + sep + sep, tmpDir + sep + "." + sep, filepath.Join(tmpDir, "data") + sep + ".." + sep, relDir, relDir + sep} {
+		store := New(storage.NewPath(baseURI))
+		if store.BaseURI.Raw != filepath.Clean(tmpDir) && store.BaseURI.Raw != filepath.Clean(relDir) {
+			t.Errorf("%s: want the canonical base, has %s", baseURI, store.BaseURI.Raw)
+		}
+		if store.RootURI() != setup.RootURI() {
+			t.Errorf("%s: want root %s, has %s", baseURI, setup.RootURI(), store.RootURI())
+		}
+		if result := read(store); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: want %+v, has %+v", baseURI, expected, result)
+		}
+	}
+}
+
+func TestListModifiedAfter(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := FileObjectStore{BaseURI: storage.NewPath(tmpDir)}
+	for _, filePath := range []string{"part-0.parquet", "part-1.parquet", "part-2.parquet", "other.json"} {
+		store.Put(storage.NewPath(filePath), []byte("some data"))
+	}
+	since := time.Now().Add(-time.Hour)
+	old := since.Add(-time.Hour)
+	os.Chtimes(filepath.Join(tmpDir, "part-0.parquet"), old, old)
+	os.Chtimes(filepath.Join(tmpDir, "part-1.parquet"), since, since)
+
+	modified, err := store.ListModifiedAfter(storage.NewPath("part-"), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Files modified exactly at since are not listed
+	compareExpectedPaths(t, []string{"part-2.parquet"}, modified)
+
+	modified, err = store.ListModifiedAfter(storage.NewPath("part-"), old.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareExpectedPaths(t, []string{"part-0.parquet", "part-1.parquet", "part-2.parquet"}, modified)
+
+	if _, err := store.ListModifiedAfter(storage.NewPath("../sibling/"), since); !errors.Is(err, storage.ErrorPathOutsideStore) {
+		t.Errorf("want ErrorPathOutsideStore, has %v", err)
+	}
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
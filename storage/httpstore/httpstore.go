@@ -0,0 +1,424 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package httpstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+// HTTPObjectStore reads a table published on a web server or a CDN over HTTP or HTTPS.
+// The store is read-only: Put, Delete, Rename and RenameIfNotExists return storage.ErrorUnsupported.
+// Objects are listed from the directory index pages of the server, such as those of nginx autoindex, Apache
+// mod_autoindex or Go's http.FileServer, or from a manifest file when the server has no directory indexes.
+type HTTPObjectStore struct {
+	BaseURI *storage.Path
+	baseURL *url.URL
+	// Client used for the requests, http.DefaultClient when nil
+	Client *http.Client
+	// Location of a manifest listing the objects of the store, relative to the store root, with one relative
+	// location per line. List reads the manifest instead of the directory index pages when it is set.
+	Manifest string
+	// Deadline of each request made by the store, including the read of the response body, past which the request
+	// is cancelled and fails with storage.ErrorOperationTimeout. Requests have no deadline when 0.
+	OperationTimeout time.Duration
+}
+
+// Compile time check that HTTPObjectStore implements storage.ObjectStore and storage.RangeGetter
+var _ storage.ObjectStore = (*HTTPObjectStore)(nil)
+var _ storage.RangeGetter = (*HTTPObjectStore)(nil)
+
+// New creates a store reading the objects under the http:// or https:// base URI
+func New(baseURI *storage.Path) (*HTTPObjectStore, error) {
+	baseURL, err := baseURI.ParseURL()
+	if err != nil {
+		return nil, err
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of %s", baseURL.Scheme, baseURI.Raw)
+	}
+	// Relative locations are resolved under the base path, which must end with a separator
+	if !strings.HasSuffix(baseURL.Path, "/") {
+		baseURL.Path += "/"
+	}
+	store := new(HTTPObjectStore)
+	store.BaseURI = baseURI
+	store.baseURL = baseURL
+	return store, nil
+}
+
+// RootURI returns the http:// or https:// URI of the store root
+func (s *HTTPObjectStore) RootURI() string {
+	return s.BaseURI.Raw
+}
+
+// Close closes the idle connections of the client
+func (s *HTTPObjectStore) Close() error {
+	s.client().CloseIdleConnections()
+	return nil
+}
+
+func (s *HTTPObjectStore) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+// objectURL returns the URL of the location, which must be under the store root
+func (s *HTTPObjectStore) objectURL(location *storage.Path) (string, error) {
+	objectURL := s.baseURL.JoinPath(location.Raw)
+	// Directories, including the root, are requested with a trailing separator
+	if (location.Raw == "" || strings.HasSuffix(location.Raw, "/")) && !strings.HasSuffix(objectURL.Path, "/") {
+		objectURL.Path += "/"
+	}
+	if !strings.HasPrefix(objectURL.Path, s.baseURL.Path) {
+		return "", storage.ErrorPathOutsideStore
+	}
+	return objectURL.String(), nil
+}
+
+// do sends a request for the location and returns the response if its status is one of the expected statuses.
+// Other statuses are returned as a storage.StorageError of the category of the status.
+func (s *HTTPObjectStore) do(operation string, method string, location *storage.Path, sentinel error, header http.Header, expected ...int) (*http.Response, error) {
+	objectURL, err := s.objectURL(location)
+	if err != nil {
+		return nil, storage.NewStorageError(operation, location, storage.ErrorUnknown, errors.Join(sentinel, err))
+	}
+	ctx, cancel := s.requestContext()
+	request, err := http.NewRequestWithContext(ctx, method, objectURL, nil)
+	if err != nil {
+		cancel()
+		return nil, storage.NewStorageError(operation, location, storage.ErrorUnknown, errors.Join(sentinel, err))
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	response, err := s.client().Do(request)
+	if err != nil {
+		err = timeoutError(ctx, err)
+		cancel()
+		return nil, storage.NewStorageError(operation, location, errorCategory(err), errors.Join(sentinel, err))
+	}
+	for _, status := range expected {
+		if response.StatusCode == status {
+			response.Body = &deadlineBody{ReadCloser: response.Body, ctx: ctx, cancel: cancel}
+			return response, nil
+		}
+	}
+	response.Body.Close()
+	cancel()
+	return nil, storage.NewStorageError(operation, location, statusCategory(response.StatusCode),
+		errors.Join(sentinel, fmt.Errorf("%s %s: %s", method, objectURL, response.Status)))
+}
+
+// requestContext returns the context of a request, with the OperationTimeout deadline if it is set
+func (s *HTTPObjectStore) requestContext() (context.Context, context.CancelFunc) {
+	if s.OperationTimeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), s.OperationTimeout)
+}
+
+// timeoutError adds storage.ErrorOperationTimeout to err if the request of ctx failed because its deadline passed
+func timeoutError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.Join(storage.ErrorOperationTimeout, err)
+	}
+	return err
+}
+
+// deadlineBody is the body of a response read under the deadline of the request, which is released on Close
+type deadlineBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != io.EOF {
+		err = timeoutError(b.ctx, err)
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// statusCategory maps an HTTP status to a storage.StorageError category.
+// Throttling and server errors are transient.
+func statusCategory(status int) error {
+	switch {
+	case status == http.StatusNotFound, status == http.StatusGone:
+		return storage.ErrorNotFound
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return storage.ErrorAccessDenied
+	case status == http.StatusTooManyRequests, status >= http.StatusInternalServerError:
+		return storage.ErrorTransient
+	}
+	return storage.ErrorUnknown
+}
+
+// errorCategory maps the error of a request that got no response to a storage.StorageError category.
+// Network timeouts and requests past their deadline are transient.
+func errorCategory(err error) error {
+	if errors.Is(err, storage.ErrorOperationTimeout) {
+		return storage.ErrorTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return storage.ErrorTransient
+	}
+	return storage.ErrorUnknown
+}
+
+// objectMeta returns the metadata of the object at the location from the headers of a response
+func objectMeta(location *storage.Path, response *http.Response) storage.ObjectMeta {
+	var m storage.ObjectMeta
+	m.Location = *location
+	m.Size = response.ContentLength
+	if lastModified, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		m.LastModified = lastModified
+	}
+	m.ETag = response.Header.Get("ETag")
+	return m
+}
+
+func (s *HTTPObjectStore) Get(location *storage.Path) ([]byte, error) {
+	data, _, err := s.GetWithMeta(location)
+	return data, err
+}
+
+func (s *HTTPObjectStore) GetWithMeta(location *storage.Path) ([]byte, storage.ObjectMeta, error) {
+	data, m, _, err := s.GetIfNoneMatch(location, "")
+	return data, m, err
+}
+
+func (s *HTTPObjectStore) GetIfNoneMatch(location *storage.Path, etag string) ([]byte, storage.ObjectMeta, bool, error) {
+	var m storage.ObjectMeta
+	header := make(http.Header)
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	response, err := s.do("get", http.MethodGet, location, storage.ErrorGetObject, header, http.StatusOK, http.StatusNotModified)
+	if err != nil {
+		return nil, m, false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotModified {
+		m.Location = *location
+		m.ETag = etag
+		return nil, m, true, nil
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, m, false, storage.NewStorageError("get", location, errorCategory(err), errors.Join(storage.ErrorGetObject, err))
+	}
+	m = objectMeta(location, response)
+	m.Size = int64(len(data))
+	return data, m, false, nil
+}
+
+// GetRange returns the bytes of the object at the location from r.Start up to r.End exclusive, with a Range request.
+// The whole object is read if the server does not support range requests.
+func (s *HTTPObjectStore) GetRange(location *storage.Path, r storage.Range) ([]byte, error) {
+	if r.Start < 0 || r.End <= r.Start {
+		return nil, storage.NewStorageError("get", location, storage.ErrorUnknown,
+			errors.Join(storage.ErrorGetObject, fmt.Errorf("invalid range %d-%d", r.Start, r.End)))
+	}
+	header := make(http.Header)
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.Start, r.End-1))
+	response, err := s.do("get", http.MethodGet, location, storage.ErrorGetObject, header, http.StatusOK, http.StatusPartialContent)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, storage.NewStorageError("get", location, errorCategory(err), errors.Join(storage.ErrorGetObject, err))
+	}
+	if response.StatusCode == http.StatusOK {
+		if r.Start >= int64(len(data)) {
+			return nil, nil
+		}
+		if r.End > int64(len(data)) {
+			return data[r.Start:], nil
+		}
+		return data[r.Start:r.End], nil
+	}
+	return data, nil
+}
+
+func (s *HTTPObjectStore) Head(location *storage.Path) (storage.ObjectMeta, error) {
+	response, err := s.do("head", http.MethodHead, location, storage.ErrorHeadObject, nil, http.StatusOK)
+	if err != nil {
+		return storage.ObjectMeta{}, err
+	}
+	response.Body.Close()
+	return objectMeta(location, response), nil
+}
+
+// List lists the objects with the given prefix, sorted by location, from the manifest if it is set or else by
+// crawling the directory index pages under the prefix. The metadata of each object is read with a HEAD request.
+func (s *HTTPObjectStore) List(prefix *storage.Path) ([]storage.ObjectMeta, error) {
+	var locations []string
+	var err error
+	if s.Manifest != "" {
+		locations, err = s.listManifest(prefix.Raw)
+	} else {
+		dir, filePrefix := path.Split(prefix.Raw)
+		locations, err = s.listIndex(dir, filePrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(locations)
+
+	objectMetas := make([]storage.ObjectMeta, 0, len(locations))
+	for _, location := range locations {
+		m, err := s.Head(storage.NewPath(location))
+		if err != nil {
+			return nil, storage.NewStorageError("list", prefix, storage.ErrorUnknown, errors.Join(storage.ErrorListObjects, err))
+		}
+		objectMetas = append(objectMetas, m)
+	}
+	return objectMetas, nil
+}
+
+// listManifest returns the locations of the manifest that start with the prefix
+func (s *HTTPObjectStore) listManifest(prefix string) ([]string, error) {
+	data, err := s.Get(storage.NewPath(s.Manifest))
+	if err != nil {
+		return nil, storage.NewStorageError("list", storage.NewPath(prefix), storage.ErrorUnknown, errors.Join(storage.ErrorListObjects, err))
+	}
+	var locations []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		location := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "/")
+		if location != "" && strings.HasPrefix(location, prefix) {
+			locations = append(locations, location)
+		}
+	}
+	return locations, nil
+}
+
+// The links of a directory index page
+var hrefPattern = regexp.MustCompile(`(?i)href\s*=\s*"([^"]*)"`)
+
+// listIndex returns the locations of the objects in the directory dir whose name starts with filePrefix, and of all
+// the objects in its subdirectories. A directory without an index page is empty.
+func (s *HTTPObjectStore) listIndex(dir string, filePrefix string) ([]string, error) {
+	response, err := s.do("list", http.MethodGet, storage.NewPath(dir), storage.ErrorListObjects, nil, http.StatusOK)
+	if errors.Is(err, storage.ErrorNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	page, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, storage.NewStorageError("list", storage.NewPath(dir), errorCategory(err), errors.Join(storage.ErrorListObjects, err))
+	}
+
+	var locations []string
+	seen := make(map[string]bool)
+	for _, match := range hrefPattern.FindAllSubmatch(page, -1) {
+		name, ok := indexEntry(string(match[1]))
+		if !ok || seen[name] || !strings.HasPrefix(name, filePrefix) {
+			continue
+		}
+		seen[name] = true
+		if strings.HasSuffix(name, "/") {
+			subLocations, err := s.listIndex(dir+name, "")
+			if err != nil {
+				return nil, err
+			}
+			locations = append(locations, subLocations...)
+		} else {
+			locations = append(locations, dir+name)
+		}
+	}
+	return locations, nil
+}
+
+// indexEntry returns the unescaped name of the file or subdirectory linked by the href of a directory index page,
+// or false if the link is not an entry of the directory, such as the parent directory or a sort link
+func indexEntry(href string) (string, bool) {
+	if href == "" || strings.ContainsAny(href, "?#") || strings.HasPrefix(href, "/") || strings.Contains(href, "://") {
+		return "", false
+	}
+	name, err := url.PathUnescape(strings.TrimPrefix(href, "./"))
+	if err != nil || name == "" || name == "../" || strings.Contains(strings.TrimSuffix(name, "/"), "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// ListModifiedAfter lists the objects with the given prefix that were last modified after since.
+// The objects are filtered client-side, by the Last-Modified header of the server.
+func (s *HTTPObjectStore) ListModifiedAfter(prefix *storage.Path, since time.Time) ([]storage.ObjectMeta, error) {
+	objects, err := s.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	modified := make([]storage.ObjectMeta, 0, len(objects))
+	for _, object := range objects {
+		if object.LastModified.After(since) {
+			modified = append(modified, object)
+		}
+	}
+	return modified, nil
+}
+
+// readOnlyError reports an operation that would modify the store
+func readOnlyError(operation string, location *storage.Path, sentinel error) error {
+	return storage.NewStorageError(operation, location, storage.ErrorUnknown, errors.Join(sentinel, storage.ErrorUnsupported))
+}
+
+// Put is not supported by the read-only store
+func (s *HTTPObjectStore) Put(location *storage.Path, data []byte) error {
+	return readOnlyError("put", location, storage.ErrorPutObject)
+}
+
+// Delete is not supported by the read-only store
+func (s *HTTPObjectStore) Delete(location *storage.Path) error {
+	return readOnlyError("delete", location, storage.ErrorDeleteObject)
+}
+
+// Rename is not supported by the read-only store
+func (s *HTTPObjectStore) Rename(from *storage.Path, to *storage.Path) error {
+	return readOnlyError("rename", from, storage.ErrorCopyObject)
+}
+
+// RenameIfNotExists is not supported by the read-only store
+func (s *HTTPObjectStore) RenameIfNotExists(from *storage.Path, to *storage.Path) error {
+	return readOnlyError("rename", from, storage.ErrorCopyObject)
+}
@@ -0,0 +1,339 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package httpstore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+// Test helper: setupTest serves the files of a temporary directory under /table/ with http.FileServer, whose
+// directory listings are index pages, and returns a store of the table
+func setupTest(t *testing.T, files map[string]string) (*HTTPObjectStore, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	for name, data := range files {
+		filePath := filepath.Join(tmpDir, "table", name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A sibling of the table, which must never be listed
+	if err := os.WriteFile(filepath.Join(tmpDir, "secret.json"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(tmpDir)))
+	t.Cleanup(server.Close)
+	store, err := New(storage.NewPath(server.URL + "/table"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, tmpDir
+}
+
+var testFiles = map[string]string{
+	"_delta_log/00000000000000000000.json": "commit 0",
+	"_delta_log/00000000000000000001.json": "commit 1",
+	"part 1.parquet":                       "0123456789",
+	"date=2023-01-01/part-2.parquet":       "data",
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(storage.NewPath("s3://bucket/table")); err == nil {
+		t.Error("s3 URIs should be rejected")
+	}
+	store, err := New(storage.NewPath("https://example.com/tables/t1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.RootURI() != "https://example.com/tables/t1" {
+		t.Errorf("unexpected root URI %s", store.RootURI())
+	}
+}
+
+func TestGet(t *testing.T) {
+	store, _ := setupTest(t, testFiles)
+
+	data, m, err := store.GetWithMeta(storage.NewPath("part 1.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" || m.Size != 10 || m.Location.Raw != "part 1.parquet" || m.LastModified.IsZero() {
+		t.Errorf("unexpected object %s %+v", data, m)
+	}
+
+	_, err = store.Get(storage.NewPath("missing.json"))
+	if !errors.Is(err, storage.ErrorObjectDoesNotExist) || !errors.Is(err, storage.ErrorGetObject) {
+		t.Errorf("want ErrorObjectDoesNotExist, has %v", err)
+	}
+	_, err = store.Get(storage.NewPath("../secret.json"))
+	if !errors.Is(err, storage.ErrorPathOutsideStore) {
+		t.Errorf("want ErrorPathOutsideStore, has %v", err)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	store, _ := setupTest(t, testFiles)
+
+	tests := []struct {
+		r        storage.Range
+		expected string
+	}{
+		{storage.Range{Start: 0, End: 4}, "0123"},
+		{storage.Range{Start: 6, End: 10}, "6789"},
+		{storage.Range{Start: 6, End: 20}, "6789"},
+	}
+	for _, test := range tests {
+		data, err := store.GetRange(storage.NewPath("part 1.parquet"), test.r)
+		if err != nil || string(data) != test.expected {
+			t.Errorf("%+v: want %s, has %s %v", test.r, test.expected, data, err)
+		}
+	}
+	if _, err := store.GetRange(storage.NewPath("part 1.parquet"), storage.Range{Start: 4, End: 4}); err == nil {
+		t.Error("an empty range should be rejected")
+	}
+
+	// A server ignoring the Range header returns the whole object
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+	store, err := New(storage.NewPath(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := store.GetRange(storage.NewPath("part-1.parquet"), storage.Range{Start: 2, End: 5})
+	if err != nil || string(data) != "234" {
+		t.Errorf("want 234, has %s %v", data, err)
+	}
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+	store, err := New(storage.NewPath(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, m, notModified, err := store.GetIfNoneMatch(storage.NewPath("object"), "")
+	if err != nil || notModified || string(data) != "data" || m.ETag != `"v1"` {
+		t.Errorf("unexpected result %s %+v %t %v", data, m, notModified, err)
+	}
+	data, _, notModified, err = store.GetIfNoneMatch(storage.NewPath("object"), `"v1"`)
+	if err != nil || !notModified || data != nil {
+		t.Errorf("want not modified, has %s %t %v", data, notModified, err)
+	}
+}
+
+func TestHead(t *testing.T) {
+	store, _ := setupTest(t, testFiles)
+
+	m, err := store.Head(storage.NewPath("date=2023-01-01/part-2.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Size != 4 || m.Location.Raw != "date=2023-01-01/part-2.parquet" || m.LastModified.IsZero() {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+	_, err = store.Head(storage.NewPath("missing.json"))
+	if !errors.Is(err, storage.ErrorObjectDoesNotExist) || !errors.Is(err, storage.ErrorHeadObject) {
+		t.Errorf("want ErrorObjectDoesNotExist, has %v", err)
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusForbidden, storage.ErrorAccessDenied},
+		{http.StatusUnauthorized, storage.ErrorAccessDenied},
+		{http.StatusTooManyRequests, storage.ErrorTransient},
+		{http.StatusServiceUnavailable, storage.ErrorTransient},
+		{http.StatusTeapot, storage.ErrorUnknown},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}))
+		store, err := New(storage.NewPath(server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Get(storage.NewPath("object"))
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%d: want %v, has %v", test.status, test.expected, err)
+		}
+		server.Close()
+	}
+}
+
+// Test helper: locations returns the locations of the listed objects
+func locations(objects []storage.ObjectMeta) []string {
+	var locations []string
+	for _, object := range objects {
+		locations = append(locations, object.Location.Raw)
+	}
+	return locations
+}
+
+func TestList(t *testing.T) {
+	store, _ := setupTest(t, testFiles)
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"", []string{"_delta_log/00000000000000000000.json", "_delta_log/00000000000000000001.json", "date=2023-01-01/part-2.parquet", "part 1.parquet"}},
+		{"_delta_log/", []string{"_delta_log/00000000000000000000.json", "_delta_log/00000000000000000001.json"}},
+		{"_delta_log/00000000000000000001", []string{"_delta_log/00000000000000000001.json"}},
+		{"date", []string{"date=2023-01-01/part-2.parquet"}},
+		{"missing/", nil},
+	}
+	for _, test := range tests {
+		objects, err := store.List(storage.NewPath(test.prefix))
+		if err != nil {
+			t.Fatalf("%q: %v", test.prefix, err)
+		}
+		if !reflect.DeepEqual(locations(objects), test.expected) {
+			t.Errorf("%q: want %v, has %v", test.prefix, test.expected, locations(objects))
+		}
+	}
+
+	objects, err := store.List(storage.NewPath("part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Size != 10 || objects[0].LastModified.IsZero() {
+		t.Errorf("unexpected objects %+v", objects)
+	}
+}
+
+func TestListManifest(t *testing.T) {
+	files := map[string]string{"_manifest": "_delta_log/00000000000000000000.json\n/part 1.parquet\n\n"}
+	for name, data := range testFiles {
+		files[name] = data
+	}
+	store, _ := setupTest(t, files)
+	store.Manifest = "_manifest"
+
+	objects, err := store.List(storage.NewPath(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"_delta_log/00000000000000000000.json", "part 1.parquet"}
+	if !reflect.DeepEqual(locations(objects), expected) {
+		t.Errorf("want %v, has %v", expected, locations(objects))
+	}
+
+	// A listed object that is missing fails the listing
+	store.Manifest = "_missing_manifest"
+	if _, err := store.List(storage.NewPath("")); !errors.Is(err, storage.ErrorListObjects) {
+		t.Errorf("want ErrorListObjects, has %v", err)
+	}
+}
+
+func TestIndexEntry(t *testing.T) {
+	tests := []struct {
+		href     string
+		expected string
+		ok       bool
+	}{
+		{"part-1.parquet", "part-1.parquet", true},
+		{"part%201.parquet", "part 1.parquet", true},
+		{"./_delta_log/", "_delta_log/", true},
+		{"../", "", false},
+		{"?C=N;O=D", "", false},
+		{"/tables/", "", false},
+		{"https://example.com/", "", false},
+		{"a/b.parquet", "", false},
+	}
+	for _, test := range tests {
+		name, ok := indexEntry(test.href)
+		if name != test.expected || ok != test.ok {
+			t.Errorf("%s: want %s %t, has %s %t", test.href, test.expected, test.ok, name, ok)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	store, tmpDir := setupTest(t, testFiles)
+	path := storage.NewPath("_delta_log/00000000000000000000.json")
+	errs := []error{
+		store.Put(storage.NewPath("new.json"), []byte("data")),
+		store.Delete(path),
+		store.Rename(path, storage.NewPath("renamed.json")),
+		store.RenameIfNotExists(path, storage.NewPath("renamed.json")),
+	}
+	for _, err := range errs {
+		if !errors.Is(err, storage.ErrorUnsupported) {
+			t.Errorf("want ErrorUnsupported, has %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "table", path.Raw)); err != nil {
+		t.Errorf("the object should be left in place: %v", err)
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow-headers":
+			<-release
+		case "/slow-body":
+			w.Header().Set("Content-Length", "8")
+			w.Write([]byte("data"))
+			w.(http.Flusher).Flush()
+			<-release
+		default:
+			w.Write([]byte("data"))
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	store, err := New(storage.NewPath(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.OperationTimeout = 100 * time.Millisecond
+
+	if data, err := store.Get(storage.NewPath("fast")); err != nil || string(data) != "data" {
+		t.Errorf("unexpected object %s %v", data, err)
+	}
+	for _, location := range []string{"slow-headers", "slow-body"} {
+		_, err := store.Get(storage.NewPath(location))
+		if !errors.Is(err, storage.ErrorOperationTimeout) || !errors.Is(err, storage.ErrorTransient) || !errors.Is(err, storage.ErrorGetObject) {
+			t.Errorf("%s: want a transient ErrorOperationTimeout, has %v", location, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitedStore is an ObjectStore limiting the rate of the requests made to the inner store, to stay below the
+// request rate limits of a shared bucket during large jobs such as vacuum or optimize.
+// Each operation waits for a token of the limiter, and fails with an ErrorUnknown StorageError wrapping the error of
+// the context if the context is done first.
+type RateLimitedStore struct {
+	ObjectStore
+	Limiter *rate.Limiter
+	ctx     context.Context
+}
+
+// Compile time check that RateLimitedStore implements ObjectStore and RangeGetter
+var _ ObjectStore = (*RateLimitedStore)(nil)
+var _ RangeGetter = (*RateLimitedStore)(nil)
+
+// NewRateLimitedStore creates a store making at most rps requests per second to the inner store, with bursts of up to
+// burst requests. Requests are not limited when rps is 0 or less.
+func NewRateLimitedStore(inner ObjectStore, rps int, burst int) *RateLimitedStore {
+	limit := rate.Limit(rps)
+	if rps <= 0 {
+		limit = rate.Inf
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	store := new(RateLimitedStore)
+	store.ObjectStore = inner
+	store.Limiter = rate.NewLimiter(limit, burst)
+	store.ctx = context.Background()
+	return store
+}
+
+// WithContext returns a store sharing the limiter of s whose operations stop waiting for a token once ctx is done
+func (s *RateLimitedStore) WithContext(ctx context.Context) *RateLimitedStore {
+	store := *s
+	store.ctx = ctx
+	return &store
+}
+
+// wait blocks until the limiter allows a request
+func (s *RateLimitedStore) wait(operation string, location *Path) error {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := s.Limiter.Wait(ctx); err != nil {
+		return NewStorageError(operation, location, ErrorUnknown, err)
+	}
+	return nil
+}
+
+func (s *RateLimitedStore) Put(location *Path, data []byte) error {
+	if err := s.wait("put", location); err != nil {
+		return err
+	}
+	return s.ObjectStore.Put(location, data)
+}
+
+func (s *RateLimitedStore) Get(location *Path) ([]byte, error) {
+	if err := s.wait("get", location); err != nil {
+		return nil, err
+	}
+	return s.ObjectStore.Get(location)
+}
+
+func (s *RateLimitedStore) GetWithMeta(location *Path) ([]byte, ObjectMeta, error) {
+	if err := s.wait("get", location); err != nil {
+		return nil, ObjectMeta{}, err
+	}
+	return s.ObjectStore.GetWithMeta(location)
+}
+
+func (s *RateLimitedStore) GetIfNoneMatch(location *Path, etag string) ([]byte, ObjectMeta, bool, error) {
+	if err := s.wait("get", location); err != nil {
+		return nil, ObjectMeta{}, false, err
+	}
+	return s.ObjectStore.GetIfNoneMatch(location, etag)
+}
+
+// GetRange returns the bytes of the object at the location from r.Start up to r.End exclusive, with the inner store's
+// GetRange if it is a RangeGetter, and from the whole object otherwise
+func (s *RateLimitedStore) GetRange(location *Path, r Range) ([]byte, error) {
+	if err := s.wait("get", location); err != nil {
+		return nil, err
+	}
+	if rangeGetter, ok := s.ObjectStore.(RangeGetter); ok {
+		return rangeGetter.GetRange(location, r)
+	}
+	data, err := s.ObjectStore.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, r), nil
+}
+
+func (s *RateLimitedStore) Head(location *Path) (ObjectMeta, error) {
+	if err := s.wait("head", location); err != nil {
+		return ObjectMeta{}, err
+	}
+	return s.ObjectStore.Head(location)
+}
+
+func (s *RateLimitedStore) Delete(location *Path) error {
+	if err := s.wait("delete", location); err != nil {
+		return err
+	}
+	return s.ObjectStore.Delete(location)
+}
+
+func (s *RateLimitedStore) List(prefix *Path) ([]ObjectMeta, error) {
+	if err := s.wait("list", prefix); err != nil {
+		return nil, err
+	}
+	return s.ObjectStore.List(prefix)
+}
+
+func (s *RateLimitedStore) ListModifiedAfter(prefix *Path, since time.Time) ([]ObjectMeta, error) {
+	if err := s.wait("list", prefix); err != nil {
+		return nil, err
+	}
+	return s.ObjectStore.ListModifiedAfter(prefix, since)
+}
+
+func (s *RateLimitedStore) Rename(from *Path, to *Path) error {
+	if err := s.wait("rename", from); err != nil {
+		return err
+	}
+	return s.ObjectStore.Rename(from, to)
+}
+
+func (s *RateLimitedStore) RenameIfNotExists(from *Path, to *Path) error {
+	if err := s.wait("rename", from); err != nil {
+		return err
+	}
+	return s.ObjectStore.RenameIfNotExists(from, to)
+}
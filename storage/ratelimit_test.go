@@ -0,0 +1,66 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedStore(t *testing.T) {
+	inner := newMemoryStore(map[string]string{"a.parquet": "0123456789"})
+	store := NewRateLimitedStore(inner, 20, 2)
+
+	// The burst is immediate, the next requests wait for tokens at 20 per second
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := store.Head(NewPath("a.parquet")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("want 6 requests to take about 200ms, has %s", elapsed)
+	}
+	data, err := store.GetRange(NewPath("a.parquet"), Range{Start: 2, End: 5})
+	if err != nil || string(data) != "234" {
+		t.Errorf("want 234, has %s %v", data, err)
+	}
+	if _, err := store.Get(NewPath("missing.parquet")); !errors.Is(err, ErrorObjectDoesNotExist) {
+		t.Errorf("want ErrorObjectDoesNotExist, has %v", err)
+	}
+
+	// Waiting for a token stops once the context is done
+	store = NewRateLimitedStore(inner, 1, 1)
+	if err := store.Put(NewPath("b.parquet"), []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.WithContext(ctx).Get(NewPath("b.parquet")); !errors.Is(err, context.Canceled) || !errors.Is(err, ErrorUnknown) {
+		t.Errorf("want a canceled ErrorUnknown, has %v", err)
+	}
+
+	// Requests are not limited without a rate
+	store = NewRateLimitedStore(inner, 0, 0)
+	start = time.Now()
+	for i := 0; i < 100; i++ {
+		if _, err := store.List(NewPath("")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("want unlimited requests, has 100 requests in %s", elapsed)
+	}
+}
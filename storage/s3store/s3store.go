@@ -16,26 +16,45 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/rivian/delta-go/storage"
 )
 
 type S3ClientAPI interface {
 	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
 }
 
+// S3PresignAPI generates presigned requests, implemented by s3.PresignClient
+type S3PresignAPI interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
 // type filePutter func(key string, data io.ReadSeeker, creds *credentials.Credentials) error
 type S3ObjectStore struct {
 	// Source object key
@@ -46,10 +65,56 @@ type S3ObjectStore struct {
 	path    string
 	//s3, http, file
 	scheme string
+	// Size of the parts of multipart uploads made by PutReader, DEFAULT_PART_SIZE when 0.
+	// Streams that fit in a single part are uploaded with PutObject.
+	PartSize int64
+	// Number of parts of a multipart upload uploaded concurrently, DEFAULT_UPLOAD_CONCURRENCY when 0
+	UploadConcurrency int
+	// Number of HeadObject requests made concurrently by HeadBulk, DEFAULT_HEAD_CONCURRENCY when 0
+	HeadConcurrency int
+	// Maximum number of keys returned by each ListObjectsV2 request of List, DEFAULT_LIST_BATCH_SIZE when 0.
+	// S3 returns at most MAX_LIST_BATCH_SIZE keys per request, so larger values are capped. Smaller batches lower the
+	// latency and memory of each request, at the cost of more requests to list a large prefix.
+	ListBatchSize int
+	// Client used by PresignGet and PresignPut. When nil it is created from Client if Client is an *s3.Client.
+	PresignClient S3PresignAPI
+	// Appended to the User-Agent header of every request, so that the requests of delta-go can be attributed in
+	// access logs and cost reports. Ignored when empty.
+	UserAgent string
+	// Tags set on the objects written by Put and PutReader, for instance for cost allocation.
+	// Renamed objects keep their tags.
+	ObjectTags map[string]string
+	// Deadline of each request made by the store, past which the request is cancelled and fails with
+	// storage.ErrorOperationTimeout. Requests have no deadline when 0.
+	OperationTimeout time.Duration
 }
 
-// Compile time check that S3ObjectStore implements storage.ObjectStore
+// Compile time check that S3ObjectStore implements storage.ObjectStore, storage.BulkDeleter, storage.BulkHeader,
+// storage.ReaderPutter and storage.Presigner
 var _ storage.ObjectStore = (*S3ObjectStore)(nil)
+var _ storage.BulkDeleter = (*S3ObjectStore)(nil)
+var _ storage.BulkHeader = (*S3ObjectStore)(nil)
+var _ storage.ReaderPutter = (*S3ObjectStore)(nil)
+var _ storage.Presigner = (*S3ObjectStore)(nil)
+
+const (
+	// The smallest part size S3 accepts, except for the last part
+	MIN_PART_SIZE int64 = 5 * 1024 * 1024
+	// The default part size of multipart uploads
+	DEFAULT_PART_SIZE int64 = 64 * 1024 * 1024
+	// The default number of parts uploaded concurrently
+	DEFAULT_UPLOAD_CONCURRENCY = 4
+	// The default number of concurrent HeadObject requests of HeadBulk
+	DEFAULT_HEAD_CONCURRENCY = 16
+	// The default and maximum number of keys of each ListObjectsV2 request of List
+	DEFAULT_LIST_BATCH_SIZE = 1000
+	MAX_LIST_BATCH_SIZE     = 1000
+	// The maximum number of parts of a multipart upload
+	maxUploadParts = 10000
+)
+
+// The maximum number of keys of a DeleteObjects request
+const maxDeleteObjectsKeys = 1000
 
 func New(client S3ClientAPI, baseURI *storage.Path) (*S3ObjectStore, error) {
 	store := new(S3ObjectStore)
@@ -65,21 +130,120 @@ func New(client S3ClientAPI, baseURI *storage.Path) (*S3ObjectStore, error) {
 	store.bucket = store.baseURL.Host
 	store.path = strings.TrimPrefix(store.baseURL.Path, "/")
 	store.scheme = store.baseURL.Scheme
+	if s3Client, ok := client.(*s3.Client); ok {
+		store.PresignClient = s3.NewPresignClient(s3Client)
+	}
 
 	return store, nil
 }
 
-func (s *S3ObjectStore) Put(location *storage.Path, data []byte) error {
+// requestOptions returns the options applied to every request made with the client
+func (s *S3ObjectStore) requestOptions() []func(*s3.Options) {
+	if s.UserAgent == "" {
+		return nil
+	}
+	return []func(*s3.Options){s3.WithAPIOptions(awsmiddleware.AddUserAgentKey(s.UserAgent))}
+}
+
+// requestContext returns the context of a request, with the OperationTimeout deadline if it is set
+func (s *S3ObjectStore) requestContext() (context.Context, context.CancelFunc) {
+	if s.OperationTimeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), s.OperationTimeout)
+}
+
+// timeoutError returns a timeout error if the request of ctx failed with err because its deadline passed, or else err
+func (s *S3ObjectStore) timeoutError(ctx context.Context, operation string, location *storage.Path, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.Join(storage.NewTimeoutError(operation, location, s.OperationTimeout), err)
+	}
+	return err
+}
+
+// tagging returns the ObjectTags encoded as the tagging of PutObject and CreateMultipartUpload, or nil if there are none
+func (s *S3ObjectStore) tagging() *string {
+	if len(s.ObjectTags) == 0 {
+		return nil
+	}
+	tags := make(url.Values, len(s.ObjectTags))
+	for key, value := range s.ObjectTags {
+		tags.Set(key, value)
+	}
+	return aws.String(tags.Encode())
+}
+
+// RootURI returns the s3:// URI of the store root
+func (s *S3ObjectStore) RootURI() string {
+	return s.BaseURI.Raw
+}
+
+// PresignGet returns a presigned GetObject URL for the location, valid until the expiry has elapsed
+func (s *S3ObjectStore) PresignGet(location *storage.Path, expiry time.Duration) (string, error) {
+	if s.PresignClient == nil {
+		return "", errors.Join(storage.ErrorPresignObject, storage.ErrorUnsupported)
+	}
 	key, err := url.JoinPath(s.path, location.Raw)
 	if err != nil {
-		return errors.Join(storage.ErrorURLJoinPath, err)
+		return "", errors.Join(storage.ErrorURLJoinPath, err)
+	}
+	request, err := s.PresignClient.PresignGetObject(context.Background(),
+		&s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", errors.Join(storage.ErrorPresignObject, err)
+	}
+	return request.URL, nil
+}
+
+// PresignPut returns a presigned PutObject URL for the location, valid until the expiry has elapsed
+func (s *S3ObjectStore) PresignPut(location *storage.Path, expiry time.Duration) (string, error) {
+	if s.PresignClient == nil {
+		return "", errors.Join(storage.ErrorPresignObject, storage.ErrorUnsupported)
+	}
+	key, err := url.JoinPath(s.path, location.Raw)
+	if err != nil {
+		return "", errors.Join(storage.ErrorURLJoinPath, err)
 	}
-	_, err = s.Client.PutObject(context.Background(),
+	request, err := s.PresignClient.PresignPutObject(context.Background(),
 		&s3.PutObjectInput{
 			Bucket: aws.String(s.bucket),
 			Key:    aws.String(key),
-			Body:   bytes.NewReader(data),
-		})
+		}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", errors.Join(storage.ErrorPresignObject, err)
+	}
+	return request.URL, nil
+}
+
+// Close closes the S3 client if it implements io.Closer, or else closes its idle connections if it can
+func (s *S3ObjectStore) Close() error {
+	switch client := s.Client.(type) {
+	case io.Closer:
+		return client.Close()
+	case interface{ CloseIdleConnections() }:
+		client.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (s *S3ObjectStore) Put(location *storage.Path, data []byte) error {
+	key, err := url.JoinPath(s.path, location.Raw)
+	if err != nil {
+		return errors.Join(storage.ErrorURLJoinPath, err)
+	}
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	_, err = s.Client.PutObject(ctx,
+		&s3.PutObjectInput{
+			Bucket:  aws.String(s.bucket),
+			Key:     aws.String(key),
+			Body:    bytes.NewReader(data),
+			Tagging: s.tagging(),
+		}, s.requestOptions()...)
+	err = s.timeoutError(ctx, "put", location, err)
 	if err != nil {
 		return errors.Join(storage.ErrorPutObject, err)
 	}
@@ -87,57 +251,377 @@ func (s *S3ObjectStore) Put(location *storage.Path, data []byte) error {
 
 }
 
+// PutReader uploads the data read from the reader to the location.
+// Data larger than PartSize is uploaded as a multipart upload, with up to UploadConcurrency parts in flight,
+// which is required for objects larger than the 5GB limit of PutObject. The upload is aborted if any part fails.
+func (s *S3ObjectStore) PutReader(location *storage.Path, reader io.Reader) error {
+	partSize := s.PartSize
+	if partSize <= 0 {
+		partSize = DEFAULT_PART_SIZE
+	}
+	if partSize < MIN_PART_SIZE {
+		partSize = MIN_PART_SIZE
+	}
+	concurrency := s.UploadConcurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_UPLOAD_CONCURRENCY
+	}
+
+	firstPart, err := readPart(reader, partSize)
+	if err != nil {
+		return errors.Join(storage.ErrorPutObject, err)
+	}
+	if int64(len(firstPart)) < partSize {
+		return s.Put(location, firstPart)
+	}
+
+	key, err := url.JoinPath(s.path, location.Raw)
+	if err != nil {
+		return errors.Join(storage.ErrorURLJoinPath, err)
+	}
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	upload, err := s.Client.CreateMultipartUpload(ctx,
+		&s3.CreateMultipartUploadInput{
+			Bucket:  aws.String(s.bucket),
+			Key:     aws.String(key),
+			Tagging: s.tagging(),
+		}, s.requestOptions()...)
+	err = s.timeoutError(ctx, "put", location, err)
+	if err != nil {
+		return errors.Join(storage.ErrorPutObject, err)
+	}
+
+	err = s.uploadParts(location, key, upload.UploadId, firstPart, reader, partSize, concurrency)
+	if err != nil {
+		abortCtx, abortCancel := s.requestContext()
+		defer abortCancel()
+		_, abortErr := s.Client.AbortMultipartUpload(abortCtx,
+			&s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(s.bucket),
+				Key:      aws.String(key),
+				UploadId: upload.UploadId,
+			}, s.requestOptions()...)
+		abortErr = s.timeoutError(abortCtx, "put", location, abortErr)
+		return errors.Join(storage.ErrorPutObject, err, abortErr)
+	}
+	return nil
+}
+
+// uploadParts uploads the parts of a multipart upload concurrently and completes the upload
+func (s *S3ObjectStore) uploadParts(location *storage.Path, key string, uploadId *string, firstPart []byte, reader io.Reader, partSize int64, concurrency int) error {
+	var (
+		mu        sync.Mutex
+		completed []types.CompletedPart
+		uploadErr error
+		wg        sync.WaitGroup
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return uploadErr != nil
+	}
+	// Limits the number of parts in flight, and so the number of part buffers in memory
+	slots := make(chan struct{}, concurrency)
+
+	part := firstPart
+	for partNumber := int32(1); len(part) > 0 && !failed(); partNumber++ {
+		if partNumber > maxUploadParts {
+			mu.Lock()
+			uploadErr = fmt.Errorf("the object has more than %d parts of %d bytes", maxUploadParts, partSize)
+			mu.Unlock()
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(partNumber int32, data []byte) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			ctx, cancel := s.requestContext()
+			defer cancel()
+			output, err := s.Client.UploadPart(ctx,
+				&s3.UploadPartInput{
+					Bucket:     aws.String(s.bucket),
+					Key:        aws.String(key),
+					UploadId:   uploadId,
+					PartNumber: partNumber,
+					Body:       bytes.NewReader(data),
+				}, s.requestOptions()...)
+			err = s.timeoutError(ctx, "put", location, err)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				uploadErr = errors.Join(uploadErr, fmt.Errorf("part %d: %w", partNumber, err))
+				return
+			}
+			completed = append(completed, types.CompletedPart{ETag: output.ETag, PartNumber: partNumber})
+		}(partNumber, part)
+
+		var err error
+		part, err = readPart(reader, partSize)
+		if err != nil {
+			mu.Lock()
+			uploadErr = errors.Join(uploadErr, err)
+			mu.Unlock()
+		}
+	}
+	wg.Wait()
+	if uploadErr != nil {
+		return uploadErr
+	}
+
+	sort.Slice(completed, func(i, j int) bool { return completed[i].PartNumber < completed[j].PartNumber })
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	_, err := s.Client.CompleteMultipartUpload(ctx,
+		&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(key),
+			UploadId:        uploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		}, s.requestOptions()...)
+	return s.timeoutError(ctx, "put", location, err)
+}
+
+// readPart reads up to partSize bytes from the reader, returning an empty part at the end of the stream
+func readPart(reader io.Reader, partSize int64) ([]byte, error) {
+	part := make([]byte, partSize)
+	n, err := io.ReadFull(reader, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return part[:n], err
+}
+
 func (s *S3ObjectStore) Get(location *storage.Path) ([]byte, error) {
 	key, err := url.JoinPath(s.path, location.Raw)
 	if err != nil {
 		return nil, errors.Join(storage.ErrorURLJoinPath, err)
 	}
 	// Get the object from S3.
-	resp, err := s.Client.GetObject(context.Background(),
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	resp, err := s.Client.GetObject(ctx,
 		&s3.GetObjectInput{
 			Bucket: aws.String(s.bucket),
 			Key:    aws.String(key),
-		})
+		}, s.requestOptions()...)
+	err = s.timeoutError(ctx, "get", location, err)
 	if err != nil {
 		return nil, errors.Join(storage.ErrorGetObject, err)
 	}
 	bodyBytes, err := io.ReadAll(resp.Body)
+	err = s.timeoutError(ctx, "get", location, err)
 	if err != nil {
 		return nil, errors.Join(storage.ErrorGetObject, err)
 	}
 	return bodyBytes, nil
 }
 
+func (s *S3ObjectStore) GetWithMeta(location *storage.Path) ([]byte, storage.ObjectMeta, error) {
+	var m storage.ObjectMeta
+	key, err := url.JoinPath(s.path, location.Raw)
+	if err != nil {
+		return nil, m, errors.Join(storage.ErrorURLJoinPath, err)
+	}
+	// The GetObject response carries the object metadata, so no HeadObject call is needed
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	resp, err := s.Client.GetObject(ctx,
+		&s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		}, s.requestOptions()...)
+	err = s.timeoutError(ctx, "get", location, err)
+	if err != nil {
+		return nil, m, errors.Join(storage.ErrorGetObject, err)
+	}
+	bodyBytes, err := io.ReadAll(resp.Body)
+	err = s.timeoutError(ctx, "get", location, err)
+	if err != nil {
+		return nil, m, errors.Join(storage.ErrorGetObject, err)
+	}
+
+	m.Location = *location
+	if resp.LastModified != nil {
+		m.LastModified = *resp.LastModified
+	}
+	m.Size = int64(len(bodyBytes))
+	m.ETag = aws.ToString(resp.ETag)
+	return bodyBytes, m, nil
+}
+
+func (s *S3ObjectStore) GetIfNoneMatch(location *storage.Path, etag string) ([]byte, storage.ObjectMeta, bool, error) {
+	var m storage.ObjectMeta
+	key, err := url.JoinPath(s.path, location.Raw)
+	if err != nil {
+		return nil, m, false, errors.Join(storage.ErrorURLJoinPath, err)
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	resp, err := s.Client.GetObject(ctx, input, s.requestOptions()...)
+	err = s.timeoutError(ctx, "get", location, err)
+	// S3 responds with 304 Not Modified when the ETag matches
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotModified {
+		m.Location = *location
+		m.ETag = etag
+		return nil, m, true, nil
+	}
+	if err != nil {
+		return nil, m, false, errors.Join(storage.ErrorGetObject, err)
+	}
+	bodyBytes, err := io.ReadAll(resp.Body)
+	err = s.timeoutError(ctx, "get", location, err)
+	if err != nil {
+		return nil, m, false, errors.Join(storage.ErrorGetObject, err)
+	}
+
+	m.Location = *location
+	if resp.LastModified != nil {
+		m.LastModified = *resp.LastModified
+	}
+	m.Size = int64(len(bodyBytes))
+	m.ETag = aws.ToString(resp.ETag)
+	return bodyBytes, m, false, nil
+}
+
 func (s *S3ObjectStore) Delete(location *storage.Path) error {
 	key, err := url.JoinPath(s.path, location.Raw)
 	if err != nil {
 		return errors.Join(storage.ErrorURLJoinPath, err)
 	}
-	_, err = s.Client.DeleteObject(context.Background(),
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	_, err = s.Client.DeleteObject(ctx,
 		&s3.DeleteObjectInput{
 			Bucket: aws.String(s.bucket),
 			Key:    aws.String(key),
-		})
+		}, s.requestOptions()...)
+	err = s.timeoutError(ctx, "delete", location, err)
 	if err != nil {
 		return errors.Join(storage.ErrorDeleteObject, err)
 	}
 	return nil
 }
 
+// DeleteBulk deletes the objects with DeleteObjects requests of up to 1000 keys
+func (s *S3ObjectStore) DeleteBulk(locations []storage.Path) []error {
+	errs := make([]error, len(locations))
+	for start := 0; start < len(locations); start += maxDeleteObjectsKeys {
+		end := start + maxDeleteObjectsKeys
+		if end > len(locations) {
+			end = len(locations)
+		}
+		indexes := make(map[string]int, end-start)
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for i := start; i < end; i++ {
+			key, err := url.JoinPath(s.path, locations[i].Raw)
+			if err != nil {
+				errs[i] = errors.Join(storage.ErrorURLJoinPath, err)
+				continue
+			}
+			indexes[key] = i
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		ctx, cancel := s.requestContext()
+		output, err := s.Client.DeleteObjects(ctx,
+			&s3.DeleteObjectsInput{
+				Bucket: aws.String(s.bucket),
+				Delete: &types.Delete{Objects: objects, Quiet: true},
+			}, s.requestOptions()...)
+		err = s.timeoutError(ctx, "delete", nil, err)
+		cancel()
+		if err != nil {
+			for _, i := range indexes {
+				errs[i] = errors.Join(storage.ErrorDeleteObject, err)
+			}
+			continue
+		}
+		for _, deleteError := range output.Errors {
+			if i, ok := indexes[aws.ToString(deleteError.Key)]; ok {
+				errs[i] = errors.Join(storage.ErrorDeleteObject, fmt.Errorf("%s: %s", aws.ToString(deleteError.Code), aws.ToString(deleteError.Message)))
+			}
+		}
+	}
+	return errs
+}
+
+// HeadBulk returns the metadata of the objects with up to HeadConcurrency concurrent HeadObject requests
+func (s *S3ObjectStore) HeadBulk(locations []*storage.Path) ([]storage.ObjectMeta, []error) {
+	metas := make([]storage.ObjectMeta, len(locations))
+	errs := make([]error, len(locations))
+	concurrency := s.HeadConcurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_HEAD_CONCURRENCY
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(locations); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				metas[i], errs[i] = s.Head(locations[i])
+			}
+		}()
+	}
+	for i := range locations {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return metas, errs
+}
+
+// RenameIfNotExists moves from to to, failing with ErrorVersionAlreadyExists if to exists.
+// S3 has no conditional copy, so the existence check and the copy are not atomic; a lock must serialize writers.
+// A failure to check the destination is returned rather than assuming it does not exist, with its category, so that
+// a transient failure is never mistaken for an existing destination.
 func (s *S3ObjectStore) RenameIfNotExists(from *storage.Path, to *storage.Path) error {
 	_, err := s.Head(to)
 	if err == nil {
-		return errors.Join(storage.ErrorObjectAlreadyExists, err)
+		return storage.NewStorageError("rename", to, storage.ErrorAlreadyExists,
+			fmt.Errorf("error %w: Object at location %s already exists", storage.ErrorVersionAlreadyExists, to.Raw))
+	}
+	if !errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		return storage.NewStorageError("rename", to, errorCategory(err), err)
 	}
 
-	err = s.Rename(from, to)
+	err = s.copyObject(from, to)
 	if err != nil {
-		return err
+		return storage.NewStorageError("rename", from, errorCategory(err), errors.Join(storage.ErrorCopyObject, err))
 	}
+	// The destination is in place, a leftover source is only a stray temporary file
+	s.Delete(from)
 	return nil
 }
 
 func (s *S3ObjectStore) Rename(from *storage.Path, to *storage.Path) error {
+	err := s.copyObject(from, to)
+	if err != nil {
+		return errors.Join(storage.ErrorCopyObject, err)
+	}
+	err = s.Delete(from)
+	if err != nil {
+		return errors.Join(storage.ErrorDeleteObject, err)
+	}
+	return nil
+}
+
+// copyObject copies the object at from to to, overwriting to if it exists
+func (s *S3ObjectStore) copyObject(from *storage.Path, to *storage.Path) error {
 	srcKey, err := url.JoinPath(s.path, from.Raw)
 	if err != nil {
 		return errors.Join(storage.ErrorURLJoinPath, err)
@@ -149,21 +633,44 @@ func (s *S3ObjectStore) Rename(from *storage.Path, to *storage.Path) error {
 	if err != nil {
 		return errors.Join(storage.ErrorURLJoinPath, err)
 	}
-	_, err = s.Client.CopyObject(context.Background(),
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	_, err = s.Client.CopyObject(ctx,
 		&s3.CopyObjectInput{
 			Bucket:                aws.String(s.bucket),
 			Key:                   aws.String(destKey),
 			CopySource:            aws.String(srcKey),
 			CopySourceIfNoneMatch: aws.String("null"),
-		})
-	if err != nil {
-		return errors.Join(storage.ErrorCopyObject, err)
+		}, s.requestOptions()...)
+	return s.timeoutError(ctx, "copy", from, err)
+}
+
+// errorCategory maps an S3 error to a storage.StorageError category.
+// Throttling, server errors and network timeouts are transient.
+func errorCategory(err error) error {
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) {
+		switch code := re.HTTPStatusCode(); {
+		case code == http.StatusNotFound:
+			return storage.ErrorNotFound
+		case code == http.StatusForbidden:
+			return storage.ErrorAccessDenied
+		case code == http.StatusTooManyRequests, code >= http.StatusInternalServerError:
+			return storage.ErrorTransient
+		}
+		return storage.ErrorUnknown
 	}
-	err = s.Delete(from)
-	if err != nil {
-		return errors.Join(storage.ErrorDeleteObject, err)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return storage.ErrorTransient
 	}
-	return nil
+	if errors.Is(err, storage.ErrorOperationTimeout) {
+		return storage.ErrorTransient
+	}
+	if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+		return storage.ErrorNotFound
+	}
+	return storage.ErrorUnknown
 }
 
 func (s *S3ObjectStore) Head(location *storage.Path) (storage.ObjectMeta, error) {
@@ -172,11 +679,14 @@ func (s *S3ObjectStore) Head(location *storage.Path) (storage.ObjectMeta, error)
 	if err != nil {
 		return m, errors.Join(storage.ErrorURLJoinPath, err)
 	}
-	result, err := s.Client.HeadObject(context.Background(),
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	result, err := s.Client.HeadObject(ctx,
 		&s3.HeadObjectInput{
 			Bucket: aws.String(s.bucket),
 			Key:    aws.String(key),
-		})
+		}, s.requestOptions()...)
+	err = s.timeoutError(ctx, "head", location, err)
 	// Check for a 404 response, indicating that the object does not exist
 	var re *awshttp.ResponseError
 	if errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotFound {
@@ -189,51 +699,83 @@ func (s *S3ObjectStore) Head(location *storage.Path) (storage.ObjectMeta, error)
 	m.Location = *location
 	m.LastModified = *result.LastModified
 	m.Size = result.ContentLength
+	m.ETag = aws.ToString(result.ETag)
 
 	return m, nil
 }
 
 func (s *S3ObjectStore) List(prefix *storage.Path) ([]storage.ObjectMeta, error) {
-	// We will need the store path with the trailing / for trimming results
+	// The store path with a trailing / is trimmed from the results. Listing under it ensures that nothing outside
+	// of the store is listed, such as the keys of a sibling table sharing the same start (e.g. if our store folder
+	// is data/t1/ and another table is in data/t1_backup/). A store at the root of the bucket has no path.
 	pathWithTrailingSeparator := s.path
-	if !strings.HasSuffix(pathWithTrailingSeparator, "/") {
+	if pathWithTrailingSeparator != "" && !strings.HasSuffix(pathWithTrailingSeparator, "/") {
 		pathWithTrailingSeparator = pathWithTrailingSeparator + "/"
 	}
 
-	// The prefix is used for pattern matching results. The fullPrefix prepends the
-	// store path.
-	var fullPrefix string
-	var err error
+	// The prefix is appended as is: keys are not paths, so cleaning it (e.g. of a ../) could leave the store
+	fullPrefix := pathWithTrailingSeparator + strings.TrimPrefix(prefix.Raw, "/")
 
-	if prefix.Raw == "" {
-		// If the prefix is empty, use path with a trailing / to avoid listing anything
-		// outside of our store path that starts with the same string.
-		// (e.g. if our store folder is /data/ and we also have /data_out.txt, etc.)
-		fullPrefix = pathWithTrailingSeparator
-	} else {
-		fullPrefix, err = url.JoinPath(s.path, prefix.Raw)
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(fullPrefix),
+		MaxKeys: int32(s.listBatchSize()),
+	}
+	objectMetas := make([]storage.ObjectMeta, 0)
+	for {
+		results, err := s.listObjects(input, prefix)
 		if err != nil {
-			return nil, errors.Join(storage.ErrorURLJoinPath, err)
+			return nil, errors.Join(storage.ErrorListObjects, err)
+		}
+		for _, result := range results.Contents {
+			if !strings.HasPrefix(*result.Key, fullPrefix) {
+				continue
+			}
+			location := strings.TrimPrefix(*result.Key, pathWithTrailingSeparator)
+			objectMetas = append(objectMetas, storage.ObjectMeta{
+				Location:     *storage.NewPath(location),
+				LastModified: *result.LastModified,
+				Size:         result.Size,
+			})
 		}
+		if !results.IsTruncated || results.NextContinuationToken == nil {
+			return objectMetas, nil
+		}
+		input.ContinuationToken = results.NextContinuationToken
 	}
+}
 
-	results, err := s.Client.ListObjectsV2(context.Background(),
-		&s3.ListObjectsV2Input{
-			Bucket: aws.String(s.bucket),
-			Prefix: aws.String(fullPrefix),
-		})
-	if err != nil {
-		return nil, errors.Join(storage.ErrorListObjects, err)
+// listObjects makes one ListObjectsV2 request, with its own deadline
+func (s *S3ObjectStore) listObjects(input *s3.ListObjectsV2Input, prefix *storage.Path) (*s3.ListObjectsV2Output, error) {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	results, err := s.Client.ListObjectsV2(ctx, input, s.requestOptions()...)
+	return results, s.timeoutError(ctx, "list", prefix, err)
+}
+
+// listBatchSize returns the number of keys of each ListObjectsV2 request, between 1 and MAX_LIST_BATCH_SIZE
+func (s *S3ObjectStore) listBatchSize() int {
+	if s.ListBatchSize <= 0 {
+		return DEFAULT_LIST_BATCH_SIZE
+	}
+	if s.ListBatchSize > MAX_LIST_BATCH_SIZE {
+		return MAX_LIST_BATCH_SIZE
 	}
-	objectMetas := make([]storage.ObjectMeta, 0, results.KeyCount)
+	return s.ListBatchSize
+}
 
-	for _, result := range results.Contents {
-		location := strings.TrimPrefix(*result.Key, pathWithTrailingSeparator)
-		objectMetas = append(objectMetas, storage.ObjectMeta{
-			Location:     *storage.NewPath(location),
-			LastModified: *result.LastModified,
-			Size:         result.Size,
-		})
+// ListModifiedAfter lists the objects with the given prefix that were last modified after since.
+// S3 cannot filter listings by modification time, so the objects are filtered client-side.
+func (s *S3ObjectStore) ListModifiedAfter(prefix *storage.Path, since time.Time) ([]storage.ObjectMeta, error) {
+	objects, err := s.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	modified := make([]storage.ObjectMeta, 0, len(objects))
+	for _, object := range objects {
+		if object.LastModified.After(since) {
+			modified = append(modified, object)
+		}
 	}
-	return objectMetas, nil
+	return modified, nil
 }
@@ -14,13 +14,21 @@ package s3store
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/rivian/delta-go/internal/s3mock"
 	"github.com/rivian/delta-go/storage"
@@ -121,6 +129,85 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetWithMeta(t *testing.T) {
+	baseURI, mockClient, s3Store := setupTest(t)
+
+	path := storage.NewPath("test.txt")
+	data := []byte("some data")
+	err := mockClient.PutFile(baseURI, path, data)
+	if err != nil {
+		t.Errorf("Error occurred setting up TestGetWithMeta: %e", err)
+	}
+	results, meta, err := s3Store.GetWithMeta(path)
+	if err != nil {
+		t.Errorf("Error occurred calling GetWithMeta: %e", err)
+	}
+	if !bytes.Equal(results, data) {
+		t.Errorf("Results did not match expected. Results: %s, Expected: %s", results, data)
+	}
+	if meta.Size != int64(len(data)) {
+		t.Errorf("Size did not match expected. Size: %d, Expected: %d", meta.Size, len(data))
+	}
+	if meta.LastModified.IsZero() {
+		t.Errorf("LastModified should be set")
+	}
+	if meta.Location != *path {
+		t.Errorf("Location did not match expected. Location: %s, Expected: %s", meta.Location.Raw, path.Raw)
+	}
+
+	_, _, err = s3Store.GetWithMeta(storage.NewPath("missing.txt"))
+	if !errors.Is(err, storage.ErrorGetObject) {
+		t.Errorf("Calling GetWithMeta on a nonexistent file did not return an appropriate error")
+	}
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	baseURI, mockClient, s3Store := setupTest(t)
+
+	path := storage.NewPath("test.txt")
+	data := []byte("some data")
+	err := mockClient.PutFile(baseURI, path, data)
+	if err != nil {
+		t.Errorf("Error occurred setting up TestGetIfNoneMatch: %e", err)
+	}
+
+	// Without an ETag the object is always returned
+	results, meta, notModified, err := s3Store.GetIfNoneMatch(path, "")
+	if err != nil {
+		t.Errorf("Error occurred calling GetIfNoneMatch: %e", err)
+	}
+	if notModified || !bytes.Equal(results, data) {
+		t.Errorf("Results did not match expected. Results: %s, Expected: %s", results, data)
+	}
+	if meta.ETag == "" {
+		t.Errorf("ETag should be set")
+	}
+
+	// With the current ETag the object is not modified
+	results, _, notModified, err = s3Store.GetIfNoneMatch(path, meta.ETag)
+	if err != nil {
+		t.Errorf("Error occurred calling GetIfNoneMatch: %e", err)
+	}
+	if !notModified || results != nil {
+		t.Errorf("Object should not be modified")
+	}
+
+	// With a stale ETag the object is returned
+	_, _, notModified, err = s3Store.GetIfNoneMatch(path, "\"stale\"")
+	if err != nil {
+		t.Errorf("Error occurred calling GetIfNoneMatch: %e", err)
+	}
+	if notModified {
+		t.Errorf("Object should be modified")
+	}
+
+	mockClient.MockError = errors.New("Something went wrong")
+	_, _, _, err = s3Store.GetIfNoneMatch(path, meta.ETag)
+	if !errors.Is(err, storage.ErrorGetObject) {
+		t.Errorf("GetIfNoneMatch did not return an appropriate error")
+	}
+}
+
 func TestGetErrorHandling(t *testing.T) {
 	baseURI, mockClient, s3Store := setupTest(t)
 
@@ -261,10 +348,22 @@ func TestRenameIfNotExistsErrorHandling(t *testing.T) {
 
 	// Renaming and overwriting should return an error
 	err = s3Store.RenameIfNotExists(path, overwritePath)
-	if !errors.Is(err, storage.ErrorObjectAlreadyExists) {
+	if !errors.Is(err, storage.ErrorObjectAlreadyExists) || !errors.Is(err, storage.ErrorVersionAlreadyExists) {
 		t.Errorf("RenameIfNotExists did not return expected error when overwriting")
 	}
 
+	// A server error is transient and is not mistaken for an existing destination
+	mockClient.MockError = s3mock.NewResponseError(http.StatusServiceUnavailable)
+	err = s3Store.RenameIfNotExists(path, storage.NewPath("third_copy.txt"))
+	if !errors.Is(err, storage.ErrorTransient) || errors.Is(err, storage.ErrorVersionAlreadyExists) {
+		t.Errorf("RenameIfNotExists did not return a transient error, has %v", err)
+	}
+	mockClient.MockError = s3mock.NewResponseError(http.StatusForbidden)
+	err = s3Store.RenameIfNotExists(path, storage.NewPath("third_copy.txt"))
+	if !errors.Is(err, storage.ErrorAccessDenied) || errors.Is(err, storage.ErrorTransient) {
+		t.Errorf("RenameIfNotExists did not return an access denied error, has %v", err)
+	}
+
 	// Test client returning an error
 	mockClient.MockError = errors.New("Something went wrong")
 	newPath := storage.NewPath("third_copy.txt")
@@ -388,6 +487,179 @@ func TestDeleteErrorHandling(t *testing.T) {
 	}
 }
 
+func TestDeleteBulk(t *testing.T) {
+	baseURI, mockClient, s3Store := setupTest(t)
+
+	paths := []storage.Path{*storage.NewPath("first.txt"), *storage.NewPath("second.txt"), *storage.NewPath("missing.txt")}
+	for _, path := range paths[:2] {
+		err := mockClient.PutFile(baseURI, &path, []byte("some data"))
+		if err != nil {
+			t.Errorf("Error occurred setting up TestDeleteBulk: %e", err)
+		}
+	}
+
+	errs := s3Store.DeleteBulk(paths)
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("Unexpected errors calling DeleteBulk: %v", errs)
+	}
+	if !errors.Is(errs[2], storage.ErrorDeleteObject) {
+		t.Errorf("DeleteBulk did not return an expected error for nonexistent file")
+	}
+	verifyFileDoesNotExist(t, baseURI, &paths[0], mockClient, "File still exists after DeleteBulk")
+	verifyFileDoesNotExist(t, baseURI, &paths[1], mockClient, "File still exists after DeleteBulk")
+
+	// Test client returning an error
+	mockClient.MockError = errors.New("Something went wrong")
+	errs = s3Store.DeleteBulk(paths[:1])
+	if !errors.Is(errs[0], storage.ErrorDeleteObject) {
+		t.Errorf("DeleteBulk did not return an expected error")
+	}
+}
+
+func TestHeadBulk(t *testing.T) {
+	baseURI, mockClient, s3Store := setupTest(t)
+	s3Store.HeadConcurrency = 2
+
+	var paths []*storage.Path
+	for i := 0; i < 5; i++ {
+		path := storage.NewPath(fmt.Sprintf("part-%d.parquet", i))
+		err := mockClient.PutFile(baseURI, path, []byte(strings.Repeat("x", i+1)))
+		if err != nil {
+			t.Errorf("Error occurred setting up TestHeadBulk: %e", err)
+		}
+		paths = append(paths, path)
+	}
+	paths = append(paths, storage.NewPath("missing.parquet"))
+
+	metas, errs := s3Store.HeadBulk(paths)
+	if len(metas) != len(paths) || len(errs) != len(paths) {
+		t.Fatalf("HeadBulk should return a result for each location")
+	}
+	for i := 0; i < 5; i++ {
+		if errs[i] != nil {
+			t.Errorf("Unexpected error calling HeadBulk: %e", errs[i])
+		}
+		if metas[i].Location.Raw != paths[i].Raw || metas[i].Size != int64(i+1) {
+			t.Errorf("Unexpected metadata for %s: %v", paths[i].Raw, metas[i])
+		}
+	}
+	if !errors.Is(errs[5], storage.ErrorObjectDoesNotExist) {
+		t.Errorf("HeadBulk did not return an expected error for nonexistent file")
+	}
+}
+
+func TestPutReader(t *testing.T) {
+	baseURI, mockClient, s3Store := setupTest(t)
+	s3Store.PartSize = MIN_PART_SIZE
+	s3Store.UploadConcurrency = 2
+
+	// A stream smaller than a part is uploaded with a single put
+	path := storage.NewPath("small.parquet")
+	err := s3Store.PutReader(path, bytes.NewReader([]byte("some data")))
+	if err != nil {
+		t.Errorf("Unexpected error calling PutReader: %e", err)
+	}
+	verifyFileContents(t, baseURI, path, mockClient, []byte("some data"), "File contents do not match after PutReader")
+	if mockClient.CompletedUploads != 0 {
+		t.Errorf("Small file should not use a multipart upload")
+	}
+
+	// A larger stream is uploaded in parts, the last one shorter
+	data := make([]byte, 3*MIN_PART_SIZE+10)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path = storage.NewPath("large.parquet")
+	err = s3Store.PutReader(path, bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Unexpected error calling PutReader: %e", err)
+	}
+	verifyFileContents(t, baseURI, path, mockClient, data, "File contents do not match after multipart PutReader")
+	if mockClient.CompletedUploads != 1 {
+		t.Errorf("Large file should use a multipart upload")
+	}
+
+	// A failed part aborts the upload
+	mockClient.MockUploadPartError = errors.New("Something went wrong")
+	path = storage.NewPath("failed.parquet")
+	err = s3Store.PutReader(path, bytes.NewReader(data))
+	if !errors.Is(err, storage.ErrorPutObject) {
+		t.Errorf("PutReader did not return an expected error")
+	}
+	if mockClient.AbortedUploads != 1 {
+		t.Errorf("Failed upload was not aborted")
+	}
+	verifyFileDoesNotExist(t, baseURI, path, mockClient, "File exists after failed PutReader")
+}
+
+func TestPresign(t *testing.T) {
+	credentials := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+	})
+	client := s3.New(s3.Options{Region: "us-east-1", Credentials: credentials})
+	s3Store, err := New(client, storage.NewPath("s3://test-bucket/test-delta-table"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := storage.NewPath("date=2023-01-01/part-0.parquet")
+	getURL, err := s3Store.PresignGet(path, 10*time.Minute)
+	if err != nil {
+		t.Errorf("Unexpected error calling PresignGet: %e", err)
+	}
+	putURL, err := s3Store.PresignPut(path, time.Hour)
+	if err != nil {
+		t.Errorf("Unexpected error calling PresignPut: %e", err)
+	}
+	for presignedURL, expires := range map[string]string{getURL: "X-Amz-Expires=600", putURL: "X-Amz-Expires=3600"} {
+		if !strings.Contains(presignedURL, "test-bucket") || !strings.Contains(presignedURL, "test-delta-table/date%3D2023-01-01/part-0.parquet") {
+			t.Errorf("Presigned URL %s does not reference the object", presignedURL)
+		}
+		if !strings.Contains(presignedURL, expires) || !strings.Contains(presignedURL, "X-Amz-Signature=") {
+			t.Errorf("Presigned URL %s is not signed with the expiry", presignedURL)
+		}
+	}
+
+	// A client that cannot presign
+	_, _, mockStore := setupTest(t)
+	_, err = mockStore.PresignGet(path, time.Minute)
+	if !errors.Is(err, storage.ErrorUnsupported) {
+		t.Errorf("PresignGet did not return ErrorUnsupported")
+	}
+}
+
+// closingClient records whether the client was closed
+type closingClient struct {
+	*s3mock.S3MockClient
+	closed bool
+}
+
+func (c *closingClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	baseURI, mockClient, s3Store := setupTest(t)
+	err := s3Store.Close()
+	if err != nil {
+		t.Errorf("Unexpected error calling Close: %e", err)
+	}
+
+	client := &closingClient{S3MockClient: mockClient}
+	s3Store, err = New(client, baseURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s3Store.Close()
+	if err != nil {
+		t.Errorf("Unexpected error calling Close: %e", err)
+	}
+	if !client.closed {
+		t.Error("Close did not close the client")
+	}
+}
+
 func compareExpectedPaths(t *testing.T, expected []string, results []storage.ObjectMeta) {
 	t.Helper()
 
@@ -459,6 +731,74 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListBatchSize(t *testing.T) {
+	baseURI, mockClient, store := setupTest(t)
+	filePaths := []string{"_delta_log/"}
+	for i := 0; i < 7; i++ {
+		filePath := fmt.Sprintf("_delta_log/%020d.json", i)
+		if err := mockClient.PutFile(baseURI, storage.NewPath(filePath), []byte("some data")); err != nil {
+			t.Fatal(err)
+		}
+		filePaths = append(filePaths, filePath)
+	}
+
+	// Batches of 2, 3 or the default size list the same objects
+	for _, batchSize := range []int{2, 3, 0, 5000} {
+		store.ListBatchSize = batchSize
+		got, err := store.List(storage.NewPath("_delta_log/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareExpectedPaths(t, filePaths, got)
+	}
+
+	for _, test := range []struct{ batchSize, want int }{{0, DEFAULT_LIST_BATCH_SIZE}, {-1, DEFAULT_LIST_BATCH_SIZE}, {100, 100}, {5000, MAX_LIST_BATCH_SIZE}} {
+		store.ListBatchSize = test.batchSize
+		if got := store.listBatchSize(); got != test.want {
+			t.Errorf("ListBatchSize %d: want %d, has %d", test.batchSize, test.want, got)
+		}
+	}
+}
+
+func TestListSiblingTables(t *testing.T) {
+	baseURI := storage.NewPath("s3://test-bucket/data/t1")
+	backupURI := storage.NewPath("s3://test-bucket/data/t1_backup")
+	mockClient, err := s3mock.NewS3MockClient(t, baseURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("some data")
+	for _, filePath := range []string{"_delta_log/00000000000000000000.json", "part-0.parquet"} {
+		if err := mockClient.PutFile(baseURI, storage.NewPath(filePath), data); err != nil {
+			t.Fatal(err)
+		}
+		if err := mockClient.PutFile(backupURI, storage.NewPath(filePath), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mockClient.PutFile(storage.NewPath("s3://test-bucket/data"), storage.NewPath("t1.json"), data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, uri := range []string{"s3://test-bucket/data/t1", "s3://test-bucket/data/t1/"} {
+		store, err := New(mockClient, storage.NewPath(uri))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for prefix, want := range map[string][]string{
+			"":            {"_delta_log/", "_delta_log/00000000000000000000.json", "part-0.parquet"},
+			"_delta_log/": {"_delta_log/", "_delta_log/00000000000000000000.json"},
+			"part":        {"part-0.parquet"},
+		} {
+			results, err := store.List(storage.NewPath(prefix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareExpectedPaths(t, want, results)
+		}
+	}
+}
+
 func TestListErrorHandling(t *testing.T) {
 	_, mockClient, store := setupTest(t)
 
@@ -470,3 +810,111 @@ func TestListErrorHandling(t *testing.T) {
 		t.Errorf("Delete did not return an expected error")
 	}
 }
+
+func TestUserAgentAndObjectTags(t *testing.T) {
+	var mu sync.Mutex
+	userAgents := make(map[string]string)
+	tagging := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents[r.Method] = r.Header.Get("User-Agent")
+		tagging[r.Method] = r.Header.Get("X-Amz-Tagging")
+		mu.Unlock()
+		io.Copy(io.Discard, r.Body)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("data"))
+		}
+	}))
+	defer server.Close()
+
+	credentials := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+	})
+	client := s3.New(s3.Options{Region: "us-east-1", Credentials: credentials, UsePathStyle: true, EndpointResolver: s3.EndpointResolverFromURL(server.URL)})
+	s3Store, err := New(client, storage.NewPath("s3://test-bucket/test-delta-table"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3Store.UserAgent = "tenant/acme"
+	s3Store.ObjectTags = map[string]string{"team": "data eng", "cost-center": "42"}
+
+	path := storage.NewPath("part-0.parquet")
+	if err := s3Store.Put(path, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3Store.Get(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(userAgents) != 2 {
+		t.Fatalf("want a PUT and a GET request, has %v", userAgents)
+	}
+	for method, userAgent := range userAgents {
+		if !strings.Contains(userAgent, "tenant/acme") {
+			t.Errorf("%s: the user agent %s should include tenant/acme", method, userAgent)
+		}
+	}
+	if tagging[http.MethodPut] != "cost-center=42&team=data+eng" {
+		t.Errorf("PutObject should be tagged, has %s", tagging[http.MethodPut])
+	}
+	if tagging[http.MethodGet] != "" {
+		t.Errorf("GetObject should not be tagged, has %s", tagging[http.MethodGet])
+	}
+}
+
+func TestListModifiedAfter(t *testing.T) {
+	baseURI, mockClient, store := setupTest(t)
+	for _, filePath := range []string{"part-0.parquet", "part-1.parquet", "other.json"} {
+		if err := mockClient.PutFile(baseURI, storage.NewPath(filePath), []byte("some data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modified, err := store.ListModifiedAfter(storage.NewPath("part-"), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareExpectedPaths(t, []string{"part-0.parquet", "part-1.parquet"}, modified)
+
+	modified, err = store.ListModifiedAfter(storage.NewPath("part-"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareExpectedPaths(t, []string{}, modified)
+}
+
+func TestOperationTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if strings.HasSuffix(r.URL.Path, "/slow.parquet") {
+			<-release
+		}
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	credentials := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+	})
+	client := s3.New(s3.Options{Region: "us-east-1", Credentials: credentials, UsePathStyle: true, EndpointResolver: s3.EndpointResolverFromURL(server.URL)})
+	s3Store, err := New(client, storage.NewPath("s3://test-bucket/test-delta-table"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3Store.OperationTimeout = 100 * time.Millisecond
+
+	if data, err := s3Store.Get(storage.NewPath("fast.parquet")); err != nil || string(data) != "data" {
+		t.Errorf("unexpected object %s %v", data, err)
+	}
+	path := storage.NewPath("slow.parquet")
+	errs := map[string]error{"put": s3Store.Put(path, []byte("data")), "delete": s3Store.Delete(path)}
+	_, errs["get"] = s3Store.Get(path)
+	_, errs["head"] = s3Store.Head(path)
+	errs["rename"] = s3Store.RenameIfNotExists(storage.NewPath("fast.parquet"), path)
+	for operation, err := range errs {
+		if !errors.Is(err, storage.ErrorOperationTimeout) || !errors.Is(err, storage.ErrorTransient) {
+			t.Errorf("%s: want a transient ErrorOperationTimeout, has %v", operation, err)
+		}
+	}
+}
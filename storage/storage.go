@@ -16,6 +16,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"path/filepath"
 	"time"
@@ -33,8 +34,65 @@ var (
 	ErrorDeleteObject         error = errors.New("error while deleting the object")
 	ErrorURLJoinPath          error = errors.New("error during url.JoinPath")
 	ErrorListObjects          error = errors.New("error while listing objects")
+	ErrorUnsupported          error = errors.New("the operation is not supported by the object store")
+	ErrorPresignObject        error = errors.New("error while presigning the object url")
+	ErrorPathOutsideStore     error = errors.New("the path is outside of the object store")
+	ErrorOperationTimeout     error = errors.New("the operation timed out")
 )
 
+// Categories of StorageError, shared by all ObjectStore implementations
+var (
+	ErrorNotFound      error = errors.New("not found")
+	ErrorAlreadyExists error = errors.New("already exists")
+	ErrorAccessDenied  error = errors.New("access denied")
+	ErrorTransient     error = errors.New("transient failure")
+	ErrorUnknown       error = errors.New("unknown failure")
+)
+
+// StorageError is returned by ObjectStore operations.
+// errors.Is matches the category, one of ErrorNotFound, ErrorAlreadyExists, ErrorAccessDenied, ErrorTransient
+// or ErrorUnknown, as well as the wrapped error. ErrorNotFound also matches ErrorObjectDoesNotExist and
+// ErrorAlreadyExists also matches ErrorObjectAlreadyExists.
+type StorageError struct {
+	// The ObjectStore operation that failed, e.g. "get"
+	Operation string
+	// The location the operation was applied to
+	Location string
+	// The category sentinel of the failure
+	Category error
+	// The underlying error
+	Err error
+}
+
+func NewStorageError(operation string, location *Path, category error, err error) *StorageError {
+	storageErr := new(StorageError)
+	storageErr.Operation = operation
+	if location != nil {
+		storageErr.Location = location.Raw
+	}
+	storageErr.Category = category
+	storageErr.Err = err
+	return storageErr
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %v", e.Operation, e.Location, e.Category, e.Err)
+}
+
+func (e *StorageError) Unwrap() []error {
+	return []error{e.Category, e.Err}
+}
+
+func (e *StorageError) Is(target error) bool {
+	switch target {
+	case ErrorObjectDoesNotExist:
+		return e.Category == ErrorNotFound
+	case ErrorObjectAlreadyExists:
+		return e.Category == ErrorAlreadyExists
+	}
+	return false
+}
+
 type DeltaStorageResult struct {
 }
 
@@ -107,6 +165,8 @@ type ObjectMeta struct {
 	LastModified time.Time
 	/// The size in bytes of the object
 	Size int64
+	/// The entity tag of the object, which changes when the object is modified
+	ETag string
 }
 
 // / Result of a list call that includes objects, prefixes (directories) and a
@@ -159,6 +219,15 @@ type ObjectStore interface {
 	/// Return the bytes that are stored at the specified location.
 	Get(location *Path) ([]byte, error)
 
+	/// Return the bytes that are stored at the specified location together with the object metadata,
+	/// avoiding a separate Head call.
+	GetWithMeta(location *Path) ([]byte, ObjectMeta, error)
+
+	/// Return the bytes and metadata stored at the specified location, unless the object's ETag matches etag.
+	/// When the ETag matches, notModified is true and no bytes are returned, letting callers cheaply poll
+	/// an object they have already cached.
+	GetIfNoneMatch(location *Path, etag string) (data []byte, meta ObjectMeta, notModified bool, err error)
+
 	// 	/// Return the bytes that are stored at the specified location
 	// 	/// in the given byte range
 	// 	GetRange(location *Path, r Range) error
@@ -179,6 +248,11 @@ type ObjectStore interface {
 	/// `foo/bar_baz/x`.
 	List(prefix *Path) ([]ObjectMeta, error)
 
+	/// List the objects with the given prefix that were last modified after since, for instance to copy only the
+	/// objects changed since a previous incremental sync. The objects are filtered as they are listed, with the same
+	/// limitations as List.
+	ListModifiedAfter(prefix *Path, since time.Time) ([]ObjectMeta, error)
+
 	// 	/// List all the objects with the given prefix.
 	// 	///
 	// 	/// Prefixes are evaluated on a path segment basis, i.e. `foo/bar/` is a prefix of `foo/bar/x` but not of
@@ -219,4 +293,41 @@ type ObjectStore interface {
 
 	// Will return an error if the destination already has an object.
 	RenameIfNotExists(from *Path, to *Path) error
+
+	/// Return the absolute URI of the store root, used to build absolute references to objects in the store
+	RootURI() string
+
+	/// Release the resources held by the store, such as network clients. The store must not be used after Close.
+	Close() error
+}
+
+// BulkDeleter is implemented by object stores that can delete many objects in a single request
+type BulkDeleter interface {
+	/// Delete the objects at the given locations. The returned errors are aligned with locations,
+	/// with a nil error for each object that was deleted.
+	DeleteBulk(locations []Path) []error
+}
+
+// BulkHeader is implemented by object stores that can fetch the metadata of many objects at once,
+// such as network stores issuing concurrent requests
+type BulkHeader interface {
+	/// Return the metadata of the objects at the given locations. The metadata and errors are aligned with
+	/// locations, with a nil error for each object that was found.
+	HeadBulk(locations []*Path) ([]ObjectMeta, []error)
+}
+
+// ReaderPutter is implemented by object stores that can write an object from a stream without buffering it whole,
+// such as large data files
+type ReaderPutter interface {
+	/// Save the data read from the reader to the specified location
+	PutReader(location *Path, reader io.Reader) error
+}
+
+// Presigner is implemented by network object stores that can generate time-limited URLs giving direct access to
+// an object without credentials. Stores that cannot presign return ErrorUnsupported.
+type Presigner interface {
+	/// Return a URL from which the object at the location can be downloaded until the expiry has elapsed
+	PresignGet(location *Path, expiry time.Duration) (string, error)
+	/// Return a URL to which an object can be uploaded to the location until the expiry has elapsed
+	PresignPut(location *Path, expiry time.Duration) (string, error)
 }
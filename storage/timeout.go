@@ -0,0 +1,163 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NewTimeoutError returns the error of an operation that did not complete within the timeout.
+// It is a transient StorageError matching ErrorOperationTimeout.
+func NewTimeoutError(operation string, location *Path, timeout time.Duration) *StorageError {
+	return NewStorageError(operation, location, ErrorTransient, errors.Join(ErrorOperationTimeout, fmt.Errorf("no result after %s", timeout)))
+}
+
+// TimeoutStore is an ObjectStore that fails the operations of the inner store that take longer than Timeout with
+// NewTimeoutError, as a safety net against hung requests. Operations are not limited when Timeout is 0.
+// An operation that times out is not cancelled: it keeps running in the background and may still take effect, like
+// any operation failing with a transient error.
+// The network stores have an OperationTimeout option that cancels the requests instead, and should be preferred.
+type TimeoutStore struct {
+	ObjectStore
+	Timeout time.Duration
+}
+
+// Compile time check that TimeoutStore implements ObjectStore and RangeGetter
+var _ ObjectStore = (*TimeoutStore)(nil)
+var _ RangeGetter = (*TimeoutStore)(nil)
+
+// NewTimeoutStore creates a store limiting each operation of the inner store to the timeout
+func NewTimeoutStore(inner ObjectStore, timeout time.Duration) *TimeoutStore {
+	store := new(TimeoutStore)
+	store.ObjectStore = inner
+	store.Timeout = timeout
+	return store
+}
+
+// withTimeout returns the result of f, or a timeout error if f does not return within the timeout
+func withTimeout[T any](timeout time.Duration, operation string, location *Path, f func() (T, error)) (T, error) {
+	if timeout <= 0 {
+		return f()
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	// Buffered so that the goroutine of an operation that timed out does not leak once it completes
+	results := make(chan result, 1)
+	go func() {
+		value, err := f()
+		results <- result{value, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-timer.C:
+		var zero T
+		return zero, NewTimeoutError(operation, location, timeout)
+	}
+}
+
+// The results of the operations returning more than a value
+type getResult struct {
+	data        []byte
+	meta        ObjectMeta
+	notModified bool
+}
+
+func (s *TimeoutStore) Put(location *Path, data []byte) error {
+	_, err := withTimeout(s.Timeout, "put", location, func() (struct{}, error) {
+		return struct{}{}, s.ObjectStore.Put(location, data)
+	})
+	return err
+}
+
+func (s *TimeoutStore) Get(location *Path) ([]byte, error) {
+	return withTimeout(s.Timeout, "get", location, func() ([]byte, error) {
+		return s.ObjectStore.Get(location)
+	})
+}
+
+func (s *TimeoutStore) GetWithMeta(location *Path) ([]byte, ObjectMeta, error) {
+	r, err := withTimeout(s.Timeout, "get", location, func() (getResult, error) {
+		data, meta, err := s.ObjectStore.GetWithMeta(location)
+		return getResult{data: data, meta: meta}, err
+	})
+	return r.data, r.meta, err
+}
+
+func (s *TimeoutStore) GetIfNoneMatch(location *Path, etag string) ([]byte, ObjectMeta, bool, error) {
+	r, err := withTimeout(s.Timeout, "get", location, func() (getResult, error) {
+		data, meta, notModified, err := s.ObjectStore.GetIfNoneMatch(location, etag)
+		return getResult{data, meta, notModified}, err
+	})
+	return r.data, r.meta, r.notModified, err
+}
+
+// GetRange returns the bytes of the object at the location from r.Start up to r.End exclusive, with the inner store's
+// GetRange if it is a RangeGetter, and from the whole object otherwise
+func (s *TimeoutStore) GetRange(location *Path, r Range) ([]byte, error) {
+	return withTimeout(s.Timeout, "get", location, func() ([]byte, error) {
+		if rangeGetter, ok := s.ObjectStore.(RangeGetter); ok {
+			return rangeGetter.GetRange(location, r)
+		}
+		data, err := s.ObjectStore.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		return sliceRange(data, r), nil
+	})
+}
+
+func (s *TimeoutStore) Head(location *Path) (ObjectMeta, error) {
+	return withTimeout(s.Timeout, "head", location, func() (ObjectMeta, error) {
+		return s.ObjectStore.Head(location)
+	})
+}
+
+func (s *TimeoutStore) Delete(location *Path) error {
+	_, err := withTimeout(s.Timeout, "delete", location, func() (struct{}, error) {
+		return struct{}{}, s.ObjectStore.Delete(location)
+	})
+	return err
+}
+
+func (s *TimeoutStore) List(prefix *Path) ([]ObjectMeta, error) {
+	return withTimeout(s.Timeout, "list", prefix, func() ([]ObjectMeta, error) {
+		return s.ObjectStore.List(prefix)
+	})
+}
+
+func (s *TimeoutStore) ListModifiedAfter(prefix *Path, since time.Time) ([]ObjectMeta, error) {
+	return withTimeout(s.Timeout, "list", prefix, func() ([]ObjectMeta, error) {
+		return s.ObjectStore.ListModifiedAfter(prefix, since)
+	})
+}
+
+func (s *TimeoutStore) Rename(from *Path, to *Path) error {
+	_, err := withTimeout(s.Timeout, "rename", from, func() (struct{}, error) {
+		return struct{}{}, s.ObjectStore.Rename(from, to)
+	})
+	return err
+}
+
+func (s *TimeoutStore) RenameIfNotExists(from *Path, to *Path) error {
+	_, err := withTimeout(s.Timeout, "rename", from, func() (struct{}, error) {
+		return struct{}{}, s.ObjectStore.RenameIfNotExists(from, to)
+	})
+	return err
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowStore is a memoryStore whose reads of the objects in slow hang until released
+type slowStore struct {
+	*memoryStore
+	slow    map[string]bool
+	release chan struct{}
+}
+
+func (s *slowStore) Get(location *Path) ([]byte, error) {
+	if s.slow[location.Raw] {
+		<-s.release
+	}
+	return s.memoryStore.Get(location)
+}
+
+func (s *slowStore) Head(location *Path) (ObjectMeta, error) {
+	if s.slow[location.Raw] {
+		<-s.release
+	}
+	return s.memoryStore.Head(location)
+}
+
+func TestTimeoutStore(t *testing.T) {
+	inner := &slowStore{
+		memoryStore: newMemoryStore(map[string]string{"fast.parquet": "0123456789", "slow.parquet": "data"}),
+		slow:        map[string]bool{"slow.parquet": true},
+		release:     make(chan struct{}),
+	}
+	defer close(inner.release)
+	store := NewTimeoutStore(inner, 50*time.Millisecond)
+
+	data, err := store.Get(NewPath("fast.parquet"))
+	if err != nil || string(data) != "0123456789" {
+		t.Errorf("unexpected object %s %v", data, err)
+	}
+	data, err = store.GetRange(NewPath("fast.parquet"), Range{Start: 2, End: 5})
+	if err != nil || string(data) != "234" {
+		t.Errorf("want 234, has %s %v", data, err)
+	}
+	if _, err := store.Get(NewPath("missing.parquet")); !errors.Is(err, ErrorObjectDoesNotExist) {
+		t.Errorf("want ErrorObjectDoesNotExist, has %v", err)
+	}
+
+	start := time.Now()
+	_, err = store.Get(NewPath("slow.parquet"))
+	if !errors.Is(err, ErrorOperationTimeout) || !errors.Is(err, ErrorTransient) {
+		t.Errorf("want a transient ErrorOperationTimeout, has %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the get should time out after 50ms, took %s", elapsed)
+	}
+	var storageErr *StorageError
+	if !errors.As(err, &storageErr) || storageErr.Operation != "get" || storageErr.Location != "slow.parquet" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := store.Head(NewPath("slow.parquet")); !errors.Is(err, ErrorOperationTimeout) {
+		t.Errorf("want ErrorOperationTimeout, has %v", err)
+	}
+}
+
+func TestTimeoutStoreWithoutTimeout(t *testing.T) {
+	inner := &slowStore{
+		memoryStore: newMemoryStore(map[string]string{"slow.parquet": "data"}),
+		slow:        map[string]bool{"slow.parquet": true},
+		release:     make(chan struct{}),
+	}
+	store := NewTimeoutStore(inner, 0)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(inner.release)
+	}()
+	data, err := store.Get(NewPath("slow.parquet"))
+	if err != nil || string(data) != "data" {
+		t.Errorf("unexpected object %s %v", data, err)
+	}
+}
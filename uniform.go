@@ -0,0 +1,106 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+const (
+	// Table property listing the formats, such as iceberg, that UniForm maintains metadata for, e.g. "iceberg,hudi"
+	UNIVERSAL_FORMAT_ENABLED_FORMATS_PROPERTY = "delta.universalFormat.enabledFormats"
+	// Table property pointing at the latest Iceberg metadata file written by UniForm, when the writer records it
+	ICEBERG_METADATA_LOCATION_PROPERTY = "delta.universalFormat.iceberg.metadataLocation"
+	// ICEBERG_METADATA_DIRECTORY is the directory of the table root holding the Iceberg metadata files
+	ICEBERG_METADATA_DIRECTORY = "metadata"
+
+	UNIVERSAL_FORMAT_ICEBERG = "iceberg"
+	UNIVERSAL_FORMAT_HUDI    = "hudi"
+)
+
+// Iceberg metadata files are named v<version>.metadata.json, or <version>-<uuid>.metadata.json by catalogs
+var icebergMetadataFileRegex = regexp.MustCompile(`^v?(\d+)(-[^/]+)?\.metadata\.json$`)
+
+// UniversalFormats returns the formats UniForm maintains metadata for, in lower case
+func (dtmd *DeltaTableMetaData) UniversalFormats() []string {
+	value, ok := dtmd.Configuration[UNIVERSAL_FORMAT_ENABLED_FORMATS_PROPERTY]
+	if !ok {
+		return nil
+	}
+	var formats []string
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format != "" {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// IcebergEnabled returns true if UniForm maintains Iceberg metadata for the table
+func (dtmd *DeltaTableMetaData) IcebergEnabled() bool {
+	for _, format := range dtmd.UniversalFormats() {
+		if format == UNIVERSAL_FORMAT_ICEBERG {
+			return true
+		}
+	}
+	return false
+}
+
+// IcebergMetadataLocation returns the location of the latest Iceberg metadata file of a UniForm table.
+// The location recorded in the table properties is preferred; otherwise the metadata directory of the table
+// is searched for the metadata file with the highest version. Returns false if Iceberg is not enabled
+// or no metadata file has been written yet. The Iceberg metadata itself is not interpreted.
+func (table *DeltaTable) IcebergMetadataLocation() (string, bool, error) {
+	if !table.State.CurrentMetadata.IcebergEnabled() {
+		return "", false, nil
+	}
+	if location, ok := table.State.Property(ICEBERG_METADATA_LOCATION_PROPERTY); ok && location != "" {
+		return location, true, nil
+	}
+
+	results, err := table.Store.List(storage.NewPath(ICEBERG_METADATA_DIRECTORY + "/"))
+	if err != nil {
+		if errors.Is(err, storage.ErrorObjectDoesNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	var latestBase string
+	latest := int64(-1)
+	for _, meta := range results {
+		base := meta.Location.Base()
+		match := icebergMetadataFileRegex.FindStringSubmatch(base)
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid Iceberg metadata file %s: %w", base, err)
+		}
+		if version > latest || (version == latest && base > latestBase) {
+			latest = version
+			latestBase = base
+		}
+	}
+	if latest < 0 {
+		return "", false, nil
+	}
+	return ICEBERG_METADATA_DIRECTORY + "/" + latestBase, true, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUniversalFormats(t *testing.T) {
+	metadata := DeltaTableMetaData{Configuration: map[string]string{UNIVERSAL_FORMAT_ENABLED_FORMATS_PROPERTY: " Iceberg, hudi ,"}}
+	if formats := metadata.UniversalFormats(); !reflect.DeepEqual(formats, []string{"iceberg", "hudi"}) {
+		t.Errorf("want [iceberg hudi], has %v", formats)
+	}
+	if !metadata.IcebergEnabled() {
+		t.Error("Iceberg should be enabled")
+	}
+	metadata = DeltaTableMetaData{Configuration: map[string]string{}}
+	if metadata.UniversalFormats() != nil || metadata.IcebergEnabled() {
+		t.Error("no format should be enabled")
+	}
+}
+
+func TestIcebergMetadataLocation(t *testing.T) {
+	table, _, tmpDir := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	configuration := map[string]string{UNIVERSAL_FORMAT_ENABLED_FORMATS_PROPERTY: UNIVERSAL_FORMAT_ICEBERG}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{}, configuration)
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// No Iceberg metadata has been written yet
+	_, ok, err := table.IcebergMetadataLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("no Iceberg metadata location should be found")
+	}
+
+	metadataDir := filepath.Join(tmpDir, ICEBERG_METADATA_DIRECTORY)
+	if err := os.MkdirAll(metadataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"v1.metadata.json", "v10.metadata.json", "v2.metadata.json", "snap-1.avro", "version-hint.text"} {
+		if err := os.WriteFile(filepath.Join(metadataDir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	location, ok, err := table.IcebergMetadataLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || location != "metadata/v10.metadata.json" {
+		t.Errorf("want metadata/v10.metadata.json, has %s", location)
+	}
+
+	// The location recorded in the table properties is preferred
+	table.State.CurrentMetadata.Configuration[ICEBERG_METADATA_LOCATION_PROPERTY] = "s3://bucket/table/metadata/00011-abc.metadata.json"
+	location, ok, err = table.IcebergMetadataLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || location != "s3://bucket/table/metadata/00011-abc.metadata.json" {
+		t.Errorf("want the recorded location, has %s", location)
+	}
+
+	// Iceberg is not enabled
+	table.State.CurrentMetadata.Configuration = map[string]string{}
+	if _, ok, _ := table.IcebergMetadataLocation(); ok {
+		t.Error("no Iceberg metadata location should be found without UniForm")
+	}
+}
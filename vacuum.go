@@ -0,0 +1,274 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrorVacuumRetentionTooShort error = errors.New("the vacuum retention is shorter than the table's delta.deletedFileRetentionDuration")
+	ErrorVacuum                  error = errors.New("error vacuuming the table")
+)
+
+const (
+	// The default number of concurrent delete workers of Vacuum
+	DEFAULT_VACUUM_PARALLELISM = 8
+	// The number of files deleted per request by object stores that implement storage.BulkDeleter
+	VACUUM_DELETE_BATCH_SIZE = 1000
+)
+
+// The table features Vacuum supports: either they do not reference files of the table directory, or the files they
+// reference, such as deletion vector files, are kept by Vacuum
+var vacuumSupportedFeatures = []string{
+	APPEND_ONLY_FEATURE,
+	INVARIANTS_FEATURE,
+	DOMAIN_METADATA_FEATURE,
+	ROW_TRACKING_FEATURE,
+	CHANGE_DATA_FEED_FEATURE,
+	DELETION_VECTORS_FEATURE,
+	VACUUM_PROTOCOL_CHECK_FEATURE,
+}
+
+// VacuumOptions configures Vacuum
+type VacuumOptions struct {
+	// Files that are no longer referenced by the table are deleted once they are older than the retention.
+	// Defaults to the table's delta.deletedFileRetentionDuration when zero.
+	Retention time.Duration
+	// Allow a retention shorter than delta.deletedFileRetentionDuration.
+	// Readers of older table versions and concurrent writers may fail if their files are deleted.
+	DisableRetentionCheck bool
+	// List the files that would be deleted without deleting them
+	DryRun bool
+	// The maximum number of concurrent delete workers
+	Parallelism int
+	// Optional callback reporting the number of processed files, including failures, out of the total to delete.
+	// Calls are serialized.
+	Progress func(processed int, total int)
+}
+
+// NewVacuumOptions returns the default vacuum options
+func NewVacuumOptions() *VacuumOptions {
+	return &VacuumOptions{Parallelism: DEFAULT_VACUUM_PARALLELISM}
+}
+
+// Vacuum deletes the data files in the table directory that are no longer referenced by the table and are older
+// than the retention. Files and directories whose name starts with _ or ., such as the _delta_log, are ignored.
+// The table state must be loaded; it is updated to the latest version before looking for unreferenced files.
+// Deletes are spread over a bounded pool of workers, using storage.BulkDeleter when the object store implements it.
+// The deletion vector files of the referenced files are kept. Tables with the vacuumProtocolCheck feature are only
+// vacuumed if all their reader and writer features are supported, ErrorUnsupportedProtocol is returned otherwise.
+// Returns the files that were deleted (or would be, when DryRun is set), and the errors of the files that could not
+// be deleted, joined.
+func (table *DeltaTable) Vacuum(options *VacuumOptions) ([]storage.Path, error) {
+	return table.VacuumWithContext(context.Background(), options)
+}
+
+// VacuumWithContext is Vacuum stopping promptly when the context is cancelled. No more deletes are started once the
+// context is done; the files deleted so far are returned with the error of the context. Since vacuum only deletes
+// files that are no longer referenced, the table is consistent whenever it is cancelled.
+func (table *DeltaTable) VacuumWithContext(ctx context.Context, options *VacuumOptions) ([]storage.Path, error) {
+	if options == nil {
+		options = NewVacuumOptions()
+	}
+	if table.State.Version < 0 {
+		return nil, ErrorNotATable
+	}
+	// Files added by commits since the state was loaded must not be deleted
+	if err := table.Update(); err != nil {
+		return nil, errors.Join(ErrorVacuum, err)
+	}
+	if err := table.checkVacuumProtocol(); err != nil {
+		return nil, errors.Join(ErrorVacuum, err)
+	}
+	retention := options.Retention
+	if retention == 0 {
+		retention = table.State.TombstoneRetention
+	}
+	if retention < table.State.TombstoneRetention && !options.DisableRetentionCheck {
+		return nil, errors.Join(ErrorVacuumRetentionTooShort, fmt.Errorf("%s < %s", retention, table.State.TombstoneRetention))
+	}
+
+	candidates, err := table.vacuumCandidates(time.Now().Add(-retention))
+	if err != nil {
+		return nil, errors.Join(ErrorVacuum, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if options.DryRun {
+		return candidates, nil
+	}
+	return deleteFiles(ctx, table.dataStore(), candidates, options.Parallelism, options.Progress)
+}
+
+// checkVacuumProtocol returns ErrorUnsupportedProtocol if the table has the vacuumProtocolCheck feature and a reader
+// or writer feature Vacuum does not support
+func (table *DeltaTable) checkVacuumProtocol() error {
+	features := append(append([]string{}, table.State.ReaderFeatures...), table.State.WriterFeatures...)
+	if !slices.Contains(features, VACUUM_PROTOCOL_CHECK_FEATURE) {
+		return nil
+	}
+	for _, feature := range features {
+		if !slices.Contains(vacuumSupportedFeatures, feature) {
+			return errors.Join(ErrorUnsupportedProtocol, fmt.Errorf("vacuum does not support the table feature %s", feature))
+		}
+	}
+	return nil
+}
+
+// vacuumCandidates lists the files of the table directory that are not referenced by the table state and were last
+// modified before the cutoff. Tombstones removed after the cutoff are kept, as are the deletion vector files of the
+// referenced files, whether their path is relative or absolute.
+func (table *DeltaTable) vacuumCandidates(cutoff time.Time) ([]storage.Path, error) {
+	referenced := make(map[string]bool, len(table.State.Files))
+	rootURI := table.dataStore().RootURI()
+	referencePath := func(path string) {
+		// Absolute paths may also point to files of the table directory
+		if relative, ok := relativePath(rootURI, path); ok {
+			path = relative
+		}
+		referenced[path] = true
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			referenced[unescaped] = true
+		}
+	}
+	reference := func(path string, deletionVector *DeletionVectorDescriptor, err error) error {
+		referencePath(path)
+		dvPath, ok, err := deletionVectorPath(deletionVector, err)
+		if ok {
+			referencePath(dvPath)
+		}
+		return err
+	}
+	for path, add := range table.State.Files {
+		deletionVector, err := add.DeletionVector()
+		if err := reference(path, deletionVector, err); err != nil {
+			return nil, err
+		}
+	}
+	for path, remove := range table.State.Tombstones {
+		if time.UnixMilli(int64(remove.DeletionTimestamp)).After(cutoff) {
+			deletionVector, err := remove.DeletionVector()
+			if err := reference(path, deletionVector, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	objects, err := table.dataStore().List(storage.NewPath(""))
+	if err != nil {
+		return nil, err
+	}
+	var candidates []storage.Path
+	for _, object := range objects {
+		path := object.Location.Raw
+		if strings.HasSuffix(path, "/") || isHiddenPath(path) || referenced[path] {
+			continue
+		}
+		if object.LastModified.Before(cutoff) {
+			candidates = append(candidates, object.Location)
+		}
+	}
+	return candidates, nil
+}
+
+// isHiddenPath returns true if a segment of the path starts with _ or ., as do the _delta_log and _change_data
+// directories. Partition directories are never hidden, since they contain an =.
+func isHiddenPath(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if (strings.HasPrefix(segment, "_") || strings.HasPrefix(segment, ".")) && !strings.Contains(segment, "=") {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteFiles deletes the files with at most parallelism concurrent workers, in batches when the store implements
+// storage.BulkDeleter. Returns the deleted files and the joined errors of the files that could not be deleted.
+// No more batches are started once the context is done, in which case the error of the context is also returned.
+func deleteFiles(ctx context.Context, store storage.ObjectStore, paths []storage.Path, parallelism int, progress func(processed int, total int)) ([]storage.Path, error) {
+	if parallelism <= 0 {
+		parallelism = DEFAULT_VACUUM_PARALLELISM
+	}
+	bulkDeleter, isBulkDeleter := store.(storage.BulkDeleter)
+	batchSize := 1
+	if isBulkDeleter {
+		batchSize = VACUUM_DELETE_BATCH_SIZE
+	}
+
+	batches := make(chan []storage.Path)
+	var mu sync.Mutex
+	var deleted []storage.Path
+	var errs []error
+	processed := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				var batchErrs []error
+				if isBulkDeleter {
+					batchErrs = bulkDeleter.DeleteBulk(batch)
+				} else {
+					batchErrs = []error{store.Delete(&batch[0])}
+				}
+
+				mu.Lock()
+				for j := range batch {
+					if batchErrs[j] != nil {
+						errs = append(errs, batchErrs[j])
+					} else {
+						deleted = append(deleted, batch[j])
+					}
+				}
+				processed += len(batch)
+				if progress != nil {
+					progress(processed, len(paths))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for start := 0; start < len(paths) && ctx.Err() == nil; start += batchSize {
+		end := start + batchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+		select {
+		case batches <- paths[start:end]:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append([]error{err}, errs...)
+	}
+	return deleted, errors.Join(errs...)
+}
@@ -0,0 +1,262 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+// failingDeleteStore fails to delete the given paths
+type failingDeleteStore struct {
+	storage.ObjectStore
+	failures map[string]bool
+}
+
+func (s *failingDeleteStore) Delete(location *storage.Path) error {
+	if s.failures[location.Raw] {
+		return errors.Join(storage.ErrorDeleteObject, errors.New(location.Raw))
+	}
+	return s.ObjectStore.Delete(location)
+}
+
+// Helper function to set up a table with one active file, one recent tombstone and unreferenced files
+func setupVacuumTable(t *testing.T) (*DeltaTable, string) {
+	t.Helper()
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}, {Name: "date", Type: String}}}
+	active := Add{Path: "date=2023-01-01/active.parquet", PartitionValues: map[string]string{"date": "2023-01-01"}}
+	table, tmpDir := setupTestTable(t, schema, []string{"date"}, nil, []Add{active}, func(transaction *DeltaTransaction) {
+		transaction.AddAction(Remove{Path: "date=2023-01-01/removed.parquet", DeletionTimestamp: DeltaDataTypeTimestamp(time.Now().UnixMilli())})
+	})
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	for _, name := range []string{"date=2023-01-01/active.parquet", "date=2023-01-01/removed.parquet", "date=2023-01-01/orphan.parquet", "orphan-0.parquet", "orphan-1.parquet", "_staging/file.parquet", ".hidden.parquet"} {
+		err := table.Store.Put(storage.NewPath(name), []byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(filepath.Join(tmpDir, name), old, old)
+	}
+	table.Store.Put(storage.NewPath("recent.parquet"), []byte("data"))
+	return table, tmpDir
+}
+
+func TestVacuum(t *testing.T) {
+	table, tmpDir := setupVacuumTable(t)
+
+	_, err := table.Vacuum(&VacuumOptions{Retention: time.Hour})
+	if !errors.Is(err, ErrorVacuumRetentionTooShort) {
+		t.Errorf("want ErrorVacuumRetentionTooShort, has %v", err)
+	}
+
+	expected := []string{"date=2023-01-01/orphan.parquet", "orphan-0.parquet", "orphan-1.parquet"}
+	dryRun, err := table.Vacuum(&VacuumOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dryRun) != len(expected) || !fileExists(filepath.Join(tmpDir, expected[0])) {
+		t.Errorf("dry run should list %v without deleting, has %v", expected, dryRun)
+	}
+
+	var mu sync.Mutex
+	var calls []int
+	options := NewVacuumOptions()
+	options.Parallelism = 2
+	options.Progress = func(processed int, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if total != len(expected) {
+			t.Errorf("want total %d, has %d", len(expected), total)
+		}
+		calls = append(calls, processed)
+	}
+	deleted, err := table.Vacuum(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, path := range deleted {
+		names = append(names, path.Raw)
+	}
+	sort.Strings(names)
+	if len(names) != len(expected) || names[0] != expected[0] || names[2] != expected[2] {
+		t.Errorf("want %v deleted, has %v", expected, names)
+	}
+	if len(calls) != len(expected) || calls[len(calls)-1] != len(expected) {
+		t.Errorf("unexpected progress %v", calls)
+	}
+	for _, name := range expected {
+		if fileExists(filepath.Join(tmpDir, name)) {
+			t.Errorf("%s should be deleted", name)
+		}
+	}
+	for _, name := range []string{"date=2023-01-01/active.parquet", "date=2023-01-01/removed.parquet", "_staging/file.parquet", ".hidden.parquet", "recent.parquet"} {
+		if !fileExists(filepath.Join(tmpDir, name)) {
+			t.Errorf("%s should be kept", name)
+		}
+	}
+}
+
+func TestVacuumDeleteErrors(t *testing.T) {
+	table, tmpDir := setupVacuumTable(t)
+	table.Store = &failingDeleteStore{ObjectStore: table.Store, failures: map[string]bool{"orphan-0.parquet": true, "orphan-1.parquet": true}}
+
+	deleted, err := table.Vacuum(nil)
+	if !errors.Is(err, storage.ErrorDeleteObject) {
+		t.Errorf("want ErrorDeleteObject, has %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].Raw != "date=2023-01-01/orphan.parquet" {
+		t.Errorf("unexpected deleted files %v", deleted)
+	}
+	if !fileExists(filepath.Join(tmpDir, "orphan-0.parquet")) {
+		t.Error("orphan-0.parquet should not be deleted")
+	}
+}
+
+func TestVacuumWithContextCancelled(t *testing.T) {
+	table, tmpDir := setupVacuumTable(t)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	deleted, err := table.VacuumWithContext(cancelled, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, has %v", err)
+	}
+	if len(deleted) != 0 || !fileExists(filepath.Join(tmpDir, "orphan-0.parquet")) {
+		t.Errorf("no file should be deleted, has %v", deleted)
+	}
+
+	// Cancelling while deleting stops before all the candidates are deleted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	options := NewVacuumOptions()
+	options.Parallelism = 1
+	options.Progress = func(processed int, total int) {
+		cancel()
+	}
+	deleted, err = table.VacuumWithContext(ctx, options)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, has %v", err)
+	}
+	if len(deleted) == 0 || len(deleted) >= 3 {
+		t.Errorf("want the vacuum stopped after the first deletes, has %v", deleted)
+	}
+}
+
+func TestVacuumKeepsNewFilesAndDeletionVectors(t *testing.T) {
+	table, tmpDir := setupVacuumTable(t)
+	// Another writer adds an old file with a deletion vector and removes a file with a deletion vector after the
+	// vacuuming table has been loaded
+	writer, err := OpenTable(table.Store, table.LockClient, table.StateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction := writer.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Add{Path: "orphan-0.parquet", DataChange: true, Extras: map[string]json.RawMessage{
+		"deletionVector": json.RawMessage(`{"storageType":"u","pathOrInlineDv":"ab^-aqEH.-t@S}K{vb[*k^","offset":1,"sizeInBytes":36,"cardinality":2}`),
+	}})
+	transaction.AddAction(Remove{Path: "date=2023-01-01/removed.parquet", DeletionTimestamp: DeltaDataTypeTimestamp(time.Now().UnixMilli()), Extras: map[string]json.RawMessage{
+		"deletionVector": json.RawMessage(`{"storageType":"u","pathOrInlineDv":"00000000000000000001","offset":1,"sizeInBytes":36,"cardinality":2}`),
+	}})
+	_, err = transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	for _, name := range []string{"ab/deletion_vector_d2c639aa-8816-431a-aaf6-d3fe2512ff61.bin", "deletion_vector_00000000-0000-0000-0000-000000000001.bin", "deletion_vector_00000000-0000-0000-0000-000000000002.bin"} {
+		table.Store.Put(storage.NewPath(name), []byte("dv"))
+		os.Chtimes(filepath.Join(tmpDir, name), old, old)
+	}
+
+	candidates, err := table.Vacuum(&VacuumOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, path := range candidates {
+		names = append(names, path.Raw)
+	}
+	sort.Strings(names)
+	expected := []string{"date=2023-01-01/orphan.parquet", "deletion_vector_00000000-0000-0000-0000-000000000002.bin", "orphan-1.parquet"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("want %v, has %v", expected, names)
+	}
+	if table.State.Version != 2 {
+		t.Errorf("the table should be updated to version 2, has %d", table.State.Version)
+	}
+
+	_, err = NewDeltaTable(table.Store, nil, nil).Vacuum(nil)
+	if !errors.Is(err, ErrorNotATable) {
+		t.Errorf("want ErrorNotATable, has %v", err)
+	}
+}
+
+func TestVacuumProtocolCheck(t *testing.T) {
+	table, tmpDir := setupVacuumTable(t)
+	// A file of the table referenced with its absolute path, whose deletion vector is also referenced absolutely
+	rootURI := table.dataStore().RootURI()
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Protocol{MinReaderVersion: 3, MinWriterVersion: 7,
+		ReaderFeatures: []string{DELETION_VECTORS_FEATURE, VACUUM_PROTOCOL_CHECK_FEATURE},
+		WriterFeatures: []string{DELETION_VECTORS_FEATURE, VACUUM_PROTOCOL_CHECK_FEATURE}})
+	transaction.AddAction(Add{Path: rootURI + "/orphan-0.parquet", DataChange: true, Extras: map[string]json.RawMessage{
+		"deletionVector": json.RawMessage(`{"storageType":"p","pathOrInlineDv":"` + rootURI + `/dv.bin","offset":1,"sizeInBytes":36,"cardinality":2}`),
+	}})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	table.Store.Put(storage.NewPath("dv.bin"), []byte("dv"))
+	os.Chtimes(filepath.Join(tmpDir, "dv.bin"), old, old)
+
+	candidates, err := table.Vacuum(&VacuumOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, path := range candidates {
+		names = append(names, path.Raw)
+	}
+	sort.Strings(names)
+	expected := []string{"date=2023-01-01/orphan.parquet", "orphan-1.parquet"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("want %v, has %v", expected, names)
+	}
+
+	// Vacuum refuses to delete files of a table with a feature it does not support
+	transaction = table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddAction(Protocol{MinReaderVersion: 3, MinWriterVersion: 7,
+		ReaderFeatures: []string{DELETION_VECTORS_FEATURE, VACUUM_PROTOCOL_CHECK_FEATURE},
+		WriterFeatures: []string{DELETION_VECTORS_FEATURE, VACUUM_PROTOCOL_CHECK_FEATURE, "icebergCompatV1"}})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Vacuum(nil); !errors.Is(err, ErrorUnsupportedProtocol) || !errors.Is(err, ErrorVacuum) {
+		t.Errorf("want ErrorUnsupportedProtocol, has %v", err)
+	}
+	for _, name := range expected {
+		if !fileExists(filepath.Join(tmpDir, name)) {
+			t.Errorf("%s should be kept", name)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/rivian/delta-go/state"
+	"github.com/rivian/delta-go/storage"
+)
+
+var (
+	ErrorVerify             error = errors.New("error verifying the table")
+	ErrorCheckpointMismatch error = errors.New("the checkpoint differs from the log")
+)
+
+// VerifyProblemKind classifies the problems found by Verify
+type VerifyProblemKind string
+
+const (
+	// An add action of the table references a data file that does not exist
+	VerifyMissingDataFile VerifyProblemKind = "missingDataFile"
+	// The size of a data file differs from the size of its add action; only checked by thorough verification
+	VerifySizeMismatch VerifyProblemKind = "sizeMismatch"
+	// A commit or checkpoint both adds and removes the same file
+	VerifyRemovedLiveFile VerifyProblemKind = "removedLiveFile"
+	// An active file of the table state also has a tombstone, so readers may return its deleted rows
+	VerifyTombstonedLiveFile VerifyProblemKind = "tombstonedLiveFile"
+	// The schema of the table metadata does not parse, or does not contain the partition columns
+	VerifyInvalidSchema VerifyProblemKind = "invalidSchema"
+	// The latest checkpoint cannot be read
+	VerifyUnreadableCheckpoint VerifyProblemKind = "unreadableCheckpoint"
+	// The latest checkpoint differs from the table state replayed from the commits it covers
+	VerifyCheckpointMismatch VerifyProblemKind = "checkpointMismatch"
+)
+
+// VerifyOptions configures Verify
+type VerifyOptions struct {
+	// Head every data file, also checking its size, instead of matching the files against a single listing of the
+	// data store. Uses storage.BulkHeader when the data store implements it.
+	Thorough bool
+}
+
+// NewVerifyOptions returns the default verify options, which run the fast verification
+func NewVerifyOptions() *VerifyOptions {
+	return &VerifyOptions{}
+}
+
+// VerifyProblem is an inconsistency found by Verify
+type VerifyProblem struct {
+	Kind VerifyProblemKind
+	// The version of the commit or checkpoint with the problem, or the table version for data file problems
+	Version state.DeltaDataTypeVersion
+	// The data file concerned, if any
+	Path    string
+	Message string
+}
+
+func (problem VerifyProblem) String() string {
+	if problem.Path != "" {
+		return fmt.Sprintf("%s at version %d: %s: %s", problem.Kind, problem.Version, problem.Path, problem.Message)
+	}
+	return fmt.Sprintf("%s at version %d: %s", problem.Kind, problem.Version, problem.Message)
+}
+
+// VerifyReport lists the problems found by Verify
+type VerifyReport struct {
+	// The table version that was verified
+	Version state.DeltaDataTypeVersion
+	// The number of commits whose actions were checked
+	CommitsChecked int
+	// The number of data files whose existence was checked
+	FilesChecked int
+	// The version of the checkpoint compared with the replayed log, or -1 if there is none or the commits it
+	// covers have been cleaned up
+	CheckpointVerified state.DeltaDataTypeVersion
+	Problems           []VerifyProblem
+}
+
+// Ok returns true if no problem was found
+func (report *VerifyReport) Ok() bool {
+	return len(report.Problems) == 0
+}
+
+func (report *VerifyReport) addProblem(kind VerifyProblemKind, version state.DeltaDataTypeVersion, path string, message string) {
+	report.Problems = append(report.Problems, VerifyProblem{Kind: kind, Version: version, Path: path, Message: message})
+}
+
+// Verify exhaustively checks the integrity of the table at its loaded version: the schema parses, no commit adds
+// and removes the same file, no active file is tombstoned, the latest checkpoint matches the table state replayed from the commits, and every
+// add action references an existing data file. Unlike the validation done while reading, every problem found is
+// collected in the report. Data files with absolute paths outside the table, such as those of shallow clones,
+// are not checked.
+// An error is returned only if the log or the data store cannot be read.
+func (table *DeltaTable) Verify(options *VerifyOptions) (VerifyReport, error) {
+	if options == nil {
+		options = NewVerifyOptions()
+	}
+	report := VerifyReport{Version: table.State.Version, CheckpointVerified: -1}
+
+	metadata := table.State.CurrentMetadata.ToMetaData()
+	if err := metadata.Validate(); err != nil {
+		report.addProblem(VerifyInvalidSchema, table.State.Version, "", err.Error())
+	}
+
+	if err := table.verifyCommits(&report); err != nil {
+		return report, errors.Join(ErrorVerify, err)
+	}
+	for _, path := range table.TombstonedActiveFiles() {
+		report.addProblem(VerifyTombstonedLiveFile, table.State.Version, path, "the active file is also tombstoned")
+	}
+	if err := table.verifyCheckpoint(&report); err != nil {
+		return report, errors.Join(ErrorVerify, err)
+	}
+	if err := table.verifyDataFiles(&report, options.Thorough); err != nil {
+		return report, errors.Join(ErrorVerify, err)
+	}
+	return report, nil
+}
+
+// verifyCommits reports the commits up to the table version that both add and remove the same file
+func (table *DeltaTable) verifyCommits(report *VerifyReport) error {
+	commits, _, err := table.listLogFiles()
+	if err != nil {
+		return err
+	}
+	versions := make([]state.DeltaDataTypeVersion, 0, len(commits))
+	for version := range commits {
+		if version <= table.State.Version {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for _, version := range versions {
+		actions, err := table.readLogEntry(table.CommitUriFromVersion(version))
+		if err != nil {
+			return err
+		}
+		for _, path := range addedAndRemoved(actions) {
+			report.addProblem(VerifyRemovedLiveFile, version, path, "the commit both adds and removes the file")
+		}
+		report.CommitsChecked++
+	}
+	return nil
+}
+
+// addedAndRemoved returns the sorted paths of the files that are both added and removed by the actions. A file added
+// back with a new deletion vector, identified by its path and the unique id of its deletion vector, is not reported.
+func addedAndRemoved(actions []Action) []string {
+	added := make(map[string]map[string]bool)
+	removed := make(map[string]map[string]bool)
+	mark := func(files map[string]map[string]bool, path string, deletionVector *DeletionVectorDescriptor) {
+		if files[path] == nil {
+			files[path] = make(map[string]bool)
+		}
+		files[path][deletionVector.uniqueID()] = true
+	}
+	for _, action := range actions {
+		switch action := action.(type) {
+		case Add:
+			deletionVector, _ := action.DeletionVector()
+			mark(added, action.Path, deletionVector)
+		case Remove:
+			deletionVector, _ := action.DeletionVector()
+			mark(removed, action.Path, deletionVector)
+		}
+	}
+	var paths []string
+	for path, ids := range added {
+		for id := range ids {
+			if removed[path][id] {
+				paths = append(paths, path)
+				break
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// TombstonedActiveFiles returns the sorted paths of the active files of the table state whose data file also has a
+// tombstone, a correctness violation making readers return deleted rows. Paths are compared unescaped, since the
+// same data file may be added and removed with differently encoded paths.
+func (table *DeltaTable) TombstonedActiveFiles() []string {
+	tombstoned := make(map[string]bool, len(table.State.Tombstones))
+	for path := range table.State.Tombstones {
+		tombstoned[unescapedDataPath(path)] = true
+	}
+	var paths []string
+	for path := range table.State.Files {
+		if tombstoned[unescapedDataPath(path)] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// verifyCheckpoint compares the latest checkpoint up to the table version with the table state replayed from the
+// commits it covers, if they are all still in the log
+func (table *DeltaTable) verifyCheckpoint(report *VerifyReport) error {
+	logFiles, err := table.Store.List(table.BaseCommitUri())
+	if err != nil {
+		return err
+	}
+	version, ok, err := table.latestCheckpointVersion(logFiles)
+	if err != nil {
+		return err
+	}
+	if !ok || version > table.State.Version {
+		return nil
+	}
+
+	actions, _, err := table.readCheckpoint(version)
+	if err != nil {
+		report.addProblem(VerifyUnreadableCheckpoint, version, "", err.Error())
+		return nil
+	}
+	for _, path := range addedAndRemoved(actions) {
+		report.addProblem(VerifyRemovedLiveFile, version, path, "the checkpoint both adds and removes the file")
+	}
+	checkpointState := NewDeltaTableState(version)
+	if err := checkpointState.applyActions(actions); err != nil {
+		report.addProblem(VerifyUnreadableCheckpoint, version, "", err.Error())
+		return nil
+	}
+
+	replayedState, ok, err := table.replayCheckpointVersion(version)
+	if err != nil || !ok {
+		return err
+	}
+	report.Problems = append(report.Problems, checkpointMismatches(version, checkpointState, replayedState)...)
+	report.CheckpointVerified = version
+	return nil
+}
+
+// replayCheckpointVersion replays the table state at the checkpoint version from the commits it covers, returning false
+// if they have been cleaned up
+func (table *DeltaTable) replayCheckpointVersion(version state.DeltaDataTypeVersion) (*DeltaTableState, bool, error) {
+	commits, compactions, err := table.listLogFiles()
+	if err != nil {
+		return nil, false, err
+	}
+	for v := state.DeltaDataTypeVersion(0); v <= version; v++ {
+		if _, ok := commits[v]; !ok {
+			return nil, false, nil
+		}
+	}
+	replayedState := NewDeltaTableState(-1)
+	if err := table.replayLog(replayedState, 0, version, compactions); err != nil {
+		return nil, false, err
+	}
+	return replayedState, true, nil
+}
+
+// checkpointMismatches lists the differences between the table state read from a checkpoint and the one replayed from
+// the commits it covers: the active files, the protocol, and the table id and schema
+func checkpointMismatches(version state.DeltaDataTypeVersion, checkpointState *DeltaTableState, replayedState *DeltaTableState) []VerifyProblem {
+	var problems []VerifyProblem
+	mismatch := func(path string, message string) {
+		problems = append(problems, VerifyProblem{Kind: VerifyCheckpointMismatch, Version: version, Path: path, Message: message})
+	}
+	for path := range replayedState.Files {
+		if _, ok := checkpointState.Files[path]; !ok {
+			mismatch(path, "the file is missing from the checkpoint")
+		}
+	}
+	for path := range checkpointState.Files {
+		if _, ok := replayedState.Files[path]; !ok {
+			mismatch(path, "the file is not in the table at the checkpoint version")
+		}
+	}
+	if checkpointState.MinReaderVersion != replayedState.MinReaderVersion || checkpointState.MinWriterVersion != replayedState.MinWriterVersion {
+		mismatch("", fmt.Sprintf("the checkpoint has protocol %d/%d, the log has %d/%d",
+			checkpointState.MinReaderVersion, checkpointState.MinWriterVersion, replayedState.MinReaderVersion, replayedState.MinWriterVersion))
+	}
+	checkpointMetadata := checkpointState.CurrentMetadata.ToMetaData()
+	replayedMetadata := replayedState.CurrentMetadata.ToMetaData()
+	if checkpointMetadata.Id != replayedMetadata.Id || checkpointMetadata.SchemaString != replayedMetadata.SchemaString {
+		mismatch("", "the checkpoint metadata differs from the log")
+	}
+	return problems
+}
+
+// VerifyCheckpoint reads the checkpoint of the given version back and compares it with the table state replayed from
+// the commits it covers, returning ErrorCheckpointMismatch if they differ. A checkpoint writer can call it on the
+// checkpoint it just wrote, or set LastCheckpointOptions.Verify, before pointing _last_checkpoint to it.
+// The commits up to the checkpoint version must still be in the log.
+func (table *DeltaTable) VerifyCheckpoint(version state.DeltaDataTypeVersion) error {
+	actions, _, err := table.readCheckpoint(version)
+	if err != nil {
+		return err
+	}
+	checkpointState := NewDeltaTableState(version)
+	if err := checkpointState.applyActions(actions); err != nil {
+		return err
+	}
+	replayedState, ok, err := table.replayCheckpointVersion(version)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Join(ErrorInvalidVersion, fmt.Errorf("the commits up to version %d have been cleaned up", version))
+	}
+
+	problems := checkpointMismatches(version, checkpointState, replayedState)
+	for _, path := range addedAndRemoved(actions) {
+		problems = append(problems, VerifyProblem{Kind: VerifyRemovedLiveFile, Version: version, Path: path, Message: "the checkpoint both adds and removes the file"})
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	errs := []error{ErrorCheckpointMismatch}
+	for _, problem := range problems {
+		errs = append(errs, errors.New(problem.String()))
+	}
+	return errors.Join(errs...)
+}
+
+// verifyDataFiles reports the add actions of the table state whose data file does not exist, either by matching
+// them against a listing of the data store or, when thorough, by heading every file
+func (table *DeltaTable) verifyDataFiles(report *VerifyReport, thorough bool) error {
+	paths := make([]string, 0, len(table.State.Files))
+	for path := range table.State.Files {
+		if !strings.Contains(path, "://") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	report.FilesChecked = len(paths)
+
+	if !thorough {
+		objects, err := table.dataStore().List(storage.NewPath(""))
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool, len(objects))
+		for _, object := range objects {
+			existing[object.Location.Raw] = true
+		}
+		for _, path := range paths {
+			if !existing[path] && !existing[unescapedDataPath(path)] {
+				report.addProblem(VerifyMissingDataFile, table.State.Version, path, "the data file does not exist")
+			}
+		}
+		return nil
+	}
+
+	locations := make([]*storage.Path, len(paths))
+	for i, path := range paths {
+		locations[i] = storage.NewPath(unescapedDataPath(path))
+	}
+	var metas []storage.ObjectMeta
+	var errs []error
+	if bulkHeader, ok := table.dataStore().(storage.BulkHeader); ok {
+		metas, errs = bulkHeader.HeadBulk(locations)
+	} else {
+		metas = make([]storage.ObjectMeta, len(locations))
+		errs = make([]error, len(locations))
+		for i, location := range locations {
+			metas[i], errs[i] = table.dataStore().Head(location)
+		}
+	}
+	for i, path := range paths {
+		if errors.Is(errs[i], storage.ErrorObjectDoesNotExist) {
+			report.addProblem(VerifyMissingDataFile, table.State.Version, path, "the data file does not exist")
+		} else if errs[i] != nil {
+			return errs[i]
+		} else if size := table.State.Files[path].Size; int64(size) != metas[i].Size {
+			report.addProblem(VerifySizeMismatch, table.State.Version, path, fmt.Sprintf("the add action has size %d, the data file has size %d", size, metas[i].Size))
+		}
+	}
+	return nil
+}
+
+// unescapedDataPath returns the path of the data file of an add action in the data store, since add paths are
+// URL encoded
+func unescapedDataPath(path string) string {
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		return unescaped
+	}
+	return path
+}
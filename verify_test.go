@@ -0,0 +1,199 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rivian/delta-go/storage"
+)
+
+// Helper function to set up a table with 2 commits and a checkpoint of version 1
+func setupVerifyTable(t *testing.T) *DeltaTable {
+	t.Helper()
+	schema := SchemaTypeStruct{Fields: []SchemaField{{Name: "id", Type: Long}}}
+	table, _ := setupTestTable(t, schema, nil, nil, []Add{{Path: "a.parquet", Size: 4}, {Path: "b.parquet", Size: 4}}, func(transaction *DeltaTransaction) {
+		transaction.AddAction(Add{Path: "c.parquet", Size: 4})
+	})
+	for _, name := range []string{"a.parquet", "b.parquet", "c.parquet"} {
+		if err := table.Store.Put(storage.NewPath(name), []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return table
+}
+
+// Helper function to write the checkpoint of the given table state, leaving out the given files
+func writeVerifyCheckpoint(t *testing.T, table *DeltaTable, tableState *DeltaTableState, skip map[string]bool) {
+	t.Helper()
+	var rows []checkpointRow
+	for _, action := range tableState.actions() {
+		if add, ok := action.(Add); ok && skip[add.Path] {
+			continue
+		}
+		if row, ok := newCheckpointRow(action); ok {
+			rows = append(rows, row)
+		}
+	}
+	writeTestCheckpoint(t, table, table.CheckpointUriFromVersion(tableState.Version), rows)
+	table.Store.Put(storage.NewPath("_delta_log/"+LAST_CHECKPOINT_FILE), []byte(`{"version":1,"size":1}`))
+}
+
+func TestVerify(t *testing.T) {
+	table := setupVerifyTable(t)
+	writeVerifyCheckpoint(t, table, &table.State, nil)
+
+	report, err := table.Verify(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Ok() {
+		t.Errorf("want no problems, has %v", report.Problems)
+	}
+	if report.CommitsChecked != 2 || report.FilesChecked != 3 || report.CheckpointVerified != 1 {
+		t.Errorf("want 2 commits, 3 files and checkpoint 1 checked, has %+v", report)
+	}
+	report, err = table.Verify(&VerifyOptions{Thorough: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Ok() {
+		t.Errorf("want no problems, has %v", report.Problems)
+	}
+}
+
+func TestVerifyProblems(t *testing.T) {
+	table := setupVerifyTable(t)
+	writeVerifyCheckpoint(t, table, &table.State, map[string]bool{"c.parquet": true})
+
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddActions([]Action{Add{Path: "d.parquet", Size: 4}, Remove{Path: "d.parquet"}, Add{Path: "e.parquet", Size: 100}})
+	_, err := transaction.Commit(Write{Mode: Append}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Store.Put(storage.NewPath("e.parquet"), []byte("data"))
+	table.Store.Delete(storage.NewPath("b.parquet"))
+
+	hasProblem := func(report VerifyReport, kind VerifyProblemKind, path string) bool {
+		for _, problem := range report.Problems {
+			if problem.Kind == kind && problem.Path == path {
+				return true
+			}
+		}
+		return false
+	}
+
+	report, err := table.Verify(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 3 {
+		t.Errorf("want 3 problems, has %v", report.Problems)
+	}
+	if !hasProblem(report, VerifyMissingDataFile, "b.parquet") {
+		t.Error("b.parquet should be missing")
+	}
+	if !hasProblem(report, VerifyRemovedLiveFile, "d.parquet") {
+		t.Error("d.parquet should be added and removed")
+	}
+	if !hasProblem(report, VerifyCheckpointMismatch, "c.parquet") {
+		t.Error("c.parquet should be missing from the checkpoint")
+	}
+
+	// The thorough verification also checks the size of the data files
+	report, err = table.Verify(&VerifyOptions{Thorough: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 4 || !hasProblem(report, VerifySizeMismatch, "e.parquet") {
+		t.Errorf("want the size of e.parquet to mismatch, has %v", report.Problems)
+	}
+}
+
+func TestTombstonedActiveFiles(t *testing.T) {
+	table := setupVerifyTable(t)
+	if paths := table.TombstonedActiveFiles(); len(paths) != 0 {
+		t.Errorf("want no tombstoned active files, has %v", paths)
+	}
+
+	// The same data file is added and removed with differently encoded paths
+	transaction := table.CreateTransaction(NewDeltaTransactionOptions())
+	transaction.AddActions([]Action{Remove{Path: "f%20g.parquet", DataChange: true}, Add{Path: "f g.parquet", Size: 4, DataChange: true}})
+	if _, err := transaction.Commit(Write{Mode: Append}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	table.Store.Put(storage.NewPath("f g.parquet"), []byte("data"))
+	if paths := table.TombstonedActiveFiles(); !reflect.DeepEqual(paths, []string{"f g.parquet"}) {
+		t.Errorf("want f g.parquet to be tombstoned, has %v", paths)
+	}
+
+	report, err := table.Verify(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].Kind != VerifyTombstonedLiveFile || report.Problems[0].Path != "f g.parquet" {
+		t.Errorf("want f g.parquet to be reported, has %v", report.Problems)
+	}
+}
+
+func TestVerifyCheckpoint(t *testing.T) {
+	table := setupVerifyTable(t)
+	writeVerifyCheckpoint(t, table, &table.State, nil)
+	if err := table.VerifyCheckpoint(table.State.Version); err != nil {
+		t.Errorf("want the checkpoint to match the log, has %v", err)
+	}
+
+	writeVerifyCheckpoint(t, table, &table.State, map[string]bool{"c.parquet": true})
+	err := table.VerifyCheckpoint(table.State.Version)
+	if !errors.Is(err, ErrorCheckpointMismatch) || !strings.Contains(err.Error(), "c.parquet") {
+		t.Errorf("want ErrorCheckpointMismatch for c.parquet, has %v", err)
+	}
+
+	if err := table.VerifyCheckpoint(table.State.Version + 1); err == nil {
+		t.Error("a missing checkpoint should fail")
+	}
+
+	// _last_checkpoint is not pointed to a checkpoint that does not match the log
+	path := storage.NewPath("_delta_log/" + LAST_CHECKPOINT_FILE)
+	if err := table.Store.Delete(path); err != nil {
+		t.Fatal(err)
+	}
+	options := NewLastCheckpointOptions()
+	options.Verify = true
+	err = table.WriteLastCheckpointWithOptions(CheckPoint{Version: table.State.Version, Size: 2}, options)
+	if !errors.Is(err, ErrorCheckpointMismatch) {
+		t.Errorf("want ErrorCheckpointMismatch, has %v", err)
+	}
+	if _, ok, err := table.readLastCheckpoint(); err != nil || ok {
+		t.Errorf("want no _last_checkpoint, has %v %v", ok, err)
+	}
+
+	writeVerifyCheckpoint(t, table, &table.State, nil)
+	if err := table.WriteLastCheckpointWithOptions(CheckPoint{Version: table.State.Version, Size: 3}, options); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint, _, err := table.readLastCheckpoint(); err != nil || checkpoint.Size != 3 {
+		t.Errorf("want the pointer to the verified checkpoint, has %+v %v", checkpoint, err)
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rivian/delta-go/state"
+	"github.com/segmentio/parquet-go"
+	"github.com/segmentio/parquet-go/compress"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrorInvalidRow            error = errors.New("invalid row")
+	ErrorUnsupportedColumnType error = errors.New("column type not supported by WriteBatch")
+	ErrorInvalidCompression    error = errors.New("invalid compression codec")
+)
+
+const (
+	// The default number of rows of the data files written by WriteBatch
+	DEFAULT_WRITE_MAX_ROWS_PER_FILE = 1000000
+	// Layout of the timestamps of the statistics, which have a millisecond precision
+	statsTimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Row is a row written by WriteBatch: the value of each column by column name, nil or absent for null.
+// Values have the Go type of the column: string, int64 for long, int32 for integer, int16 for short, int8 for
+// byte, float32 for float, float64 for double, bool, []byte for binary and time.Time for date and timestamp.
+// Integer columns also accept an int.
+type Row map[string]any
+
+// CompressionCodec is the compression codec of the data files written by WriteBatch
+type CompressionCodec string
+
+const (
+	CompressionSnappy CompressionCodec = "snappy"
+	CompressionZstd   CompressionCodec = "zstd"
+	CompressionGzip   CompressionCodec = "gzip"
+	CompressionNone   CompressionCodec = "none"
+)
+
+// The parquet codec of each compression codec, and the extension it adds before .parquet to the data file names
+var compressionCodecs = map[CompressionCodec]struct {
+	codec     compress.Codec
+	extension string
+}{
+	CompressionSnappy: {&parquet.Snappy, ".snappy"},
+	CompressionZstd:   {&parquet.Zstd, ".zstd"},
+	CompressionGzip:   {&parquet.Gzip, ".gz"},
+	CompressionNone:   {&parquet.Uncompressed, ""},
+}
+
+// WriteOptions configures WriteBatch
+type WriteOptions struct {
+	// The maximum number of rows of a data file, a partition with more rows is written to several files
+	MaxRowsPerFile int
+	// The compression codec of the data files, CompressionSnappy when empty.
+	// The codec is recorded in the data files only, readers of the table do not need to know it.
+	CompressionCodec CompressionCodec
+	// The options of the transaction committing the data files
+	TransactionOptions *DeltaTransactionOptions
+	// Application metadata added to the commit info
+	AppMetadata map[string]any
+}
+
+// NewWriteOptions returns the default write options
+func NewWriteOptions() *WriteOptions {
+	return &WriteOptions{MaxRowsPerFile: DEFAULT_WRITE_MAX_ROWS_PER_FILE, TransactionOptions: NewDeltaTransactionOptions()}
+}
+
+// WriteBatch writes the rows to Parquet data files and commits them to the table in a single append.
+// Rows are routed to a file per partition, written under the DataDirectory of the transaction options, and the
+// statistics of each file (the number of records, min and max values and null counts) are collected.
+// The table state must be loaded. Columns of struct, array, map and decimal types are not supported.
+// Data files are buffered in memory until they are complete. If writing or committing fails, the data files
+// written are removed and the remaining rows are drained in the background so that the sender does not block.
+// Returns the committed version, or the current version if there were no rows to write.
+func (table *DeltaTable) WriteBatch(rows <-chan Row, options *WriteOptions) (state.DeltaDataTypeVersion, error) {
+	if options == nil {
+		options = NewWriteOptions()
+	}
+	transaction := table.CreateTransaction(options.TransactionOptions)
+	writer, err := newBatchWriter(transaction, options.MaxRowsPerFile, options.CompressionCodec)
+	if err == nil {
+		for row := range rows {
+			if err = writer.write(row); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = writer.flushAll()
+	}
+	if err != nil {
+		go func() {
+			for range rows {
+			}
+		}()
+		transaction.AbortWrite()
+		return table.State.Version, err
+	}
+	if writer.filesWritten == 0 {
+		return table.State.Version, nil
+	}
+
+	operation := Write{Mode: Append, PartitionBy: table.State.CurrentMetadata.PartitionColumns}
+	return transaction.Commit(operation, options.AppMetadata)
+}
+
+// batchWriter routes the rows of WriteBatch to a data file per partition
+type batchWriter struct {
+	transaction    *DeltaTransaction
+	maxRowsPerFile int
+	codec          compress.Codec
+	// extension of the data file names for the codec
+	extension        string
+	partitionColumns []SchemaField
+	dataColumns      []SchemaField
+	schema           *parquet.Schema
+	// index of the parquet column of each data column
+	columnIndexes []int
+	files         map[string]*batchFile
+	filesWritten  int
+}
+
+// batchFile is a data file being written by WriteBatch
+type batchFile struct {
+	partitionValues map[string]string
+	buffer          bytes.Buffer
+	writer          *parquet.Writer
+	numRecords      int64
+	minValues       map[string]any
+	maxValues       map[string]any
+	nullCount       map[string]int64
+}
+
+func newBatchWriter(transaction *DeltaTransaction, maxRowsPerFile int, codec CompressionCodec) (*batchWriter, error) {
+	if maxRowsPerFile <= 0 {
+		maxRowsPerFile = DEFAULT_WRITE_MAX_ROWS_PER_FILE
+	}
+	if codec == "" {
+		codec = CompressionSnappy
+	}
+	compression, ok := compressionCodecs[codec]
+	if !ok {
+		return nil, errors.Join(ErrorInvalidCompression, fmt.Errorf("%q", codec))
+	}
+	metadata := transaction.DeltaTable.State.CurrentMetadata
+	writer := &batchWriter{transaction: transaction, maxRowsPerFile: maxRowsPerFile, files: make(map[string]*batchFile)}
+	writer.codec = compression.codec
+	writer.extension = compression.extension
+
+	group := make(parquet.Group)
+	for _, field := range metadata.Schema.Fields {
+		if slices.Contains(metadata.PartitionColumns, field.Name) {
+			writer.partitionColumns = append(writer.partitionColumns, field)
+			continue
+		}
+		node, err := parquetNode(field)
+		if err != nil {
+			return nil, err
+		}
+		group[field.Name] = node
+		writer.dataColumns = append(writer.dataColumns, field)
+	}
+	writer.schema = parquet.NewSchema("delta", group)
+	for _, field := range writer.dataColumns {
+		leaf, _ := writer.schema.Lookup(field.Name)
+		writer.columnIndexes = append(writer.columnIndexes, leaf.ColumnIndex)
+	}
+	return writer, nil
+}
+
+// parquetNode returns the parquet node of a column
+func parquetNode(field SchemaField) (parquet.Node, error) {
+	var node parquet.Node
+	switch field.Type {
+	case String:
+		node = parquet.String()
+	case Long:
+		node = parquet.Int(64)
+	case Integer:
+		node = parquet.Int(32)
+	case Short:
+		node = parquet.Int(16)
+	case Byte:
+		node = parquet.Int(8)
+	case Float:
+		node = parquet.Leaf(parquet.FloatType)
+	case Double:
+		node = parquet.Leaf(parquet.DoubleType)
+	case Boolean:
+		node = parquet.Leaf(parquet.BooleanType)
+	case Binary:
+		node = parquet.Leaf(parquet.ByteArrayType)
+	case Date:
+		node = parquet.Date()
+	case Timestamp:
+		node = parquet.Timestamp(parquet.Microsecond)
+	default:
+		return nil, errors.Join(ErrorUnsupportedColumnType, fmt.Errorf("column %s has type %s", field.Name, field.Type))
+	}
+	if field.Nullable {
+		node = parquet.Optional(node)
+	}
+	return node, nil
+}
+
+// write adds the row to the data file of its partition, completing the file once it has MaxRowsPerFile rows
+func (writer *batchWriter) write(row Row) error {
+	for column := range row {
+		if !slices.ContainsFunc(writer.partitionColumns, func(field SchemaField) bool { return field.Name == column }) &&
+			!slices.ContainsFunc(writer.dataColumns, func(field SchemaField) bool { return field.Name == column }) {
+			return errors.Join(ErrorInvalidRow, fmt.Errorf("column %s is not in the schema", column))
+		}
+	}
+
+	partitionValues := make(map[string]string, len(writer.partitionColumns))
+	for _, field := range writer.partitionColumns {
+		value, err := columnValue(field, row[field.Name])
+		if err != nil {
+			return err
+		}
+		partitionValues[field.Name], err = formatPartitionValue(field.Type, value)
+		if err != nil {
+			return errors.Join(ErrorInvalidRow, err)
+		}
+	}
+	key := partitionKey(partitionValues)
+	file, ok := writer.files[key]
+	if !ok {
+		file = &batchFile{
+			partitionValues: partitionValues,
+			minValues:       make(map[string]any),
+			maxValues:       make(map[string]any),
+			nullCount:       make(map[string]int64),
+		}
+		file.writer = parquet.NewWriter(&file.buffer, writer.schema, parquet.Compression(writer.codec))
+		writer.files[key] = file
+	}
+
+	parquetRow := make(parquet.Row, len(writer.dataColumns))
+	for i, field := range writer.dataColumns {
+		value, err := columnValue(field, row[field.Name])
+		if err != nil {
+			return err
+		}
+		parquetRow[writer.columnIndexes[i]] = parquetValue(field, value, writer.columnIndexes[i])
+		file.updateStats(field, value)
+	}
+	if _, err := file.writer.WriteRows([]parquet.Row{parquetRow}); err != nil {
+		return err
+	}
+	file.numRecords++
+
+	if file.numRecords >= int64(writer.maxRowsPerFile) {
+		delete(writer.files, key)
+		return writer.flush(file)
+	}
+	return nil
+}
+
+// columnValue checks that the value of a column has the Go type of the column, converting ints
+func columnValue(field SchemaField, value any) (any, error) {
+	if value == nil {
+		if !field.Nullable {
+			return nil, errors.Join(ErrorInvalidRow, fmt.Errorf("column %s is not nullable", field.Name))
+		}
+		return nil, nil
+	}
+	if v, ok := value.(int); ok {
+		switch field.Type {
+		case Long:
+			value = int64(v)
+		case Integer:
+			value = int32(v)
+		case Short:
+			value = int16(v)
+		case Byte:
+			value = int8(v)
+		}
+	}
+
+	ok := false
+	switch field.Type {
+	case String:
+		_, ok = value.(string)
+	case Long:
+		_, ok = value.(int64)
+	case Integer:
+		_, ok = value.(int32)
+	case Short:
+		_, ok = value.(int16)
+	case Byte:
+		_, ok = value.(int8)
+	case Float:
+		_, ok = value.(float32)
+	case Double:
+		_, ok = value.(float64)
+	case Boolean:
+		_, ok = value.(bool)
+	case Binary:
+		_, ok = value.([]byte)
+	case Date, Timestamp:
+		_, ok = value.(time.Time)
+	}
+	if !ok {
+		return nil, errors.Join(ErrorInvalidRow, fmt.Errorf("column %s of type %s has a %T value", field.Name, field.Type, value))
+	}
+	return value, nil
+}
+
+// parquetValue converts the value of a column into a parquet value of the column at the given index
+func parquetValue(field SchemaField, value any, columnIndex int) parquet.Value {
+	definitionLevel := 0
+	if field.Nullable {
+		definitionLevel = 1
+	}
+	var parquetValue parquet.Value
+	switch value := value.(type) {
+	case nil:
+		return parquet.NullValue().Level(0, 0, columnIndex)
+	case string:
+		parquetValue = parquet.ByteArrayValue([]byte(value))
+	case []byte:
+		parquetValue = parquet.ByteArrayValue(value)
+	case int64:
+		parquetValue = parquet.Int64Value(value)
+	case int32:
+		parquetValue = parquet.Int32Value(value)
+	case int16:
+		parquetValue = parquet.Int32Value(int32(value))
+	case int8:
+		parquetValue = parquet.Int32Value(int32(value))
+	case float32:
+		parquetValue = parquet.FloatValue(value)
+	case float64:
+		parquetValue = parquet.DoubleValue(value)
+	case bool:
+		parquetValue = parquet.BooleanValue(value)
+	case time.Time:
+		if field.Type == Date {
+			parquetValue = parquet.Int32Value(int32(math.Floor(float64(value.Unix()) / (24 * 60 * 60))))
+		} else {
+			parquetValue = parquet.Int64Value(value.UnixMicro())
+		}
+	}
+	return parquetValue.Level(0, definitionLevel, columnIndex)
+}
+
+// updateStats adds the value of a column to the statistics of the file.
+// Like other Delta writers, min and max values are not collected for boolean and binary columns.
+func (file *batchFile) updateStats(field SchemaField, value any) {
+	if value == nil {
+		file.nullCount[field.Name]++
+		return
+	}
+	if _, ok := file.nullCount[field.Name]; !ok {
+		file.nullCount[field.Name] = 0
+	}
+	if field.Type == Boolean || field.Type == Binary {
+		return
+	}
+	if min, ok := file.minValues[field.Name]; !ok || compareTypedValues(value, min) < 0 {
+		file.minValues[field.Name] = value
+	}
+	if max, ok := file.maxValues[field.Name]; !ok || compareTypedValues(value, max) > 0 {
+		file.maxValues[field.Name] = value
+	}
+}
+
+// stats returns the statistics of the file
+func (file *batchFile) stats(dataColumns []SchemaField) Stats {
+	stats := Stats{
+		NumRecords:  file.numRecords,
+		TightBounds: true,
+		MinValues:   make(map[string]any, len(file.minValues)),
+		MaxValues:   make(map[string]any, len(file.maxValues)),
+		NullCount:   file.nullCount,
+	}
+	for _, field := range dataColumns {
+		if min, ok := file.minValues[field.Name]; ok {
+			stats.MinValues[field.Name] = statsValue(field, min, false)
+		}
+		if max, ok := file.maxValues[field.Name]; ok {
+			stats.MaxValues[field.Name] = statsValue(field, max, true)
+		}
+	}
+	return stats
+}
+
+// statsValue converts a min or max value into its JSON statistics form. Timestamps have a millisecond
+// precision in the statistics, so max values are rounded up to keep them an upper bound.
+func statsValue(field SchemaField, value any, isMax bool) any {
+	t, ok := value.(time.Time)
+	if !ok {
+		return value
+	}
+	t = t.UTC()
+	if field.Type == Date {
+		return t.Format(partitionDateLayout)
+	}
+	truncated := t.Truncate(time.Millisecond)
+	if isMax && !truncated.Equal(t) {
+		truncated = truncated.Add(time.Millisecond)
+	}
+	return truncated.Format(statsTimestampLayout)
+}
+
+// flush completes a data file and adds it to the transaction
+func (writer *batchWriter) flush(file *batchFile) error {
+	if err := file.writer.Close(); err != nil {
+		return err
+	}
+	fileName := fmt.Sprintf("part-%05d-%s-c000%s.parquet", writer.filesWritten, uuid.New().String(), writer.extension)
+	stats := file.stats(writer.dataColumns)
+	_, err := writer.transaction.AppendDataFile(fileName, file.partitionValues, file.buffer.Bytes(), string(stats.Json()))
+	if err != nil {
+		return err
+	}
+	writer.filesWritten++
+	return nil
+}
+
+// flushAll completes the data files of all partitions, in the order of their partition values
+func (writer *batchWriter) flushAll() error {
+	keys := make([]string, 0, len(writer.files))
+	for key := range writer.files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := writer.flush(writer.files[key]); err != nil {
+			return err
+		}
+		delete(writer.files, key)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 Rivian Automotive, Inc.
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rivian/delta-go/storage"
+	"github.com/segmentio/parquet-go"
+	"github.com/segmentio/parquet-go/format"
+)
+
+// Helper function to set up a table partitioned by date for WriteBatch
+func setupWriteBatchTable(t *testing.T) *DeltaTable {
+	t.Helper()
+	table, _, _ := setupTest(t)
+	schema := SchemaTypeStruct{Fields: []SchemaField{
+		{Name: "id", Type: Long},
+		{Name: "name", Type: String, Nullable: true},
+		{Name: "ts", Type: Timestamp},
+		{Name: "date", Type: Date},
+	}}
+	metadata := NewDeltaTableMetaData("", "", new(Format).Default(), schema, []string{"date"}, map[string]string{})
+	err := table.Create(*metadata, Protocol{MinReaderVersion: 1, MinWriterVersion: 2}, CommitInfo{}, []Add{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+// sendRows sends the rows on a channel that is closed once they have all been sent
+func sendRows(rows []Row) <-chan Row {
+	ch := make(chan Row)
+	go func() {
+		defer close(ch)
+		for _, row := range rows {
+			ch <- row
+		}
+	}()
+	return ch
+}
+
+func TestWriteBatch(t *testing.T) {
+	table := setupWriteBatchTable(t)
+	day1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	rows := []Row{
+		{"id": int64(1), "name": "a", "ts": day1.Add(time.Hour), "date": day1},
+		{"id": 3, "name": nil, "ts": day1.Add(2 * time.Hour), "date": day1},
+		{"id": int64(2), "name": "c", "ts": day1.Add(3*time.Hour + time.Microsecond), "date": day1},
+		{"id": int64(4), "ts": day2.Add(time.Hour), "date": day2},
+	}
+	options := NewWriteOptions()
+	options.MaxRowsPerFile = 2
+	version, err := table.WriteBatch(sendRows(rows), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("want version 1, has %d", version)
+	}
+	if err := table.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []Add
+	for _, add := range table.State.Files {
+		files = append(files, add)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		stats1, _ := files[i].ParseStats()
+		stats2, _ := files[j].ParseStats()
+		return files[i].Path[:15] < files[j].Path[:15] || (files[i].Path[:15] == files[j].Path[:15] && stats1.NumRecords > stats2.NumRecords)
+	})
+	if len(files) != 3 {
+		t.Fatalf("want 3 files, has %d", len(files))
+	}
+	for i, date := range []string{"2023-01-01", "2023-01-01", "2023-01-02"} {
+		if files[i].PartitionValues["date"] != date || files[i].Path[:16] != "date="+date+"/" {
+			t.Errorf("file %d: want partition %s, has %s %v", i, date, files[i].Path, files[i].PartitionValues)
+		}
+	}
+
+	stats, err := files[0].ParseStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NumRecords != 2 || stats.MinValues["id"] != json.Number("1") || stats.MaxValues["id"] != json.Number("3") || stats.MinValues["name"] != "a" || stats.NullCount["name"] != 1 {
+		t.Errorf("unexpected stats %s", files[0].Stats)
+	}
+	if stats.MinValues["ts"] != "2023-01-01T01:00:00.000Z" || stats.MaxValues["ts"] != "2023-01-01T02:00:00.000Z" {
+		t.Errorf("unexpected ts stats %v %v", stats.MinValues["ts"], stats.MaxValues["ts"])
+	}
+	if _, ok := stats.MinValues["date"]; ok {
+		t.Error("partition columns should not have statistics")
+	}
+	stats, _ = files[1].ParseStats()
+	if stats.MaxValues["ts"] != "2023-01-01T03:00:00.001Z" {
+		t.Errorf("the max timestamp should be rounded up, has %v", stats.MaxValues["ts"])
+	}
+
+	// The data files can be read back
+	type dataRow struct {
+		Id   int64     `parquet:"id"`
+		Name *string   `parquet:"name,optional"`
+		Ts   time.Time `parquet:"ts,timestamp(microsecond)"`
+	}
+	data, err := table.Store.Get(storage.NewPath(files[0].Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != int64(files[0].Size) {
+		t.Errorf("want size %d, has %d", len(data), files[0].Size)
+	}
+	written, err := parquet.Read[dataRow](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "a"
+	expected := []dataRow{{Id: 1, Name: &name, Ts: day1.Add(time.Hour)}, {Id: 3, Ts: day1.Add(2 * time.Hour)}}
+	if len(written) != 2 || written[0].Id != 1 || *written[0].Name != "a" || !written[0].Ts.Equal(expected[0].Ts) || written[1].Name != nil || !written[1].Ts.Equal(expected[1].Ts) {
+		t.Errorf("want %v, has %v", expected, written)
+	}
+
+	// No rows, no commit
+	version, err = table.WriteBatch(sendRows(nil), nil)
+	if err != nil || version != 1 {
+		t.Errorf("want version 1 without a commit, has %d %v", version, err)
+	}
+}
+
+func TestWriteBatchInvalidRows(t *testing.T) {
+	for _, row := range []Row{
+		{"id": "1", "ts": time.Now(), "date": time.Now()},
+		{"ts": time.Now(), "date": time.Now()},
+		{"id": int64(1), "ts": time.Now(), "date": time.Now(), "other": 1},
+	} {
+		table := setupWriteBatchTable(t)
+		valid := Row{"id": int64(1), "ts": time.Now(), "date": time.Now()}
+		// The file of the first row is written before the invalid row is found
+		options := NewWriteOptions()
+		options.MaxRowsPerFile = 1
+		_, err := table.WriteBatch(sendRows([]Row{valid, row, valid}), options)
+		if !errors.Is(err, ErrorInvalidRow) {
+			t.Errorf("%v: want ErrorInvalidRow, has %v", row, err)
+		}
+		if len(table.State.Files) != 0 || table.State.Version != 0 {
+			t.Errorf("%v: nothing should be committed", row)
+		}
+		objects, _ := table.Store.List(storage.NewPath(""))
+		for _, object := range objects {
+			if strings.HasSuffix(object.Location.Raw, ".parquet") {
+				t.Errorf("%v: data file %s should not be left", row, object.Location.Raw)
+			}
+		}
+	}
+}
+
+func TestWriteBatchCompression(t *testing.T) {
+	tests := []struct {
+		codec     CompressionCodec
+		expected  format.CompressionCodec
+		extension string
+	}{
+		{"", format.Snappy, "-c000.snappy.parquet"},
+		{CompressionSnappy, format.Snappy, "-c000.snappy.parquet"},
+		{CompressionZstd, format.Zstd, "-c000.zstd.parquet"},
+		{CompressionGzip, format.Gzip, "-c000.gz.parquet"},
+		{CompressionNone, format.Uncompressed, "-c000.parquet"},
+	}
+	day := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		table := setupWriteBatchTable(t)
+		options := NewWriteOptions()
+		options.CompressionCodec = test.codec
+		rows := []Row{{"id": int64(1), "name": "a", "ts": day, "date": day}, {"id": int64(2), "ts": day, "date": day}}
+		if _, err := table.WriteBatch(sendRows(rows), options); err != nil {
+			t.Fatalf("%q: %v", test.codec, err)
+		}
+		if err := table.Load(); err != nil {
+			t.Fatal(err)
+		}
+		for _, add := range table.State.Files {
+			if !strings.HasSuffix(add.Path, test.extension) {
+				t.Errorf("%q: want a file name ending with %s, has %s", test.codec, test.extension, add.Path)
+			}
+			data, err := table.Store.Get(storage.NewPath(add.Path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, column := range file.Metadata().RowGroups[0].Columns {
+				if column.MetaData.Codec != test.expected {
+					t.Errorf("%q: want codec %s, has %s", test.codec, test.expected, column.MetaData.Codec)
+				}
+			}
+			if file.NumRows() != 2 {
+				t.Errorf("%q: want 2 rows, has %d", test.codec, file.NumRows())
+			}
+		}
+	}
+
+	table := setupWriteBatchTable(t)
+	options := NewWriteOptions()
+	options.CompressionCodec = "lzo"
+	_, err := table.WriteBatch(sendRows([]Row{{"id": int64(1), "ts": day, "date": day}}), options)
+	if !errors.Is(err, ErrorInvalidCompression) {
+		t.Errorf("want ErrorInvalidCompression, has %v", err)
+	}
+}